@@ -38,6 +38,9 @@ func unpad(paddedtext []byte) ([]byte, error) {
 // 1. ciphertext is aligned to the standard AES block size
 // 2. ciphertext is padded using PKCS#7
 // 3. IV is prepended to the ciphertext
+//
+// Deprecated: the ciphertext is not authenticated, so it can be tampered
+// with undetected. Use Seal instead.
 func AESEncrypt(plaintext, key []byte) ([]byte, error) {
 	plaintext, err := pad(plaintext, aes.BlockSize)
 	if err != nil {
@@ -66,6 +69,8 @@ func AESEncrypt(plaintext, key []byte) ([]byte, error) {
 // 1. ciphertext is aligned to the standard AES block size
 // 2. ciphertext is padded using PKCS#7
 // 3. the IV is prepended to ciphertext
+//
+// Deprecated: use Open, with ciphertexts produced by Seal.
 func AESDecrypt(ciphertext, key []byte) ([]byte, error) {
 	if len(ciphertext) < aes.BlockSize {
 		return nil, errors.New("ciphertext too short")
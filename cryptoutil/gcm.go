@@ -0,0 +1,69 @@
+package cryptoutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrAuthenticationFailed is returned by Open when a ciphertext or its
+// additional data has been tampered with, or the wrong key was used.
+var ErrAuthenticationFailed = errors.New("message authentication failed")
+
+// checkAESKey returns an error unless key is 16, 24 or 32 bytes long,
+// selecting AES-128, AES-192 or AES-256.
+func checkAESKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("invalid AES key size %d: must be 16, 24 or 32 bytes", len(key))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if err := checkAESKey(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts and authenticates plaintext with AES-GCM, also
+// authenticating additionalData, which may be nil. A random nonce is
+// prepended to the returned ciphertext. With random nonces a single key
+// should not be used for more than about 2^32 messages.
+func Seal(plaintext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts and authenticates a ciphertext produced by Seal with the
+// same key and additional data. It returns ErrAuthenticationFailed if the
+// ciphertext is not authentic.
+func Open(ciphertext, key, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,73 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		key := bytes.Repeat([]byte{7}, size)
+		plaintext := []byte("the quick brown fox")
+		ad := []byte("header")
+
+		ciphertext, err := Seal(plaintext, key, ad)
+		if err != nil {
+			t.Fatalf("%d byte key: unexpected error: %v", size, err)
+		}
+		got, err := Open(ciphertext, key, ad)
+		if err != nil {
+			t.Fatalf("%d byte key: unexpected error: %v", size, err)
+		}
+		if !bytes.Equal(plaintext, got) {
+			t.Errorf("%d byte key: want=%q got=%q", size, plaintext, got)
+		}
+
+		// Nonces are random, so sealing twice differs.
+		again, _ := Seal(plaintext, key, ad)
+		if bytes.Equal(ciphertext, again) {
+			t.Errorf("%d byte key: ciphertexts are identical", size)
+		}
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	ciphertext, err := Seal([]byte("secret"), key, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := append([]byte(nil), ciphertext...)
+	flipped[len(flipped)-1] ^= 1
+	otherKey := bytes.Repeat([]byte{2}, 32)
+
+	tests := []struct {
+		key, ciphertext, ad []byte
+	}{
+		{key, flipped, []byte("ad")},
+		{key, ciphertext, []byte("other")},
+		{key, ciphertext, nil},
+		{otherKey, ciphertext, []byte("ad")},
+	}
+	for i, tt := range tests {
+		if _, err := Open(tt.ciphertext, tt.key, tt.ad); err != ErrAuthenticationFailed {
+			t.Errorf("case %d: want ErrAuthenticationFailed, got %v", i, err)
+		}
+	}
+
+	if _, err := Open(ciphertext[:10], key, []byte("ad")); err == nil {
+		t.Errorf("expected error for short ciphertext")
+	}
+}
+
+func TestSealKeySize(t *testing.T) {
+	for _, size := range []int{0, 8, 15, 33, 64} {
+		if _, err := Seal([]byte("x"), make([]byte, size), nil); err == nil {
+			t.Errorf("expected error for %d byte key", size)
+		}
+		if _, err := Open(make([]byte, 64), make([]byte, size), nil); err == nil {
+			t.Errorf("expected error for %d byte key", size)
+		}
+	}
+}
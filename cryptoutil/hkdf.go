@@ -0,0 +1,39 @@
+package cryptoutil
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveKeys derives n independent keys of size bytes each from master
+// using HKDF-SHA256 (RFC 5869). salt may be nil but should be random when
+// master is not uniformly random, e.g. a password. info binds the keys to
+// a purpose, such as "myservice cookie keys v1"; different info values
+// produce unrelated keys from the same master.
+//
+// The keys are consecutive slices of a single HKDF output, so they must
+// always be derived with the same n and size to be reproducible in order.
+func DeriveKeys(master, salt, info []byte, n, size int) ([][]byte, error) {
+	if len(master) == 0 {
+		return nil, errors.New("empty master key")
+	}
+	if n < 1 || size < 1 {
+		return nil, errors.New("number and size of keys must be positive")
+	}
+	if n*size > 255*sha256.Size {
+		return nil, errors.New("cannot derive more than 8160 bytes of keys")
+	}
+
+	buf := make([]byte, n*size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, salt, info), buf); err != nil {
+		return nil, err
+	}
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = buf[i*size : (i+1)*size : (i+1)*size]
+	}
+	return keys, nil
+}
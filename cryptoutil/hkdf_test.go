@@ -0,0 +1,69 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveKeysRFC5869(t *testing.T) {
+	// RFC 5869 appendix A.1.
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	want := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+
+	keys, err := DeriveKeys(ikm, salt, info, 1, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(keys[0]); got != want {
+		t.Errorf("want=%s got=%s", want, got)
+	}
+
+	// Splitting the same output into several keys.
+	keys, err = DeriveKeys(ikm, salt, info, 2, 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(keys[0]) + hex.EncodeToString(keys[1]); got != want {
+		t.Errorf("want=%s got=%s", want, got)
+	}
+}
+
+func TestDeriveKeys(t *testing.T) {
+	master := []byte("master secret")
+	enc, err := DeriveKeys(master, nil, []byte("encryption"), 2, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign, _ := DeriveKeys(master, nil, []byte("signing"), 2, 32)
+	if bytes.Equal(enc[0], enc[1]) || bytes.Equal(enc[0], sign[0]) {
+		t.Errorf("derived keys are not independent")
+	}
+	again, _ := DeriveKeys(master, nil, []byte("encryption"), 2, 32)
+	if !bytes.Equal(enc[1], again[1]) {
+		t.Errorf("derivation is not deterministic")
+	}
+
+	// Appending to one key must not clobber the next.
+	_ = append(enc[0], 1)
+	if !bytes.Equal(enc[1], again[1]) {
+		t.Errorf("keys share capacity")
+	}
+
+	tests := []struct {
+		master  []byte
+		n, size int
+	}{
+		{nil, 1, 32},
+		{master, 0, 32},
+		{master, 1, 0},
+		{master, 256, 32},
+	}
+	for i, tt := range tests {
+		if _, err := DeriveKeys(tt.master, nil, nil, tt.n, tt.size); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package cryptoutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens which are malformed, use an
+	// unexpected algorithm, or have a bad signature.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for tokens past their exp claim. It wraps
+	// ErrInvalidToken.
+	ErrTokenExpired = fmt.Errorf("%w: token is expired", ErrInvalidToken)
+	// ErrTokenNotYetValid is returned for tokens before their nbf claim. It
+	// wraps ErrInvalidToken.
+	ErrTokenNotYetValid = fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+)
+
+// JWTAlgorithm is a JWS signing algorithm. The "none" algorithm is never
+// supported.
+type JWTAlgorithm string
+
+const (
+	// HS256 is HMAC-SHA256, with a []byte key of at least 32 bytes.
+	HS256 JWTAlgorithm = "HS256"
+	// RS256 is RSASSA-PKCS1-v1_5 with SHA-256, with a key of at least
+	// 2048 bits.
+	RS256 JWTAlgorithm = "RS256"
+	// ES256 is ECDSA on the P-256 curve with SHA-256.
+	ES256 JWTAlgorithm = "ES256"
+)
+
+var jwtEncoding = base64.RawURLEncoding
+
+// Audience is the aud claim, which may be encoded as a single string or an
+// array of strings.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a Audience) contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims are the registered JWT claims. Times are seconds since the Unix
+// epoch. Embed Claims in a struct to add private claims.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+type jwtHeader struct {
+	Algorithm JWTAlgorithm `json:"alg"`
+	Type      string       `json:"typ,omitempty"`
+	Critical  []string     `json:"crit,omitempty"`
+}
+
+// SignJWT returns a compact JWS of claims, which must marshal to a JSON
+// object, signed with key using alg. key is a []byte for HS256, an
+// *rsa.PrivateKey for RS256 and an *ecdsa.PrivateKey for ES256.
+func SignJWT(alg JWTAlgorithm, key interface{}, claims interface{}) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: alg, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) == 0 || payload[0] != '{' {
+		return "", errors.New("JWT claims must be a JSON object")
+	}
+
+	signingInput := jwtEncoding.EncodeToString(header) + "." + jwtEncoding.EncodeToString(payload)
+	sig, err := jwtSign(alg, key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + jwtEncoding.EncodeToString(sig), nil
+}
+
+func jwtSign(alg JWTAlgorithm, key interface{}, input []byte) ([]byte, error) {
+	digest := sha256.Sum256(input)
+	switch alg {
+	case HS256:
+		k, ok := key.([]byte)
+		if !ok || len(k) < 32 {
+			return nil, errors.New("HS256 requires a []byte key of at least 32 bytes")
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RS256:
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok || k.N.BitLen() < 2048 {
+			return nil, errors.New("RS256 requires an *rsa.PrivateKey of at least 2048 bits")
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case ES256:
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 *ecdsa.PrivateKey")
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses fixed size big-endian r||s rather than ASN.1.
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+}
+
+func jwtVerify(alg JWTAlgorithm, key interface{}, input, sig []byte) error {
+	digest := sha256.Sum256(input)
+	switch alg {
+	case HS256:
+		k, ok := key.([]byte)
+		if !ok || len(k) < 32 {
+			return errors.New("HS256 requires a []byte key of at least 32 bytes")
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write(input)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrInvalidToken
+		}
+		return nil
+	case RS256:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok || k.N.BitLen() < 2048 {
+			return errors.New("RS256 requires an *rsa.PublicKey of at least 2048 bits")
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	case ES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return errors.New("ES256 requires a P-256 *ecdsa.PublicKey")
+		}
+		if len(sig) != 64 {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported JWT algorithm %q", alg)
+}
+
+// JWTVerifier validates tokens signed with a single algorithm and key.
+// The algorithm named in a token's header must equal Algorithm, so a
+// token can never choose how it is verified.
+type JWTVerifier struct {
+	Algorithm JWTAlgorithm
+	// Key is a []byte for HS256, an *rsa.PublicKey for RS256 and an
+	// *ecdsa.PublicKey for ES256.
+	Key interface{}
+
+	// Issuer, if set, must equal the iss claim.
+	Issuer string
+	// Audience, if set, must be one of the aud claim's values.
+	Audience string
+	// Leeway is the clock skew tolerated when checking exp and nbf.
+	Leeway time.Duration
+
+	now func() time.Time
+}
+
+// Verify checks the signature and registered claims of token, then
+// unmarshals its payload into claims, which may be nil. The exp claim is
+// required; nbf is checked when present. Claim errors wrap
+// ErrInvalidToken, so compare with errors.Is.
+func (v *JWTVerifier) Verify(token string, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Algorithm != v.Algorithm || len(header.Critical) > 0 {
+		return ErrInvalidToken
+	}
+	if header.Type != "" && !strings.EqualFold(header.Type, "JWT") {
+		return ErrInvalidToken
+	}
+
+	sig, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := jwtVerify(v.Algorithm, v.Key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return err
+	}
+
+	var registered Claims
+	if err := decodeJWTPart(parts[1], &registered); err != nil {
+		return err
+	}
+	if err := v.validate(registered); err != nil {
+		return err
+	}
+	if claims != nil {
+		return decodeJWTPart(parts[1], claims)
+	}
+	return nil
+}
+
+func (v *JWTVerifier) validate(c Claims) error {
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	t := now()
+
+	if c.ExpiresAt == 0 {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if !t.Before(time.Unix(c.ExpiresAt, 0).Add(v.Leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != 0 && t.Add(v.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, c.Issuer)
+	}
+	if v.Audience != "" && !c.Audience.contains(v.Audience) {
+		return fmt.Errorf("%w: audience %q not allowed", ErrInvalidToken, v.Audience)
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := jwtEncoding.DecodeString(part)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
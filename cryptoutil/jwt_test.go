@@ -0,0 +1,147 @@
+package cryptoutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	Claims
+	Role string `json:"role"`
+}
+
+func TestJWTRoundTrip(t *testing.T) {
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg       JWTAlgorithm
+		signKey   interface{}
+		verifyKey interface{}
+	}{
+		{HS256, hmacKey, hmacKey},
+		{RS256, rsaKey, &rsaKey.PublicKey},
+		{ES256, ecKey, &ecKey.PublicKey},
+	}
+	in := testClaims{
+		Claims: Claims{
+			Issuer:    "auth",
+			Subject:   "alice",
+			Audience:  Audience{"api"},
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		Role: "admin",
+	}
+	for i, tt := range tests {
+		token, err := SignJWT(tt.alg, tt.signKey, in)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		v := JWTVerifier{Algorithm: tt.alg, Key: tt.verifyKey, Issuer: "auth", Audience: "api"}
+		var out testClaims
+		if err := v.Verify(token, &out); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if out.Subject != "alice" || out.Role != "admin" {
+			t.Errorf("case %d: unexpected claims %+v", i, out)
+		}
+
+		// Flip a bit of the payload.
+		parts := strings.Split(token, ".")
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		payload[len(payload)-2] ^= 1
+		parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+		if err := v.Verify(strings.Join(parts, "."), nil); err != ErrInvalidToken {
+			t.Errorf("case %d: want ErrInvalidToken for tampered token, got %v", i, err)
+		}
+	}
+}
+
+func TestJWTVerifyRejects(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Unix(1500000000, 0)
+	sign := func(c Claims) string {
+		token, err := SignJWT(HS256, key, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := Claims{Issuer: "auth", Audience: Audience{"a", "api"}, ExpiresAt: now.Unix() + 60}
+	hdr := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	payload := strings.Split(sign(valid), ".")[1]
+
+	tests := []struct {
+		token string
+		want  error
+	}{
+		{sign(valid), nil},
+		{sign(Claims{ExpiresAt: now.Unix()}), ErrTokenExpired},
+		{sign(Claims{ExpiresAt: now.Unix() + 60, NotBefore: now.Unix() + 30}), ErrTokenNotYetValid},
+		{sign(Claims{}), ErrInvalidToken},
+		{sign(Claims{Issuer: "evil", ExpiresAt: now.Unix() + 60}), ErrInvalidToken},
+		{sign(Claims{Issuer: "auth", Audience: Audience{"other"}, ExpiresAt: now.Unix() + 60}), ErrInvalidToken},
+		{hdr(`{"alg":"none"}`) + "." + payload + ".", ErrInvalidToken},
+		{hdr(`{"alg":"RS256"}`) + "." + payload + ".", ErrInvalidToken},
+		{hdr(`{"alg":"HS256","crit":["exp"]}`) + "." + payload + ".", ErrInvalidToken},
+		{"not.a.jwt", ErrInvalidToken},
+		{"a.b", ErrInvalidToken},
+	}
+	for i, tt := range tests {
+		v := JWTVerifier{Algorithm: HS256, Key: key, Issuer: "auth", Audience: "api", now: func() time.Time { return now }}
+		if err := v.Verify(tt.token, nil); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("case %d: want %v, got %v", i, tt.want, err)
+		}
+		if err := v.Verify(tt.token, nil); tt.want != nil && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("case %d: %v does not wrap ErrInvalidToken", i, err)
+		}
+	}
+
+	// Leeway tolerates clock skew.
+	v := JWTVerifier{Algorithm: HS256, Key: key, Leeway: time.Minute, now: func() time.Time { return now }}
+	if err := v.Verify(sign(Claims{ExpiresAt: now.Unix() - 30}), nil); err != nil {
+		t.Errorf("unexpected error within leeway: %v", err)
+	}
+}
+
+func TestJWTKeyChecks(t *testing.T) {
+	if _, err := SignJWT(HS256, []byte("short"), Claims{}); err == nil {
+		t.Errorf("expected error for short HMAC key")
+	}
+	if _, err := SignJWT("none", nil, Claims{}); err == nil {
+		t.Errorf("expected error for alg none")
+	}
+	if _, err := SignJWT(HS256, make([]byte, 32), []string{"x"}); err == nil {
+		t.Errorf("expected error for non-object claims")
+	}
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := SignJWT(ES256, ecKey, Claims{}); err == nil {
+		t.Errorf("expected error for P-384 key")
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	var c Claims
+	for _, in := range []string{`{"aud":"api"}`, `{"aud":["api"]}`} {
+		if err := decodeJWTPart(base64.RawURLEncoding.EncodeToString([]byte(in)), &c); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.Audience) != 1 || c.Audience[0] != "api" {
+			t.Errorf("%s: unexpected audience %v", in, c.Audience)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package cryptoutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownKey is returned when a ciphertext names a key which is
+	// not in the keyring.
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrNoPrimaryKey is returned by Encrypt on an empty keyring.
+	ErrNoPrimaryKey = errors.New("keyring has no primary key")
+)
+
+// keyringVersion is the first byte of ciphertexts produced by a Keyring.
+const keyringVersion = 1
+
+// KeyMetadata describes a key in a Keyring, without its material.
+type KeyMetadata struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Primary bool      `json:"primary,omitempty"`
+}
+
+type keyringKey struct {
+	KeyMetadata
+	key []byte
+}
+
+// Keyring holds named AES-256 data encryption keys, one of which is the
+// primary. Data is always encrypted with the primary key, and the key's ID
+// is recorded in the ciphertext so it can be decrypted with any key still
+// in the keyring. Rotate adds a new primary key; old keys remain for
+// decryption until removed, once data encrypted with them has been
+// re-encrypted.
+//
+// The keyring itself is persisted with Marshal, which encrypts the keys
+// under a key encryption key, typically held in a KMS or HSM.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string]*keyringKey
+	primary string
+
+	now func() time.Time
+}
+
+// NewKeyring returns an empty keyring. Call Rotate to create its first
+// key.
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]*keyringKey), now: time.Now}
+}
+
+// Rotate generates a new key, makes it the primary and returns its ID.
+func (k *Keyring) Rotate() (string, error) {
+	key, err := RandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	id, err := NewULID()
+	if err != nil {
+		return "", err
+	}
+	if err := k.Add(id, key); err != nil {
+		return "", err
+	}
+	return id, k.SetPrimary(id)
+}
+
+// Add imports an existing key under id. The keyring takes ownership of
+// key, which must be 16, 24 or 32 bytes long.
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("invalid key ID %q", id)
+	}
+	if err := checkAESKey(key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key %q already exists", id)
+	}
+	k.keys[id] = &keyringKey{
+		KeyMetadata: KeyMetadata{ID: id, Created: k.now().UTC()},
+		key:         key,
+	}
+	return nil
+}
+
+// SetPrimary makes the key id the one new data is encrypted with.
+func (k *Keyring) SetPrimary(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return ErrUnknownKey
+	}
+	k.primary = id
+	return nil
+}
+
+// Remove deletes the key id, and zeroes its material. The primary key
+// cannot be removed.
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return ErrUnknownKey
+	}
+	if id == k.primary {
+		return errors.New("cannot remove the primary key")
+	}
+	Zero(key.key)
+	delete(k.keys, id)
+	return nil
+}
+
+// Keys returns the metadata of every key, oldest first.
+func (k *Keyring) Keys() []KeyMetadata {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.metadata()
+}
+
+// metadata returns the metadata of every key, oldest first. k.mu must be
+// held.
+func (k *Keyring) metadata() []KeyMetadata {
+	keys := make([]KeyMetadata, 0, len(k.keys))
+	for _, key := range k.keys {
+		m := key.KeyMetadata
+		m.Primary = key.ID == k.primary
+		keys = append(keys, m)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].Created.Equal(keys[j].Created) {
+			return keys[i].Created.Before(keys[j].Created)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// keyringHeader returns the ciphertext prefix naming key id, which is also
+// authenticated as additional data so it cannot be swapped.
+func keyringHeader(id string) []byte {
+	return append([]byte{keyringVersion, byte(len(id))}, id...)
+}
+
+// Encrypt encrypts plaintext with the primary key using AES-GCM,
+// authenticating additionalData, which may be nil.
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	// The lock is held while the key is used, as Remove zeroes it.
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[k.primary]
+	if !ok {
+		return nil, ErrNoPrimaryKey
+	}
+	header := keyringHeader(key.ID)
+	sealed, err := Seal(plaintext, key.key, append(header[:len(header):len(header)], additionalData...))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt with any key in the
+// keyring.
+func (k *Keyring) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	id, err := KeyID(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	n := 2 + len(id)
+	return Open(ciphertext[n:], key.key, append(ciphertext[:n:n], additionalData...))
+}
+
+// KeyID returns the ID of the key a Keyring ciphertext was encrypted with,
+// e.g. to find data which must be re-encrypted before a key is removed.
+func KeyID(ciphertext []byte) (string, error) {
+	if len(ciphertext) < 2 || ciphertext[0] != keyringVersion {
+		return "", errors.New("not a keyring ciphertext")
+	}
+	n := int(ciphertext[1])
+	if n == 0 || len(ciphertext) < 2+n {
+		return "", errors.New("ciphertext too short")
+	}
+	return string(ciphertext[2 : 2+n]), nil
+}
+
+type marshaledKeyring struct {
+	Keys []marshaledKey `json:"keys"`
+}
+
+type marshaledKey struct {
+	KeyMetadata
+	// Key is the key material sealed under the key encryption key.
+	Key []byte `json:"key"`
+}
+
+// Marshal serializes the keyring as JSON. Key metadata is stored in the
+// clear; each key is sealed with Seal under kek.
+func (k *Keyring) Marshal(kek []byte) ([]byte, error) {
+	// The lock is held while the keys are used, as Remove zeroes them.
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var out marshaledKeyring
+	for _, m := range k.metadata() {
+		sealed, err := Seal(k.keys[m.ID].key, kek, []byte("keyring key "+m.ID))
+		if err != nil {
+			return nil, err
+		}
+		out.Keys = append(out.Keys, marshaledKey{KeyMetadata: m, Key: sealed})
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalKeyring restores a keyring serialized by Marshal with the same
+// key encryption key.
+func UnmarshalKeyring(data, kek []byte) (*Keyring, error) {
+	var in marshaledKeyring
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	k := NewKeyring()
+	for _, mk := range in.Keys {
+		key, err := Open(mk.Key, kek, []byte("keyring key "+mk.ID))
+		if err != nil {
+			return nil, fmt.Errorf("unsealing key %q: %v", mk.ID, err)
+		}
+		if err := k.Add(mk.ID, key); err != nil {
+			return nil, err
+		}
+		k.keys[mk.ID].Created = mk.Created
+		if mk.Primary {
+			k.primary = mk.ID
+		}
+	}
+	if len(k.keys) > 0 && k.primary == "" {
+		return nil, ErrNoPrimaryKey
+	}
+	return k, nil
+}
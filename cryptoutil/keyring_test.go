@@ -0,0 +1,208 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	k := NewKeyring()
+	if _, err := k.Encrypt([]byte("x"), nil); err != ErrNoPrimaryKey {
+		t.Errorf("want ErrNoPrimaryKey, got %v", err)
+	}
+
+	first, err := k.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := k.Encrypt([]byte("old data"), []byte("row 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := k.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := k.Encrypt([]byte("new data"), nil)
+
+	if id, _ := KeyID(old); id != first {
+		t.Errorf("want old data under %s, got %s", first, id)
+	}
+	if id, _ := KeyID(current); id != second {
+		t.Errorf("want new data under %s, got %s", second, id)
+	}
+
+	// Data under either key decrypts.
+	if got, err := k.Decrypt(old, []byte("row 1")); err != nil || string(got) != "old data" {
+		t.Errorf("decrypt old: %q %v", got, err)
+	}
+	if got, err := k.Decrypt(current, nil); err != nil || string(got) != "new data" {
+		t.Errorf("decrypt current: %q %v", got, err)
+	}
+	if _, err := k.Decrypt(old, []byte("row 2")); err != ErrAuthenticationFailed {
+		t.Errorf("want ErrAuthenticationFailed for wrong additional data, got %v", err)
+	}
+
+	keys := k.Keys()
+	if len(keys) != 2 || keys[0].ID != first || keys[0].Primary || !keys[1].Primary {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	if err := k.Remove(second); err == nil {
+		t.Errorf("removed the primary key")
+	}
+	if err := k.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Decrypt(old, []byte("row 1")); err != ErrUnknownKey {
+		t.Errorf("want ErrUnknownKey after removal, got %v", err)
+	}
+}
+
+func TestKeyringRemoveConcurrent(t *testing.T) {
+	// A key removed while in use by Decrypt is zeroed only once Decrypt is
+	// done with it.
+	k := NewKeyring()
+	first, _ := k.Rotate()
+	old, _ := k.Encrypt([]byte("old data"), nil)
+	k.Rotate()
+
+	started, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			if i == 1 {
+				close(started)
+			}
+			got, err := k.Decrypt(old, nil)
+			if err == ErrUnknownKey {
+				return
+			}
+			if err != nil || string(got) != "old data" {
+				t.Errorf("decrypt: %q %v", got, err)
+				return
+			}
+		}
+	}()
+	<-started
+	if err := k.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestKeyringMarshalRemoveConcurrent(t *testing.T) {
+	// Keys removed while Marshal runs are either sealed intact or left out.
+	k := NewKeyring()
+	k.Rotate()
+	kek := make([]byte, 32)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			data, err := k.Marshal(kek)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			restored, err := UnmarshalKeyring(data, kek)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for _, m := range restored.Keys() {
+				if key := restored.keys[m.ID].key; m.ID == "old" && key[0] != 1 {
+					t.Errorf("sealed a zeroed key")
+					return
+				}
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if err := k.Add("old", bytes.Repeat([]byte{1}, 32)); err != nil {
+			t.Fatal(err)
+		}
+		if err := k.Remove("old"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	<-done
+}
+
+func TestKeyringHeaderAuthenticated(t *testing.T) {
+	k := NewKeyring()
+	key := bytes.Repeat([]byte{1}, 32)
+	k.Add("a", key)
+	k.Add("b", key)
+	k.SetPrimary("a")
+
+	ct, _ := k.Encrypt([]byte("secret"), nil)
+	// Relabelling the ciphertext with another key ID, even one with the
+	// same material, fails authentication.
+	ct[2] = 'b'
+	if _, err := k.Decrypt(ct, nil); err != ErrAuthenticationFailed {
+		t.Errorf("want ErrAuthenticationFailed, got %v", err)
+	}
+
+	for i, bad := range [][]byte{nil, {9, 1, 'a'}, {keyringVersion, 5, 'a'}} {
+		if _, err := k.Decrypt(bad, nil); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestKeyringMarshal(t *testing.T) {
+	k := NewKeyring()
+	first, _ := k.Rotate()
+	old, _ := k.Encrypt([]byte("old"), nil)
+	second, _ := k.Rotate()
+
+	kek := bytes.Repeat([]byte{7}, 32)
+	data, err := k.Marshal(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, k.keys[first].key) {
+		t.Errorf("key material stored in the clear")
+	}
+
+	restored, err := UnmarshalKeyring(data, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := restored.Decrypt(old, nil); err != nil || string(got) != "old" {
+		t.Errorf("decrypt after restore: %q %v", got, err)
+	}
+	keys := restored.Keys()
+	if len(keys) != 2 || !keys[1].Primary || keys[1].ID != second || !keys[0].Created.Equal(k.Keys()[0].Created) {
+		t.Errorf("metadata not restored: %+v", keys)
+	}
+
+	if _, err := UnmarshalKeyring(data, bytes.Repeat([]byte{8}, 32)); err == nil {
+		t.Errorf("expected error for wrong key encryption key")
+	}
+}
+
+func TestKeyringAdd(t *testing.T) {
+	k := NewKeyring()
+	if err := k.Add("a", make([]byte, 10)); err == nil {
+		t.Errorf("expected error for short key")
+	}
+	if err := k.Add("", make([]byte, 32)); err == nil {
+		t.Errorf("expected error for empty ID")
+	}
+	k.Add("a", make([]byte, 32))
+	if err := k.Add("a", make([]byte, 32)); err == nil {
+		t.Errorf("expected error for duplicate ID")
+	}
+	if err := k.SetPrimary("missing"); err != ErrUnknownKey {
+		t.Errorf("want ErrUnknownKey, got %v", err)
+	}
+}
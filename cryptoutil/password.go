@@ -0,0 +1,226 @@
+package cryptoutil
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	// ErrPasswordMismatch is returned by VerifyPassword when the password
+	// does not match the hash.
+	ErrPasswordMismatch = errors.New("password does not match hash")
+
+	// ErrInvalidPasswordHash is returned when an encoded hash cannot be
+	// parsed.
+	ErrInvalidPasswordHash = errors.New("invalid password hash")
+)
+
+var b64 = base64.RawStdEncoding
+
+// These bound the work a crafted or corrupted hash can demand of
+// VerifyPassword.
+const (
+	maxPasswordHashMemory = 1 << 30 // bytes
+	maxArgon2Time         = 32
+	maxArgon2Threads      = 64
+	maxScryptLogN         = 24
+	maxScryptR            = 32
+	maxScryptP            = 16
+)
+
+// PasswordParams selects a password hashing algorithm and its cost
+// parameters. It is implemented by Argon2Params and ScryptParams.
+type PasswordParams interface {
+	derive(password, salt []byte, keyLen uint32) ([]byte, error)
+	encode(salt, key []byte) string
+	saltLen() uint32
+	keyLen() uint32
+	// weaker reports whether p uses a different algorithm or cheaper
+	// parameters than other.
+	weaker(other PasswordParams) bool
+}
+
+// Argon2Params are the parameters of an argon2id hash.
+type Argon2Params struct {
+	// Time is the number of passes over memory.
+	Time uint32
+	// Memory is the amount of memory used, in KiB.
+	Memory uint32
+	// Threads is the degree of parallelism.
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params follows the RFC 9106 second recommended option.
+var DefaultArgon2Params = Argon2Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+func (p Argon2Params) derive(password, salt []byte, keyLen uint32) ([]byte, error) {
+	if p.Time < 1 || p.Memory < 8*uint32(p.Threads) || p.Threads < 1 {
+		return nil, fmt.Errorf("invalid argon2id parameters t=%d m=%d p=%d", p.Time, p.Memory, p.Threads)
+	}
+	return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, keyLen), nil
+}
+
+func (p Argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+func (p Argon2Params) saltLen() uint32 { return p.SaltLen }
+func (p Argon2Params) keyLen() uint32  { return p.KeyLen }
+
+func (p Argon2Params) weaker(other PasswordParams) bool {
+	o, ok := other.(Argon2Params)
+	return !ok || p.Time < o.Time || p.Memory < o.Memory || p.Threads != o.Threads
+}
+
+// ScryptParams are the parameters of an scrypt hash.
+type ScryptParams struct {
+	// N is the CPU/memory cost and must be a power of two greater than 1.
+	N       int
+	R       int
+	P       int
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultScryptParams are the parameters recommended for interactive
+// logins in the scrypt paper.
+var DefaultScryptParams = ScryptParams{
+	N:       1 << 15,
+	R:       8,
+	P:       1,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+func (p ScryptParams) derive(password, salt []byte, keyLen uint32) ([]byte, error) {
+	return scrypt.Key(password, salt, p.N, p.R, p.P, int(keyLen))
+}
+
+func (p ScryptParams) encode(salt, key []byte) string {
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s",
+		bits.Len(uint(p.N))-1, p.R, p.P, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+func (p ScryptParams) saltLen() uint32 { return p.SaltLen }
+func (p ScryptParams) keyLen() uint32  { return p.KeyLen }
+
+func (p ScryptParams) weaker(other PasswordParams) bool {
+	o, ok := other.(ScryptParams)
+	return !ok || p.N < o.N || p.R < o.R || p.P < o.P
+}
+
+// HashPassword hashes password with a random salt and returns a
+// self-describing string in PHC format which embeds the algorithm and
+// parameters, e.g. "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>".
+func HashPassword(password []byte, params PasswordParams) (string, error) {
+	if params.saltLen() < 8 || params.keyLen() < 16 {
+		return "", errors.New("password hash salt must be at least 8 bytes and key at least 16 bytes")
+	}
+	salt := make([]byte, params.saltLen())
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := params.derive(password, salt, params.keyLen())
+	if err != nil {
+		return "", err
+	}
+	return params.encode(salt, key), nil
+}
+
+// VerifyPassword checks password against a hash produced by HashPassword
+// in constant time. It returns ErrPasswordMismatch if the password is
+// wrong.
+func VerifyPassword(password []byte, encoded string) error {
+	params, salt, key, err := parsePasswordHash(encoded)
+	if err != nil {
+		return err
+	}
+	got, err := params.derive(password, salt, uint32(len(key)))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports whether encoded was hashed with a different
+// algorithm or weaker parameters than params, or is unparseable. Callers
+// should rehash the password after a successful VerifyPassword when it
+// returns true.
+func NeedsRehash(encoded string, params PasswordParams) bool {
+	p, salt, key, err := parsePasswordHash(encoded)
+	if err != nil {
+		return true
+	}
+	return p.weaker(params) ||
+		uint32(len(salt)) < params.saltLen() || uint32(len(key)) < params.keyLen()
+}
+
+func parsePasswordHash(encoded string) (PasswordParams, []byte, []byte, error) {
+	fields := strings.Split(encoded, "$")
+	if len(fields) < 5 || fields[0] != "" {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var params PasswordParams
+	switch fields[1] {
+	case "argon2id":
+		if len(fields) != 6 {
+			return nil, nil, nil, ErrInvalidPasswordHash
+		}
+		var version int
+		if _, err := fmt.Sscanf(fields[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return nil, nil, nil, ErrInvalidPasswordHash
+		}
+		var p Argon2Params
+		if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil ||
+			uint64(p.Memory)*1024 > maxPasswordHashMemory || p.Time > maxArgon2Time || p.Threads > maxArgon2Threads {
+			return nil, nil, nil, ErrInvalidPasswordHash
+		}
+		params, fields = p, fields[4:]
+	case "scrypt":
+		if len(fields) != 5 {
+			return nil, nil, nil, ErrInvalidPasswordHash
+		}
+		var ln uint
+		var p ScryptParams
+		if _, err := fmt.Sscanf(fields[2], "ln=%d,r=%d,p=%d", &ln, &p.R, &p.P); err != nil ||
+			ln < 1 || ln > maxScryptLogN || p.R < 1 || p.R > maxScryptR || p.P < 1 || p.P > maxScryptP ||
+			128*uint64(p.R)<<ln > maxPasswordHashMemory {
+			return nil, nil, nil, ErrInvalidPasswordHash
+		}
+		p.N = 1 << ln
+		params, fields = p, fields[3:]
+	default:
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := b64.DecodeString(fields[0])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := b64.DecodeString(fields[1])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	return params, salt, key, nil
+}
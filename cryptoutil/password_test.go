@@ -0,0 +1,95 @@
+package cryptoutil
+
+import (
+	"strings"
+	"testing"
+)
+
+// Cheap parameters keep the tests fast.
+var (
+	testArgon2Params = Argon2Params{Time: 1, Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32}
+	testScryptParams = ScryptParams{N: 16, R: 8, P: 1, SaltLen: 16, KeyLen: 32}
+)
+
+func TestHashVerifyPassword(t *testing.T) {
+	tests := []struct {
+		params PasswordParams
+		prefix string
+	}{
+		{testArgon2Params, "$argon2id$v=19$m=64,t=1,p=1$"},
+		{testScryptParams, "$scrypt$ln=4,r=8,p=1$"},
+	}
+	for i, tt := range tests {
+		encoded, err := HashPassword([]byte("hunter2"), tt.params)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if !strings.HasPrefix(encoded, tt.prefix) {
+			t.Errorf("case %d: want prefix %q, got %q", i, tt.prefix, encoded)
+		}
+		if err := VerifyPassword([]byte("hunter2"), encoded); err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if err := VerifyPassword([]byte("hunter3"), encoded); err != ErrPasswordMismatch {
+			t.Errorf("case %d: want ErrPasswordMismatch, got %v", i, err)
+		}
+
+		again, _ := HashPassword([]byte("hunter2"), tt.params)
+		if again == encoded {
+			t.Errorf("case %d: hashes are identical, salt is not random", i)
+		}
+	}
+}
+
+func TestVerifyPasswordInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"plaintext",
+		"$pbkdf2$i=1000$c2FsdA$a2V5",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!$a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+		"$scrypt$ln=99,r=8,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		// Costs beyond the limits.
+		"$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=4294967295,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=255$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$scrypt$ln=40,r=8,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$scrypt$ln=20,r=32,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$scrypt$ln=10,r=8,p=1000$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$scrypt$ln=10,r=0,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+	}
+	for i, tt := range tests {
+		if err := VerifyPassword([]byte("x"), tt); err != ErrInvalidPasswordHash {
+			t.Errorf("case %d: want ErrInvalidPasswordHash, got %v", i, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon, _ := HashPassword([]byte("pw"), testArgon2Params)
+	scrypted, _ := HashPassword([]byte("pw"), testScryptParams)
+
+	stronger := testArgon2Params
+	stronger.Time = 2
+
+	tests := []struct {
+		encoded string
+		params  PasswordParams
+		want    bool
+	}{
+		{argon, testArgon2Params, false},
+		{argon, stronger, true},
+		{stronger.encode(make([]byte, 16), make([]byte, 32)), testArgon2Params, false},
+		{scrypted, testScryptParams, false},
+		{scrypted, testArgon2Params, true},
+		{argon, testScryptParams, true},
+		{"garbage", testArgon2Params, true},
+	}
+	for i, tt := range tests {
+		if got := NeedsRehash(tt.encoded, tt.params); got != tt.want {
+			t.Errorf("case %d: want=%t got=%t", i, tt.want, got)
+		}
+	}
+}
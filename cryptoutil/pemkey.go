@@ -0,0 +1,266 @@
+package cryptoutil
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	// ErrIncorrectPassphrase is returned when an encrypted key cannot be
+	// decrypted with the given passphrase.
+	ErrIncorrectPassphrase = errors.New("incorrect passphrase or corrupt key")
+
+	// ErrKeyPermissions is returned by LoadPrivateKey for key files which
+	// are accessible by users other than their owner.
+	ErrKeyPermissions = errors.New("private key file permissions are too open")
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+var pbkdf2Iterations = 600000
+
+// maxPBKDF2Iterations bounds the work a crafted key file can demand.
+const maxPBKDF2Iterations = 10000000
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// EncryptPrivateKeyPEM encodes key as an "ENCRYPTED PRIVATE KEY" PEM block:
+// PKCS#8 encrypted with PBES2, using PBKDF2-HMAC-SHA256 to derive an
+// AES-256-CBC key from passphrase. The result can also be read by
+// OpenSSL.
+func EncryptPrivateKeyPEM(key crypto.PrivateKey, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(der)
+
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbkdf2Iterations,
+		KeyLength:      32,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dk := pbkdf2.Key(passphrase, salt, pbkdf2Iterations, 32, sha256.New)
+	defer Zero(dk)
+	block, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+	padded, err := pad(append([]byte(nil), der...), aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(padded)
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	info, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: info}), nil
+}
+
+// DecryptPrivateKeyPEM decrypts a key encoded by EncryptPrivateKeyPEM, or
+// by OpenSSL using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func DecryptPrivateKeyPEM(pemdata, passphrase []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(pemdata)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if block.Type != "ENCRYPTED PRIVATE KEY" {
+		return nil, fmt.Errorf("PEM block is %q, not an encrypted private key", block.Type)
+	}
+
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+		return nil, fmt.Errorf("malformed encrypted private key: %v", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption algorithm %v", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("malformed PBES2 parameters: %v", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %v", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("malformed PBKDF2 parameters: %v", err)
+	}
+	if !kdf.PRF.Algorithm.Equal(oidHMACWithSHA256) {
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %v", kdf.PRF.Algorithm)
+	}
+	if kdf.IterationCount < 1 || kdf.IterationCount > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("unsupported PBKDF2 iteration count %d", kdf.IterationCount)
+	}
+	if !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		return nil, fmt.Errorf("unsupported key encryption scheme %v", params.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("malformed AES-CBC IV")
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed encrypted private key data")
+	}
+
+	dk := pbkdf2.Key(passphrase, kdf.Salt, kdf.IterationCount, 32, sha256.New)
+	defer Zero(dk)
+	c, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+	der := make([]byte, len(info.EncryptedData))
+	defer Zero(der)
+	cipher.NewCBCDecrypter(c, iv).CryptBlocks(der, info.EncryptedData)
+	unpadded, err := unpad(der)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	key, err := x509.ParsePKCS8PrivateKey(unpadded)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return key, nil
+}
+
+// SavePrivateKey encrypts key with passphrase and atomically writes it to
+// path, readable only by its owner.
+func SavePrivateKey(path string, key crypto.PrivateKey, passphrase []byte) error {
+	data, err := EncryptPrivateKeyPEM(key, passphrase)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	// TempFile creates files with mode 0600.
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes the renaming of a file within dir durable. Directories
+// cannot be synced on Windows, where NTFS journals metadata changes itself.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LoadPrivateKey reads and decrypts a key written by SavePrivateKey. It
+// refuses to load keys which are accessible by group or others, returning
+// an error wrapping ErrKeyPermissions which explains how to fix them.
+func LoadPrivateKey(path string, passphrase []byte) (crypto.PrivateKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// Permission bits do not describe access on Windows.
+	if perm := fi.Mode().Perm(); perm&0077 != 0 && runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("%w: %s has mode %04o, but must not be accessible by group or others; run `chmod 600 %s`",
+			ErrKeyPermissions, path, perm, path)
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptPrivateKeyPEM(data, passphrase)
+}
@@ -0,0 +1,164 @@
+package cryptoutil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// randReader is the source of all random bytes in this file; tests replace
+// it to exercise failures.
+var randReader io.Reader = rand.Reader
+
+// RandomBytes returns n bytes from crypto/rand. Unlike ignoring the error
+// of rand.Read, it never returns fewer or predictable bytes: a failure to
+// read is returned as an error.
+func RandomBytes(n int) ([]byte, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid random byte count %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		return nil, fmt.Errorf("reading random bytes: %v", err)
+	}
+	return b, nil
+}
+
+// RandomToken returns nBytes random bytes encoded as unpadded base64url,
+// suitable for URLs, cookies and headers. Use at least 16 bytes for
+// tokens that must not be guessable, and 32 for long lived secrets.
+func RandomToken(nBytes int) (string, error) {
+	b, err := RandomBytes(nBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// RandomHex returns nBytes random bytes hex encoded.
+func RandomHex(nBytes int) (string, error) {
+	b, err := RandomBytes(nBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// maxULIDTime is the largest timestamp a ULID can hold, in milliseconds.
+const maxULIDTime = 1<<48 - 1
+
+var defaultULID = ulidGenerator{now: time.Now}
+
+// ulidGenerator produces monotonic ULIDs: IDs generated within the same
+// millisecond, or after the clock steps back, increment the previous
+// random component instead of drawing a new one, so they still sort in
+// generation order.
+type ulidGenerator struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	lastMS  uint64
+	lastRnd [10]byte
+}
+
+// NewULID returns a new ULID: a 26 character, lexicographically sortable
+// ID made of a 48 bit millisecond timestamp and 80 random bits. IDs from
+// one process sort in the order they were generated.
+func NewULID() (string, error) {
+	return defaultULID.next()
+}
+
+func (g *ulidGenerator) next() (string, error) {
+	ms := uint64(g.now().UnixNano() / int64(time.Millisecond))
+	if ms > maxULIDTime {
+		return "", errors.New("time is too late for a ULID")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ms <= g.lastMS && g.lastMS != 0 {
+		ms = g.lastMS
+		if !increment(g.lastRnd[:]) {
+			return "", errors.New("too many ULIDs generated in one millisecond")
+		}
+	} else {
+		if _, err := io.ReadFull(randReader, g.lastRnd[:]); err != nil {
+			return "", fmt.Errorf("reading random bytes: %v", err)
+		}
+		g.lastMS = ms
+	}
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> uint(40-8*i))
+	}
+	copy(id[6:], g.lastRnd[:])
+	return encodeULID(id), nil
+}
+
+// increment adds one to the big-endian number b, reporting false on
+// overflow.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(id [16]byte) string {
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(id[i])
+		lo = lo<<8 | uint64(id[i+8])
+	}
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// ULIDTime returns the time encoded in a ULID, to millisecond precision.
+func ULIDTime(id string) (time.Time, error) {
+	if len(id) != 26 {
+		return time.Time{}, fmt.Errorf("invalid ULID %q: must be 26 characters", id)
+	}
+	// The first character only holds 3 bits.
+	if id[0] > '7' {
+		return time.Time{}, fmt.Errorf("invalid ULID %q: overflows 128 bits", id)
+	}
+	var ms uint64
+	for i := 0; i < 26; i++ {
+		v := strings.IndexByte(crockford, upper(id[i]))
+		if v < 0 {
+			return time.Time{}, fmt.Errorf("invalid ULID %q: bad character %q", id, id[i])
+		}
+		// The timestamp is the first 10 characters, 50 bits of which
+		// the top 2 are always zero.
+		if i < 10 {
+			ms = ms<<5 | uint64(v)
+		}
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)), nil
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
@@ -0,0 +1,126 @@
+package cryptoutil
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestRandomToken(t *testing.T) {
+	tok, err := RandomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := base64.RawURLEncoding.DecodeString(tok); err != nil || len(b) != 32 {
+		t.Errorf("invalid token %q: %v", tok, err)
+	}
+	other, _ := RandomToken(32)
+	if tok == other {
+		t.Errorf("tokens are identical")
+	}
+
+	h, err := RandomHex(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := hex.DecodeString(h); err != nil || len(b) != 16 {
+		t.Errorf("invalid hex %q: %v", h, err)
+	}
+
+	if _, err := RandomToken(0); err == nil {
+		t.Errorf("expected error for zero length")
+	}
+}
+
+func TestRandomFailure(t *testing.T) {
+	defer func(r io.Reader) { randReader = r }(randReader)
+	randReader = iotest.ErrReader(errors.New("entropy exhausted"))
+
+	if _, err := RandomBytes(16); err == nil {
+		t.Errorf("RandomBytes: expected error")
+	}
+	if _, err := RandomToken(16); err == nil {
+		t.Errorf("RandomToken: expected error")
+	}
+	g := ulidGenerator{now: time.Now}
+	if _, err := g.next(); err == nil {
+		t.Errorf("ULID: expected error")
+	}
+}
+
+func TestULID(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	g := ulidGenerator{now: func() time.Time { return now }}
+
+	var ids []string
+	for i := 0; i < 100; i++ {
+		id, err := g.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 26 {
+			t.Fatalf("invalid ULID %q", id)
+		}
+		ids = append(ids, id)
+		if i == 50 {
+			// The clock stepping back keeps IDs monotonic.
+			now = now.Add(-time.Second)
+		}
+		if i == 80 {
+			now = now.Add(time.Hour)
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ULIDs are not monotonic: %v", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			t.Errorf("duplicate ULID %s", ids[i])
+		}
+	}
+
+	got, err := ULIDTime(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC); !got.Equal(want) {
+		t.Errorf("want time %v, got %v", want, got)
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	tests := []struct {
+		id   [16]byte
+		want string
+	}{
+		{[16]byte{}, "00000000000000000000000000"},
+		{max, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+		{[16]byte{15: 1}, "00000000000000000000000001"},
+		{[16]byte{5: 1}, "0000000001" + "0000000000000000"},
+	}
+	for i, tt := range tests {
+		if got := encodeULID(tt.id); got != tt.want {
+			t.Errorf("case %d: want=%s got=%s", i, tt.want, got)
+		}
+	}
+}
+
+func TestULIDTimeInvalid(t *testing.T) {
+	for i, id := range []string{"", "0000000000", "80000000000000000000000000", "0000000000000000000000000U"} {
+		if _, err := ULIDTime(id); err == nil {
+			t.Errorf("case %d: expected error for %q", i, id)
+		}
+	}
+	if _, err := ULIDTime("01arz3ndektsv4rrffq69g5fav"); err != nil {
+		t.Errorf("lowercase ULID rejected: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package cryptoutil
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"runtime"
+)
+
+// Redacted is what a Secret prints as.
+const Redacted = "[REDACTED]"
+
+// EqualConstantTime reports whether a and b are equal, taking time
+// independent of their contents so that tokens and MACs can be compared
+// without leaking how many leading bytes match. Only the lengths may
+// leak.
+func EqualConstantTime(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// Zero overwrites b with zeros, to limit how long key material lingers in
+// memory. The garbage collector may already have copied b elsewhere, so
+// this is a mitigation, not a guarantee.
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+// Secret holds sensitive bytes, such as a password or API token, and
+// redacts itself whenever it is printed with any fmt verb, marshaled to
+// JSON or text, or logged through capnslog, which formats with fmt. The
+// value is only available through Bytes.
+//
+// A Secret can be unmarshaled from a JSON string or text, so it can be
+// used directly in configuration structs.
+type Secret struct {
+	b []byte
+}
+
+// NewSecret returns a Secret holding b. The Secret takes ownership of b.
+func NewSecret(b []byte) Secret {
+	return Secret{b: b}
+}
+
+// Bytes returns the secret value. Callers must not retain it beyond the
+// life of the Secret.
+func (s Secret) Bytes() []byte {
+	return s.b
+}
+
+// Empty reports whether the secret has no value.
+func (s Secret) Empty() bool {
+	return len(s.b) == 0
+}
+
+// Equal compares two secrets in constant time.
+func (s Secret) Equal(o Secret) bool {
+	return EqualConstantTime(s.b, o.b)
+}
+
+// Zero overwrites the secret value with zeros.
+func (s Secret) Zero() {
+	Zero(s.b)
+}
+
+func (s Secret) String() string   { return Redacted }
+func (s Secret) GoString() string { return "cryptoutil.Secret(" + Redacted + ")" }
+
+// Format implements fmt.Formatter so that no verb, including %x and %#v,
+// prints the value.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		fmt.Fprint(f, s.GoString())
+		return
+	}
+	fmt.Fprint(f, Redacted)
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Redacted)
+}
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	s.b = append([]byte(nil), text...)
+	return nil
+}
+
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	s.b = []byte(str)
+	return nil
+}
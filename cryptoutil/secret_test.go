@@ -0,0 +1,93 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEqualConstantTime(t *testing.T) {
+	tests := []struct {
+		a, b []byte
+		want bool
+	}{
+		{[]byte("token"), []byte("token"), true},
+		{[]byte("token"), []byte("tokem"), false},
+		{[]byte("token"), []byte("toke"), false},
+		{nil, []byte{}, true},
+	}
+	for i, tt := range tests {
+		if got := EqualConstantTime(tt.a, tt.b); got != tt.want {
+			t.Errorf("case %d: want=%t got=%t", i, tt.want, got)
+		}
+	}
+}
+
+func TestZero(t *testing.T) {
+	b := []byte("hunter2")
+	Zero(b)
+	if !bytes.Equal(b, make([]byte, 7)) {
+		t.Errorf("not zeroed: %v", b)
+	}
+
+	s := NewSecret([]byte("hunter2"))
+	s.Zero()
+	if !bytes.Equal(s.Bytes(), make([]byte, 7)) {
+		t.Errorf("secret not zeroed: %v", s.Bytes())
+	}
+}
+
+func TestSecretRedaction(t *testing.T) {
+	s := NewSecret([]byte("hunter2"))
+	type config struct {
+		User     string
+		Password Secret
+		Token    *Secret
+	}
+	c := config{User: "alice", Password: s, Token: &s}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+		for _, arg := range []interface{}{s, &s, c} {
+			out := fmt.Sprintf(format, arg)
+			if strings.Contains(out, "hunter2") || strings.Contains(out, "68756e74657232") {
+				t.Errorf("%s of %T leaked the secret: %s", format, arg, out)
+			}
+		}
+	}
+
+	out, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"User":"alice","Password":"[REDACTED]","Token":"[REDACTED]"}`; string(out) != want {
+		t.Errorf("want=%s got=%s", want, out)
+	}
+}
+
+func TestSecretUnmarshal(t *testing.T) {
+	var c struct {
+		Password Secret
+	}
+	if err := json.Unmarshal([]byte(`{"Password":"hunter2"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if string(c.Password.Bytes()) != "hunter2" {
+		t.Errorf("unexpected value %q", c.Password.Bytes())
+	}
+	if !c.Password.Equal(NewSecret([]byte("hunter2"))) || c.Password.Equal(NewSecret(nil)) {
+		t.Errorf("Equal gave the wrong answer")
+	}
+	if err := json.Unmarshal([]byte(`{"Password":42}`), &c); err == nil {
+		t.Errorf("expected error for non-string secret")
+	}
+
+	var s Secret
+	if !s.Empty() {
+		t.Errorf("zero Secret is not empty")
+	}
+	if err := s.UnmarshalText([]byte("abc")); err != nil || string(s.Bytes()) != "abc" {
+		t.Errorf("UnmarshalText: %v %q", err, s.Bytes())
+	}
+}
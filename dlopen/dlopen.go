@@ -13,70 +13,245 @@
 // limitations under the License.
 
 // Package dlopen provides some convenience functions to dlopen a library and
-// get its symbols.
+// get its symbols. On Windows the same API is backed by LoadLibraryEx and
+// GetProcAddress.
 package dlopen
 
-// #cgo LDFLAGS: -ldl
-// #include <stdlib.h>
-// #include <dlfcn.h>
-import "C"
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"unsafe"
 )
 
 var ErrSoNotFound = errors.New("unable to open a handle to the library")
 
-// LibHandle represents an open handle to a library (.so)
+// ErrHandleClosed is returned when using a LibHandle after its last
+// reference has been closed.
+var ErrHandleClosed = errors.New("library handle is closed")
+
+// LibHandle represents an open handle to a library (.so). A handle is
+// reference counted: it starts with one reference, Retain adds one and
+// Close releases one, and the library is only dlclosed when the last
+// reference is released.
 type LibHandle struct {
 	Handle  unsafe.Pointer
 	Libname string
+
+	mu      sync.RWMutex
+	symbols map[string]unsafe.Pointer
+	// extra counts references beyond the initial one.
+	extra  int
+	closed bool
+	// shared is set for handles in the process-wide registry. It is
+	// written before the handle is published and never changes.
+	shared bool
 }
 
 // GetHandle tries to get a handle to a library (.so), attempting to access it
 // by the names specified in libs and returning the first that is successfully
 // opened. Callers are responsible for closing the handler. If no library can
 // be successfully opened, an error is returned.
+//
+// Handles are shared process-wide: while a library is open, getting it again
+// by the same name, from any goroutine, returns the same LibHandle with an
+// added reference, and concurrent first opens of a name call dlopen once.
 func GetHandle(libs []string) (*LibHandle, error) {
-	for _, name := range libs {
-		libname := C.CString(name)
-		defer C.free(unsafe.Pointer(libname))
-		handle := C.dlopen(libname, C.RTLD_LAZY)
-		if handle != nil {
-			h := &LibHandle{
-				Handle:  handle,
-				Libname: name,
+	return Loader{}.GetHandle(libs)
+}
+
+// Loader opens libraries from locations other than the dynamic linker's
+// default search path, such as nonstandard directories in containers.
+type Loader struct {
+	// EnvVar names an environment variable, such as MYAPP_LIBFOO_PATH,
+	// which overrides the search when set. Its value is either the path
+	// of the library itself or a directory to look for it in.
+	EnvVar string
+
+	// Dirs are searched, in order, for each of the library names before
+	// the default search path.
+	Dirs []string
+
+	// Finalize sets a finalizer on opened handles which closes them if
+	// they become unreachable without being closed. Symbol pointers from
+	// the handle must not be used after that point either, so this is a
+	// safety net against leaking handles, e.g. across plugin reloads, not
+	// a replacement for Close. Such handles are private to the caller
+	// rather than shared, since the registry would keep them reachable.
+	Finalize bool
+}
+
+// GetHandle is like the package level GetHandle, but first tries the
+// library named by l.EnvVar and then each name in libs within l.Dirs,
+// before falling back to opening the names in libs as given.
+func (l Loader) GetHandle(libs []string) (*LibHandle, error) {
+	for _, path := range l.candidates(libs) {
+		if !l.Finalize {
+			if h := acquire(path); h != nil {
+				return h, nil
 			}
+			continue
+		}
+		if h := dlopen(path); h != nil {
+			runtime.SetFinalizer(h, (*LibHandle).finalize)
 			return h, nil
 		}
 	}
 	return nil, ErrSoNotFound
 }
 
+// candidates returns the paths to try, in order.
+func (l Loader) candidates(libs []string) []string {
+	var paths []string
+	if l.EnvVar != "" {
+		if v := os.Getenv(l.EnvVar); v != "" {
+			if fi, err := os.Stat(v); err == nil && fi.IsDir() {
+				for _, name := range libs {
+					paths = append(paths, filepath.Join(v, name))
+				}
+			} else {
+				paths = append(paths, v)
+			}
+		}
+	}
+	for _, dir := range l.Dirs {
+		for _, name := range libs {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	return append(paths, libs...)
+}
+
+func dlopen(name string) *LibHandle {
+	handle, err := openLibrary(name)
+	if err != nil {
+		return nil
+	}
+	return &LibHandle{
+		Handle:  handle,
+		Libname: name,
+	}
+}
+
 // GetSymbolPointer takes a symbol name and returns a pointer to the symbol.
+// Symbols are resolved on first use and cached, so repeated lookups of the
+// same symbol are cheap and safe from multiple goroutines.
 func (l *LibHandle) GetSymbolPointer(symbol string) (unsafe.Pointer, error) {
-	sym := C.CString(symbol)
-	defer C.free(unsafe.Pointer(sym))
-
-	C.dlerror()
-	p := C.dlsym(l.Handle, sym)
-	e := C.dlerror()
-	if e != nil {
-		return nil, fmt.Errorf("error resolving symbol %q: %v", symbol, errors.New(C.GoString(e)))
+	l.mu.RLock()
+	p, ok := l.symbols[symbol]
+	l.mu.RUnlock()
+	if ok {
+		return p, nil
 	}
 
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrHandleClosed
+	}
+	if p, ok := l.symbols[symbol]; ok {
+		return p, nil
+	}
+	p, err := lookupSymbol(l.Handle, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if l.symbols == nil {
+		l.symbols = make(map[string]unsafe.Pointer)
+	}
+	l.symbols[symbol] = p
 	return p, nil
 }
 
-// Close closes a LibHandle.
+// HasSymbol reports whether the library provides symbol, for detecting
+// optional capabilities of the installed version. A symbol which is found
+// is cached for later GetSymbolPointer calls.
+func (l *LibHandle) HasSymbol(symbol string) bool {
+	_, err := l.GetSymbolPointer(symbol)
+	return err == nil
+}
+
+// MissingSymbols returns those of symbols the library does not provide,
+// in order, or nil if it provides them all.
+func (l *LibHandle) MissingSymbols(symbols ...string) []string {
+	var missing []string
+	for _, s := range symbols {
+		if !l.HasSymbol(s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// Prefetch resolves and caches symbols up front, so that a library missing
+// any of them can be rejected at startup rather than on first call. The
+// error names every symbol which could not be resolved.
+func (l *LibHandle) Prefetch(symbols ...string) error {
+	if missing := l.MissingSymbols(symbols...); len(missing) > 0 {
+		return fmt.Errorf("%v is missing symbols: %s", l.Libname, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Retain adds a reference to the handle, which must be released with its
+// own call to Close.
+func (l *LibHandle) Retain() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrHandleClosed
+	}
+	l.extra++
+	return nil
+}
+
+// Close releases a reference to the LibHandle, closing the library once
+// no references remain.
 func (l *LibHandle) Close() error {
-	C.dlerror()
-	C.dlclose(l.Handle)
-	e := C.dlerror()
-	if e != nil {
-		return fmt.Errorf("error closing %v: %v", l.Libname, errors.New(C.GoString(e)))
+	if l.shared {
+		// Lock the registry first, as acquire does, so a handle is never
+		// found there after its last reference is released.
+		registry.Lock()
+		defer registry.Unlock()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrHandleClosed
+	}
+	if l.extra > 0 {
+		l.extra--
+		return nil
+	}
+	if l.shared {
+		delete(registry.handles, l.Libname)
+	}
+	return l.closeLocked()
+}
+
+func (l *LibHandle) closeLocked() error {
+	l.closed = true
+	l.symbols = nil
+	runtime.SetFinalizer(l, nil)
+
+	if err := closeLibrary(l.Handle); err != nil {
+		return fmt.Errorf("error closing %v: %v", l.Libname, err)
 	}
 
 	return nil
 }
+
+// finalize closes the library when the handle is garbage collected,
+// regardless of outstanding references: none can be left if the handle
+// is unreachable.
+func (l *LibHandle) finalize() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closeLocked()
+	}
+}
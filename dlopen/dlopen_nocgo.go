@@ -0,0 +1,48 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !cgo && !windows
+// +build !cgo,!windows
+
+package dlopen
+
+import (
+	"errors"
+	"unsafe"
+)
+
+var errNoCgo = errors.New("dlopen requires cgo")
+
+func openLibrary(name string) (unsafe.Pointer, error) {
+	return nil, errNoCgo
+}
+
+func lookupSymbol(handle unsafe.Pointer, symbol string) (unsafe.Pointer, error) {
+	return nil, errNoCgo
+}
+
+func closeLibrary(handle unsafe.Pointer) error {
+	return errNoCgo
+}
+
+// No handle can be opened without cgo, so the call functions are
+// unreachable.
+
+func callIntVoid(f unsafe.Pointer) int                  { panic(errNoCgo) }
+func callIntInt(f unsafe.Pointer, a int) int            { panic(errNoCgo) }
+func callIntPtr(f, a unsafe.Pointer) int                { panic(errNoCgo) }
+func callPtrVoid(f unsafe.Pointer) unsafe.Pointer       { panic(errNoCgo) }
+func callPtrInt(f unsafe.Pointer, a int) unsafe.Pointer { panic(errNoCgo) }
+func callPtrPtr(f, a unsafe.Pointer) unsafe.Pointer     { panic(errNoCgo) }
+func callVoidPtr(f, a unsafe.Pointer)                   { panic(errNoCgo) }
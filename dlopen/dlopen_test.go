@@ -11,10 +11,19 @@
 // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 // See the License for the specific language governing permissions and
 // limitations under the License.
+
+//go:build linux && cgo
+// +build linux,cgo
+
 package dlopen
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -61,3 +70,212 @@ func TestDlopen(t *testing.T) {
 		}
 	}
 }
+
+func TestSymbolCache(t *testing.T) {
+	h, err := GetHandle([]string{"libc.so.6", "libc.so"})
+	if err != nil {
+		t.Fatalf("couldn't get a handle to libc: %v", err)
+	}
+	defer h.Close()
+
+	p1, err := h.GetSymbolPointer("strlen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.symbols["strlen"]; !ok {
+		t.Errorf("symbol was not cached")
+	}
+	p2, err := h.GetSymbolPointer("strlen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1 != p2 {
+		t.Errorf("cached pointer differs: %p != %p", p1, p2)
+	}
+
+	if _, err := h.GetSymbolPointer("no_such_symbol"); err == nil {
+		t.Errorf("expected error for missing symbol")
+	}
+	if _, ok := h.symbols["no_such_symbol"]; ok {
+		t.Errorf("missing symbol was cached")
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	h, err := GetHandle([]string{"libc.so.6", "libc.so"})
+	if err != nil {
+		t.Fatalf("couldn't get a handle to libc: %v", err)
+	}
+	defer h.Close()
+
+	if err := h.Prefetch("strlen", "malloc", "free"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(h.symbols) != 3 {
+		t.Errorf("want 3 cached symbols, got %d", len(h.symbols))
+	}
+
+	err = h.Prefetch("strlen", "no_such_symbol", "another_missing_symbol")
+	if err == nil {
+		t.Fatalf("expected error for missing symbols")
+	}
+	for _, s := range []string{"no_such_symbol", "another_missing_symbol"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error %q does not name %s", err, s)
+		}
+	}
+}
+
+func TestLoaderCandidates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dlopen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer os.Unsetenv("DLOPEN_TEST_LIB_PATH")
+
+	tests := []struct {
+		loader Loader
+		env    string
+		want   []string
+	}{
+		{
+			loader: Loader{},
+			want:   []string{"libfoo.so.1", "libfoo.so"},
+		},
+		{
+			loader: Loader{Dirs: []string{"/opt/foo/lib"}},
+			want:   []string{"/opt/foo/lib/libfoo.so.1", "/opt/foo/lib/libfoo.so", "libfoo.so.1", "libfoo.so"},
+		},
+		{
+			loader: Loader{EnvVar: "DLOPEN_TEST_LIB_PATH"},
+			env:    dir,
+			want:   []string{dir + "/libfoo.so.1", dir + "/libfoo.so", "libfoo.so.1", "libfoo.so"},
+		},
+		{
+			loader: Loader{EnvVar: "DLOPEN_TEST_LIB_PATH", Dirs: []string{"/lib"}},
+			env:    "/custom/libfoo.so.2",
+			want:   []string{"/custom/libfoo.so.2", "/lib/libfoo.so.1", "/lib/libfoo.so", "libfoo.so.1", "libfoo.so"},
+		},
+		{
+			loader: Loader{EnvVar: "DLOPEN_TEST_LIB_PATH"},
+			env:    "",
+			want:   []string{"libfoo.so.1", "libfoo.so"},
+		},
+	}
+	for i, tt := range tests {
+		os.Setenv("DLOPEN_TEST_LIB_PATH", tt.env)
+		got := tt.loader.candidates([]string{"libfoo.so.1", "libfoo.so"})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, got)
+		}
+	}
+}
+
+func TestLoaderEnvOverride(t *testing.T) {
+	// Find where libc lives to point the override at it.
+	var libc string
+	for _, dir := range []string{"/lib/x86_64-linux-gnu", "/usr/lib/x86_64-linux-gnu", "/lib64", "/usr/lib64", "/lib", "/usr/lib"} {
+		if _, err := os.Stat(filepath.Join(dir, "libc.so.6")); err == nil {
+			libc = dir
+			break
+		}
+	}
+	if libc == "" {
+		t.Skip("libc.so.6 not found in a standard directory")
+	}
+
+	os.Setenv("DLOPEN_TEST_LIBC_PATH", filepath.Join(libc, "libc.so.6"))
+	defer os.Unsetenv("DLOPEN_TEST_LIBC_PATH")
+	h, err := Loader{EnvVar: "DLOPEN_TEST_LIBC_PATH"}.GetHandle([]string{"libstrange.so"})
+	if err != nil {
+		t.Fatalf("env override not honored: %v", err)
+	}
+	defer h.Close()
+	if h.Libname != filepath.Join(libc, "libc.so.6") {
+		t.Errorf("unexpected library %s", h.Libname)
+	}
+
+	if _, err := (Loader{Dirs: []string{libc}}).GetHandle([]string{"libstrange.so"}); err != ErrSoNotFound {
+		t.Errorf("want ErrSoNotFound, got %v", err)
+	}
+}
+
+func TestRefcount(t *testing.T) {
+	h, err := GetHandle([]string{"libc.so.6", "libc.so"})
+	if err != nil {
+		t.Fatalf("couldn't get a handle to libc: %v", err)
+	}
+	if err := h.Retain(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Retain(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := h.Close(); err != nil {
+			t.Fatalf("close %d: unexpected error: %v", i, err)
+		}
+		if _, err := h.GetSymbolPointer("strlen"); err != nil {
+			t.Errorf("close %d: handle unusable while referenced: %v", i, err)
+		}
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("final close: unexpected error: %v", err)
+	}
+	if _, err := h.GetSymbolPointer("strlen"); err != ErrHandleClosed {
+		t.Errorf("want ErrHandleClosed from lookup, got %v", err)
+	}
+	if err := h.Close(); err != ErrHandleClosed {
+		t.Errorf("want ErrHandleClosed from close, got %v", err)
+	}
+	if err := h.Retain(); err != ErrHandleClosed {
+		t.Errorf("want ErrHandleClosed from retain, got %v", err)
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	h, err := Loader{Finalize: true}.GetHandle([]string{"libc.so.6", "libc.so"})
+	if err != nil {
+		t.Fatalf("couldn't get a handle to libc: %v", err)
+	}
+	h.Retain()
+	h.finalize()
+	if !h.closed {
+		t.Errorf("finalizer did not close a referenced handle")
+	}
+	// Finalizing a closed handle is a no-op.
+	h.finalize()
+}
+
+func TestSymbolProbing(t *testing.T) {
+	h, err := GetHandle([]string{"libc.so.6", "libc.so"})
+	if err != nil {
+		t.Fatalf("couldn't get a handle to libc: %v", err)
+	}
+	defer h.Close()
+
+	if !h.HasSymbol("strlen") {
+		t.Errorf("libc does not have strlen")
+	}
+	if h.HasSymbol("no_such_symbol") {
+		t.Errorf("libc has no_such_symbol")
+	}
+
+	tests := []struct {
+		symbols []string
+		missing []string
+	}{
+		{nil, nil},
+		{[]string{"strlen", "malloc"}, nil},
+		{[]string{"strlen", "no_such_symbol", "malloc", "another_missing_symbol"}, []string{"no_such_symbol", "another_missing_symbol"}},
+	}
+	for i, tt := range tests {
+		if got := h.MissingSymbols(tt.symbols...); !reflect.DeepEqual(got, tt.missing) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.missing, got)
+		}
+	}
+}
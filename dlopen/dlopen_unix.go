@@ -0,0 +1,120 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cgo && !windows
+// +build cgo,!windows
+
+package dlopen
+
+// #cgo LDFLAGS: -ldl
+// #include <stdlib.h>
+// #include <dlfcn.h>
+//
+// // dlerror state is per thread, and a goroutine may move between threads
+// // between cgo calls, so the lookup and its error check are one call.
+// static void *
+// dlsym_checked(void *handle, const char *symbol, char **err)
+// {
+//   void *p;
+//
+//   dlerror();
+//   p = dlsym(handle, symbol);
+//   *err = dlerror();
+//   return p;
+// }
+//
+// static int
+// dlclose_checked(void *handle, char **err)
+// {
+//   int r;
+//
+//   dlerror();
+//   r = dlclose(handle);
+//   *err = dlerror();
+//   return r;
+// }
+//
+// // Trampolines for the signatures wrapped in funcs.go.
+// static int call_int_void(void *f) { return ((int (*)(void))f)(); }
+// static int call_int_int(void *f, int a) { return ((int (*)(int))f)(a); }
+// static int call_int_ptr(void *f, void *a) { return ((int (*)(void *))f)(a); }
+// static void *call_ptr_void(void *f) { return ((void *(*)(void))f)(); }
+// static void *call_ptr_int(void *f, int a) { return ((void *(*)(int))f)(a); }
+// static void *call_ptr_ptr(void *f, void *a) { return ((void *(*)(void *))f)(a); }
+// static void call_void_ptr(void *f, void *a) { ((void (*)(void *))f)(a); }
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+func openLibrary(name string) (unsafe.Pointer, error) {
+	libname := C.CString(name)
+	defer C.free(unsafe.Pointer(libname))
+	handle := C.dlopen(libname, C.RTLD_LAZY)
+	if handle == nil {
+		return nil, ErrSoNotFound
+	}
+	return handle, nil
+}
+
+func lookupSymbol(handle unsafe.Pointer, symbol string) (unsafe.Pointer, error) {
+	sym := C.CString(symbol)
+	defer C.free(unsafe.Pointer(sym))
+
+	var e *C.char
+	p := C.dlsym_checked(handle, sym, &e)
+	if e != nil {
+		return nil, fmt.Errorf("error resolving symbol %q: %v", symbol, errors.New(C.GoString(e)))
+	}
+
+	return p, nil
+}
+
+func closeLibrary(handle unsafe.Pointer) error {
+	var e *C.char
+	if C.dlclose_checked(handle, &e) != 0 && e != nil {
+		return errors.New(C.GoString(e))
+	}
+	return nil
+}
+
+func callIntVoid(f unsafe.Pointer) int {
+	return int(C.call_int_void(f))
+}
+
+func callIntInt(f unsafe.Pointer, a int) int {
+	return int(C.call_int_int(f, C.int(a)))
+}
+
+func callIntPtr(f, a unsafe.Pointer) int {
+	return int(C.call_int_ptr(f, a))
+}
+
+func callPtrVoid(f unsafe.Pointer) unsafe.Pointer {
+	return C.call_ptr_void(f)
+}
+
+func callPtrInt(f unsafe.Pointer, a int) unsafe.Pointer {
+	return C.call_ptr_int(f, C.int(a))
+}
+
+func callPtrPtr(f, a unsafe.Pointer) unsafe.Pointer {
+	return C.call_ptr_ptr(f, a)
+}
+
+func callVoidPtr(f, a unsafe.Pointer) {
+	C.call_void_ptr(f, a)
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlopen
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// toPointer converts a module handle or procedure address, which are not
+// Go pointers, to the unsafe.Pointer the API uses, without vet's
+// uintptr to unsafe.Pointer conversion check.
+func toPointer(p uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&p))
+}
+
+func openLibrary(name string) (unsafe.Pointer, error) {
+	// Bare names are only looked up in the application directory,
+	// System32 and directories added with AddDllDirectory, never the
+	// current directory, which avoids DLL preloading attacks. Paths load
+	// their dependencies from the DLL's own directory.
+	flags := uintptr(windows.LOAD_LIBRARY_SEARCH_DEFAULT_DIRS)
+	if filepath.IsAbs(name) {
+		flags = windows.LOAD_LIBRARY_SEARCH_DLL_LOAD_DIR | windows.LOAD_LIBRARY_SEARCH_DEFAULT_DIRS
+	}
+	h, err := windows.LoadLibraryEx(name, 0, flags)
+	if err != nil {
+		return nil, ErrSoNotFound
+	}
+	return toPointer(uintptr(h)), nil
+}
+
+func lookupSymbol(handle unsafe.Pointer, symbol string) (unsafe.Pointer, error) {
+	p, err := windows.GetProcAddress(windows.Handle(uintptr(handle)), symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving symbol %q: %v", symbol, err)
+	}
+	return toPointer(p), nil
+}
+
+func closeLibrary(handle unsafe.Pointer) error {
+	return windows.FreeLibrary(windows.Handle(uintptr(handle)))
+}
+
+// The call functions implement the signatures wrapped in funcs.go. Every
+// argument is passed and returned in a full register, so an int result is
+// truncated to the 32 bits of a C int.
+
+func callIntVoid(f unsafe.Pointer) int {
+	r, _, _ := syscall.SyscallN(uintptr(f))
+	return int(int32(r))
+}
+
+func callIntInt(f unsafe.Pointer, a int) int {
+	r, _, _ := syscall.SyscallN(uintptr(f), uintptr(int32(a)))
+	return int(int32(r))
+}
+
+func callIntPtr(f, a unsafe.Pointer) int {
+	r, _, _ := syscall.SyscallN(uintptr(f), uintptr(a))
+	return int(int32(r))
+}
+
+func callPtrVoid(f unsafe.Pointer) unsafe.Pointer {
+	r, _, _ := syscall.SyscallN(uintptr(f))
+	return toPointer(r)
+}
+
+func callPtrInt(f unsafe.Pointer, a int) unsafe.Pointer {
+	r, _, _ := syscall.SyscallN(uintptr(f), uintptr(int32(a)))
+	return toPointer(r)
+}
+
+func callPtrPtr(f, a unsafe.Pointer) unsafe.Pointer {
+	r, _, _ := syscall.SyscallN(uintptr(f), uintptr(a))
+	return toPointer(r)
+}
+
+func callVoidPtr(f, a unsafe.Pointer) {
+	syscall.SyscallN(uintptr(f), uintptr(a))
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlopen
+
+import "testing"
+
+func TestWindowsLoadLibrary(t *testing.T) {
+	h, err := Loader{Dirs: []string{`C:\nonexistent`}}.GetHandle([]string{"nosuchlib.dll", "kernel32.dll"})
+	if err != nil {
+		t.Fatalf("couldn't get a handle to kernel32: %v", err)
+	}
+	if h.Libname != "kernel32.dll" {
+		t.Errorf("unexpected library %s", h.Libname)
+	}
+
+	p, err := h.GetSymbolPointer("GetTickCount")
+	if err != nil || p == nil {
+		t.Errorf("couldn't resolve GetTickCount: %v", err)
+	}
+	if missing := h.MissingSymbols("GetTickCount", "NoSuchFunction"); len(missing) != 1 || missing[0] != "NoSuchFunction" {
+		t.Errorf("unexpected missing symbols %v", missing)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Errorf("unexpected error closing: %v", err)
+	}
+	if _, err := GetHandle([]string{"nosuchlib.dll"}); err != ErrSoNotFound {
+		t.Errorf("want ErrSoNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlopen
+
+import "unsafe"
+
+// The Func methods wrap C functions with common signatures as typed Go
+// functions, so callers need neither cgo nor unsafe for simple calls. Each
+// is named for the C return type and then the argument types, with Void
+// standing for no return value. C int maps to Go int, const char * to Go
+// string, and void * to unsafe.Pointer.
+//
+// The caller must pick the wrapper matching the C prototype exactly;
+// calling a function through the wrong signature is undefined behavior.
+// String arguments are copied into NUL terminated buffers which are only
+// valid for the duration of the call. String results are copied, and a
+// NULL result becomes "".
+
+// FuncInt wraps int f(void).
+func (l *LibHandle) FuncInt(symbol string) (func() int, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func() int { return callIntVoid(p) }, nil
+}
+
+// FuncIntInt wraps int f(int).
+func (l *LibHandle) FuncIntInt(symbol string) (func(int) int, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(a int) int { return callIntInt(p, a) }, nil
+}
+
+// FuncIntString wraps int f(const char *).
+func (l *LibHandle) FuncIntString(symbol string) (func(string) int, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(s string) int {
+		b := cString(s)
+		return callIntPtr(p, unsafe.Pointer(&b[0]))
+	}, nil
+}
+
+// FuncIntPointer wraps int f(void *).
+func (l *LibHandle) FuncIntPointer(symbol string) (func(unsafe.Pointer) int, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(a unsafe.Pointer) int { return callIntPtr(p, a) }, nil
+}
+
+// FuncString wraps const char *f(void), e.g. a library version function.
+func (l *LibHandle) FuncString(symbol string) (func() string, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func() string { return goString(callPtrVoid(p)) }, nil
+}
+
+// FuncStringInt wraps const char *f(int).
+func (l *LibHandle) FuncStringInt(symbol string) (func(int) string, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(a int) string { return goString(callPtrInt(p, a)) }, nil
+}
+
+// FuncStringString wraps const char *f(const char *).
+func (l *LibHandle) FuncStringString(symbol string) (func(string) string, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(s string) string {
+		b := cString(s)
+		return goString(callPtrPtr(p, unsafe.Pointer(&b[0])))
+	}, nil
+}
+
+// FuncPointer wraps void *f(void).
+func (l *LibHandle) FuncPointer(symbol string) (func() unsafe.Pointer, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func() unsafe.Pointer { return callPtrVoid(p) }, nil
+}
+
+// FuncPointerInt wraps void *f(int).
+func (l *LibHandle) FuncPointerInt(symbol string) (func(int) unsafe.Pointer, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(a int) unsafe.Pointer { return callPtrInt(p, a) }, nil
+}
+
+// FuncPointerPointer wraps void *f(void *).
+func (l *LibHandle) FuncPointerPointer(symbol string) (func(unsafe.Pointer) unsafe.Pointer, error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(a unsafe.Pointer) unsafe.Pointer { return callPtrPtr(p, a) }, nil
+}
+
+// FuncVoidPointer wraps void f(void *), e.g. a function freeing a resource.
+func (l *LibHandle) FuncVoidPointer(symbol string) (func(unsafe.Pointer), error) {
+	p, err := l.GetSymbolPointer(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return func(a unsafe.Pointer) { callVoidPtr(p, a) }, nil
+}
+
+// cString returns s as a NUL terminated byte slice.
+func cString(s string) []byte {
+	b := make([]byte, len(s)+1)
+	copy(b, s)
+	return b
+}
+
+// goString copies the NUL terminated string at p.
+func goString(p unsafe.Pointer) string {
+	if p == nil {
+		return ""
+	}
+	n := 0
+	for *(*byte)(unsafe.Add(p, n)) != 0 {
+		n++
+	}
+	return string(unsafe.Slice((*byte)(p), n))
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && cgo
+// +build linux,cgo
+
+package dlopen
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestFuncs(t *testing.T) {
+	h, err := GetHandle([]string{"libc.so.6", "libc.so"})
+	if err != nil {
+		t.Fatalf("couldn't get a handle to libc: %v", err)
+	}
+	defer h.Close()
+
+	getpid, err := h.FuncInt("getpid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getpid(); got != os.Getpid() {
+		t.Errorf("getpid: want=%d got=%d", os.Getpid(), got)
+	}
+
+	abs, err := h.FuncIntInt("abs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := abs(-5); got != 5 {
+		t.Errorf("abs: want=5 got=%d", got)
+	}
+
+	strlen, err := h.FuncIntString("strlen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range []string{"", "test", "hello, world"} {
+		if got := strlen(s); got != len(s) {
+			t.Errorf("case %d: strlen: want=%d got=%d", i, len(s), got)
+		}
+	}
+
+	strerror, err := h.FuncStringInt("strerror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strerror(2); !strings.Contains(got, "No such file") {
+		t.Errorf("strerror: got %q", got)
+	}
+
+	if err := os.Setenv("DLOPEN_FUNCS_TEST", "value"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("DLOPEN_FUNCS_TEST")
+	getenv, err := h.FuncStringString("getenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getenv("DLOPEN_FUNCS_TEST"); got != "value" {
+		t.Errorf("getenv: want=%q got=%q", "value", got)
+	}
+	if got := getenv("DLOPEN_FUNCS_TEST_UNSET"); got != "" {
+		t.Errorf("getenv of an unset variable: got %q", got)
+	}
+
+	malloc, err := h.FuncPointerInt("malloc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	free, err := h.FuncVoidPointer("free")
+	if err != nil {
+		t.Fatal(err)
+	}
+	strlenp, err := h.FuncIntPointer("strlen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := malloc(4)
+	if p == nil {
+		t.Fatal("malloc returned NULL")
+	}
+	copy(unsafe.Slice((*byte)(p), 4), "abc\x00")
+	if got := strlenp(p); got != 3 {
+		t.Errorf("strlen of malloc'd buffer: want=3 got=%d", got)
+	}
+	free(p)
+
+	if _, err := h.FuncInt("no_such_symbol"); err == nil {
+		t.Errorf("expected an error for a missing symbol")
+	}
+}
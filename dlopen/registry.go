@@ -0,0 +1,80 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlopen
+
+import "sync"
+
+// registry holds the shared handles returned by GetHandle, keyed by the
+// name they were opened with, and the opens in progress.
+var registry = struct {
+	sync.Mutex
+	handles  map[string]*LibHandle
+	inflight map[string]*openCall
+}{
+	handles:  make(map[string]*LibHandle),
+	inflight: make(map[string]*openCall),
+}
+
+// openCall is an open in progress. h is set, possibly to nil if the open
+// failed, before done is closed.
+type openCall struct {
+	done chan struct{}
+	h    *LibHandle
+}
+
+// acquire returns a new reference to the shared handle for name, opening
+// the library if it is not open yet. Concurrent callers for the same name
+// wait for a single open. It returns nil if the library cannot be opened.
+func acquire(name string) *LibHandle {
+	for {
+		registry.Lock()
+		if h, ok := registry.handles[name]; ok {
+			// Close removes handles under the registry lock before
+			// they are closed, so this cannot fail.
+			h.Retain()
+			registry.Unlock()
+			return h
+		}
+		if c, ok := registry.inflight[name]; ok {
+			registry.Unlock()
+			<-c.done
+			if c.h == nil {
+				return nil
+			}
+			// The opener may have closed the handle already; if so,
+			// start over.
+			if c.h.Retain() == nil {
+				return c.h
+			}
+			continue
+		}
+		c := &openCall{done: make(chan struct{})}
+		registry.inflight[name] = c
+		registry.Unlock()
+
+		h := dlopen(name)
+
+		registry.Lock()
+		delete(registry.inflight, name)
+		if h != nil {
+			h.shared = true
+			registry.handles[name] = h
+		}
+		c.h = h
+		close(c.done)
+		registry.Unlock()
+		return h
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && cgo
+// +build linux,cgo
+
+package dlopen
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSharedHandles(t *testing.T) {
+	libs := []string{"libc.so.6", "libc.so"}
+
+	const n = 50
+	handles := make([]*LibHandle, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h, err := GetHandle(libs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			handles[i] = h
+		}(i)
+	}
+	wg.Wait()
+
+	for i, h := range handles {
+		if h != handles[0] {
+			t.Fatalf("handle %d is not shared", i)
+		}
+	}
+	if h := handles[0]; h.extra != n-1 {
+		t.Errorf("want %d extra references, got %d", n-1, h.extra)
+	}
+
+	for i, h := range handles {
+		if err := h.Close(); err != nil {
+			t.Fatalf("close %d: %v", i, err)
+		}
+	}
+	registry.Lock()
+	_, ok := registry.handles["libc.so.6"]
+	inflight := len(registry.inflight)
+	registry.Unlock()
+	if ok || inflight != 0 {
+		t.Errorf("registry not cleaned up: registered=%t inflight=%d", ok, inflight)
+	}
+
+	// Once closed, the library is opened afresh.
+	h, err := GetHandle(libs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if h == handles[0] {
+		t.Errorf("got a closed handle back")
+	}
+	if _, err := h.GetSymbolPointer("strlen"); err != nil {
+		t.Errorf("reopened handle unusable: %v", err)
+	}
+}
+
+func TestSharedHandlesConcurrentClose(t *testing.T) {
+	libs := []string{"libc.so.6"}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				h, err := GetHandle(libs)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := h.GetSymbolPointer("strlen"); err != nil {
+					t.Error(err)
+				}
+				if err := h.Close(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFinalizeHandlesArePrivate(t *testing.T) {
+	shared, err := GetHandle([]string{"libc.so.6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Close()
+	private, err := Loader{Finalize: true}.GetHandle([]string{"libc.so.6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer private.Close()
+	if private == shared || private.shared {
+		t.Errorf("finalized handle was shared")
+	}
+}
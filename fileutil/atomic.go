@@ -0,0 +1,48 @@
+// Package fileutil provides helpers for working with files safely: atomic
+// writes, locking, copying and watching.
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to the file named by path, replacing it
+// atomically: readers see either the old contents or the new, never a
+// partial write, even after a crash or power loss. The data is written to a
+// temporary file in the same directory, which is synced and renamed over
+// path, and the directory is then synced so the rename itself is durable.
+//
+// The file is given the permissions perm, unmodified by the umask. On error
+// the temporary file is removed and path is left untouched.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	return SyncDir(dir)
+}
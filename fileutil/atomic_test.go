@@ -0,0 +1,81 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	for i, tt := range []struct {
+		data string
+		perm os.FileMode
+	}{
+		{`{"version": 1}`, 0644},
+		{`{"version": 2}`, 0600},
+	} {
+		if err := WriteFileAtomic(path, []byte(tt.data), tt.perm); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if string(got) != tt.data {
+			t.Errorf("case %d: want=%q got=%q", i, tt.data, got)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if runtime.GOOS != "windows" && fi.Mode().Perm() != tt.perm {
+			t.Errorf("case %d: want mode %v, got %v", i, tt.perm, fi.Mode().Perm())
+		}
+	}
+
+	// No temporary files are left behind.
+	names, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("want only the written file in %s, got %d entries", dir, len(names))
+	}
+}
+
+func TestWriteFileAtomicError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Renaming over a non-empty directory fails.
+	path := filepath.Join(dir, "target")
+	if err := os.MkdirAll(filepath.Join(path, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("data"), 0644); err == nil {
+		t.Fatal("expected an error")
+	}
+	names, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("the temporary file was not removed: %d entries in %s", len(names), dir)
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "file"), []byte("data"), 0644); err == nil {
+		t.Error("expected an error writing into a missing directory")
+	}
+}
@@ -0,0 +1,288 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/pkg/progressutil"
+)
+
+// OverwritePolicy decides what CopyTree does with files that already exist
+// at the destination.
+type OverwritePolicy int
+
+const (
+	// OverwriteNever fails the copy with an error satisfying os.IsExist.
+	OverwriteNever OverwritePolicy = iota
+	// OverwriteAlways replaces existing files.
+	OverwriteAlways
+	// OverwriteIfNewer replaces existing files which are older than the
+	// source file, and leaves the rest untouched.
+	OverwriteIfNewer
+	// OverwriteSkip leaves existing files untouched.
+	OverwriteSkip
+)
+
+// CopyOptions configure CopyTree.
+type CopyOptions struct {
+	// Overwrite applies to files and symlinks. Existing directories are
+	// always merged into.
+	Overwrite OverwritePolicy
+
+	// PreserveOwner copies the owning user and group, which usually
+	// requires privileges. It has no effect on Windows.
+	PreserveOwner bool
+
+	// PreserveXattrs copies extended attributes. It is only supported on
+	// Linux.
+	PreserveXattrs bool
+
+	// Progress, if set, reports the bytes copied so far against the total
+	// size of the regular files in src, which is measured first. The
+	// caller prints it with the ProgressBarPrinter it was added to.
+	Progress *progressutil.ProgressBar
+}
+
+var errXattrsUnsupported = errors.New("extended attributes are not supported on this platform")
+
+// CopyTree copies src to dst, recursing into directories, and preserves
+// permission bits and modification times, like cp -a. Symlinks are copied
+// as symlinks, never followed. Directories are created as needed, and are
+// given their final permissions only after their contents have been copied,
+// so read-only trees can be copied. Other file types, such as devices and
+// sockets, fail the copy.
+//
+// The modification times of symlinks are not preserved. CopyTree refuses to
+// copy a directory into itself.
+func CopyTree(src, dst string, opts CopyOptions) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		if err := checkNotWithin(src, dst); err != nil {
+			return err
+		}
+	}
+
+	c := &treeCopier{opts: opts}
+	if opts.Progress != nil {
+		if c.total, err = treeSize(src); err != nil {
+			return err
+		}
+		c.report()
+	}
+
+	err = filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return c.copy(path, filepath.Join(dst, rel), fi)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Apply directory metadata innermost first, now that nothing more
+	// will be written into them.
+	for i := len(c.dirs) - 1; i >= 0; i-- {
+		d := c.dirs[i]
+		if err := c.setMetadata(d.src, d.dst, d.fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type copiedDir struct {
+	src, dst string
+	fi       os.FileInfo
+}
+
+type treeCopier struct {
+	opts   CopyOptions
+	dirs   []copiedDir
+	copied int64
+	total  int64
+}
+
+func (c *treeCopier) copy(src, dst string, fi os.FileInfo) error {
+	switch mode := fi.Mode(); {
+	case mode.IsDir():
+		if err := os.Mkdir(dst, 0700); err != nil {
+			// Merge into an existing directory.
+			if dfi, serr := os.Lstat(dst); serr != nil || !dfi.IsDir() {
+				return err
+			}
+		}
+		c.dirs = append(c.dirs, copiedDir{src, dst, fi})
+		return nil
+	case mode.IsRegular():
+		ok, err := c.replace(dst, fi)
+		if !ok || err != nil {
+			if err == nil {
+				// Skipped files still count towards the total.
+				c.copied += fi.Size()
+				c.report()
+			}
+			return err
+		}
+		if err := c.copyFile(src, dst, fi); err != nil {
+			return err
+		}
+		return c.setMetadata(src, dst, fi)
+	case mode&os.ModeSymlink != 0:
+		ok, err := c.replace(dst, fi)
+		if !ok || err != nil {
+			return err
+		}
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return err
+		}
+		return c.setOwnership(src, dst, fi)
+	}
+	return fmt.Errorf("%s: cannot copy file of type %v", src, fi.Mode().Type())
+}
+
+// replace applies the overwrite policy to dst, removing it if it is to be
+// replaced. It reports whether src should be copied.
+func (c *treeCopier) replace(dst string, fi os.FileInfo) (bool, error) {
+	dfi, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if dfi.IsDir() {
+		return false, fmt.Errorf("%s: cannot overwrite directory", dst)
+	}
+
+	switch c.opts.Overwrite {
+	case OverwriteSkip:
+		return false, nil
+	case OverwriteIfNewer:
+		if !fi.ModTime().After(dfi.ModTime()) {
+			return false, nil
+		}
+	case OverwriteAlways:
+	default:
+		return false, &os.PathError{Op: "copy", Path: dst, Err: os.ErrExist}
+	}
+	return true, os.Remove(dst)
+}
+
+func (c *treeCopier) copyFile(src, dst string, fi os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = out
+	if c.opts.Progress != nil {
+		w = &countingWriter{w: out, c: c}
+	}
+	_, err = io.Copy(w, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// setMetadata applies the permissions, times, ownership and extended
+// attributes of src to dst.
+func (c *treeCopier) setMetadata(src, dst string, fi os.FileInfo) error {
+	if err := c.setOwnership(src, dst, fi); err != nil {
+		return err
+	}
+	// Changing the owner may clear the setuid and setgid bits, so the mode
+	// is set afterwards.
+	if err := os.Chmod(dst, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
+
+func (c *treeCopier) setOwnership(src, dst string, fi os.FileInfo) error {
+	if c.opts.PreserveOwner {
+		if err := copyOwner(dst, fi); err != nil {
+			return err
+		}
+	}
+	if c.opts.PreserveXattrs {
+		return copyXattrs(src, dst)
+	}
+	return nil
+}
+
+func (c *treeCopier) report() {
+	pb := c.opts.Progress
+	if pb == nil {
+		return
+	}
+	pb.SetPrintAfter(fmt.Sprintf("%s / %s", progressutil.ByteUnitStr(c.copied), progressutil.ByteUnitStr(c.total)))
+	progress := 1.0
+	if c.total > 0 && c.copied < c.total {
+		progress = float64(c.copied) / float64(c.total)
+	}
+	pb.SetCurrentProgress(progress)
+}
+
+type countingWriter struct {
+	w io.Writer
+	c *treeCopier
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.c.copied += int64(n)
+	cw.c.report()
+	return n, err
+}
+
+// treeSize returns the total size of the regular files under root.
+func treeSize(root string) (int64, error) {
+	var total int64
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			total += fi.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// checkNotWithin returns an error if dst is src or lies within it.
+func checkNotWithin(src, dst string) error {
+	s, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	d, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if d == s || strings.HasPrefix(d, s+string(filepath.Separator)) {
+		return fmt.Errorf("cannot copy %s into itself", src)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/progressutil"
+)
+
+// makeTree creates a small tree under dir and returns its root.
+func makeTree(t *testing.T, dir string) string {
+	src := filepath.Join(dir, "src")
+	mtime := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, f := range []struct {
+		path string
+		data string
+		mode os.FileMode
+	}{
+		{"a", "alpha", 0640},
+		{"sub/b", "bravo", 0600},
+		{"ro/c", "charlie", 0444},
+	} {
+		path := filepath.Join(src, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f.data), f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(src, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("a", filepath.Join(src, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(src, "ro"), 0555); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() {
+		// Make read-only copies removable.
+		filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err == nil && fi.IsDir() {
+				os.Chmod(path, 0755)
+			}
+			return nil
+		})
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	src := makeTree(t, dir)
+	dst := filepath.Join(dir, "dst")
+
+	pbp := &progressutil.ProgressBarPrinter{}
+	pb := pbp.AddProgressBar()
+	if err := CopyTree(src, dst, CopyOptions{Progress: pb}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, f := range []struct {
+		path string
+		data string
+		mode os.FileMode
+	}{
+		{"a", "alpha", 0640},
+		{"sub/b", "bravo", 0600},
+		{"ro/c", "charlie", 0444},
+	} {
+		path := filepath.Join(dst, f.path)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", f.path, err)
+			continue
+		}
+		if string(data) != f.data {
+			t.Errorf("%s: want=%q got=%q", f.path, f.data, data)
+		}
+		sfi, _ := os.Stat(filepath.Join(src, f.path))
+		dfi, _ := os.Stat(path)
+		if runtime.GOOS != "windows" && dfi.Mode() != f.mode {
+			t.Errorf("%s: want mode %v, got %v", f.path, f.mode, dfi.Mode())
+		}
+		if !dfi.ModTime().Equal(sfi.ModTime()) {
+			t.Errorf("%s: want mtime %v, got %v", f.path, sfi.ModTime(), dfi.ModTime())
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		for path, want := range map[string]os.FileMode{"sub": 0750, "ro": 0555} {
+			fi, err := os.Stat(filepath.Join(dst, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != want {
+				t.Errorf("%s: want mode %v, got %v", path, want, fi.Mode().Perm())
+			}
+		}
+		target, err := os.Readlink(filepath.Join(dst, "link"))
+		if err != nil || target != "a" {
+			t.Errorf("link: want a symlink to a, got %q (%v)", target, err)
+		}
+	}
+
+	if got := pb.GetCurrentProgress(); got != 1 {
+		t.Errorf("want progress 1, got %v", got)
+	}
+	if want, got := "17 B / 17 B", pb.GetPrintAfter(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
+
+func TestCopyTreeOverwrite(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	for _, d := range []string{src, dst} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	write := func(path, data string, mtime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, tt := range []struct {
+		policy  OverwritePolicy
+		srcTime time.Time
+		want    string
+		exist   bool
+	}{
+		{policy: OverwriteNever, srcTime: now, want: "old", exist: true},
+		{policy: OverwriteSkip, srcTime: now, want: "old"},
+		{policy: OverwriteIfNewer, srcTime: old.Add(-time.Hour), want: "old"},
+		{policy: OverwriteIfNewer, srcTime: now, want: "new"},
+		{policy: OverwriteAlways, srcTime: old.Add(-time.Hour), want: "new"},
+	} {
+		write(filepath.Join(src, "f"), "new", tt.srcTime)
+		write(filepath.Join(dst, "f"), "old", old)
+
+		err := CopyTree(src, dst, CopyOptions{Overwrite: tt.policy})
+		if tt.exist {
+			if !os.IsExist(err) {
+				t.Errorf("case %d: want an existence error, got %v", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dst, "f"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, data)
+		}
+	}
+}
+
+func TestCopyTreeIntoItself(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	if err := CopyTree(dir, filepath.Join(dir, "copy"), CopyOptions{}); err == nil {
+		t.Fatal("expected an error copying a directory into itself")
+	}
+}
+
+func TestCopyTreeFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	src := filepath.Join(dir, "f")
+	if err := ioutil.WriteFile(src, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "g")
+	if err := CopyTree(src, dst, CopyOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := ioutil.ReadFile(dst); err != nil || string(data) != "data" {
+		t.Errorf("want %q, got %q (%v)", "data", data, err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package fileutil
+
+import "os"
+
+// SyncDir flushes the directory dir to stable storage, making the creation,
+// removal or renaming of files within it durable.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
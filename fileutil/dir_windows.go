@@ -0,0 +1,9 @@
+package fileutil
+
+// SyncDir flushes the directory dir to stable storage, making the creation,
+// removal or renaming of files within it durable. Directories cannot be
+// synced on Windows, where NTFS journals metadata changes itself, so SyncDir
+// does nothing.
+func SyncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,189 @@
+package fileutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/pkg/timeutil"
+)
+
+// DefaultFollowInterval is the default Interval of a Follower.
+const DefaultFollowInterval = 250 * time.Millisecond
+
+// Follower follows a file like tail -F, delivering the lines appended to it.
+// The file is polled, and followed by name: when it is replaced, as by log
+// rotation, the new file is read from its start, and when it is truncated it
+// is read again from its start. A file which does not exist yet is waited
+// for.
+type Follower struct {
+	Path string
+
+	// Interval is the time between polls once the end of the file has
+	// been reached. It defaults to DefaultFollowInterval.
+	Interval time.Duration
+
+	// FromStart delivers the lines already in the file. By default,
+	// following starts at the end of the file as it is when Lines is
+	// called.
+	FromStart bool
+
+	// Clock defaults to timeutil.RealClock.
+	Clock timeutil.Clock
+
+	// Logger, if set, logs rotation and truncation at INFO and read
+	// errors at WARNING.
+	Logger *capnslog.PackageLogger
+}
+
+// Follow follows the file at path from its end, as by a Follower with the
+// default options.
+func Follow(ctx context.Context, path string) <-chan string {
+	f := &Follower{Path: path}
+	return f.Lines(ctx)
+}
+
+// Lines follows the file until ctx is done. Each complete line is sent on the
+// returned channel without its line ending; an incomplete last line is only
+// sent once the file is replaced. The channel is closed once ctx is done.
+func (f *Follower) Lines(ctx context.Context) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		f.run(ctx, lines)
+	}()
+	return lines
+}
+
+func (f *Follower) run(ctx context.Context, lines chan<- string) {
+	clock := f.Clock
+	if clock == nil {
+		clock = timeutil.RealClock
+	}
+	interval := f.Interval
+	if interval <= 0 {
+		interval = DefaultFollowInterval
+	}
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	emit := func(line []byte) bool {
+		select {
+		case lines <- string(bytes.TrimSuffix(line, []byte("\r"))):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var (
+		file    *os.File
+		partial []byte
+		first   = true
+		buf     = make([]byte, 32*1024)
+	)
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		if file == nil {
+			fh, err := os.Open(f.Path)
+			if err == nil {
+				if first && !f.FromStart {
+					fh.Seek(0, io.SeekEnd)
+				}
+				file = fh
+			} else if !os.IsNotExist(err) {
+				f.logf(capnslog.WARNING, "failed to open %s: %v", f.Path, err)
+			}
+			first = false
+		}
+
+		if file != nil {
+			for {
+				n, err := file.Read(buf)
+				partial = append(partial, buf[:n]...)
+				for {
+					i := bytes.IndexByte(partial, '\n')
+					if i < 0 {
+						break
+					}
+					if !emit(partial[:i]) {
+						return
+					}
+					partial = partial[i+1:]
+				}
+				if err != nil {
+					if err != io.EOF {
+						f.logf(capnslog.WARNING, "failed to read %s: %v", f.Path, err)
+					}
+					break
+				}
+			}
+			partial = append([]byte(nil), partial...)
+
+			switch f.check(file) {
+			case fileReplaced:
+				// The old file will not grow any more.
+				if len(partial) > 0 && !emit(partial) {
+					return
+				}
+				partial = nil
+				file.Close()
+				file = nil
+				f.logf(capnslog.INFO, "%s was replaced, reopening", f.Path)
+				continue
+			case fileTruncated:
+				partial = nil
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					file.Close()
+					file = nil
+				}
+				f.logf(capnslog.INFO, "%s was truncated, reading from the start", f.Path)
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+type fileChange int
+
+const (
+	fileUnchanged fileChange = iota
+	fileReplaced
+	fileTruncated
+)
+
+// check reports how the open file relates to the one at f.Path.
+func (f *Follower) check(file *os.File) fileChange {
+	fi, err := file.Stat()
+	if err != nil {
+		return fileReplaced
+	}
+	pi, err := os.Stat(f.Path)
+	if err != nil || !os.SameFile(fi, pi) {
+		return fileReplaced
+	}
+	if pos, err := file.Seek(0, io.SeekCurrent); err == nil && fi.Size() < pos {
+		return fileTruncated
+	}
+	return fileUnchanged
+}
+
+func (f *Follower) logf(l capnslog.LogLevel, format string, args ...interface{}) {
+	if f.Logger != nil {
+		f.Logger.Logf(l, format, args...)
+	}
+}
@@ -0,0 +1,102 @@
+package fileutil
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectLines(t *testing.T, lines <-chan string, want ...string) {
+	for _, w := range want {
+		select {
+		case got, ok := <-lines:
+			if !ok {
+				t.Fatalf("channel closed, want %q", w)
+			}
+			if got != w {
+				t.Fatalf("want=%q got=%q", w, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func TestFollow(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "log")
+	if err := ioutil.WriteFile(path, []byte("old line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := &Follower{Path: path, Interval: 5 * time.Millisecond}
+	lines := f.Lines(ctx)
+
+	// Give the follower time to open the file and seek to its end.
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "one\ntwo\r\nthr")
+	expectLines(t, lines, "one", "two")
+	appendFile(t, path, "ee\n")
+	expectLines(t, lines, "three")
+
+	// Truncation.
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "after truncate\n")
+	expectLines(t, lines, "after truncate")
+
+	// Rotation: the old file is moved away with an incomplete line and a
+	// new one created.
+	appendFile(t, path, "partial")
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "rotated\n")
+	expectLines(t, lines, "partial", "rotated")
+
+	cancel()
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Fatal("unexpected line after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after cancellation")
+	}
+}
+
+func TestFollowFromStart(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "log")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := &Follower{Path: path, Interval: 5 * time.Millisecond, FromStart: true}
+	lines := f.Lines(ctx)
+
+	// The file does not exist yet.
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, path, "first\nsecond\n")
+	expectLines(t, lines, "first", "second")
+}
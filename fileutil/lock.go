@@ -0,0 +1,208 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DirLockName is the name of the lock file created by LockDir in the
+// directory it locks.
+const DirLockName = ".lock"
+
+// ErrLocked is returned, wrapped in a LockedError, when a lock is held by
+// another process.
+var ErrLocked = errors.New("locked by another process")
+
+// errWouldBlock is returned by the platform lock functions when a
+// non-blocking lock is already held.
+var errWouldBlock = errors.New("lock would block")
+
+// LockedError is returned by TryLockFile and TryLockDir when the lock is
+// held by another process. It wraps ErrLocked.
+type LockedError struct {
+	Path string
+	// PID is the process ID recorded by the holder, or zero if unknown.
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID != 0 {
+		return fmt.Sprintf("%s: %v (pid %d)", e.Path, ErrLocked, e.PID)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, ErrLocked)
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Lock is an exclusive advisory lock on a file, held until Close is called
+// or the process exits. The lock is taken with flock on Unix (fcntl on AIX)
+// and LockFileEx on Windows, so the operating system releases it when its holder dies and
+// a lock file left behind by a crashed process is never mistaken for a held
+// lock. The holder's process ID is written to the file for diagnostics.
+type Lock struct {
+	path      string
+	recovered int
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// LockFile locks the file at path, creating it if needed, and blocks until
+// the lock is acquired.
+func LockFile(path string) (*Lock, error) {
+	return lockFile(path, true)
+}
+
+// TryLockFile is like LockFile, but returns a LockedError immediately if
+// another process holds the lock.
+func TryLockFile(path string) (*Lock, error) {
+	return lockFile(path, false)
+}
+
+// LockDir locks the directory dir through the file DirLockName within it,
+// blocking until the lock is acquired.
+func LockDir(dir string) (*Lock, error) {
+	return LockFile(filepath.Join(dir, DirLockName))
+}
+
+// TryLockDir is like LockDir, but returns a LockedError immediately if
+// another process holds the lock.
+func TryLockDir(dir string) (*Lock, error) {
+	return TryLockFile(filepath.Join(dir, DirLockName))
+}
+
+func lockFile(path string, block bool) (*Lock, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lock(f, block); err != nil {
+			f.Close()
+			if err == errWouldBlock {
+				return nil, &LockedError{Path: path, PID: readPID(path)}
+			}
+			return nil, err
+		}
+
+		// The previous holder removes the file as it releases the lock,
+		// so the file locked may no longer be the one at path, and
+		// holding it would exclude nobody. Start over if so.
+		same, err := isFileAt(f, path)
+		if err != nil {
+			unlock(f)
+			f.Close()
+			return nil, err
+		}
+		if !same {
+			unlock(f)
+			f.Close()
+			continue
+		}
+
+		l := &Lock{path: path, f: f}
+		// A holder that released the lock would have removed the file,
+		// so a recorded PID belongs to a holder which died. It is read
+		// through f, as closing any other descriptor for the file
+		// releases a POSIX record lock, which is used on AIX.
+		if pid := filePID(f); pid != 0 && pid != os.Getpid() {
+			l.recovered = pid
+		}
+		// The PID is only informational, so failing to record it does
+		// not fail the lock.
+		if err := f.Truncate(0); err == nil {
+			f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		}
+		return l, nil
+	}
+}
+
+// isFileAt reports whether f is the file currently at path.
+func isFileAt(f *os.File, path string) (bool, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	pi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return os.SameFile(fi, pi), nil
+}
+
+// readPID returns the process ID recorded in the lock file at path, or zero.
+// It must not be used on a lock file held by this process.
+func readPID(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	return filePID(f)
+}
+
+// filePID returns the process ID recorded in the lock file f, or zero.
+func filePID(f *os.File) int {
+	b := make([]byte, 32)
+	n, err := f.ReadAt(b, 0)
+	if err != nil && err != io.EOF {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b[:n])))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+// Path returns the path of the locked file.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// RecoveredPID returns the process ID of a previous holder which exited
+// without releasing the lock, as recorded in the lock file when it was
+// acquired, or zero if the lock was not stale.
+func (l *Lock) RecoveredPID() int {
+	return l.recovered
+}
+
+// Close releases the lock and removes the lock file. Calling Close more than
+// once has no effect.
+func (l *Lock) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+
+	var err error
+	if removeWhileLocked {
+		// Removing the file before unlocking it means a process which
+		// was waiting for it will notice and retry on a new file.
+		err = os.Remove(l.path)
+	}
+	if uerr := unlock(l.f); err == nil {
+		err = uerr
+	}
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	if !removeWhileLocked {
+		// Another process may have opened the file already, in which
+		// case it cannot be removed; that process keeps using it.
+		os.Remove(l.path)
+	}
+	l.f = nil
+	return err
+}
@@ -0,0 +1,40 @@
+package fileutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// removeWhileLocked is set where open files can be removed.
+const removeWhileLocked = true
+
+// AIX has no flock, so a POSIX record lock covering the whole file is used.
+// Unlike flock, it does not exclude other Locks taken by the same process.
+func lock(f *os.File, block bool) error {
+	cmd := unix.F_SETLKW
+	if !block {
+		cmd = unix.F_SETLK
+	}
+	lk := unix.Flock_t{Type: unix.F_WRLCK}
+	for {
+		err := unix.FcntlFlock(f.Fd(), cmd, &lk)
+		switch err {
+		case nil:
+			return nil
+		case unix.EINTR:
+			continue
+		case unix.EAGAIN, unix.EACCES:
+			return errWouldBlock
+		}
+		return &os.PathError{Op: "fcntl", Path: f.Name(), Err: err}
+	}
+}
+
+func unlock(f *os.File) error {
+	lk := unix.Flock_t{Type: unix.F_UNLCK}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk); err != nil {
+		return &os.PathError{Op: "fcntl", Path: f.Name(), Err: err}
+	}
+	return nil
+}
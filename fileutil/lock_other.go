@@ -0,0 +1,22 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+)
+
+// removeWhileLocked is set where open files can be removed.
+const removeWhileLocked = true
+
+var errLockUnsupported = errors.New("file locking is not supported on this platform")
+
+func lock(f *os.File, block bool) error {
+	return &os.PathError{Op: "lock", Path: f.Name(), Err: errLockUnsupported}
+}
+
+func unlock(f *os.File) error {
+	return &os.PathError{Op: "unlock", Path: f.Name(), Err: errLockUnsupported}
+}
@@ -0,0 +1,133 @@
+package fileutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lock")
+
+	l, err := TryLockFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l.RecoveredPID() != 0 {
+		t.Errorf("fresh lock: want no recovered PID, got %d", l.RecoveredPID())
+	}
+
+	// Locks taken through separate opens exclude each other, even within
+	// one process.
+	_, err = TryLockFile(path)
+	var lerr *LockedError
+	if !errors.As(err, &lerr) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("want a LockedError, got %v", err)
+	}
+	if lerr.PID != os.Getpid() {
+		t.Errorf("want holder PID %d, got %d", os.Getpid(), lerr.PID)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("second Close: unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock file was not removed: %v", err)
+	}
+
+	l, err = TryLockFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error after release: %v", err)
+	}
+	l.Close()
+}
+
+func TestLockDirBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first, err := LockDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join(dir, DirLockName); first.Path() != want {
+		t.Errorf("want path %q, got %q", want, first.Path())
+	}
+
+	acquired := make(chan *Lock)
+	go func() {
+		l, err := LockDir(dir)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- l
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while the first is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case l := <-acquired:
+		if l == nil {
+			t.FailNow()
+		}
+		// The waiter must hold the lock on the file now at the path,
+		// not the one removed by the first holder.
+		if _, err := TryLockDir(dir); !errors.Is(err, ErrLocked) {
+			t.Errorf("want ErrLocked, got %v", err)
+		}
+		l.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not acquired after the first was released")
+	}
+}
+
+func TestLockStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lock")
+
+	// A lock file left behind by a process which died holding it.
+	if err := ioutil.WriteFile(path, []byte("999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := TryLockFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	if l.RecoveredPID() != 999999 {
+		t.Errorf("want recovered PID 999999, got %d", l.RecoveredPID())
+	}
+	if pid := filePID(l.f); pid != os.Getpid() {
+		t.Errorf("want our PID recorded, got %d", pid)
+	}
+	// Reading the PID doesn't release the lock.
+	_, err = TryLockFile(path)
+	if le, ok := err.(*LockedError); !ok || le.PID != os.Getpid() {
+		t.Errorf("want LockedError with our PID, got %v", err)
+	}
+}
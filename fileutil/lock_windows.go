@@ -0,0 +1,41 @@
+package fileutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// removeWhileLocked is set where open files can be removed.
+const removeWhileLocked = false
+
+// Windows locks are mandatory, so the lock covers a single byte far beyond
+// the end of the file; the PID written at its start stays readable.
+const (
+	lockOffset     = ^uint32(0)
+	lockOffsetHigh = ^uint32(0) >> 1
+)
+
+func lock(f *os.File, block bool) error {
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK)
+	if !block {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	ol := &windows.Overlapped{Offset: lockOffset, OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, ol)
+	switch err {
+	case nil:
+		return nil
+	case windows.ERROR_LOCK_VIOLATION:
+		return errWouldBlock
+	}
+	return &os.PathError{Op: "LockFileEx", Path: f.Name(), Err: err}
+}
+
+func unlock(f *os.File) error {
+	ol := &windows.Overlapped{Offset: lockOffset, OffsetHigh: lockOffsetHigh}
+	if err := windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol); err != nil {
+		return &os.PathError{Op: "UnlockFileEx", Path: f.Name(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+func copyOwner(dst string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return os.Lchown(dst, int(st.Uid), int(st.Gid))
+}
@@ -0,0 +1,62 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+)
+
+// Preallocate reserves disk space for the first size bytes of f, so that
+// files written incrementally, such as write-ahead logs, are laid out
+// contiguously and run out of space when they are preallocated rather than
+// part way through a write. Preallocate never shrinks f.
+//
+// If extendOnly is set, f is extended to size bytes where it is smaller,
+// with the space allocated as zeros. Otherwise the space is reserved beyond
+// the end of f without changing its size, and later writes extending f use
+// it.
+//
+// On Linux the space is allocated with fallocate(2). Where that is not
+// available, f is instead extended by writing zeros, and reserving space
+// without extending f is a no-op.
+func Preallocate(f *os.File, size int64, extendOnly bool) error {
+	if size < 0 {
+		return errors.New("negative preallocation size")
+	}
+	if size == 0 {
+		return nil
+	}
+	if err := preallocate(f, size, extendOnly); err != errPreallocUnsupported {
+		return err
+	}
+	if !extendOnly {
+		return nil
+	}
+	return zeroFill(f, size)
+}
+
+var errPreallocUnsupported = errors.New("preallocation is not supported")
+
+// zeroFill extends f to size bytes by writing zeros after its end. Unlike
+// truncating, which leaves a sparse file, this allocates the space.
+func zeroFill(f *os.File, size int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	off := fi.Size()
+	if off >= size {
+		return nil
+	}
+	zeros := make([]byte, 64*1024)
+	for off < size {
+		n := int64(len(zeros))
+		if size-off < n {
+			n = size - off
+		}
+		if _, err := f.WriteAt(zeros[:n], off); err != nil {
+			return err
+		}
+		off += n
+	}
+	return nil
+}
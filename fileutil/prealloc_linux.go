@@ -0,0 +1,28 @@
+package fileutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func preallocate(f *os.File, size int64, extendOnly bool) error {
+	mode := uint32(unix.FALLOC_FL_KEEP_SIZE)
+	if extendOnly {
+		mode = 0
+	}
+	for {
+		err := unix.Fallocate(int(f.Fd()), mode, 0, size)
+		switch err {
+		case nil:
+			return nil
+		case unix.EINTR:
+			continue
+		case unix.EOPNOTSUPP, unix.ENOSYS:
+			// Not supported by the filesystem, as on some network
+			// filesystems.
+			return errPreallocUnsupported
+		}
+		return &os.PathError{Op: "fallocate", Path: f.Name(), Err: err}
+	}
+}
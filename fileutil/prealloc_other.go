@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fileutil
+
+import "os"
+
+func preallocate(f *os.File, size int64, extendOnly bool) error {
+	return errPreallocUnsupported
+}
@@ -0,0 +1,86 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreallocate(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	tests := []struct {
+		initial    int64
+		size       int64
+		extendOnly bool
+		want       int64
+	}{
+		{0, 1 << 20, true, 1 << 20},
+		{100, 100000, true, 100000},
+		{0, 1 << 20, false, 0},
+		{100, 100000, false, 100},
+		// Preallocate never shrinks.
+		{100000, 100, true, 100000},
+		{100000, 100, false, 100000},
+		{100, 0, true, 100},
+	}
+	for i, tt := range tests {
+		f, err := os.Create(filepath.Join(dir, "file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Truncate(tt.initial); err != nil {
+			t.Fatal(err)
+		}
+		if err := Preallocate(f, tt.size, tt.extendOnly); err != nil {
+			t.Errorf("case %d: %v", i, err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != tt.want {
+			t.Errorf("case %d: want size %d, got %d", i, tt.want, fi.Size())
+		}
+		f.Close()
+	}
+
+	f, err := os.Create(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := Preallocate(f, -1, true); err == nil {
+		t.Errorf("expected error for negative size")
+	}
+}
+
+func TestZeroFill(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	f, err := os.Create(filepath.Join(dir, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zeroFill(f, 200000); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 200000 || string(b[:4]) != "data" {
+		t.Fatalf("unexpected contents of length %d", len(b))
+	}
+	for i, c := range b[4:] {
+		if c != 0 {
+			t.Fatalf("byte %d is %d, want 0", i+4, c)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package fileutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrTooLarge is returned, wrapped with the path and limit, by
+	// ReadFileMax when a file is larger than allowed.
+	ErrTooLarge = errors.New("file too large")
+
+	// ErrChecksumMismatch is returned, wrapped with the path and checksums,
+	// by ReadFileChecksum when a file does not have the expected contents.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// ReadFileMax reads the file at path as ioutil.ReadFile does, but fails with
+// ErrTooLarge rather than read more than maxBytes. The limit is enforced on
+// the data read, not only the size reported for the file, so files which
+// grow while being read, and special files, are bounded too.
+func ReadFileMax(path string, maxBytes int64) ([]byte, error) {
+	if maxBytes < 0 {
+		return nil, errors.New("negative file size limit")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tooLarge := func() error {
+		return fmt.Errorf("%s: %w (limit %d bytes)", path, ErrTooLarge, maxBytes)
+	}
+	var buf bytes.Buffer
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+		if fi.Size() > maxBytes {
+			return nil, tooLarge()
+		}
+		buf.Grow(int(fi.Size()))
+	}
+	// One byte more than the limit is read to tell if the file exceeds it.
+	limit := maxBytes
+	if limit < math.MaxInt64 {
+		limit++
+	}
+	if _, err := buf.ReadFrom(io.LimitReader(f, limit)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) > maxBytes {
+		return nil, tooLarge()
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadFileChecksum reads the file at path and verifies that its SHA-256
+// checksum is expectedSHA256, given in hex as printed by sha256sum. It fails
+// with ErrChecksumMismatch, returning no data, if it is not.
+func ReadFileChecksum(path string, expectedSHA256 string) ([]byte, error) {
+	want, err := hex.DecodeString(strings.TrimSpace(expectedSHA256))
+	if err != nil || len(want) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 checksum %q", expectedSHA256)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	got := sha256.Sum256(data)
+	if !bytes.Equal(got[:], want) {
+		return nil, fmt.Errorf("%s: %w: want sha256 %x, got %x", path, ErrChecksumMismatch, want, got)
+	}
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package fileutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileMax(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "f")
+	if err := ioutil.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		max     int64
+		tooLong bool
+	}{
+		{math.MaxInt64, false},
+		{100, false},
+		{10, false},
+		{9, true},
+		{0, true},
+	}
+	for i, tt := range tests {
+		data, err := ReadFileMax(path, tt.max)
+		if tt.tooLong {
+			if !errors.Is(err, ErrTooLarge) {
+				t.Errorf("case %d: want ErrTooLarge, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: %v", i, err)
+		} else if string(data) != "0123456789" {
+			t.Errorf("case %d: got %q", i, data)
+		}
+	}
+
+	if _, err := ReadFileMax(path, -1); err == nil || errors.Is(err, ErrTooLarge) {
+		t.Errorf("want error for negative limit, got %v", err)
+	}
+	if _, err := ReadFileMax(filepath.Join(dir, "missing"), 10); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
+
+func TestReadFileMaxUnknownSize(t *testing.T) {
+	// The size of a device is not known up front, and this one is endless.
+	if _, err := os.Stat("/dev/zero"); err != nil {
+		t.Skip("no /dev/zero")
+	}
+	if _, err := ReadFileMax("/dev/zero", 1000); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("want ErrTooLarge, got %v", err)
+	}
+}
+
+func TestReadFileChecksum(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "f")
+	if err := ioutil.WriteFile(path, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const sum = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+	tests := []struct {
+		sum     string
+		wantErr error
+	}{
+		{sum, nil},
+		{strings.ToUpper(sum) + "\n", nil},
+		{strings.Replace(sum, "5", "6", 1), ErrChecksumMismatch},
+	}
+	for i, tt := range tests {
+		data, err := ReadFileChecksum(path, tt.sum)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("case %d: want %v, got %v", i, tt.wantErr, err)
+		}
+		if tt.wantErr == nil && string(data) != "hello\n" {
+			t.Errorf("case %d: got %q", i, data)
+		}
+		if tt.wantErr != nil && data != nil {
+			t.Errorf("case %d: data returned despite mismatch", i)
+		}
+	}
+
+	for _, bad := range []string{"", "xyz", sum[:62]} {
+		if _, err := ReadFileChecksum(path, bad); err == nil || errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("%q: want invalid checksum error, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package fileutil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrShredIneffective is returned, wrapped in a ShredWarning, by
+// CheckShredEffective for a file on storage where overwriting it is unlikely
+// to destroy its contents.
+var ErrShredIneffective = errors.New("overwriting may not have destroyed the file contents")
+
+// ShredWarning is returned by CheckShredEffective when the storage a file is
+// on is suspected to keep copies of overwritten data. It wraps
+// ErrShredIneffective.
+type ShredWarning struct {
+	Path   string
+	Reason string
+}
+
+func (e *ShredWarning) Error() string {
+	return fmt.Sprintf("%s: %v: %s", e.Path, ErrShredIneffective, e.Reason)
+}
+
+func (e *ShredWarning) Unwrap() error {
+	return ErrShredIneffective
+}
+
+// ShredFile overwrites the regular file at path with random data passes
+// times, at least once, syncing it after each pass, and then truncates,
+// renames and removes it and syncs its directory.
+//
+// Overwriting in place does not destroy data on copy-on-write filesystems
+// such as btrfs and ZFS, nor reliably on solid state drives, which
+// CheckShredEffective detects. ShredFile is best effort there, and still
+// removes the file. Encrypting the storage is the only reliable protection.
+func ShredFile(path string, passes int) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s: cannot shred file of type %v", path, fi.Mode().Type())
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = overwrite(f, fi.Size(), passes)
+	if err == nil {
+		err = f.Truncate(0)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Hide the name as well as the contents.
+	dir := filepath.Dir(path)
+	hidden := filepath.Join(dir, randomName())
+	if err := os.Rename(path, hidden); err != nil {
+		return err
+	}
+	if err := os.Remove(hidden); err != nil {
+		return err
+	}
+	return SyncDir(dir)
+}
+
+// CheckShredEffective returns a *ShredWarning if the file at path is on
+// storage where ShredFile is unlikely to destroy its contents, currently
+// only detected on Linux, and nil if there is no known reason.
+func CheckShredEffective(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if reason := overwriteIneffective(f); reason != "" {
+		return &ShredWarning{Path: path, Reason: reason}
+	}
+	return nil
+}
+
+// overwrite writes passes rounds of random data over the first size bytes
+// of f.
+func overwrite(f *os.File, size int64, passes int) error {
+	if passes < 1 {
+		passes = 1
+	}
+	buf := make([]byte, 64*1024)
+	for i := 0; i < passes; i++ {
+		for off := int64(0); off < size; {
+			n := int64(len(buf))
+			if size-off < n {
+				n = size - off
+			}
+			if _, err := rand.Read(buf[:n]); err != nil {
+				return err
+			}
+			if _, err := f.WriteAt(buf[:n], off); err != nil {
+				return err
+			}
+			off += n
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func randomName() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "." + hex.EncodeToString(b)
+}
@@ -0,0 +1,50 @@
+package fileutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// cowFilesystems names the filesystems, by statfs(2) magic number, which
+// write modified data to new blocks rather than over the old ones.
+var cowFilesystems = map[uint32]string{
+	0x9123683e: "btrfs",
+	0x2fc12fc1: "zfs",
+	0xca451a4e: "bcachefs",
+	0xf2f52010: "f2fs",
+	0x3434:     "nilfs",
+}
+
+// overwriteIneffective returns why overwriting f is unlikely to destroy its
+// contents, or "" if there is no known reason.
+func overwriteIneffective(f *os.File) string {
+	fd := int(f.Fd())
+	var fs unix.Statfs_t
+	if err := unix.Fstatfs(fd, &fs); err == nil {
+		if name, ok := cowFilesystems[uint32(fs.Type)]; ok {
+			return fmt.Sprintf("%s writes changes to new blocks", name)
+		}
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err == nil && nonRotational(uint64(st.Dev)) {
+		return "solid state drives remap overwritten blocks"
+	}
+	return ""
+}
+
+// nonRotational reports whether the block device dev is known to be solid
+// state. The queue of a partition is found in its parent device.
+func nonRotational(dev uint64) bool {
+	base := fmt.Sprintf("/sys/dev/block/%d:%d/", unix.Major(dev), unix.Minor(dev))
+	for _, p := range []string{base + "queue/rotational", base + "../queue/rotational"} {
+		b, err := ioutil.ReadFile(p)
+		if err == nil {
+			return strings.TrimSpace(string(b)) == "0"
+		}
+	}
+	return false
+}
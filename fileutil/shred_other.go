@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fileutil
+
+import "os"
+
+func overwriteIneffective(f *os.File) string {
+	return ""
+}
@@ -0,0 +1,83 @@
+package fileutil
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShredFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	for i, passes := range []int{0, 1, 3} {
+		path := filepath.Join(dir, "key")
+		if err := ioutil.WriteFile(path, bytes.Repeat([]byte("secret"), 20000), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckShredEffective(path); err != nil && !errors.Is(err, ErrShredIneffective) {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if err := ShredFile(path, passes); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("case %d: file not removed: %v", i, err)
+		}
+		if names, _ := ioutil.ReadDir(dir); len(names) != 0 {
+			t.Errorf("case %d: left %s behind", i, names[0].Name())
+		}
+	}
+
+	if err := ShredFile(filepath.Join(dir, "missing"), 1); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+	if err := CheckShredEffective(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+	if err := ShredFile(dir, 1); err == nil {
+		t.Errorf("expected error shredding a directory")
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "key")
+	data := bytes.Repeat([]byte("secret"), 20000)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := overwrite(f, int64(len(data)), 2); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(data) {
+		t.Fatalf("size changed from %d to %d", len(data), len(got))
+	}
+	if bytes.Contains(got, []byte("secret")) {
+		t.Errorf("contents not overwritten")
+	}
+}
+
+func TestShredWarning(t *testing.T) {
+	err := error(&ShredWarning{Path: "/key", Reason: "btrfs writes changes to new blocks"})
+	if !errors.Is(err, ErrShredIneffective) {
+		t.Errorf("warning does not wrap ErrShredIneffective")
+	}
+	want := "/key: overwriting may not have destroyed the file contents: btrfs writes changes to new blocks"
+	if err.Error() != want {
+		t.Errorf("want=%q got=%q", want, err.Error())
+	}
+}
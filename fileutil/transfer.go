@@ -0,0 +1,30 @@
+package fileutil
+
+import (
+	"errors"
+	"io"
+)
+
+// Transfer copies from src to dst until EOF or an error, as io.Copy does,
+// but on Linux the data is moved within the kernel where both ends allow it:
+// with copy_file_range(2) between regular files, with sendfile(2) from a
+// regular file to anything else, such as a socket, and with splice(2) from
+// a pipe or socket. Otherwise, or if the kernel or filesystem refuses, the
+// data is copied with io.Copy.
+//
+// Only ends implementing syscall.Conn, such as *os.File, *net.TCPConn and
+// *net.UnixConn, are candidates; wrapped readers and writers are always
+// copied with io.Copy. Files are read and written at, and advance, their
+// current offsets.
+func Transfer(dst io.Writer, src io.Reader) (int64, error) {
+	written, err := transfer(dst, src)
+	if err != errTransferFallback {
+		return written, err
+	}
+	n, err := io.Copy(dst, src)
+	return written + n, err
+}
+
+// errTransferFallback is returned by transfer when the rest of a copy has to
+// be made with io.Copy.
+var errTransferFallback = errors.New("zero-copy transfer unsupported")
@@ -0,0 +1,250 @@
+package fileutil
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxTransferChunk bounds each system call, so that a large copy into a
+// socket does not hold up other writes for long.
+const maxTransferChunk = 4 << 20
+
+func transfer(dst io.Writer, src io.Reader) (int64, error) {
+	dc, ok := dst.(syscall.Conn)
+	if !ok {
+		return 0, errTransferFallback
+	}
+	sc, ok := src.(syscall.Conn)
+	if !ok {
+		return 0, errTransferFallback
+	}
+	draw, err := dc.SyscallConn()
+	if err != nil {
+		return 0, errTransferFallback
+	}
+	sraw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, errTransferFallback
+	}
+
+	if !isRegularFile(src) {
+		// splice is made non-blocking and waits on the runtime poller,
+		// which doesn't manage blocking descriptors such as os.Stdin.
+		if !nonBlocking(sraw) || (!isRegularFile(dst) && !nonBlocking(draw)) {
+			return 0, errTransferFallback
+		}
+		return splice(dst, draw, sraw)
+	}
+	if isRegularFile(dst) {
+		n, err := copyFileRange(draw, sraw)
+		if err != errTransferFallback {
+			return n, err
+		}
+	}
+	return sendfile(draw, sraw)
+}
+
+func isRegularFile(v interface{}) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// nonBlocking reports whether c is in non-blocking mode, as descriptors
+// managed by the runtime poller are.
+func nonBlocking(c syscall.RawConn) bool {
+	var flags int
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		flags, err = unix.FcntlInt(fd, unix.F_GETFL, 0)
+	}); cerr != nil || err != nil {
+		return false
+	}
+	return flags&unix.O_NONBLOCK != 0
+}
+
+// canFallBack reports whether err from the first call of a zero-copy system
+// call means that it is not supported for the files involved.
+func canFallBack(err error) bool {
+	switch err {
+	case unix.ENOSYS, unix.EINVAL, unix.EXDEV, unix.EOPNOTSUPP, unix.EPERM, unix.EBADF:
+		return true
+	}
+	return false
+}
+
+// transferError converts err from the system call named op, made once
+// written bytes had been copied.
+func transferError(op string, written int64, err error) error {
+	if written == 0 && canFallBack(err) {
+		return errTransferFallback
+	}
+	return os.NewSyscallError(op, err)
+}
+
+func copyFileRange(dst, src syscall.RawConn) (written int64, err error) {
+	cerr := src.Control(func(sfd uintptr) {
+		cerr := dst.Control(func(dfd uintptr) {
+			for {
+				n, e := unix.CopyFileRange(int(sfd), nil, int(dfd), nil, maxTransferChunk, 0)
+				switch {
+				case e == unix.EINTR:
+					continue
+				case e != nil:
+					err = transferError("copy_file_range", written, e)
+					return
+				case n == 0:
+					// Some files, such as those in /proc, read as
+					// empty here.
+					if written == 0 {
+						err = errTransferFallback
+					}
+					return
+				}
+				written += int64(n)
+			}
+		})
+		if err == nil {
+			err = cerr
+		}
+	})
+	if err == nil {
+		err = cerr
+	}
+	return written, err
+}
+
+func sendfile(dst, src syscall.RawConn) (written int64, err error) {
+	cerr := src.Control(func(sfd uintptr) {
+		werr := dst.Write(func(dfd uintptr) bool {
+			for {
+				n, e := unix.Sendfile(int(dfd), int(sfd), nil, maxTransferChunk)
+				if n > 0 {
+					written += int64(n)
+				}
+				switch {
+				case e == unix.EINTR:
+					continue
+				case e == unix.EAGAIN:
+					return false
+				case e != nil:
+					err = transferError("sendfile", written, e)
+					return true
+				case n == 0:
+					if written == 0 {
+						err = errTransferFallback
+					}
+					return true
+				}
+			}
+		})
+		if err == nil {
+			err = werr
+		}
+	})
+	if err == nil {
+		err = cerr
+	}
+	return written, err
+}
+
+// splice moves data from src to dst through a pipe, as splice(2) needs one
+// end of each call to be a pipe.
+func splice(w io.Writer, dst, src syscall.RawConn) (written int64, err error) {
+	var p [2]int
+	if err := unix.Pipe2(p[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		return 0, errTransferFallback
+	}
+	defer unix.Close(p[0])
+	defer unix.Close(p[1])
+	const flags = unix.SPLICE_F_MOVE | unix.SPLICE_F_NONBLOCK
+
+	for {
+		// The pipe is empty, so EAGAIN means that src has no data.
+		var n int64
+		var serr error
+		rerr := src.Read(func(sfd uintptr) bool {
+			for {
+				n, serr = splice64(int(sfd), p[1], maxTransferChunk, flags)
+				if serr == unix.EINTR {
+					continue
+				}
+				return serr != unix.EAGAIN
+			}
+		})
+		switch {
+		case serr != nil:
+			return written, transferError("splice", written, serr)
+		case rerr != nil:
+			return written, rerr
+		case n == 0:
+			return written, nil
+		}
+
+		for n > 0 {
+			// The pipe is not empty, so EAGAIN means that dst is full.
+			var m int64
+			var derr error
+			werr := dst.Write(func(dfd uintptr) bool {
+				for {
+					m, derr = splice64(p[0], int(dfd), int(n), flags)
+					if derr == unix.EINTR {
+						continue
+					}
+					return derr != unix.EAGAIN
+				}
+			})
+			if m > 0 {
+				written += m
+				n -= m
+			}
+			switch {
+			case derr != nil && written == 0 && canFallBack(derr):
+				// dst can't be spliced to, and the data already read
+				// from src is in the pipe.
+				m, err := drainPipe(w, p[0], n)
+				return written + m, err
+			case derr != nil:
+				return written, os.NewSyscallError("splice", derr)
+			case werr != nil:
+				return written, werr
+			}
+		}
+	}
+}
+
+// splice64 calls splice(2) without offsets. The type of its result varies
+// between architectures.
+func splice64(rfd, wfd, n, flags int) (int64, error) {
+	m, err := unix.Splice(rfd, nil, wfd, nil, n, flags)
+	return int64(m), err
+}
+
+// drainPipe writes the n bytes in the pipe read end fd to w. It returns
+// errTransferFallback once they are written, for the rest of src to be
+// copied with io.Copy.
+func drainPipe(w io.Writer, fd int, n int64) (int64, error) {
+	buf := make([]byte, n)
+	var read int
+	for read < len(buf) {
+		m, err := unix.Read(fd, buf[read:])
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, os.NewSyscallError("read", err)
+		}
+		read += m
+	}
+	m, err := w.Write(buf)
+	if err != nil {
+		return int64(m), err
+	}
+	return int64(m), errTransferFallback
+}
@@ -0,0 +1,176 @@
+package fileutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpPair returns the two ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := <-accepted
+	if s == nil {
+		t.FailNow()
+	}
+	return c.(*net.TCPConn), s.(*net.TCPConn)
+}
+
+func TestTransferSendfile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	data := bytes.Repeat([]byte("sendfile"), 200000)
+	path := filepath.Join(dir, "src")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	c, s := tcpPair(t)
+	defer s.Close()
+	received := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(s)
+		received <- b
+	}()
+
+	n, err := transfer(c, src)
+	c.Close()
+	if err != nil {
+		t.Fatalf("sendfile not used: %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("want %d bytes written, got %d", len(data), n)
+	}
+	if got := <-received; !bytes.Equal(got, data) {
+		t.Errorf("received %d bytes, not the file", len(got))
+	}
+}
+
+func TestTransferSplice(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	data := bytes.Repeat([]byte("splice"), 200000)
+
+	c, s := tcpPair(t)
+	defer s.Close()
+	go func() {
+		c.Write(data)
+		c.Close()
+	}()
+
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	n, err := transfer(dst, s)
+	if err != nil {
+		t.Fatalf("splice not used: %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("want %d bytes written, got %d", len(data), n)
+	}
+	got, err := ioutil.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("file has %d bytes, not the data sent", len(got))
+	}
+}
+
+func TestTransferSpliceDrain(t *testing.T) {
+	// Splicing into a file opened for appending fails, after the data has
+	// been read into the pipe.
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		w.Write([]byte("first "))
+		w.Write([]byte("second"))
+		w.Close()
+	}()
+	dst, err := os.OpenFile(filepath.Join(dir, "dst"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	n, err := Transfer(dst, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 12 || string(got) != "first second" {
+		t.Errorf("got %d bytes %q", n, got)
+	}
+}
+
+func TestTransferSpliceBlocking(t *testing.T) {
+	// A blocking descriptor, such as os.Stdin, isn't managed by the
+	// runtime poller, so it can't wait for splice to have data.
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	var p [2]int
+	if err := unix.Pipe2(p[:], unix.O_CLOEXEC); err != nil {
+		t.Fatal(err)
+	}
+	r := os.NewFile(uintptr(p[0]), "r")
+	defer r.Close()
+	w := os.NewFile(uintptr(p[1]), "w")
+	go func() {
+		w.Write([]byte("first "))
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("second"))
+		w.Close()
+	}()
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	n, err := Transfer(dst, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 12 || string(got) != "first second" {
+		t.Errorf("got %d bytes %q", n, got)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package fileutil
+
+import "io"
+
+func transfer(dst io.Writer, src io.Reader) (int64, error) {
+	return 0, errTransferFallback
+}
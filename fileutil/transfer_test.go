@@ -0,0 +1,75 @@
+package fileutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransferFiles(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	if err := ioutil.WriteFile(filepath.Join(dir, "src"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// Both files are used from their current offsets.
+	if _, err := src.Seek(10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.WriteString("header"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Transfer(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)-10) {
+		t.Errorf("want %d bytes written, got %d", len(data)-10, n)
+	}
+	got, err := ioutil.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append([]byte("header"), data[10:]...); !bytes.Equal(got, want) {
+		t.Errorf("unexpected contents of length %d", len(got))
+	}
+	if pos, _ := src.Seek(0, io.SeekCurrent); pos != int64(len(data)) {
+		t.Errorf("source offset is %d, want %d", pos, len(data))
+	}
+}
+
+func TestTransferFallback(t *testing.T) {
+	tests := []struct {
+		dst io.Writer
+		src io.Reader
+	}{
+		{&bytes.Buffer{}, strings.NewReader("some data")},
+		{&bytes.Buffer{}, io.LimitReader(strings.NewReader("some data"), 100)},
+	}
+	for i, tt := range tests {
+		n, err := Transfer(tt.dst, tt.src)
+		if err != nil {
+			t.Errorf("case %d: %v", i, err)
+		}
+		if got := tt.dst.(*bytes.Buffer).String(); n != 9 || got != "some data" {
+			t.Errorf("case %d: got %d bytes %q", i, n, got)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/pkg/timeutil"
+)
+
+const (
+	// DefaultWatchInterval is the default Interval of a Watcher.
+	DefaultWatchInterval = time.Second
+
+	// DefaultWatchDebounce is the default Debounce of a Watcher.
+	DefaultWatchDebounce = 100 * time.Millisecond
+)
+
+// EventOp is the kind of change reported by an Event.
+type EventOp int
+
+const (
+	// EventCreate reports a file which did not exist before.
+	EventCreate EventOp = iota + 1
+	// EventModify reports a file whose contents, metadata or identity
+	// changed, including a file which was replaced.
+	EventModify
+	// EventRemove reports a file which no longer exists.
+	EventRemove
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "create"
+	case EventModify:
+		return "modify"
+	case EventRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// Event is a change of a file in a watched directory.
+type Event struct {
+	// Path is the path of the file, within the watched directory.
+	Path string
+	Op   EventOp
+}
+
+// Watcher watches the files in a directory, not including subdirectories'
+// contents, for changes. The directory is rescanned every Interval, and on
+// Linux also as soon as inotify reports a change, so changes are seen
+// promptly where inotify works and eventually everywhere else, such as on
+// network filesystems.
+//
+// Changes are compared between scans rather than taken from inotify, so
+// they are reported the same way on every platform. Files are followed
+// through symlinks, so the symlink swaps used by Kubernetes to update secret
+// and config map volumes are reported as changes of the files themselves.
+type Watcher struct {
+	Dir string
+
+	// Interval is the time between scans. It defaults to
+	// DefaultWatchInterval.
+	Interval time.Duration
+
+	// Debounce is how long changes must stop for before they are
+	// reported, so that a file being written, or several files being
+	// replaced together, are reported once. It defaults to
+	// DefaultWatchDebounce.
+	Debounce time.Duration
+
+	// Clock defaults to timeutil.RealClock.
+	Clock timeutil.Clock
+
+	// Logger, if set, logs failures to scan the directory, and to use
+	// inotify, at WARNING.
+	Logger *capnslog.PackageLogger
+}
+
+// Watch watches the directory until ctx is done. The changes since the last
+// batch are sent as a batch, sorted by path, once Debounce has passed
+// without further changes. Changes which cancel out, such as a file created
+// and removed again, are not reported. The channel is closed once ctx is
+// done.
+//
+// The directory is first scanned when Watch is called, so files already in
+// it are not reported. If it does not exist, it is watched for, and its
+// files are reported as created when it appears.
+func (w *Watcher) Watch(ctx context.Context) <-chan []Event {
+	events := make(chan []Event)
+	base := w.scan()
+	go func() {
+		defer close(events)
+		w.run(ctx, base, events)
+	}()
+	return events
+}
+
+func (w *Watcher) run(ctx context.Context, base snapshot, events chan<- []Event) {
+	clock := w.Clock
+	if clock == nil {
+		clock = timeutil.RealClock
+	}
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	debounce := w.Debounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	// settled is signalled once debounce has passed without changes.
+	settled := make(chan struct{}, 1)
+	db := timeutil.DebounceWithClock(clock, debounce, func() {
+		select {
+		case settled <- struct{}{}:
+		default:
+		}
+	})
+	defer db.Stop()
+
+	var (
+		n       *notifier
+		useNote = true
+		last    = base
+	)
+	defer func() {
+		if n != nil {
+			n.Close()
+		}
+	}()
+
+	for {
+		if n == nil && useNote {
+			var err error
+			n, err = newNotifier(w.Dir)
+			switch {
+			case err == errNotifyUnsupported:
+				useNote = false
+			case err != nil && !os.IsNotExist(err):
+				w.logf(capnslog.WARNING, "failed to watch %s with inotify, polling: %v", w.Dir, err)
+				useNote = false
+			}
+		}
+		var wake <-chan struct{}
+		if n != nil {
+			wake = n.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-settled:
+			if batch := w.diff(base, last); len(batch) > 0 {
+				select {
+				case events <- batch:
+				case <-ctx.Done():
+					return
+				}
+			}
+			base = last
+			continue
+		case <-ticker.C():
+		case _, ok := <-wake:
+			if !ok {
+				// The directory was removed or renamed.
+				n.Close()
+				n = nil
+			}
+		}
+
+		cur := w.scan()
+		if !cur.equal(last) {
+			last = cur
+			// Changes since the last signal restart the wait.
+			select {
+			case <-settled:
+			default:
+			}
+			db.Call()
+		}
+	}
+}
+
+// errNotifyUnsupported is returned by newNotifier where there is no
+// inotify.
+var errNotifyUnsupported = errors.New("inotify is not supported")
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	fi os.FileInfo
+}
+
+func (s fileStamp) same(o fileStamp) bool {
+	return os.SameFile(s.fi, o.fi) &&
+		s.fi.ModTime().Equal(o.fi.ModTime()) &&
+		s.fi.Size() == o.fi.Size() &&
+		s.fi.Mode() == o.fi.Mode()
+}
+
+// snapshot maps the names of the files in a directory to their stamps.
+type snapshot map[string]fileStamp
+
+func (s snapshot) equal(o snapshot) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for name, st := range s {
+		if ost, ok := o[name]; !ok || !st.same(ost) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *Watcher) scan() snapshot {
+	d, err := os.Open(w.Dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.logf(capnslog.WARNING, "failed to scan %s: %v", w.Dir, err)
+		}
+		return nil
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		w.logf(capnslog.WARNING, "failed to scan %s: %v", w.Dir, err)
+	}
+
+	s := make(snapshot, len(names))
+	for _, name := range names {
+		path := filepath.Join(w.Dir, name)
+		fi, err := os.Stat(path)
+		if err != nil {
+			// A dangling symlink, or a file removed since it was
+			// listed.
+			if fi, err = os.Lstat(path); err != nil {
+				continue
+			}
+		}
+		s[name] = fileStamp{fi: fi}
+	}
+	return s
+}
+
+// diff returns the events which turned the directory from old into cur.
+func (w *Watcher) diff(old, cur snapshot) []Event {
+	var events []Event
+	add := func(name string, op EventOp) {
+		events = append(events, Event{Path: filepath.Join(w.Dir, name), Op: op})
+	}
+	for name, st := range cur {
+		if ost, ok := old[name]; !ok {
+			add(name, EventCreate)
+		} else if !st.same(ost) {
+			add(name, EventModify)
+		}
+	}
+	for name := range old {
+		if _, ok := cur[name]; !ok {
+			add(name, EventRemove)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	return events
+}
+
+func (w *Watcher) logf(l capnslog.LogLevel, format string, args ...interface{}) {
+	if w.Logger != nil {
+		w.Logger.Logf(l, format, args...)
+	}
+}
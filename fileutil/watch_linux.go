@@ -0,0 +1,65 @@
+package fileutil
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY |
+	unix.IN_ATTRIB | unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// notifier wakes a Watcher when inotify reports a change in its directory.
+// C is closed once the watch ends, as when the directory is removed.
+type notifier struct {
+	C chan struct{}
+	f *os.File
+}
+
+func newNotifier(dir string) (*notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		unix.Close(fd)
+		return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	// The descriptor is non-blocking, so reads wait in the runtime poller
+	// and are interrupted by Close.
+	n := &notifier{C: make(chan struct{}, 1), f: os.NewFile(uintptr(fd), "inotify")}
+	go n.read()
+	return n, nil
+}
+
+func (n *notifier) read() {
+	defer close(n.C)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		m, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+		ended := false
+		for off := 0; off+unix.SizeofInotifyEvent <= m; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				ended = true
+			}
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+		}
+		select {
+		case n.C <- struct{}{}:
+		default:
+		}
+		if ended {
+			return
+		}
+	}
+}
+
+func (n *notifier) Close() {
+	n.f.Close()
+}
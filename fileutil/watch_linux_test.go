@@ -0,0 +1,54 @@
+package fileutil
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+func TestWatcherInotify(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	staging, cleanup2 := tempDir(t)
+	defer cleanup2()
+	if err := ioutil.WriteFile(filepath.Join(staging, "f"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The directory is not polled within the test, so only inotify
+	// reports the change.
+	clock := timeutil.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &Watcher{Dir: dir, Interval: time.Hour, Clock: clock}
+	events := w.Watch(ctx)
+	clock.BlockUntil(1)
+
+	if err := os.Rename(filepath.Join(staging, "f"), filepath.Join(dir, "f")); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the debounce timer.
+	clock.BlockUntil(2)
+	select {
+	case batch := <-events:
+		t.Fatalf("batch %v sent before debouncing", batch)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(DefaultWatchDebounce)
+	select {
+	case batch := <-events:
+		want := []Event{{filepath.Join(dir, "f"), EventCreate}}
+		if !reflect.DeepEqual(batch, want) {
+			t.Errorf("want=%v got=%v", want, batch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch after debouncing")
+	}
+}
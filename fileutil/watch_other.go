@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package fileutil
+
+type notifier struct {
+	C chan struct{}
+}
+
+func newNotifier(dir string) (*notifier, error) {
+	return nil, errNotifyUnsupported
+}
+
+func (n *notifier) Close() {}
@@ -0,0 +1,164 @@
+package fileutil
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collectEvents receives batches from events until the last change seen for
+// every path in want is as given, or times out.
+func collectEvents(t *testing.T, events <-chan []Event, want map[string]EventOp) {
+	got := make(map[string]EventOp)
+	timeout := time.After(5 * time.Second)
+	for !reflect.DeepEqual(got, want) {
+		select {
+		case batch, ok := <-events:
+			if !ok {
+				t.Fatal("events closed")
+			}
+			for _, ev := range batch {
+				got[ev.Path] = ev.Op
+			}
+		case <-timeout:
+			t.Fatalf("want=%v got=%v", want, got)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	for _, name := range []string{"keep", "old"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &Watcher{Dir: dir, Interval: 10 * time.Millisecond, Debounce: 20 * time.Millisecond}
+	events := w.Watch(ctx)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "new"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "keep"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "old")); err != nil {
+		t.Fatal(err)
+	}
+	collectEvents(t, events, map[string]EventOp{
+		filepath.Join(dir, "new"):  EventCreate,
+		filepath.Join(dir, "keep"): EventModify,
+		filepath.Join(dir, "old"):  EventRemove,
+	})
+
+	// A symlink swap, as made by Kubernetes, changes the files linked
+	// through it.
+	for _, v := range []string{"v1", "v2"} {
+		if err := os.Mkdir(filepath.Join(dir, v), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, v, "cert"), []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..data/cert", filepath.Join(dir, "cert")); err != nil {
+		t.Fatal(err)
+	}
+	collectEvents(t, events, map[string]EventOp{
+		filepath.Join(dir, "v1"):     EventCreate,
+		filepath.Join(dir, "v2"):     EventCreate,
+		filepath.Join(dir, "..data"): EventCreate,
+		filepath.Join(dir, "cert"):   EventCreate,
+	})
+	if err := os.Symlink("v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	collectEvents(t, events, map[string]EventOp{
+		filepath.Join(dir, "..data"): EventModify,
+		filepath.Join(dir, "cert"):   EventModify,
+	})
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatcherMissingDir(t *testing.T) {
+	parent, cleanup := tempDir(t)
+	defer cleanup()
+	dir := filepath.Join(parent, "dir")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &Watcher{Dir: dir, Interval: 10 * time.Millisecond, Debounce: 20 * time.Millisecond}
+	events := w.Watch(ctx)
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "f"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	collectEvents(t, events, map[string]EventOp{filepath.Join(dir, "f"): EventCreate})
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	collectEvents(t, events, map[string]EventOp{filepath.Join(dir, "f"): EventRemove})
+
+	// Watching resumes once the directory is back.
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "g"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	collectEvents(t, events, map[string]EventOp{filepath.Join(dir, "g"): EventCreate})
+}
+
+func TestWatcherDiff(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	w := &Watcher{Dir: dir}
+	stamp := func(name, data string) fileStamp {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fileStamp{fi: fi}
+	}
+	a, b, b2 := stamp("a", "a"), stamp("b", "b"), stamp("b", "bb")
+
+	tests := []struct {
+		old, cur snapshot
+		want     []Event
+	}{
+		{nil, nil, nil},
+		{snapshot{"a": a}, snapshot{"a": a}, nil},
+		{nil, snapshot{"b": b, "a": a}, []Event{{filepath.Join(dir, "a"), EventCreate}, {filepath.Join(dir, "b"), EventCreate}}},
+		{snapshot{"a": a, "b": b}, snapshot{"b": b2}, []Event{{filepath.Join(dir, "a"), EventRemove}, {filepath.Join(dir, "b"), EventModify}}},
+	}
+	for i, tt := range tests {
+		if got := w.diff(tt.old, tt.cur); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, got)
+		}
+	}
+}
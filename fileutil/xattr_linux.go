@@ -0,0 +1,71 @@
+package fileutil
+
+import (
+	"bytes"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func copyXattrs(src, dst string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		value, err := getXattr(src, name)
+		if err != nil {
+			return err
+		}
+		if err := unix.Lsetxattr(dst, name, value, 0); err != nil {
+			return &os.PathError{Op: "lsetxattr", Path: dst, Err: err}
+		}
+	}
+	return nil
+}
+
+func listXattrs(path string) ([]string, error) {
+	for {
+		n, err := unix.Llistxattr(path, nil)
+		if err == unix.ENOTSUP {
+			return nil, nil
+		} else if err != nil {
+			return nil, &os.PathError{Op: "llistxattr", Path: path, Err: err}
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n)
+		n, err = unix.Llistxattr(path, buf)
+		if err == unix.ERANGE {
+			// The list grew between the calls.
+			continue
+		} else if err != nil {
+			return nil, &os.PathError{Op: "llistxattr", Path: path, Err: err}
+		}
+		var names []string
+		for _, name := range bytes.Split(buf[:n], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		n, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: "lgetxattr", Path: path, Err: err}
+		}
+		buf := make([]byte, n)
+		n, err = unix.Lgetxattr(path, name, buf)
+		if err == unix.ERANGE {
+			continue
+		} else if err != nil {
+			return nil, &os.PathError{Op: "lgetxattr", Path: path, Err: err}
+		}
+		return buf[:n], nil
+	}
+}
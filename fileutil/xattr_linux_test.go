@@ -0,0 +1,33 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCopyTreeXattrs(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	src := filepath.Join(dir, "src")
+	if err := ioutil.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Lsetxattr(src, "user.fileutil", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes are not supported here: %v", err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	if err := CopyTree(src, dst, CopyOptions{PreserveXattrs: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value, err := getXattr(dst, "user.fileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "value" {
+		t.Errorf("want=%q got=%q", "value", value)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package fileutil
+
+func copyXattrs(src, dst string) error {
+	return errXattrsUnsupported
+}
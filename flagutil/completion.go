@@ -0,0 +1,137 @@
+package flagutil
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrUnknownShell = errors.New("unsupported shell: must be one of bash, zsh, fish")
+
+// WriteCompletion writes a completion script for the given shell ("bash",
+// "zsh" or "fish") covering every flag registered in fs, for a program
+// invoked as prog. Flags backed by an EnumFlag complete to their allowed
+// values and flags backed by a PathFlag complete to file names.
+func WriteCompletion(w io.Writer, shell, prog string, fs *flag.FlagSet) error {
+	var buf bytes.Buffer
+	switch shell {
+	case "bash":
+		writeBashCompletion(&buf, prog, fs)
+	case "zsh":
+		writeZshCompletion(&buf, prog, fs)
+	case "fish":
+		writeFishCompletion(&buf, prog, fs)
+	default:
+		return ErrUnknownShell
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+type boolFlag interface {
+	IsBoolFlag() bool
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	bf, ok := f.Value.(boolFlag)
+	return ok && bf.IsBoolFlag()
+}
+
+func writeBashCompletion(w io.Writer, prog string, fs *flag.FlagSet) {
+	fn := "_" + shellIdent(prog) + "_completion"
+	var names []string
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "\tlocal prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "\tcase \"$prev\" in\n")
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+		switch v := f.Value.(type) {
+		case *EnumFlag:
+			fmt.Fprintf(w, "\t-%s|--%s)\n", f.Name, f.Name)
+			fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(v.Allowed(), " ")))
+			fmt.Fprintf(w, "\t\treturn 0\n\t\t;;\n")
+		case *PathFlag:
+			fmt.Fprintf(w, "\t-%s|--%s)\n", f.Name, f.Name)
+			fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+			fmt.Fprintf(w, "\t\treturn 0\n\t\t;;\n")
+		}
+	})
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -F %s %s\n", fn, prog)
+}
+
+func writeZshCompletion(w io.Writer, prog string, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "#compdef %s\n\n", prog)
+	fmt.Fprintf(w, "_arguments")
+	fs.VisitAll(func(f *flag.Flag) {
+		spec := fmt.Sprintf("-%s[%s]", f.Name, zshEscape(f.Usage))
+		switch v := f.Value.(type) {
+		case *EnumFlag:
+			spec += fmt.Sprintf(":%s:(%s)", f.Name, strings.Join(v.Allowed(), " "))
+		case *PathFlag:
+			spec += fmt.Sprintf(":%s:_files", f.Name)
+		default:
+			if !isBoolFlag(f) {
+				spec += fmt.Sprintf(":%s: ", f.Name)
+			}
+		}
+		fmt.Fprintf(w, " \\\n\t%s", shellQuote(spec))
+	})
+	fmt.Fprintf(w, "\n")
+}
+
+func writeFishCompletion(w io.Writer, prog string, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(w, "complete -c %s -o %s", prog, f.Name)
+		if f.Usage != "" {
+			fmt.Fprintf(w, " -d %s", fishQuote(f.Usage))
+		}
+		switch v := f.Value.(type) {
+		case *EnumFlag:
+			fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(v.Allowed(), " ")))
+		case *PathFlag:
+			fmt.Fprintf(w, " -r -F")
+		default:
+			if !isBoolFlag(f) {
+				fmt.Fprintf(w, " -x")
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	})
+}
+
+// shellIdent turns s into something usable as a shell function name.
+func shellIdent(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// shellQuote single-quotes s for bash and zsh.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// zshEscape escapes the characters with special meaning inside an
+// _arguments description.
+func zshEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`)
+	return r.Replace(s)
+}
+
+// fishQuote single-quotes s for fish, which uses backslash escapes inside
+// single quotes.
+func fishQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "'", `\'`)
+	return "'" + r.Replace(s) + "'"
+}
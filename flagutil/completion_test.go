@@ -0,0 +1,78 @@
+package flagutil
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func newCompletionFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("testing", flag.ExitOnError)
+	fs.Bool("verbose", false, "be chatty")
+	fs.Var(NewEnumFlag("json", "json", "text"), "format", "output [format]")
+	var p PathFlag
+	fs.Var(&p, "config", "config file")
+	fs.String("name", "", "it's a name")
+	return fs
+}
+
+func TestWriteCompletion(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{
+			shell: "bash",
+			want: []string{
+				"_my_tool_completion() {",
+				"\t-format|--format)\n\t\tCOMPREPLY=($(compgen -W 'json text' -- \"$cur\"))",
+				"\t-config|--config)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))",
+				"COMPREPLY=($(compgen -W '-config -format -name -verbose' -- \"$cur\"))",
+				"complete -F _my_tool_completion my-tool\n",
+			},
+		},
+		{
+			shell: "zsh",
+			want: []string{
+				"#compdef my-tool\n",
+				`'-format[output \[format\]]:format:(json text)'`,
+				`'-config[config file]:config:_files'`,
+				`'-name[it'\''s a name]:name: '`,
+				`'-verbose[be chatty]'`,
+			},
+		},
+		{
+			shell: "fish",
+			want: []string{
+				"complete -c my-tool -o format -d 'output [format]' -x -a 'json text'\n",
+				"complete -c my-tool -o config -d 'config file' -r -F\n",
+				`complete -c my-tool -o name -d 'it\'s a name' -x` + "\n",
+				"complete -c my-tool -o verbose -d 'be chatty'\n",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteCompletion(&buf, tt.shell, "my-tool", newCompletionFlagSet()); err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("case %d: output does not contain %q:\n%s", i, w, buf.String())
+			}
+		}
+	}
+}
+
+func TestWriteCompletionUnknownShell(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCompletion(&buf, "tcsh", "my-tool", newCompletionFlagSet()); err != ErrUnknownShell {
+		t.Errorf("want ErrUnknownShell, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
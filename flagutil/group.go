@@ -0,0 +1,365 @@
+package flagutil
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/pkg/multierror"
+)
+
+// Group is a named group of the flags of a FlagSet, such as the flags of
+// one component of a program. The flags of a group are named after it, so
+// the flag cert-file of the group tls, itself in the group server, is
+// server.tls.cert-file. Groups are made from the root group of a FlagSet,
+// as returned by NewGroups, whose flags are not prefixed.
+type Group struct {
+	// Description heads the flags of the group in its usage.
+	Description string
+
+	// EnvPrefix is prefixed to the names of the environment variables
+	// of the flags of the group by SetFlagsFromEnv. It defaults to the
+	// EnvPrefix of the parent group and the name of the group, in
+	// uppercase and joined by an underscore, such as MYPROJ_TLS.
+	EnvPrefix string
+
+	fs       *flag.FlagSet
+	name     string // of the group, without its parents
+	parent   *Group
+	children []*Group
+	flags    []string // the local names of the flags of the group
+}
+
+// NewGroups returns the root group of fs, whose EnvPrefix is envPrefix.
+func NewGroups(fs *flag.FlagSet, envPrefix string) *Group {
+	return &Group{fs: fs, EnvPrefix: envPrefix}
+}
+
+// Group returns the group name within g, making it with description if it
+// does not exist yet. name must not contain dots.
+func (g *Group) Group(name, description string) *Group {
+	if name == "" || strings.Contains(name, ".") {
+		panic(fmt.Sprintf("flagutil: invalid group name %q", name))
+	}
+	for _, c := range g.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &Group{Description: description, fs: g.fs, name: name, parent: g}
+	g.children = append(g.children, c)
+	return c
+}
+
+// Name returns the full name of g, such as server.tls, or "" for a root
+// group.
+func (g *Group) Name() string {
+	if g.parent == nil {
+		return ""
+	}
+	if p := g.parent.Name(); p != "" {
+		return p + "." + g.name
+	}
+	return g.name
+}
+
+// FlagName returns the name of the flag name of g in its FlagSet.
+func (g *Group) FlagName(name string) string {
+	if p := g.Name(); p != "" {
+		return p + "." + name
+	}
+	return name
+}
+
+func (g *Group) envPrefix() string {
+	if g.EnvPrefix != "" || g.parent == nil {
+		return g.EnvPrefix
+	}
+	return envName(g.parent.envPrefix(), g.name)
+}
+
+// envName returns the environment variable of the flag name with prefix.
+func envName(prefix, name string) string {
+	name = strings.ToUpper(strings.Replace(name, "-", "_", -1))
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// Lookup returns the flag name of g, or nil if there is none.
+func (g *Group) Lookup(name string) *flag.Flag {
+	return g.fs.Lookup(g.FlagName(name))
+}
+
+// Var defines a flag of g, as flag.FlagSet.Var does.
+func (g *Group) Var(value flag.Value, name, usage string) {
+	g.fs.Var(value, g.FlagName(name), usage)
+	g.flags = append(g.flags, name)
+}
+
+// StringVar defines a string flag of g.
+func (g *Group) StringVar(p *string, name, value, usage string) {
+	g.fs.StringVar(p, g.FlagName(name), value, usage)
+	g.flags = append(g.flags, name)
+}
+
+// String defines a string flag of g, and returns the address of its value.
+func (g *Group) String(name, value, usage string) *string {
+	p := new(string)
+	g.StringVar(p, name, value, usage)
+	return p
+}
+
+// BoolVar defines a bool flag of g.
+func (g *Group) BoolVar(p *bool, name string, value bool, usage string) {
+	g.fs.BoolVar(p, g.FlagName(name), value, usage)
+	g.flags = append(g.flags, name)
+}
+
+// Bool defines a bool flag of g, and returns the address of its value.
+func (g *Group) Bool(name string, value bool, usage string) *bool {
+	p := new(bool)
+	g.BoolVar(p, name, value, usage)
+	return p
+}
+
+// IntVar defines an int flag of g.
+func (g *Group) IntVar(p *int, name string, value int, usage string) {
+	g.fs.IntVar(p, g.FlagName(name), value, usage)
+	g.flags = append(g.flags, name)
+}
+
+// Int defines an int flag of g, and returns the address of its value.
+func (g *Group) Int(name string, value int, usage string) *int {
+	p := new(int)
+	g.IntVar(p, name, value, usage)
+	return p
+}
+
+// DurationVar defines a time.Duration flag of g.
+func (g *Group) DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
+	g.fs.DurationVar(p, g.FlagName(name), value, usage)
+	g.flags = append(g.flags, name)
+}
+
+// Duration defines a time.Duration flag of g, and returns the address of
+// its value.
+func (g *Group) Duration(name string, value time.Duration, usage string) *time.Duration {
+	p := new(time.Duration)
+	g.DurationVar(p, name, value, usage)
+	return p
+}
+
+// visit calls fn with each group within g, and g itself first, in the order
+// they were made.
+func (g *Group) visit(fn func(*Group)) {
+	fn(g)
+	for _, c := range g.children {
+		c.visit(fn)
+	}
+}
+
+// localFlags returns the flags of g, sorted by name, with the names they
+// have within g. The root group also has the flags of its FlagSet which are
+// not in any group.
+func (g *Group) localFlags() []*flag.Flag {
+	var flags []*flag.Flag
+	if g.parent == nil {
+		grouped := make(map[string]bool)
+		for _, c := range g.children {
+			c.visit(func(c *Group) {
+				for _, name := range c.flags {
+					grouped[c.FlagName(name)] = true
+				}
+			})
+		}
+		g.fs.VisitAll(func(f *flag.Flag) {
+			if !grouped[f.Name] {
+				flags = append(flags, f)
+			}
+		})
+		return flags
+	}
+	for _, name := range g.flags {
+		f := *g.Lookup(name)
+		f.Name = name
+		flags = append(flags, &f)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// SetFlagsFromEnv sets the flags of g and of the groups within it which are
+// not already set from environment variables, named as by the
+// SetFlagsFromEnv function with the EnvPrefix of their group. For example,
+// if the root group's EnvPrefix is MYPROJ, tls.cert-file is set from
+// MYPROJ_TLS_CERT_FILE. Every invalid value is reported in the returned
+// error, after the other flags have been set.
+func (g *Group) SetFlagsFromEnv() error {
+	alreadySet := g.alreadySet()
+	var errs multierror.Error
+	g.visit(func(c *Group) {
+		prefix := c.envPrefix()
+		for _, f := range c.localFlags() {
+			name := c.FlagName(f.Name)
+			if alreadySet[name] {
+				continue
+			}
+			key := envName(prefix, f.Name)
+			val := os.Getenv(key)
+			if val == "" {
+				continue
+			}
+			if err := g.fs.Set(name, val); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", val, key, err))
+			}
+		}
+	})
+	return errs.ErrorOrNil()
+}
+
+func (g *Group) alreadySet() map[string]bool {
+	alreadySet := make(map[string]bool)
+	g.fs.Visit(func(f *flag.Flag) {
+		alreadySet[f.Name] = true
+	})
+	return alreadySet
+}
+
+// SetFlagsFromSection sets the flags of g which are not already set from a
+// section of a configuration file, as decoded from JSON or YAML. The keys
+// of section are the names of flags within g, with underscores read as
+// dashes, and nested mappings are flattened by joining their keys with
+// dots, so that
+//
+//	tls:
+//	  cert_file: server.pem
+//
+// given to the group server sets server.tls.cert-file. A sequence is given
+// to its flag as a comma-separated list, as accepted by StringSliceFlag,
+// and a null value as the empty string.
+// Every key without a flag, and every value its flag rejects, is reported
+// in the returned error, after the other flags have been set.
+func (g *Group) SetFlagsFromSection(section map[string]interface{}) error {
+	vals := make(map[string]string)
+	flattenSection("", section, vals)
+	keys := make([]string, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	alreadySet := g.alreadySet()
+	var errs multierror.Error
+	for _, key := range keys {
+		name := g.FlagName(strings.Replace(key, "_", "-", -1))
+		if g.fs.Lookup(name) == nil {
+			errs = append(errs, fmt.Errorf("unknown key %s", g.FlagName(key)))
+			continue
+		}
+		if alreadySet[name] {
+			continue
+		}
+		if err := g.fs.Set(name, vals[key]); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", vals[key], g.FlagName(key), err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func flattenSection(prefix string, v interface{}, vals map[string]string) {
+	join := func(k interface{}) string {
+		if prefix == "" {
+			return fmt.Sprint(k)
+		}
+		return prefix + "." + fmt.Sprint(k)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			flattenSection(join(k), sub, vals)
+		}
+	case map[interface{}]interface{}:
+		for k, sub := range v {
+			flattenSection(join(k), sub, vals)
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = sectionScalar(item)
+		}
+		vals[prefix] = strings.Join(items, ",")
+	default:
+		vals[prefix] = sectionScalar(v)
+	}
+}
+
+// sectionScalar formats a decoded scalar as a flag value, as
+// yamlutil.SetFlagsFromYAML does. A null value, as written by a YAML key
+// with nothing after it, is the empty string.
+func sectionScalar(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// PrintDefaults writes the usage of the flags of g to w, as
+// flag.FlagSet.PrintDefaults does, followed by that of each group within
+// it under its description. The environment variable of each flag is
+// given with its usage. A program prints its flags by group with
+//
+//	fs.Usage = func() {
+//		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+//		root.PrintDefaults(fs.Output())
+//	}
+func (g *Group) PrintDefaults(w io.Writer) {
+	first := true
+	g.visit(func(c *Group) {
+		flags := c.localFlags()
+		if len(flags) == 0 {
+			return
+		}
+		if c.parent != nil {
+			if !first {
+				fmt.Fprintln(w)
+			}
+			heading := c.Description
+			if heading == "" {
+				heading = c.Name()
+			}
+			fmt.Fprintf(w, "%s:\n", heading)
+		}
+		first = false
+		prefix := c.envPrefix()
+		for _, f := range flags {
+			printFlag(w, f, c.FlagName(f.Name), envName(prefix, f.Name))
+		}
+	})
+}
+
+// printFlag writes the usage of f, named name, in the format of
+// flag.FlagSet.PrintDefaults.
+func printFlag(w io.Writer, f *flag.Flag, name, env string) {
+	typ, usage := flag.UnquoteUsage(f)
+	line := "  -" + name
+	if typ != "" {
+		line += " " + typ
+	}
+	line += "\n    \t" + strings.Replace(usage, "\n", "\n    \t", -1)
+	switch f.DefValue {
+	case "", "0", "false", "0s", "[]":
+	default:
+		if typ == "string" {
+			line += fmt.Sprintf(" (default %q)", f.DefValue)
+		} else {
+			line += fmt.Sprintf(" (default %v)", f.DefValue)
+		}
+	}
+	fmt.Fprintf(w, "%s [$%s]\n", line, env)
+}
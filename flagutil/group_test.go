@@ -0,0 +1,161 @@
+package flagutil
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestGroups() (*flag.FlagSet, *Group) {
+	fs := flag.NewFlagSet("testing", flag.ContinueOnError)
+	root := NewGroups(fs, "MYPROJ")
+	root.Bool("verbose", false, "log more")
+	fs.String("legacy", "", "registered without a group")
+
+	server := root.Group("server", "Server options")
+	server.String("listen-addr", ":8080", "address to listen on")
+	server.Duration("read-timeout", 5*time.Second, "timeout for reading requests")
+	tls := server.Group("tls", "TLS options")
+	tls.String("cert-file", "", "certificate file")
+	var ciphers StringSliceFlag
+	tls.Var(&ciphers, "ciphers", "allowed cipher suites")
+
+	log := root.Group("log", "")
+	log.EnvPrefix = "LOGGING"
+	log.Int("level", 2, "log level")
+	return fs, root
+}
+
+func TestGroupNames(t *testing.T) {
+	fs, root := newTestGroups()
+	tls := root.Group("server", "").Group("tls", "")
+	if got := tls.Name(); got != "server.tls" {
+		t.Errorf("unexpected group name %q", got)
+	}
+	if tls.Lookup("cert-file") != fs.Lookup("server.tls.cert-file") || tls.Lookup("cert-file") == nil {
+		t.Errorf("flag not registered under its group")
+	}
+	if root.Group("server", "other").Description != "Server options" {
+		t.Errorf("existing group not returned")
+	}
+}
+
+func TestGroupSetFlagsFromEnv(t *testing.T) {
+	fs, root := newTestGroups()
+
+	os.Clearenv()
+	os.Setenv("MYPROJ_VERBOSE", "true")
+	os.Setenv("MYPROJ_LEGACY", "old")
+	os.Setenv("MYPROJ_SERVER_LISTEN_ADDR", ":9090")
+	os.Setenv("MYPROJ_SERVER_TLS_CERT_FILE", "env.pem")
+	os.Setenv("MYPROJ_SERVER_READ_TIMEOUT", "soon")
+	os.Setenv("LOGGING_LEVEL", "4")
+	defer os.Clearenv()
+	if err := fs.Parse([]string{"-server.tls.cert-file", "flag.pem"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := root.SetFlagsFromEnv()
+	if err == nil || !strings.Contains(err.Error(), "MYPROJ_SERVER_READ_TIMEOUT") {
+		t.Errorf("unexpected error %v", err)
+	}
+	for name, want := range map[string]string{
+		"verbose":              "true",
+		"legacy":               "old",
+		"server.listen-addr":   ":9090",
+		"server.tls.cert-file": "flag.pem",
+		"log.level":            "4",
+	} {
+		if got := fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("flag %q=%q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGroupSetFlagsFromSection(t *testing.T) {
+	fs, root := newTestGroups()
+	if err := fs.Parse([]string{"-server.listen-addr", ":1"}); err != nil {
+		t.Fatal(err)
+	}
+	section := map[string]interface{}{
+		"listen_addr":  ":2",
+		"read-timeout": "1m",
+		"tls": map[interface{}]interface{}{
+			"cert_file": "server.pem",
+			"ciphers":   []interface{}{"a", "b"},
+		},
+		"tls.unknown": true,
+	}
+	err := root.Group("server", "").SetFlagsFromSection(section)
+	if err == nil || !strings.Contains(err.Error(), "unknown key server.tls.unknown") {
+		t.Errorf("unexpected error %v", err)
+	}
+	for name, want := range map[string]string{
+		"server.listen-addr":   ":1",
+		"server.read-timeout":  "1m0s",
+		"server.tls.cert-file": "server.pem",
+		"server.tls.ciphers":   "[a b]",
+	} {
+		if got := fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("flag %q=%q, want %q", name, got, want)
+		}
+	}
+
+	if err := root.Group("log", "").SetFlagsFromSection(map[string]interface{}{"level": "high"}); err == nil {
+		t.Errorf("invalid value accepted")
+	}
+
+	// Nulls are empty, as in SetFlagsFromYAML.
+	fs, root = newTestGroups()
+	section = map[string]interface{}{
+		"read-timeout": "1m",
+		"tls": map[interface{}]interface{}{
+			"cert_file": nil,
+			"ciphers":   []interface{}{"a", nil},
+		},
+	}
+	if err := root.Group("server", "").SetFlagsFromSection(section); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"server.tls.cert-file": "",
+		"server.tls.ciphers":   "[a ]",
+	} {
+		if got := fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("flag %q=%q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGroupPrintDefaults(t *testing.T) {
+	_, root := newTestGroups()
+	var buf bytes.Buffer
+	root.PrintDefaults(&buf)
+	want := `  -legacy string
+    	registered without a group [$MYPROJ_LEGACY]
+  -verbose
+    	log more [$MYPROJ_VERBOSE]
+
+Server options:
+  -server.listen-addr string
+    	address to listen on (default ":8080") [$MYPROJ_SERVER_LISTEN_ADDR]
+  -server.read-timeout duration
+    	timeout for reading requests (default 5s) [$MYPROJ_SERVER_READ_TIMEOUT]
+
+TLS options:
+  -server.tls.cert-file string
+    	certificate file [$MYPROJ_SERVER_TLS_CERT_FILE]
+  -server.tls.ciphers value
+    	allowed cipher suites [$MYPROJ_SERVER_TLS_CIPHERS]
+
+log:
+  -log.level int
+    	log level (default 2) [$LOGGING_LEVEL]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -42,3 +42,77 @@ func (ss *StringSliceFlag) Set(v string) error {
 	*ss = strings.Split(v, ",")
 	return nil
 }
+
+// EnumFlag parses a string after asserting that it is one of a fixed set of
+// allowed values. This type implements the flag.Value interface.
+type EnumFlag struct {
+	allowed         []string
+	caseInsensitive bool
+	val             string
+}
+
+// NewEnumFlag returns an EnumFlag which only accepts the given values. The
+// flag initially holds def, which should normally be one of allowed.
+func NewEnumFlag(def string, allowed ...string) *EnumFlag {
+	return &EnumFlag{allowed: allowed, val: def}
+}
+
+// NewCaseInsensitiveEnumFlag is like NewEnumFlag, but values are matched
+// without regard to case. The selected value is always reported using the
+// spelling given in allowed.
+func NewCaseInsensitiveEnumFlag(def string, allowed ...string) *EnumFlag {
+	f := &EnumFlag{allowed: allowed, caseInsensitive: true, val: def}
+	if v, ok := f.match(def); ok {
+		f.val = v
+	}
+	return f
+}
+
+// Value returns the currently selected value.
+func (f *EnumFlag) Value() string {
+	return f.val
+}
+
+// Allowed returns the set of values accepted by this flag.
+func (f *EnumFlag) Allowed() []string {
+	return append([]string(nil), f.allowed...)
+}
+
+func (f *EnumFlag) Set(v string) error {
+	match, ok := f.match(v)
+	if !ok {
+		return fmt.Errorf("must be one of: %s", strings.Join(f.allowed, ", "))
+	}
+	f.val = match
+	return nil
+}
+
+func (f *EnumFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.val
+}
+
+func (f *EnumFlag) match(v string) (string, bool) {
+	for _, a := range f.allowed {
+		if a == v || (f.caseInsensitive && strings.EqualFold(a, v)) {
+			return a, true
+		}
+	}
+	return "", false
+}
+
+// PathFlag holds a filesystem path. It behaves like a plain string flag, but
+// lets tools such as the completion generators know the value names a file.
+// This type implements the flag.Value interface.
+type PathFlag string
+
+func (p *PathFlag) String() string {
+	return string(*p)
+}
+
+func (p *PathFlag) Set(v string) error {
+	*p = PathFlag(v)
+	return nil
+}
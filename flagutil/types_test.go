@@ -55,3 +55,39 @@ func TestStringSliceFlag(t *testing.T) {
 		}
 	}
 }
+
+func TestEnumFlag(t *testing.T) {
+	tests := []struct {
+		flag    *EnumFlag
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{flag: NewEnumFlag("a", "a", "b"), input: "b", want: "b"},
+		{flag: NewEnumFlag("a", "a", "b"), input: "a", want: "a"},
+		{flag: NewEnumFlag("a", "a", "b"), input: "B", want: "a", wantErr: true},
+		{flag: NewEnumFlag("a", "a", "b"), input: "", want: "a", wantErr: true},
+		{flag: NewCaseInsensitiveEnumFlag("a", "a", "Bee"), input: "BEE", want: "Bee"},
+		{flag: NewCaseInsensitiveEnumFlag("a", "a", "Bee"), input: "c", want: "a", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		err := tt.flag.Set(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("case %d: err=%v, wantErr=%t", i, err, tt.wantErr)
+		}
+		if got := tt.flag.Value(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+		if got := tt.flag.String(); got != tt.want {
+			t.Errorf("case %d: String() want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestEnumFlagDefaultCanonical(t *testing.T) {
+	f := NewCaseInsensitiveEnumFlag("DEBUG", "debug", "info")
+	if got := f.Value(); got != "debug" {
+		t.Errorf("want=%q got=%q", "debug", got)
+	}
+}
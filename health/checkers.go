@@ -0,0 +1,222 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+// DefaultCheckerTimeout is the default Timeout of the checkers in this
+// package.
+const DefaultCheckerTimeout = 2 * time.Second
+
+// contextTimeout bounds ctx by d, or by DefaultCheckerTimeout if d is 0.
+func contextTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		d = DefaultCheckerTimeout
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// call is a run of a function passed to await.
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+var (
+	callsMu sync.Mutex
+	calls   = make(map[string]*call)
+)
+
+// await runs f, which cannot be interrupted, and returns its result or, if
+// ctx is done first, ctx.Err(). f is then left to finish in the background.
+// While f is running, later calls with the same key wait for its result
+// instead, so that a hung file system doesn't cost a goroutine per check.
+func await(ctx context.Context, key string, f func() (interface{}, error)) (interface{}, error) {
+	callsMu.Lock()
+	c, ok := calls[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		calls[key] = c
+		go func() {
+			c.val, c.err = f()
+			callsMu.Lock()
+			delete(calls, key)
+			callsMu.Unlock()
+			close(c.done)
+		}()
+	}
+	callsMu.Unlock()
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TCPCheck checks that a TCP connection can be made to Addr, such as
+// "db:5432".
+type TCPCheck struct {
+	Addr string
+
+	// Timeout defaults to DefaultCheckerTimeout.
+	Timeout time.Duration
+}
+
+// Check dials c.Addr and closes the connection.
+func (c TCPCheck) Check(ctx context.Context) error {
+	ctx, cancel := contextTimeout(ctx, c.Timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// HTTPCheck checks that a GET of URL answers with the expected status.
+type HTTPCheck struct {
+	URL string
+
+	// Status is the expected status code, and defaults to 200.
+	Status int
+
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Timeout defaults to DefaultCheckerTimeout.
+	Timeout time.Duration
+}
+
+// Check makes the request, and discards the body of the response.
+func (c HTTPCheck) Check(ctx context.Context) error {
+	ctx, cancel := contextTimeout(ctx, c.Timeout)
+	defer cancel()
+	req, err := http.NewRequest("GET", c.URL, nil)
+	if err != nil {
+		return err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096))
+
+	want := c.Status
+	if want == 0 {
+		want = http.StatusOK
+	}
+	if res.StatusCode != want {
+		return fmt.Errorf("GET %s: unexpected status %s", c.URL, res.Status)
+	}
+	return nil
+}
+
+// Pinger is a database connection, such as a *sql.DB or *sql.Conn.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// SQLCheck checks that DB can be reached.
+type SQLCheck struct {
+	DB Pinger
+
+	// Timeout defaults to DefaultCheckerTimeout.
+	Timeout time.Duration
+}
+
+// Check pings c.DB.
+func (c SQLCheck) Check(ctx context.Context) error {
+	ctx, cancel := contextTimeout(ctx, c.Timeout)
+	defer cancel()
+	return c.DB.PingContext(ctx)
+}
+
+// DiskCheck checks the free space of the file system holding Path.
+type DiskCheck struct {
+	Path string
+
+	// MinFree is the number of bytes which must be available to
+	// unprivileged users.
+	MinFree uint64
+
+	// Timeout defaults to DefaultCheckerTimeout. It guards against file
+	// systems, such as network mounts, on which the query hangs.
+	Timeout time.Duration
+}
+
+// Check fails if less than c.MinFree bytes are free.
+func (c DiskCheck) Check(ctx context.Context) error {
+	ctx, cancel := contextTimeout(ctx, c.Timeout)
+	defer cancel()
+	free, err := await(ctx, "statfs "+c.Path, func() (interface{}, error) {
+		return diskFree(c.Path)
+	})
+	if err != nil {
+		return err
+	}
+	if free := free.(uint64); free < c.MinFree {
+		return fmt.Errorf("%s: %d bytes free, want at least %d", c.Path, free, c.MinFree)
+	}
+	return nil
+}
+
+// ErrStale is returned by a FileCheck when the file has not been modified
+// within its MaxAge.
+var ErrStale = errors.New("file is stale")
+
+// FileCheck checks that the file at Path exists and, if MaxAge is set, that
+// it was modified within MaxAge, as with a heartbeat or a periodically
+// refreshed cache.
+type FileCheck struct {
+	Path   string
+	MaxAge time.Duration
+
+	// Timeout defaults to DefaultCheckerTimeout.
+	Timeout time.Duration
+
+	// Clock defaults to timeutil.RealClock.
+	Clock timeutil.Clock
+}
+
+// Check stats c.Path. A stale file is reported with an error wrapping
+// ErrStale.
+func (c FileCheck) Check(ctx context.Context) error {
+	ctx, cancel := contextTimeout(ctx, c.Timeout)
+	defer cancel()
+	fi, err := await(ctx, "stat "+c.Path, func() (interface{}, error) {
+		return os.Stat(c.Path)
+	})
+	if err != nil {
+		return err
+	}
+	if c.MaxAge <= 0 {
+		return nil
+	}
+	clock := c.Clock
+	if clock == nil {
+		clock = timeutil.RealClock
+	}
+	if age := clock.Since(fi.(os.FileInfo).ModTime()); age > c.MaxAge {
+		return fmt.Errorf("%s: %w: modified %s ago", c.Path, ErrStale, age.Round(time.Second))
+	}
+	return nil
+}
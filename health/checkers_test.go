@@ -0,0 +1,178 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+func TestTCPCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	ctx := context.Background()
+	if err := (TCPCheck{Addr: addr}).Check(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	l.Close()
+	if err := (TCPCheck{Addr: addr}).Check(ctx); err == nil {
+		t.Errorf("dial to a closed port succeeded")
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		check HTTPCheck
+		ok    bool
+	}{
+		{HTTPCheck{URL: ts.URL + "/ok"}, true},
+		{HTTPCheck{URL: ts.URL + "/teapot"}, false},
+		{HTTPCheck{URL: ts.URL + "/teapot", Status: http.StatusTeapot}, true},
+		{HTTPCheck{URL: ts.URL + "/slow", Timeout: 10 * time.Millisecond}, false},
+		{HTTPCheck{URL: "://bad"}, false},
+	}
+	for i, tt := range tests {
+		err := tt.check.Check(context.Background())
+		if (err == nil) != tt.ok {
+			t.Errorf("case %d: unexpected result %v", i, err)
+		}
+	}
+}
+
+type pinger struct{ err error }
+
+func (p pinger) PingContext(ctx context.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSQLCheck(t *testing.T) {
+	errDown := errors.New("database is down")
+	if err := (SQLCheck{DB: pinger{errDown}}).Check(context.Background()); err != errDown {
+		t.Errorf("want %v, got %v", errDown, err)
+	}
+	err := SQLCheck{DB: pinger{}, Timeout: 10 * time.Millisecond}.Check(context.Background())
+	if err != context.DeadlineExceeded {
+		t.Errorf("want timeout, got %v", err)
+	}
+}
+
+func TestAwaitShared(t *testing.T) {
+	// Checks of a hung file system share the one stuck call.
+	var n int32
+	release := make(chan struct{})
+	f := func() (interface{}, error) {
+		atomic.AddInt32(&n, 1)
+		<-release
+		return "done", nil
+	}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if _, err := await(ctx, "hung", f); err != context.DeadlineExceeded {
+			t.Errorf("call %d: want DeadlineExceeded, got %v", i, err)
+		}
+		cancel()
+	}
+	if got := atomic.LoadInt32(&n); got != 1 {
+		t.Errorf("f called %d times, want 1", got)
+	}
+
+	close(release)
+	for {
+		v, err := await(context.Background(), "hung", f)
+		if err != nil || v != "done" {
+			t.Fatalf("unexpected result %v, %v", v, err)
+		}
+		if atomic.LoadInt32(&n) == 2 {
+			// The finished call has been forgotten.
+			break
+		}
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	if err := (DiskCheck{Path: dir, MinFree: 1}).Check(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (DiskCheck{Path: dir, MinFree: math.MaxUint64}).Check(ctx); err == nil {
+		t.Errorf("impossible free space requirement met")
+	}
+	if err := (DiskCheck{Path: filepath.Join(dir, "missing")}).Check(ctx); !os.IsNotExist(errors.Unwrap(err)) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
+
+func TestFileCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "heartbeat")
+	ctx := context.Background()
+
+	if err := (FileCheck{Path: path}).Check(ctx); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1e9, 0)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	clock := timeutil.NewFakeClock(mtime.Add(time.Minute))
+
+	tests := []struct {
+		maxAge time.Duration
+		err    error
+	}{
+		{0, nil},
+		{time.Hour, nil},
+		{time.Second, ErrStale},
+	}
+	for i, tt := range tests {
+		err := FileCheck{Path: path, MaxAge: tt.maxAge, Clock: clock}.Check(ctx)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("case %d: want %v, got %v", i, tt.err, err)
+		}
+	}
+}
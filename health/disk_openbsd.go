@@ -0,0 +1,21 @@
+package health
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskFree returns the bytes available to unprivileged users on the file
+// system holding path.
+func diskFree(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	// The available blocks go negative once the reserve is in use.
+	if st.F_bavail < 0 {
+		return 0, nil
+	}
+	return uint64(st.F_bavail) * uint64(st.F_bsize), nil
+}
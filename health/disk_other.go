@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package health
+
+import "errors"
+
+// diskFree is not supported on this platform.
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("disk free space is not supported on this platform")
+}
@@ -0,0 +1,20 @@
+//go:build netbsd || solaris
+// +build netbsd solaris
+
+package health
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskFree returns the bytes available to unprivileged users on the file
+// system holding path.
+func diskFree(path string) (uint64, error) {
+	var st unix.Statvfs_t
+	if err := unix.Statvfs(path, &st); err != nil {
+		return 0, &os.PathError{Op: "statvfs", Path: path, Err: err}
+	}
+	return uint64(st.Bavail) * uint64(st.Frsize), nil
+}
@@ -0,0 +1,20 @@
+//go:build aix || darwin || dragonfly || freebsd || linux
+// +build aix darwin dragonfly freebsd linux
+
+package health
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskFree returns the bytes available to unprivileged users on the file
+// system holding path.
+func diskFree(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,138 @@
+// Package healthprom exports the results of the checks of a health.Registry
+// as Prometheus metrics, so that alerts can act on the health of
+// dependencies without scraping the JSON report.
+package healthprom
+
+import (
+	"sync"
+
+	"github.com/coreos/pkg/health"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics is a prometheus.Collector of the results of health checks, which
+// it is given by its Observe method. For a check named by the label
+// "check", it exports:
+//
+//	health_check_up                        1 if the last run passed, 0 if not
+//	health_check_degraded                  1 if the last run passed degraded
+//	health_check_duration_seconds          the duration of the last run
+//	health_check_consecutive_failures      the failed runs since it last passed
+//	health_check_failures_total            the failed runs in all
+//
+// A typical use is
+//
+//	m := healthprom.New()
+//	prometheus.MustRegister(m)
+//	r := &health.Registry{Observe: m.Observe}
+type Metrics struct {
+	up          *prometheus.GaugeVec
+	degraded    *prometheus.GaugeVec
+	duration    *prometheus.GaugeVec
+	consecutive *prometheus.GaugeVec
+	failures    *prometheus.CounterVec
+
+	// mu serializes Observe, so that the metrics of a check describe the
+	// same run.
+	mu sync.Mutex
+}
+
+// New returns Metrics without any observed checks.
+func New() *Metrics {
+	labels := []string{"check"}
+	return &Metrics{
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "health_check_up",
+			Help: "Whether the last run of the health check passed.",
+		}, labels),
+		degraded: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "health_check_degraded",
+			Help: "Whether the last run of the health check passed, but degraded.",
+		}, labels),
+		duration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "health_check_duration_seconds",
+			Help: "Duration of the last run of the health check.",
+		}, labels),
+		consecutive: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "health_check_consecutive_failures",
+			Help: "Number of runs of the health check which failed since it last passed.",
+		}, labels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "health_check_failures_total",
+			Help: "Total number of runs of the health check which failed.",
+		}, labels),
+	}
+}
+
+// Register creates Metrics, registers them with reg and sets them to
+// observe the checks of r, which must not be in use yet. A function r
+// already observes with is still called.
+func Register(reg prometheus.Registerer, r *health.Registry) (*Metrics, error) {
+	m := New()
+	if err := reg.Register(m); err != nil {
+		return nil, err
+	}
+	if prev := r.Observe; prev != nil {
+		r.Observe = func(name string, res health.CheckResult) {
+			prev(name, res)
+			m.Observe(name, res)
+		}
+	} else {
+		r.Observe = m.Observe
+	}
+	return m, nil
+}
+
+// Observe records the result of a run of the check name. It has the
+// signature of health.Registry.Observe.
+func (m *Metrics) Observe(name string, res health.CheckResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.duration.WithLabelValues(name).Set(res.Duration.Seconds())
+	// Export the counter from the first run, so that rate() sees the
+	// first failure.
+	failures := m.failures.WithLabelValues(name)
+	degraded := 0.0
+	if res.Status == health.StatusWarn {
+		degraded = 1
+	}
+	m.degraded.WithLabelValues(name).Set(degraded)
+	if res.Status != health.StatusError {
+		m.up.WithLabelValues(name).Set(1)
+		m.consecutive.WithLabelValues(name).Set(0)
+		return
+	}
+	m.up.WithLabelValues(name).Set(0)
+	m.consecutive.WithLabelValues(name).Inc()
+	failures.Inc()
+}
+
+// Forget removes the metrics of the check name, as when it is unregistered.
+func (m *Metrics) Forget(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range []*prometheus.MetricVec{m.up.MetricVec, m.degraded.MetricVec, m.duration.MetricVec, m.consecutive.MetricVec, m.failures.MetricVec} {
+		v.DeleteLabelValues(name)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.up.Describe(ch)
+	m.degraded.Describe(ch)
+	m.duration.Describe(ch)
+	m.consecutive.Describe(ch)
+	m.failures.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.up.Collect(ch)
+	m.degraded.Collect(ch)
+	m.duration.Collect(ch)
+	m.consecutive.Collect(ch)
+	m.failures.Collect(ch)
+}
@@ -0,0 +1,81 @@
+package healthprom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/coreos/pkg/health"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	var r health.Registry
+	var observed []string
+	r.Observe = func(name string, res health.CheckResult) {
+		observed = append(observed, name)
+	}
+	m, err := Register(reg, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var err2 error
+	r.Register("db", func(context.Context) error { return err2 })
+
+	tests := []struct {
+		err         error
+		up          float64
+		degraded    float64
+		consecutive float64
+		failures    float64
+	}{
+		{nil, 1, 0, 0, 0},
+		{errors.New("down"), 0, 0, 1, 1},
+		{health.Warn(errors.New("slow")), 1, 1, 0, 1},
+		{errors.New("down"), 0, 0, 1, 2},
+		{errors.New("down"), 0, 0, 2, 3},
+		{nil, 1, 0, 0, 3},
+	}
+	for i, tt := range tests {
+		err2 = tt.err
+		r.Run(context.Background())
+		for _, v := range []struct {
+			name      string
+			got, want float64
+		}{
+			{"up", testutil.ToFloat64(m.up.WithLabelValues("db")), tt.up},
+			{"degraded", testutil.ToFloat64(m.degraded.WithLabelValues("db")), tt.degraded},
+			{"consecutive", testutil.ToFloat64(m.consecutive.WithLabelValues("db")), tt.consecutive},
+			{"failures", testutil.ToFloat64(m.failures.WithLabelValues("db")), tt.failures},
+		} {
+			if v.got != v.want {
+				t.Errorf("case %d: %s: want=%v got=%v", i, v.name, v.want, v.got)
+			}
+		}
+	}
+	if len(observed) != len(tests) {
+		t.Errorf("previous observer called %d times, want %d", len(observed), len(tests))
+	}
+
+	want := `
+# HELP health_check_up Whether the last run of the health check passed.
+# TYPE health_check_up gauge
+health_check_up{check="db"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "health_check_up"); err != nil {
+		t.Error(err)
+	}
+
+	m.Forget("db")
+	if n := testutil.CollectAndCount(m); n != 0 {
+		t.Errorf("%d metrics left after Forget", n)
+	}
+
+	if _, err := Register(reg, &health.Registry{}); err == nil {
+		t.Errorf("registering twice succeeded")
+	}
+}
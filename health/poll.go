@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+// Poll runs the checks with an Interval in the background until ctx is
+// done, each on its own schedule, including checks registered while
+// polling. Meanwhile probes report the last result of each, with its age,
+// rather than run it, so that a slow dependency never holds up a probe.
+// Until a check has first completed, it is reported as failing.
+//
+// Poll may not be called again until it has returned. Once it returns,
+// background checks are run when probed again.
+func (r *Registry) Poll(ctx context.Context) {
+	r.mu.Lock()
+	if r.pollCtx != nil {
+		r.mu.Unlock()
+		panic("health: Registry is already polling")
+	}
+	r.pollCtx = ctx
+	for _, e := range r.checks {
+		r.startLocked(e)
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+
+	r.mu.Lock()
+	r.pollCtx = nil
+	for _, e := range r.checks {
+		e.stop()
+	}
+	r.mu.Unlock()
+}
+
+// startLocked starts the background runs of e, if it is a background check
+// and r is polling. r.mu must be held.
+func (r *Registry) startLocked(e *entry) {
+	if e.Interval <= 0 || r.pollCtx == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(r.pollCtx)
+	e.cancel = cancel
+	go r.poll(ctx, e)
+}
+
+func (r *Registry) poll(ctx context.Context, e *entry) {
+	clock := r.clock()
+	var ticker timeutil.Ticker
+	for {
+		res := r.runCheck(ctx, e)
+		if ctx.Err() != nil {
+			// Stopped, rather than failed.
+			break
+		}
+		e.mu.Lock()
+		e.result, e.at = &res, clock.Now()
+		e.mu.Unlock()
+
+		// The interval starts once the first run has completed.
+		if ticker == nil {
+			ticker = clock.NewTicker(e.Interval)
+			defer ticker.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// stop stops the background runs of e, if any. The registry's lock must be
+// held.
+func (e *entry) stop() {
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+}
+
+// cached returns the last result of the background check e.
+func (e *entry) cached(clock timeutil.Clock) CheckResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.result == nil {
+		return CheckResult{Status: StatusError, Error: "not checked yet", Cached: true}
+	}
+	res := *e.result
+	res.Cached = true
+	res.Age = clock.Since(e.at)
+	return res
+}
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+func TestRegistryPoll(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	r := Registry{Clock: clock}
+
+	var calls, fail int32
+	r.RegisterCheck(CheckConfig{
+		Name: "db",
+		Func: func(context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			if atomic.LoadInt32(&fail) != 0 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+		Interval: time.Minute,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Poll(ctx)
+		close(done)
+	}()
+	// The ticker is created once the first run has completed.
+	clock.BlockUntil(1)
+
+	clock.Advance(10 * time.Second)
+	res := r.Run(context.Background()).Checks["db"]
+	if res.Status != StatusOK || !res.Cached || res.Age != 10*time.Second {
+		t.Errorf("unexpected cached result %+v", res)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("check run %d times, want 1", n)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	clock.Advance(50 * time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Run(context.Background()).Healthy() {
+		if time.Now().After(deadline) {
+			t.Fatal("failure not picked up after the interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if res := r.Run(context.Background()).Checks["db"]; res.Error != "connection refused" || res.Age != 0 {
+		t.Errorf("unexpected result after the interval %+v", res)
+	}
+
+	cancel()
+	<-done
+	before := atomic.LoadInt32(&calls)
+	res = r.Run(context.Background()).Checks["db"]
+	if res.Cached || atomic.LoadInt32(&calls) != before+1 {
+		t.Errorf("check not run directly after polling stopped: %+v", res)
+	}
+}
+
+func TestRegistryPollPending(t *testing.T) {
+	var r Registry
+	release := make(chan struct{})
+	r.RegisterCheck(CheckConfig{
+		Name: "slow",
+		Func: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+		Interval: time.Hour,
+	})
+	r.Register("fast", passing)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Poll(ctx)
+		close(done)
+	}()
+	waitPolling(t, &r)
+
+	rep := r.Run(context.Background())
+	if res := rep.Checks["slow"]; res.Status != StatusError || res.Error != "not checked yet" {
+		t.Errorf("unexpected pending result %+v", res)
+	}
+	if res := rep.Checks["fast"]; res.Status != StatusOK || res.Cached {
+		t.Errorf("unexpected result of check without interval %+v", res)
+	}
+
+	close(release)
+	cancel()
+	<-done
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	var r Registry
+	r.RegisterCheck(CheckConfig{
+		Name: "hung",
+		Func: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Timeout: 10 * time.Millisecond,
+	})
+	if res := r.Run(context.Background()).Checks["hung"]; res.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func waitPolling(t *testing.T, r *Registry) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.RLock()
+		polling := r.pollCtx != nil
+		r.mu.RUnlock()
+		if polling {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("registry not polling")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
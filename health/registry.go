@@ -0,0 +1,362 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/pkg/httputil"
+	"github.com/coreos/pkg/timeutil"
+)
+
+const (
+	// StatusOK reports a passing check, or that all checks passed.
+	StatusOK = "ok"
+	// StatusWarn reports a check which passed but is degraded, as when a
+	// dependency is slow, or that a check is degraded and none failed.
+	StatusWarn = "warn"
+	// StatusError reports a failing check, or that a check failed.
+	StatusError = "error"
+)
+
+// Warn wraps err, returned by a CheckFunc, to report the check as degraded
+// rather than failing.
+func Warn(err error) error {
+	return &warning{err}
+}
+
+type warning struct{ err error }
+
+func (w *warning) Error() string { return w.err.Error() }
+func (w *warning) Unwrap() error { return w.err }
+
+// CheckFunc checks a component, returning nil when it is healthy and an
+// error describing the problem otherwise. It should return promptly once
+// ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+// CheckableFunc adapts a Checkable to a CheckFunc.
+func CheckableFunc(c Checkable) CheckFunc {
+	return func(context.Context) error {
+		return c.Healthy()
+	}
+}
+
+// Kind is a set of the probes a check is part of. The probes follow the
+// semantics of Kubernetes: a failing liveness probe gets the process
+// restarted, a failing readiness probe takes it out of service until it
+// passes again, and a startup probe holds off the other two until it has
+// passed once.
+type Kind int
+
+const (
+	Liveness Kind = 1 << iota
+	Readiness
+	Startup
+
+	allKinds = Liveness | Readiness | Startup
+)
+
+func (k Kind) String() string {
+	var names []string
+	for _, kn := range []struct {
+		kind Kind
+		name string
+	}{{Liveness, "liveness"}, {Readiness, "readiness"}, {Startup, "startup"}} {
+		if k&kn.kind != 0 {
+			names = append(names, kn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// The paths Handle serves each kind of probe on, as used by Kubernetes
+// components.
+const (
+	LivenessPath  = "/livez"
+	ReadinessPath = "/readyz"
+	StartupPath   = "/startupz"
+)
+
+// CheckConfig configures a named check registered with a Registry.
+type CheckConfig struct {
+	Name string
+	Func CheckFunc
+
+	// Kinds are the probes the check is part of, and defaults to
+	// Readiness. Only checks which can be fixed by restarting the
+	// process belong in Liveness; a dependency which is down should not
+	// get every process depending on it restarted.
+	Kinds Kind
+
+	// Interval, if set, runs the check in the background this often
+	// while the Registry is polling, as described by Poll, instead of
+	// whenever it is probed.
+	Interval time.Duration
+
+	// Timeout bounds each run of the check, and defaults to
+	// DefaultCheckTimeout. A check which ignores the cancellation of its
+	// context is abandoned shortly after the timeout passes, and fails
+	// until that run returns.
+	Timeout time.Duration
+
+	// WarnAfter, if set, reports a run which passed but took longer as
+	// degraded.
+	WarnAfter time.Duration
+}
+
+// DefaultCheckTimeout is the default Timeout of a check.
+const DefaultCheckTimeout = 5 * time.Second
+
+// Registry is a set of named checks which the components of a program
+// register themselves, and an http.Handler serving their results as a
+// Report in JSON. The zero Registry is empty and ready to use.
+type Registry struct {
+	// Clock defaults to timeutil.RealClock.
+	Clock timeutil.Clock
+
+	// Observe, if set, is called with the result of every run of a
+	// check, including background runs, from the goroutine which ran it,
+	// unless the run was abandoned by cancelling its context. Cached
+	// results are not observed again. It must not be changed once
+	// the Registry is in use.
+	Observe func(name string, res CheckResult)
+
+	// WarnFails are the probes which a degraded check fails. By default,
+	// degraded checks are reported, but do not fail any probe: a slow
+	// dependency is better than none.
+	WarnFails Kind
+
+	// MaxConcurrent, if set, limits how many checks run at once,
+	// including background runs. A check waiting for its turn does so
+	// within its Timeout.
+	MaxConcurrent int
+
+	// Logger, if set, logs checks which panicked at ERROR, with the
+	// stack, and checks which did not return by their timeout at
+	// WARNING.
+	Logger *capnslog.PackageLogger
+
+	mu      sync.RWMutex
+	checks  map[string]*entry
+	pollCtx context.Context // set while polling
+
+	semOnce sync.Once
+	sem     chan struct{} // limits the running checks, if MaxConcurrent is set
+}
+
+// entry is a registered check and, for a background check, its last
+// result.
+type entry struct {
+	CheckConfig
+
+	// cancel stops the background runs.
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	result *CheckResult
+	at     time.Time
+
+	// abandoned counts the runs which timed out but have not returned.
+	abandoned int
+}
+
+func (r *Registry) clock() timeutil.Clock {
+	if r.Clock == nil {
+		return timeutil.RealClock
+	}
+	return r.Clock
+}
+
+// Register registers check under name as a readiness check. It panics if
+// name is already registered, as http.Handle does for patterns.
+func (r *Registry) Register(name string, check CheckFunc) {
+	r.RegisterCheck(CheckConfig{Name: name, Func: check})
+}
+
+// RegisterCheck registers c. It panics if c.Name is already registered.
+func (r *Registry) RegisterCheck(c CheckConfig) {
+	if c.Name == "" || c.Func == nil {
+		panic("health: check without a name or function")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.checks[c.Name]; ok {
+		panic(fmt.Sprintf("health: check %q registered twice", c.Name))
+	}
+	if c.Kinds == 0 {
+		c.Kinds = Readiness
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultCheckTimeout
+	}
+	if r.checks == nil {
+		r.checks = make(map[string]*entry)
+	}
+	e := &entry{CheckConfig: c}
+	r.checks[c.Name] = e
+	r.startLocked(e)
+}
+
+// Unregister removes the check registered under name, if any.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	if e, ok := r.checks[name]; ok {
+		e.stop()
+		delete(r.checks, name)
+	}
+	r.mu.Unlock()
+}
+
+// Names returns the names of the registered checks, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Report is the result of running the checks of a Registry.
+type Report struct {
+	// Status is StatusOK if every check passed, StatusWarn if some
+	// were degraded but none failed, and StatusError otherwise. A
+	// degraded check counts as failed when Registry.WarnFails covers the
+	// probe.
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Healthy reports whether no check failed.
+func (rep Report) Healthy() bool {
+	return rep.Status != StatusError
+}
+
+// CheckResult is the result of one check.
+type CheckResult struct {
+	Status   string
+	Error    string
+	Duration time.Duration
+
+	// Cached is set for the result of a background check, and Age is then
+	// the time since it was produced.
+	Cached bool
+	Age    time.Duration
+}
+
+// MarshalJSON writes durations in the format of time.Duration.String, and
+// the age only of cached results.
+func (res CheckResult) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Status   string `json:"status"`
+		Error    string `json:"error,omitempty"`
+		Duration string `json:"duration"`
+		Age      string `json:"age,omitempty"`
+	}{Status: res.Status, Error: res.Error, Duration: res.Duration.String()}
+	if res.Cached {
+		out.Age = res.Age.String()
+	}
+	return json.Marshal(out)
+}
+
+// Run runs every registered check, concurrently, and reports their results.
+// While the Registry is polling, background checks are not run, and their
+// last results are reported instead.
+func (r *Registry) Run(ctx context.Context) Report {
+	return r.RunKind(ctx, allKinds)
+}
+
+// RunKind is Run for the checks which are part of any of the probes in
+// kind. With no such checks, the report is healthy.
+func (r *Registry) RunKind(ctx context.Context, kind Kind) Report {
+	r.mu.RLock()
+	polling := r.pollCtx != nil
+	checks := make([]*entry, 0, len(r.checks))
+	for _, e := range r.checks {
+		if e.Kinds&kind != 0 {
+			checks = append(checks, e)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, e := range checks {
+		if polling && e.Interval > 0 {
+			results[i] = e.cached(r.clock())
+			continue
+		}
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = r.runCheck(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		rep.Checks[c.Name] = results[i]
+		switch status := results[i].Status; {
+		case status == StatusError, status == StatusWarn && r.WarnFails&kind != 0:
+			rep.Status = StatusError
+		case status == StatusWarn && rep.Status == StatusOK:
+			rep.Status = StatusWarn
+		}
+	}
+	return rep
+}
+
+// ServeHTTP runs the checks and writes their Report, with status 200 if it
+// is healthy and 503 otherwise.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.serve(w, req, allKinds)
+}
+
+// Handler returns a handler serving the Report of RunKind for kind, as
+// ServeHTTP does.
+func (r *Registry) Handler(kind Kind) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.serve(w, req, kind)
+	})
+}
+
+// Handle registers the handlers for each kind of probe on mux, at
+// LivenessPath, ReadinessPath and StartupPath below prefix, which may be
+// empty.
+func (r *Registry) Handle(mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux.Handle(prefix+LivenessPath, r.Handler(Liveness))
+	mux.Handle(prefix+ReadinessPath, r.Handler(Readiness))
+	mux.Handle(prefix+StartupPath, r.Handler(Startup))
+}
+
+func (r *Registry) serve(w http.ResponseWriter, req *http.Request, kind Kind) {
+	if req.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeReport(w, r.RunKind(req.Context(), kind))
+}
+
+func writeReport(w http.ResponseWriter, rep Report) {
+	code := http.StatusOK
+	if !rep.Healthy() {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httputil.WriteJSON(w, code, rep)
+}
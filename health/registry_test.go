@@ -0,0 +1,208 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+func passing(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("connection refused") }
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	r.Register("db", failing)
+	r.Register("cache", passing)
+	r.Register("legacy", CheckableFunc(boolChecker(true)))
+
+	if got, want := r.Names(), []string{"cache", "db", "legacy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%v got=%v", want, got)
+	}
+
+	rep := r.Run(context.Background())
+	if rep.Healthy() {
+		t.Errorf("report healthy despite a failing check")
+	}
+	if res := rep.Checks["db"]; res.Status != StatusError || res.Error != "connection refused" {
+		t.Errorf("unexpected db result %+v", res)
+	}
+	if res := rep.Checks["cache"]; res.Status != StatusOK || res.Error != "" {
+		t.Errorf("unexpected cache result %+v", res)
+	}
+
+	r.Unregister("db")
+	if rep := r.Run(context.Background()); !rep.Healthy() || len(rep.Checks) != 2 {
+		t.Errorf("unexpected report after unregistering: %+v", rep)
+	}
+}
+
+func TestRegistryDuplicate(t *testing.T) {
+	var r Registry
+	r.Register("db", passing)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a name twice did not panic")
+		}
+	}()
+	r.Register("db", passing)
+}
+
+func TestRegistryHandler(t *testing.T) {
+	var r Registry
+	r.Register("cache", passing)
+
+	tests := []struct {
+		method string
+		fail   bool
+		code   int
+		status string
+	}{
+		{"GET", false, http.StatusOK, StatusOK},
+		{"GET", true, http.StatusServiceUnavailable, StatusError},
+		{"POST", false, http.StatusMethodNotAllowed, ""},
+	}
+	for i, tt := range tests {
+		if tt.fail {
+			r.Register("db", failing)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, "/health", nil))
+		r.Unregister("db")
+
+		if w.Code != tt.code {
+			t.Errorf("case %d: want code %d, got %d", i, tt.code, w.Code)
+		}
+		if tt.status == "" {
+			continue
+		}
+		var body struct {
+			Status string `json:"status"`
+			Checks map[string]struct {
+				Status   string `json:"status"`
+				Error    string `json:"error"`
+				Duration string `json:"duration"`
+			} `json:"checks"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if body.Status != tt.status {
+			t.Errorf("case %d: want status %q, got %q", i, tt.status, body.Status)
+		}
+		if c := body.Checks["cache"]; c.Status != StatusOK || c.Duration == "" {
+			t.Errorf("case %d: unexpected cache check %+v", i, c)
+		}
+		if c, ok := body.Checks["db"]; tt.fail && (!ok || c.Error != "connection refused") {
+			t.Errorf("case %d: unexpected db check %+v", i, c)
+		}
+	}
+}
+
+func TestRegistryKinds(t *testing.T) {
+	var r Registry
+	r.Register("db", failing)
+	r.RegisterCheck(CheckConfig{Name: "deadlock", Func: passing, Kinds: Liveness | Readiness})
+	r.RegisterCheck(CheckConfig{Name: "warmup", Func: failing, Kinds: Startup})
+
+	mux := http.NewServeMux()
+	r.Handle(mux, "/health/")
+	tests := []struct {
+		path   string
+		code   int
+		checks []string
+	}{
+		// A failing dependency does not fail liveness.
+		{"/health/livez", http.StatusOK, []string{"deadlock"}},
+		{"/health/readyz", http.StatusServiceUnavailable, []string{"db", "deadlock"}},
+		{"/health/startupz", http.StatusServiceUnavailable, []string{"warmup"}},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("case %d: want code %d, got %d", i, tt.code, w.Code)
+		}
+		var body struct {
+			Checks map[string]interface{} `json:"checks"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if len(body.Checks) != len(tt.checks) {
+			t.Errorf("case %d: want checks %v, got %v", i, tt.checks, body.Checks)
+		}
+		for _, name := range tt.checks {
+			if _, ok := body.Checks[name]; !ok {
+				t.Errorf("case %d: check %s missing", i, name)
+			}
+		}
+	}
+
+	// Without checks of a kind, its probe passes.
+	var empty Registry
+	if rep := empty.RunKind(context.Background(), Liveness); !rep.Healthy() {
+		t.Errorf("empty liveness report unhealthy")
+	}
+
+	if got := (Liveness | Startup).String(); got != "liveness|startup" {
+		t.Errorf("unexpected kind string %q", got)
+	}
+}
+
+func TestRegistryWarn(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	r := Registry{Clock: clock}
+	r.Register("cache", passing)
+	r.Register("replica", func(context.Context) error {
+		return Warn(errors.New("replication lag 30s"))
+	})
+	r.RegisterCheck(CheckConfig{
+		Name: "api",
+		Func: func(context.Context) error {
+			clock.Advance(2 * time.Second)
+			return nil
+		},
+		Kinds:     Readiness | Liveness,
+		WarnAfter: time.Second,
+	})
+
+	rep := r.Run(context.Background())
+	if rep.Status != StatusWarn || !rep.Healthy() {
+		t.Errorf("unexpected report status %s", rep.Status)
+	}
+	tests := []struct {
+		name   string
+		status string
+		err    string
+	}{
+		{"cache", StatusOK, ""},
+		{"replica", StatusWarn, "replication lag 30s"},
+		{"api", StatusWarn, "took 2s, longer than 1s"},
+	}
+	for i, tt := range tests {
+		if res := rep.Checks[tt.name]; res.Status != tt.status || res.Error != tt.err {
+			t.Errorf("case %d: unexpected result %+v", i, res)
+		}
+	}
+
+	r.WarnFails = Liveness
+	if rep := r.RunKind(context.Background(), Readiness); !rep.Healthy() {
+		t.Errorf("degraded check failed readiness")
+	}
+	if rep := r.RunKind(context.Background(), Liveness); rep.Healthy() || rep.Checks["api"].Status != StatusWarn {
+		t.Errorf("degraded check did not fail liveness: %+v", rep)
+	}
+
+	r.Register("db", failing)
+	if rep := r.Run(context.Background()); rep.Status != StatusError {
+		t.Errorf("unexpected report status %s", rep.Status)
+	}
+}
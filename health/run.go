@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+// abandonGrace is how long a check which respects its context has to return
+// once its timeout passes, before its run is abandoned.
+const abandonGrace = 50 * time.Millisecond
+
+// runCheck runs e once, bounded by its Timeout. The check is run on its own
+// goroutine, so that it is abandoned shortly after the timeout passes even if
+// it ignores its context, and a panic fails the check instead of the program.
+// Until an abandoned run returns, e fails without being run again, so that
+// a hung check cannot pile up goroutines.
+func (r *Registry) runCheck(parent context.Context, e *entry) CheckResult {
+	ctx, cancel := context.WithTimeout(parent, e.Timeout)
+	defer cancel()
+
+	clock := r.clock()
+	res := CheckResult{Status: StatusError}
+	e.mu.Lock()
+	abandoned := e.abandoned
+	e.mu.Unlock()
+	if abandoned > 0 {
+		res.Error = "a run which timed out has not returned"
+		return res
+	}
+
+	if sem := r.semaphore(); sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-ctx.Done():
+			res.Error = fmt.Sprintf("waiting for its turn: %v", ctx.Err())
+			return res
+		}
+	}
+
+	start := clock.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				r.logf(capnslog.ERROR, "check %s panicked: %v\n%s", e.Name, p, debug.Stack())
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- e.Func(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		grace := time.NewTimer(abandonGrace)
+		defer grace.Stop()
+		select {
+		case err = <-done:
+		case <-grace.C:
+			// The check ignores its context.
+			r.logf(capnslog.WARNING, "check %s did not return by its timeout of %v", e.Name, e.Timeout)
+			e.mu.Lock()
+			e.abandoned++
+			e.mu.Unlock()
+			go func() {
+				<-done
+				e.mu.Lock()
+				e.abandoned--
+				e.mu.Unlock()
+			}()
+		}
+	}
+	res.Duration = clock.Since(start)
+
+	var w *warning
+	switch {
+	case errors.As(err, &w):
+		res.Status = StatusWarn
+		res.Error = err.Error()
+	case err != nil:
+		res.Error = err.Error()
+	case e.WarnAfter > 0 && res.Duration > e.WarnAfter:
+		res.Status = StatusWarn
+		res.Error = fmt.Sprintf("took %s, longer than %s", res.Duration, e.WarnAfter)
+	default:
+		res.Status = StatusOK
+	}
+	// A run abandoned by its caller says nothing about the check.
+	if r.Observe != nil && parent.Err() == nil {
+		r.Observe(e.Name, res)
+	}
+	return res
+}
+
+// semaphore returns the channel limiting the running checks, or nil if
+// their number is not limited.
+func (r *Registry) semaphore() chan struct{} {
+	r.semOnce.Do(func() {
+		if r.MaxConcurrent > 0 {
+			r.sem = make(chan struct{}, r.MaxConcurrent)
+		}
+	})
+	return r.sem
+}
+
+func (r *Registry) logf(l capnslog.LogLevel, format string, args ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Logf(l, format, args...)
+	}
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistryHungCheck(t *testing.T) {
+	var r Registry
+	release := make(chan struct{})
+	var calls int32
+	r.RegisterCheck(CheckConfig{
+		Name: "hung",
+		Func: func(context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil
+		},
+		Timeout: 10 * time.Millisecond,
+	})
+
+	if res := r.Run(context.Background()).Checks["hung"]; res.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("unexpected result %+v", res)
+	}
+	// Not run again while the abandoned run is stuck.
+	res := r.Run(context.Background()).Checks["hung"]
+	if res.Status != StatusError || !strings.Contains(res.Error, "has not returned") {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("check run %d times, want 1", n)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !r.Run(context.Background()).Healthy() {
+		if time.Now().After(deadline) {
+			t.Fatal("check still failing after its run returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRegistrySlowCancel(t *testing.T) {
+	// A check which returns shortly after its context is done is not
+	// abandoned.
+	var r Registry
+	var calls int32
+	r.RegisterCheck(CheckConfig{
+		Name: "slow",
+		Func: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-ctx.Done()
+			time.Sleep(time.Millisecond)
+			return ctx.Err()
+		},
+		Timeout: 10 * time.Millisecond,
+	})
+	for i := 0; i < 2; i++ {
+		if res := r.Run(context.Background()).Checks["slow"]; res.Error != context.DeadlineExceeded.Error() {
+			t.Errorf("run %d: unexpected result %+v", i, res)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("check run %d times, want 2", n)
+	}
+}
+
+func TestRegistryPanic(t *testing.T) {
+	var r Registry
+	r.Register("buggy", func(context.Context) error {
+		var m map[string]int
+		m["x"]++
+		return nil
+	})
+	r.Register("cache", passing)
+
+	rep := r.Run(context.Background())
+	if res := rep.Checks["buggy"]; res.Status != StatusError || !strings.HasPrefix(res.Error, "panic: ") {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if res := rep.Checks["cache"]; res.Status != StatusOK {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestRegistryMaxConcurrent(t *testing.T) {
+	r := Registry{MaxConcurrent: 2}
+	var running, max int32
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		r.Register(name, func(context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+	if rep := r.Run(context.Background()); !rep.Healthy() {
+		t.Errorf("unexpected report %+v", rep)
+	}
+	if m := atomic.LoadInt32(&max); m != 2 {
+		t.Errorf("%d checks ran at once, want 2", m)
+	}
+
+	// A check waiting for its turn times out.
+	block := make(chan struct{})
+	defer close(block)
+	r2 := Registry{MaxConcurrent: 1}
+	r2.RegisterCheck(CheckConfig{Name: "slow", Func: func(ctx context.Context) error {
+		<-block
+		return nil
+	}, Kinds: Startup, Timeout: time.Hour})
+	r2.RegisterCheck(CheckConfig{Name: "fast", Func: passing, Timeout: 10 * time.Millisecond})
+	go r2.RunKind(context.Background(), Startup)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res := r2.RunKind(context.Background(), Readiness).Checks["fast"]
+		if strings.HasPrefix(res.Error, "waiting for its turn") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected result %+v", res)
+		}
+	}
+}
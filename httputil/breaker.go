@@ -0,0 +1,307 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CircuitBreakerTransport when requests to a
+// host are being rejected without being attempted.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through while monitoring their outcome.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of trial requests through to
+	// decide whether to close the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerTransport is an http.RoundTripper which stops sending
+// requests to a host once too many recent requests to it have failed or been
+// slow. Each host, as named in the request URL, has its own breaker.
+//
+// A breaker watches the outcome of the last WindowSize requests. Once at
+// least MinCalls have been made, if the proportion of failures reaches
+// FailureRate, or the proportion of calls slower than SlowCallDuration
+// reaches SlowCallRate, the breaker opens and requests fail immediately with
+// ErrCircuitOpen. After OpenDuration the breaker becomes half-open, allowing
+// HalfOpenCalls trial requests; if all succeed the breaker closes, and
+// otherwise it opens again.
+type CircuitBreakerTransport struct {
+	// Transport is used to make the actual requests. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// WindowSize defaults to 20, and MinCalls to half of WindowSize.
+	// MinCalls is capped at WindowSize, as the window never holds more
+	// outcomes.
+	WindowSize int
+	MinCalls   int
+
+	// FailureRate defaults to 0.5.
+	FailureRate float64
+
+	// SlowCallDuration enables slow call detection when non-zero.
+	// SlowCallRate defaults to 1, i.e. the breaker opens once every call in
+	// the window is slow.
+	SlowCallDuration time.Duration
+	SlowCallRate     float64
+
+	// OpenDuration defaults to 30 seconds, and HalfOpenCalls to 1.
+	OpenDuration  time.Duration
+	HalfOpenCalls int
+
+	// IsFailure reports whether a request outcome counts as a failure. If
+	// nil, transport errors and 5xx responses are failures.
+	IsFailure func(resp *http.Response, err error) bool
+
+	// OnStateChange, if set, is called whenever a host's breaker changes
+	// state. It must not block.
+	OnStateChange func(host string, from, to CircuitState)
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+type breaker struct {
+	state    CircuitState
+	openedAt time.Time
+	// generation counts state changes, so that the outcome of a request is
+	// only recorded in the state it was admitted in.
+	generation uint64
+
+	// window is a ring buffer of the most recent outcomes.
+	window []callOutcome
+	next   int
+	count  int
+
+	halfOpenInflight  int
+	halfOpenSucceeded int
+}
+
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+type stateChange struct {
+	from, to CircuitState
+}
+
+// State returns the current state of the breaker for host.
+func (t *CircuitBreakerTransport) State(host string) CircuitState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if b, ok := t.breakers[host]; ok {
+		if b.state == CircuitOpen && t.clock().Sub(b.openedAt) >= t.openDuration() {
+			return CircuitHalfOpen
+		}
+		return b.state
+	}
+	return CircuitClosed
+}
+
+func (t *CircuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	gen, ok := t.allow(host)
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	// A request which panics is recorded as a failure, so that it can't
+	// keep a half-open slot taken.
+	outcome := callOutcome{failed: true}
+	defer func() { t.record(host, gen, outcome) }()
+
+	start := t.clock()
+	resp, err := transport.RoundTrip(req)
+	elapsed := t.clock().Sub(start)
+
+	if t.IsFailure != nil {
+		outcome.failed = t.IsFailure(resp, err)
+	} else {
+		outcome.failed = err != nil || resp.StatusCode >= 500
+	}
+	outcome.slow = t.SlowCallDuration > 0 && elapsed >= t.SlowCallDuration
+	return resp, err
+}
+
+// allow reports whether a request to host may be made, and the generation of
+// the breaker it is admitted in.
+func (t *CircuitBreakerTransport) allow(host string) (uint64, bool) {
+	var changes []stateChange
+	defer func() { t.notify(host, changes) }()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.breaker(host)
+	if b.state == CircuitOpen {
+		if t.clock().Sub(b.openedAt) < t.openDuration() {
+			return 0, false
+		}
+		changes = append(changes, t.transition(b, CircuitHalfOpen))
+	}
+	if b.state == CircuitHalfOpen {
+		if b.halfOpenInflight >= t.halfOpenCalls() {
+			return 0, false
+		}
+		b.halfOpenInflight++
+	}
+	return b.generation, true
+}
+
+func (t *CircuitBreakerTransport) record(host string, gen uint64, o callOutcome) {
+	var changes []stateChange
+	defer func() { t.notify(host, changes) }()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.breaker(host)
+	if gen != b.generation {
+		// The request was admitted before the breaker last changed
+		// state, e.g. while it was closed.
+		return
+	}
+	switch b.state {
+	case CircuitHalfOpen:
+		b.halfOpenInflight--
+		if o.failed || o.slow {
+			changes = append(changes, t.transition(b, CircuitOpen))
+			return
+		}
+		b.halfOpenSucceeded++
+		if b.halfOpenSucceeded >= t.halfOpenCalls() {
+			changes = append(changes, t.transition(b, CircuitClosed))
+		}
+	case CircuitClosed:
+		b.window[b.next] = o
+		b.next = (b.next + 1) % len(b.window)
+		if b.count < len(b.window) {
+			b.count++
+		}
+		if b.count < t.minCalls() {
+			return
+		}
+		var failed, slow int
+		for _, w := range b.window[:b.count] {
+			if w.failed {
+				failed++
+			}
+			if w.slow {
+				slow++
+			}
+		}
+		failureRate := t.FailureRate
+		if failureRate == 0 {
+			failureRate = 0.5
+		}
+		slowRate := t.SlowCallRate
+		if slowRate == 0 {
+			slowRate = 1
+		}
+		if float64(failed)/float64(b.count) >= failureRate ||
+			(t.SlowCallDuration > 0 && float64(slow)/float64(b.count) >= slowRate) {
+			changes = append(changes, t.transition(b, CircuitOpen))
+		}
+	}
+}
+
+// transition moves b to state to, resetting the bookkeeping for the new
+// state. t.mu must be held.
+func (t *CircuitBreakerTransport) transition(b *breaker, to CircuitState) stateChange {
+	change := stateChange{from: b.state, to: to}
+	b.state = to
+	b.generation++
+	b.halfOpenInflight = 0
+	b.halfOpenSucceeded = 0
+	switch to {
+	case CircuitOpen:
+		b.openedAt = t.clock()
+	case CircuitClosed:
+		b.next, b.count = 0, 0
+	}
+	return change
+}
+
+func (t *CircuitBreakerTransport) notify(host string, changes []stateChange) {
+	if t.OnStateChange == nil {
+		return
+	}
+	for _, c := range changes {
+		t.OnStateChange(host, c.from, c.to)
+	}
+}
+
+// breaker returns the breaker for host, creating it if needed. t.mu must be
+// held.
+func (t *CircuitBreakerTransport) breaker(host string) *breaker {
+	if t.breakers == nil {
+		t.breakers = make(map[string]*breaker)
+	}
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{window: make([]callOutcome, t.windowSize())}
+		t.breakers[host] = b
+	}
+	return b
+}
+
+func (t *CircuitBreakerTransport) minCalls() int {
+	size := t.windowSize()
+	switch {
+	case t.MinCalls > size:
+		return size
+	case t.MinCalls > 0:
+		return t.MinCalls
+	}
+	return (size + 1) / 2
+}
+
+func (t *CircuitBreakerTransport) windowSize() int {
+	if t.WindowSize > 0 {
+		return t.WindowSize
+	}
+	return 20
+}
+
+func (t *CircuitBreakerTransport) openDuration() time.Duration {
+	return orDuration(t.OpenDuration, 30*time.Second)
+}
+
+func (t *CircuitBreakerTransport) halfOpenCalls() int {
+	return orInt(t.HalfOpenCalls, 1)
+}
+
+func (t *CircuitBreakerTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
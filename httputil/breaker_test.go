@@ -0,0 +1,218 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransport(t *testing.T) {
+	now := time.Unix(1000, 0)
+	fail := false
+	var changes []string
+	cb := &CircuitBreakerTransport{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if fail {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		WindowSize:   4,
+		MinCalls:     2,
+		FailureRate:  0.5,
+		OpenDuration: time.Minute,
+		OnStateChange: func(host string, from, to CircuitState) {
+			changes = append(changes, host+": "+from.String()+" -> "+to.String())
+		},
+		now: func() time.Time { return now },
+	}
+	do := func(host string) error {
+		_, err := cb.RoundTrip(httptest.NewRequest("GET", "http://"+host+"/", nil))
+		return err
+	}
+
+	for i, test := range []struct {
+		advance     time.Duration
+		fail        bool
+		expectedErr error
+		state       CircuitState
+	}{
+		{0, false, nil, CircuitClosed},
+		{0, false, nil, CircuitClosed},
+		{0, false, nil, CircuitClosed},
+		// 1 of 4 failed.
+		{0, true, errors.New(""), CircuitClosed},
+		// 2 of 4 failed, the breaker trips.
+		{0, true, errors.New(""), CircuitOpen},
+		{0, false, ErrCircuitOpen, CircuitOpen},
+		// Trial request fails, opening the breaker again.
+		{time.Minute, true, errors.New(""), CircuitOpen},
+		{time.Second, false, ErrCircuitOpen, CircuitOpen},
+		// Trial request succeeds, closing the breaker.
+		{time.Minute, false, nil, CircuitClosed},
+		{0, false, nil, CircuitClosed},
+	} {
+		now = now.Add(test.advance)
+		fail = test.fail
+		err := do("a.example.com")
+		if test.expectedErr == ErrCircuitOpen {
+			if err != ErrCircuitOpen {
+				t.Errorf("case %d: want ErrCircuitOpen, got %v", i, err)
+			}
+		} else if (err != nil) != (test.expectedErr != nil) {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if s := cb.State("a.example.com"); s != test.state {
+			t.Errorf("case %d: state == %v, want %v", i, s, test.state)
+		}
+	}
+
+	// Other hosts are unaffected.
+	if s := cb.State("b.example.com"); s != CircuitClosed {
+		t.Errorf("state of unrelated host == %v, want %v", s, CircuitClosed)
+	}
+
+	want := []string{
+		"a.example.com: closed -> open",
+		"a.example.com: open -> half-open",
+		"a.example.com: half-open -> open",
+		"a.example.com: open -> half-open",
+		"a.example.com: half-open -> closed",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("state changes == %q, want %q", changes, want)
+	}
+}
+
+func TestCircuitBreakerSlowCalls(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cb := &CircuitBreakerTransport{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			now = now.Add(2 * time.Second)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		WindowSize:       2,
+		MinCalls:         2,
+		SlowCallDuration: time.Second,
+		now:              func() time.Time { return now },
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cb.RoundTrip(httptest.NewRequest("GET", "http://slow/", nil)); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+	if s := cb.State("slow"); s != CircuitOpen {
+		t.Errorf("state == %v, want %v", s, CircuitOpen)
+	}
+}
+
+func TestCircuitBreakerStaleOutcomes(t *testing.T) {
+	now := time.Unix(1000, 0)
+	entered := make(chan string)
+	release := map[string]chan bool{"/closed": make(chan bool), "/trial": make(chan bool)}
+	cb := &CircuitBreakerTransport{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if c, ok := release[r.URL.Path]; ok {
+				entered <- r.URL.Path
+				if <-c {
+					return nil, errors.New("connection refused")
+				}
+			} else if r.URL.Path == "/fail" {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		WindowSize:   2,
+		MinCalls:     2,
+		OpenDuration: time.Minute,
+		now:          func() time.Time { return now },
+	}
+	done := make(chan struct{})
+	start := func(path string) {
+		go func() {
+			cb.RoundTrip(httptest.NewRequest("GET", "http://a"+path, nil))
+			done <- struct{}{}
+		}()
+		<-entered
+	}
+
+	// A request admitted while closed is still running when the breaker
+	// trips and then admits a trial request.
+	start("/closed")
+	for i := 0; i < 2; i++ {
+		cb.RoundTrip(httptest.NewRequest("GET", "http://a/fail", nil))
+	}
+	now = now.Add(time.Minute)
+	start("/trial")
+
+	// Its success is not mistaken for that of the trial.
+	release["/closed"] <- false
+	<-done
+	if s := cb.State("a"); s != CircuitHalfOpen {
+		t.Errorf("state == %v, want %v", s, CircuitHalfOpen)
+	}
+	release["/trial"] <- true
+	<-done
+	if s := cb.State("a"); s != CircuitOpen {
+		t.Errorf("state == %v, want %v", s, CircuitOpen)
+	}
+	if b := cb.breakers["a"]; b.halfOpenInflight != 0 {
+		t.Errorf("%d half-open requests in flight", b.halfOpenInflight)
+	}
+}
+
+func TestCircuitBreakerMinCallsCapped(t *testing.T) {
+	cb := &CircuitBreakerTransport{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		}),
+		WindowSize: 2,
+		MinCalls:   5,
+	}
+	for i := 0; i < 2; i++ {
+		cb.RoundTrip(httptest.NewRequest("GET", "http://a/", nil))
+	}
+	if s := cb.State("a"); s != CircuitOpen {
+		t.Errorf("state == %v, want %v", s, CircuitOpen)
+	}
+}
+
+func TestCircuitBreakerPanic(t *testing.T) {
+	now := time.Unix(1000, 0)
+	panics := false
+	cb := &CircuitBreakerTransport{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if panics {
+				panic("transport bug")
+			}
+			return nil, errors.New("connection refused")
+		}),
+		WindowSize:   1,
+		OpenDuration: time.Minute,
+		now:          func() time.Time { return now },
+	}
+	do := func() (err error) {
+		defer func() {
+			if recover() != nil {
+				err = errors.New("panicked")
+			}
+		}()
+		_, err = cb.RoundTrip(httptest.NewRequest("GET", "http://a/", nil))
+		return err
+	}
+	do()
+	now = now.Add(time.Minute)
+	panics = true
+	do()
+	// The panicking trial failed rather than keeping the half-open slot.
+	if s := cb.State("a"); s != CircuitOpen {
+		t.Errorf("state == %v, want %v", s, CircuitOpen)
+	}
+	now = now.Add(time.Minute)
+	if err := do(); err == ErrCircuitOpen {
+		t.Errorf("no trial request allowed after a panic")
+	}
+}
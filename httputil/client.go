@@ -0,0 +1,107 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	DefaultClientTimeout       = 30 * time.Second
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 16
+)
+
+// ClientOptions configures clients and transports built by NewClient and
+// NewTransport. Zero values select the defaults above.
+type ClientOptions struct {
+	// Timeout bounds the whole exchange, including reading the response
+	// body. A negative Timeout disables it.
+	Timeout time.Duration
+
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	IdleConnTimeout       time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the total connections per host; zero means
+	// no limit.
+	MaxConnsPerHost int
+
+	// Proxy selects the proxy for each request. If nil, the environment
+	// (HTTP_PROXY, HTTPS_PROXY, NO_PROXY) is consulted unless NoProxy is
+	// set.
+	Proxy   func(*http.Request) (*url.URL, error)
+	NoProxy bool
+
+	// TLSConfig is used for https connections.
+	TLSConfig *tls.Config
+
+	// WrapTransport, if set, is applied to the transport before it is
+	// installed in the client, e.g. to add a RetryTransport.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+}
+
+// NewClient returns an http.Client configured according to opts. Unlike the
+// zero http.Client, it always has a timeout.
+func NewClient(opts ClientOptions) *http.Client {
+	var rt http.RoundTripper = NewTransport(opts)
+	if opts.WrapTransport != nil {
+		rt = opts.WrapTransport(rt)
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultClientTimeout
+	} else if timeout < 0 {
+		timeout = 0
+	}
+	return &http.Client{Transport: rt, Timeout: timeout}
+}
+
+// NewTransport returns an http.Transport configured according to opts. The
+// Timeout and WrapTransport options are ignored.
+func NewTransport(opts ClientOptions) *http.Transport {
+	proxy := opts.Proxy
+	if proxy == nil && !opts.NoProxy {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   orDuration(opts.DialTimeout, DefaultDialTimeout),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       opts.TLSConfig,
+		TLSHandshakeTimeout:   orDuration(opts.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout),
+		IdleConnTimeout:       orDuration(opts.IdleConnTimeout, DefaultIdleConnTimeout),
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          orInt(opts.MaxIdleConns, DefaultMaxIdleConns),
+		MaxIdleConnsPerHost:   orInt(opts.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+	}
+}
+
+func orDuration(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+func orInt(i, def int) int {
+	if i == 0 {
+		return def
+	}
+	return i
+}
@@ -0,0 +1,81 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestNewClientDefaults(t *testing.T) {
+	c := NewClient(ClientOptions{})
+	if c.Timeout != DefaultClientTimeout {
+		t.Errorf("Timeout == %v, want %v", c.Timeout, DefaultClientTimeout)
+	}
+	tr, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport is %T, want *http.Transport", c.Transport)
+	}
+	if tr.TLSHandshakeTimeout != DefaultTLSHandshakeTimeout || tr.IdleConnTimeout != DefaultIdleConnTimeout {
+		t.Errorf("unexpected transport timeouts: %v %v", tr.TLSHandshakeTimeout, tr.IdleConnTimeout)
+	}
+	if tr.MaxIdleConns != DefaultMaxIdleConns || tr.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("unexpected pool settings: %d %d", tr.MaxIdleConns, tr.MaxIdleConnsPerHost)
+	}
+	if tr.Proxy == nil {
+		t.Errorf("expected proxy from environment")
+	}
+
+	if c := NewClient(ClientOptions{Timeout: -1, NoProxy: true}); c.Timeout != 0 || c.Transport.(*http.Transport).Proxy != nil {
+		t.Errorf("expected timeout and proxy to be disabled")
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	proxyURL, _ := url.Parse("http://proxy.example.com:3128")
+	tr := NewTransport(ClientOptions{Proxy: http.ProxyURL(proxyURL)})
+	got, err := tr.Proxy(httptest.NewRequest("GET", "http://example.com/", nil))
+	if err != nil || got.String() != proxyURL.String() {
+		t.Errorf("Proxy == (%v, %v), want %v", got, err, proxyURL)
+	}
+
+	var wrapped bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	c := NewClient(ClientOptions{
+		NoProxy: true,
+		WrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				wrapped = true
+				return rt.RoundTrip(r)
+			})
+		},
+	})
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if !wrapped {
+		t.Errorf("WrapTransport was not used")
+	}
+}
+
+func TestNewClientTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ClientOptions{Timeout: 10 * time.Millisecond, NoProxy: true})
+	if _, err := c.Get(ts.URL); err == nil {
+		t.Errorf("expected timeout error")
+	}
+}
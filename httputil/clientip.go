@@ -0,0 +1,114 @@
+package httputil
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseCIDRs parses a list of CIDR blocks, such as "10.0.0.0/8", for use with
+// ClientIP. Bare IP addresses are accepted and treated as a single host.
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "CIDR address", Text: c}
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ClientIP returns the IP address of the client which made r.
+//
+// Forwarding headers are only consulted when the immediate peer is within
+// one of the trusted networks, and are then walked from the nearest hop
+// outwards, skipping any further trusted proxies; the first untrusted
+// address found is the client. The Forwarded header takes precedence over
+// X-Forwarded-For, which in turn takes precedence over X-Real-IP.
+//
+// If the peer address cannot be parsed, nil is returned.
+func ClientIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	peer := parseHostIP(r.RemoteAddr)
+	if peer == nil || !containsIP(trusted, peer) {
+		return peer
+	}
+
+	var hops []string
+	if fwd := r.Header["Forwarded"]; len(fwd) > 0 {
+		hops = forwardedFor(fwd)
+	} else if xff := r.Header["X-Forwarded-For"]; len(xff) > 0 {
+		for _, h := range xff {
+			hops = append(hops, strings.Split(h, ",")...)
+		}
+	} else if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		hops = []string{xri}
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHostIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// Obfuscated or garbled entry; the last proxy we could
+			// identify is as close to the client as we can get.
+			break
+		}
+		client = ip
+		if !containsIP(trusted, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor extracts the "for" parameters from RFC 7239 Forwarded
+// headers, in order.
+func forwardedFor(headers []string) []string {
+	var out []string
+	for _, h := range headers {
+		for _, elem := range strings.Split(h, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					out = append(out, strings.Trim(kv[1], `"`))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// parseHostIP parses an IP address which may carry a port and, for IPv6,
+// surrounding brackets.
+func parseHostIP(s string) net.IP {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if i := strings.IndexByte(s, '%'); i >= 0 {
+		s = s[:i]
+	}
+	return net.ParseIP(s)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package httputil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, test := range []struct {
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		// No headers.
+		{"1.2.3.4:1000", nil, "1.2.3.4"},
+		// Untrusted peer, headers ignored.
+		{"1.2.3.4:1000", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "1.2.3.4"},
+		// Trusted peer.
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "5.6.7.8"},
+		{"192.168.1.1:1000", map[string]string{"X-Real-IP": "5.6.7.8"}, "5.6.7.8"},
+		// Chain of trusted proxies, with a spoofed entry on the far left.
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "6.6.6.6, 5.6.7.8, 10.1.1.1"}, "5.6.7.8"},
+		// All hops trusted.
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "10.2.2.2, 10.1.1.1"}, "10.2.2.2"},
+		// Forwarded takes precedence.
+		{"10.0.0.1:1000", map[string]string{
+			"Forwarded":       `for="[2001:db8:cafe::17]:4711", for=9.9.9.9;proto=https`,
+			"X-Forwarded-For": "5.6.7.8",
+		}, "9.9.9.9"},
+		{"[2001:db8::1]:1000", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711";by=_proxy`}, "2001:db8:cafe::17"},
+		// Obfuscated identifier stops the walk at the last known proxy.
+		{"10.0.0.1:1000", map[string]string{"Forwarded": "for=_hidden, for=10.3.3.3"}, "10.3.3.3"},
+		// Garbage.
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "not-an-ip"}, "10.0.0.1"},
+		{"nonsense", nil, "<nil>"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		if got := ClientIP(r, trusted).String(); got != test.expected {
+			t.Errorf("case %d: ClientIP == %s, want %s", i, got, test.expected)
+		}
+	}
+}
+
+func TestParseCIDRsInvalid(t *testing.T) {
+	for i, in := range []string{"10.0.0.0/33", "foo", "10.0.0"} {
+		if _, err := ParseCIDRs([]string{in}); err == nil {
+			t.Errorf("case %d: expected error for %q", i, in)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package httputil
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultCompressMinSize is the smallest response body CompressHandler
+	// will compress when MinSize is unset.
+	DefaultCompressMinSize = 1024
+)
+
+// DefaultCompressibleTypes is the content type allow list used by
+// CompressHandler when ContentTypes is unset.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// Encoding is a content coding which CompressHandler can apply to responses.
+type Encoding struct {
+	// Name is the content-coding token, e.g. "gzip" or "br".
+	Name string
+	// NewWriter returns a writer compressing into w.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// GzipEncoding returns an Encoding producing gzip at the given compression
+// level.
+func GzipEncoding(level int) Encoding {
+	return Encoding{
+		Name: "gzip",
+		NewWriter: func(w io.Writer) io.WriteCloser {
+			gz, err := gzip.NewWriterLevel(w, level)
+			if err != nil {
+				gz = gzip.NewWriter(w)
+			}
+			return gz
+		},
+	}
+}
+
+// CompressHandler is middleware which compresses responses from Handler
+// using an encoding accepted by the client. Responses are only compressed
+// when their body reaches MinSize bytes and their Content-Type is in the
+// allow list; responses which already carry a Content-Encoding are passed
+// through untouched. Content-Length is dropped from compressed responses,
+// and Vary: Accept-Encoding is always added.
+type CompressHandler struct {
+	Handler http.Handler
+
+	// Encodings lists the supported encodings in order of preference. If
+	// empty, gzip at the default compression level is used. Encodings such
+	// as zstd or br may be added by wrapping a third party implementation.
+	Encodings []Encoding
+
+	// ContentTypes lists the media types which should be compressed. An
+	// entry of the form "type/*" matches every subtype. If empty,
+	// DefaultCompressibleTypes is used.
+	ContentTypes []string
+
+	// MinSize is the minimum body size worth compressing. If zero,
+	// DefaultCompressMinSize is used.
+	MinSize int
+}
+
+func (ch *CompressHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	enc, ok := ch.selectEncoding(r.Header.Get("Accept-Encoding"))
+	if !ok || r.Method == "HEAD" {
+		ch.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressWriter{ResponseWriter: w, ch: ch, encoding: enc}
+	defer cw.Close()
+	ch.Handler.ServeHTTP(cw, r)
+}
+
+func (ch *CompressHandler) selectEncoding(acceptEncoding string) (Encoding, bool) {
+	encs := ch.Encodings
+	if len(encs) == 0 {
+		encs = []Encoding{GzipEncoding(gzip.DefaultCompression)}
+	}
+	names := make([]string, len(encs))
+	for i, e := range encs {
+		names[i] = e.Name
+	}
+	chosen := negotiateEncoding(acceptEncoding, names)
+	for _, e := range encs {
+		if e.Name == chosen {
+			return e, true
+		}
+	}
+	return Encoding{}, false
+}
+
+func (ch *CompressHandler) compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	types := ch.ContentTypes
+	if len(types) == 0 {
+		types = DefaultCompressibleTypes
+	}
+	for _, t := range types {
+		if t == mt || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	ch       *CompressHandler
+	encoding Encoding
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	min := cw.ch.MinSize
+	if min == 0 {
+		min = DefaultCompressMinSize
+	}
+	if len(cw.buf) < min {
+		return len(b), nil
+	}
+	if err := cw.decide(true); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// decide commits to compressing the response or not and writes out the
+// headers and anything buffered so far.
+func (cw *compressWriter) decide(bigEnough bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if bigEnough && h.Get("Content-Encoding") == "" && cw.status != http.StatusNoContent &&
+		cw.status != http.StatusNotModified && cw.ch.compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", cw.encoding.Name)
+		h.Del("Content-Length")
+		cw.enc = cw.encoding.NewWriter(cw.ResponseWriter)
+	}
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(len(cw.buf) > 0)
+	}
+	if f, ok := cw.enc.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if cw.enc != nil {
+		return cw.enc.Close()
+	}
+	return nil
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,116 @@
+package httputil
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressHandler(t *testing.T) {
+	big := strings.Repeat(`{"hello":"world"}`, 100)
+
+	for i, test := range []struct {
+		method          string
+		acceptEncoding  string
+		contentType     string
+		contentEncoding string
+		body            string
+		expectedGzip    bool
+	}{
+		{"GET", "gzip", "application/json", "", big, true},
+		{"GET", "deflate, gzip;q=0.5", "text/plain; charset=utf-8", "", big, true},
+		{"GET", "*", "application/json", "", big, true},
+		// Sniffed content type.
+		{"GET", "gzip", "", "", strings.Repeat("plain text ", 200), true},
+		// Too small.
+		{"GET", "gzip", "application/json", "", `{"hello":"world"}`, false},
+		// Client does not accept gzip.
+		{"GET", "", "application/json", "", big, false},
+		{"GET", "br", "application/json", "", big, false},
+		{"GET", "gzip;q=0", "application/json", "", big, false},
+		// Not an allowed content type.
+		{"GET", "gzip", "image/png", "", big, false},
+		// Already encoded.
+		{"GET", "gzip", "application/json", "identity", big, false},
+		// HEAD requests.
+		{"HEAD", "gzip", "application/json", "", "", false},
+	} {
+		h := &CompressHandler{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.contentType != "" {
+				w.Header().Set("Content-Type", test.contentType)
+			}
+			if test.contentEncoding != "" {
+				w.Header().Set("Content-Encoding", test.contentEncoding)
+			}
+			w.Header().Set("Content-Length", "12345")
+			w.WriteHeader(http.StatusCreated)
+			// Write in small pieces to exercise buffering.
+			for j := 0; j < len(test.body); j += 100 {
+				end := j + 100
+				if end > len(test.body) {
+					end = len(test.body)
+				}
+				w.Write([]byte(test.body[j:end]))
+			}
+		})}
+
+		r := httptest.NewRequest(test.method, "/", nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, http.StatusCreated)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("case %d: Vary == %q, want %q", i, got, "Accept-Encoding")
+		}
+
+		body := w.Body.String()
+		gotGzip := w.Header().Get("Content-Encoding") == "gzip"
+		if gotGzip != test.expectedGzip {
+			t.Errorf("case %d: gzip == %t, want %t", i, gotGzip, test.expectedGzip)
+			continue
+		}
+		if gotGzip {
+			if w.Header().Get("Content-Length") != "" {
+				t.Errorf("case %d: Content-Length should have been removed", i)
+			}
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Errorf("case %d: bad gzip stream: %v", i, err)
+				continue
+			}
+			b, _ := ioutil.ReadAll(gz)
+			body = string(b)
+		}
+		if body != test.body {
+			t.Errorf("case %d: body mismatch, got %d bytes want %d", i, len(body), len(test.body))
+		}
+	}
+}
+
+func TestCompressHandlerPreference(t *testing.T) {
+	fake := Encoding{Name: "fake", NewWriter: GzipEncoding(gzip.BestSpeed).NewWriter}
+	h := &CompressHandler{Encodings: []Encoding{fake, GzipEncoding(gzip.BestSpeed)}}
+
+	for i, test := range []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"gzip, fake", "fake"},
+		{"gzip, fake;q=0.9", "gzip"},
+		{"*;q=0.1, gzip;q=0.2", "gzip"},
+		{"identity", ""},
+	} {
+		e, _ := h.selectEncoding(test.acceptEncoding)
+		if e.Name != test.expected {
+			t.Errorf("case %d: got %q, want %q", i, e.Name, test.expected)
+		}
+	}
+}
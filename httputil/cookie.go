@@ -1,10 +1,29 @@
 package httputil
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrInvalidCookie is returned by CookieCodec.Decode when a cookie value
+	// is malformed, was signed with a different key, or has been tampered
+	// with.
+	ErrInvalidCookie = errors.New("cookie value is invalid")
+
+	// ErrCookieHashKeyTooShort is returned by NewCookieCodec when the hash
+	// key is shorter than 32 bytes.
+	ErrCookieHashKeyTooShort = errors.New("cookie hash key must be at least 32 bytes")
+)
+
 // DeleteCookies effectively deletes all named cookies
 // by wiping all data and setting to expire immediately.
 func DeleteCookies(w http.ResponseWriter, cookieNames ...string) {
@@ -19,3 +38,153 @@ func DeleteCookies(w http.ResponseWriter, cookieNames ...string) {
 		http.SetCookie(w, c)
 	}
 }
+
+// CookieOptions controls the attributes of cookies created by NewCookie. The
+// zero value produces a Secure, HttpOnly, SameSite=Lax session cookie scoped
+// to the whole site.
+type CookieOptions struct {
+	// Path defaults to "/".
+	Path   string
+	Domain string
+
+	// MaxAge is how long the cookie should be kept by the browser. Both the
+	// Max-Age and Expires attributes are set from it, for the benefit of
+	// older clients. A zero MaxAge creates a session cookie.
+	MaxAge time.Duration
+
+	// SameSite defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+
+	// Insecure allows the cookie to be sent over plain HTTP. It should only
+	// be set for local development.
+	Insecure bool
+
+	// AllowScripts makes the cookie visible to JavaScript by omitting the
+	// HttpOnly attribute.
+	AllowScripts bool
+}
+
+// NewCookie returns a cookie with the given name and value and attributes
+// taken from opts.
+func NewCookie(name, value string, opts CookieOptions) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Secure:   !opts.Insecure,
+		HttpOnly: !opts.AllowScripts,
+		SameSite: opts.SameSite,
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	if opts.MaxAge > 0 {
+		SetCookieMaxAge(c, opts.MaxAge)
+	}
+	return c
+}
+
+// SetCookie adds a Set-Cookie header to w for a cookie created by NewCookie.
+func SetCookie(w http.ResponseWriter, name, value string, opts CookieOptions) {
+	http.SetCookie(w, NewCookie(name, value, opts))
+}
+
+// SetCookieMaxAge sets both the Max-Age and Expires attributes of c so that
+// it expires d from now. Durations are rounded down to whole seconds.
+func SetCookieMaxAge(c *http.Cookie, d time.Duration) {
+	secs := int(d / time.Second)
+	if secs <= 0 {
+		c.MaxAge = -1
+		c.Expires = time.Unix(1, 0).UTC()
+		return
+	}
+	c.MaxAge = secs
+	c.Expires = time.Now().Add(time.Duration(secs) * time.Second).UTC()
+}
+
+// CookieCodec signs, and optionally encrypts, cookie values so that they can
+// be handed to clients without being forged or, when encrypted, read. The
+// cookie name is bound into both the signature and the ciphertext, so a
+// value cannot be replayed under a different cookie name.
+type CookieCodec struct {
+	hashKey []byte
+	aead    cipher.AEAD
+}
+
+// NewCookieCodec returns a CookieCodec which signs values with HMAC-SHA256
+// using hashKey. If blockKey is non-nil, values are also encrypted with
+// AES-GCM; it must be 16, 24 or 32 bytes long to select AES-128, AES-192 or
+// AES-256.
+func NewCookieCodec(hashKey, blockKey []byte) (*CookieCodec, error) {
+	if len(hashKey) < 32 {
+		return nil, ErrCookieHashKeyTooShort
+	}
+	cc := &CookieCodec{hashKey: hashKey}
+	if blockKey != nil {
+		block, err := aes.NewCipher(blockKey)
+		if err != nil {
+			return nil, err
+		}
+		cc.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cc, nil
+}
+
+// Encode returns the signed, and possibly encrypted, form of value for use
+// as the value of the cookie called name.
+func (cc *CookieCodec) Encode(name, value string) (string, error) {
+	payload := []byte(value)
+	if cc.aead != nil {
+		nonce := make([]byte, cc.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = cc.aead.Seal(nonce, nonce, payload, []byte(name))
+	}
+	enc := base64.RawURLEncoding.EncodeToString(payload)
+	return enc + "." + base64.RawURLEncoding.EncodeToString(cc.mac(name, enc)), nil
+}
+
+// Decode verifies and, if necessary, decrypts a value previously produced by
+// Encode for the cookie called name. ErrInvalidCookie is returned if the
+// value cannot be authenticated.
+func (cc *CookieCodec) Decode(name, encoded string) (string, error) {
+	parts := strings.Split(encoded, ".")
+	if len(parts) != 2 {
+		return "", ErrInvalidCookie
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, cc.mac(name, parts[0])) {
+		return "", ErrInvalidCookie
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	if cc.aead != nil {
+		ns := cc.aead.NonceSize()
+		if len(payload) < ns {
+			return "", ErrInvalidCookie
+		}
+		payload, err = cc.aead.Open(nil, payload[:ns], payload[ns:], []byte(name))
+		if err != nil {
+			return "", ErrInvalidCookie
+		}
+	}
+	return string(payload), nil
+}
+
+func (cc *CookieCodec) mac(name, payload string) []byte {
+	m := hmac.New(sha256.New, cc.hashKey)
+	m.Write([]byte(name))
+	m.Write([]byte{0})
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
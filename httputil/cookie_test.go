@@ -3,6 +3,8 @@ package httputil
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -41,11 +43,128 @@ func TestDeleteCookies(t *testing.T) {
 				t.Errorf("case %d: unexpected cookie path, want: %q, got: %q", i, "/", c.Path)
 			}
 			if c.MaxAge != -1 {
-				t.Errorf("case %d: unexpected cookie max-age, want: %q, got: %q", i, -1, c.MaxAge)
+				t.Errorf("case %d: unexpected cookie max-age, want: %d, got: %d", i, -1, c.MaxAge)
 			}
 			if !c.Expires.IsZero() {
-				t.Errorf("case %d: unexpected cookie expires, want: %q, got: %q", i, time.Time{}, c.MaxAge)
+				t.Errorf("case %d: unexpected cookie expires, want: %v, got: %v", i, time.Time{}, c.Expires)
 			}
 		}
 	}
 }
+
+func TestNewCookie(t *testing.T) {
+	tests := []struct {
+		opts CookieOptions
+		want http.Cookie
+	}{
+		// defaults
+		{
+			opts: CookieOptions{},
+			want: http.Cookie{Path: "/", Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		},
+		// everything relaxed
+		{
+			opts: CookieOptions{
+				Path:         "/app",
+				Domain:       "example.com",
+				SameSite:     http.SameSiteNoneMode,
+				Insecure:     true,
+				AllowScripts: true,
+			},
+			want: http.Cookie{Path: "/app", Domain: "example.com", SameSite: http.SameSiteNoneMode},
+		},
+	}
+
+	for i, tt := range tests {
+		c := NewCookie("foo", "bar", tt.opts)
+		tt.want.Name = "foo"
+		tt.want.Value = "bar"
+		if !reflect.DeepEqual(*c, tt.want) {
+			t.Errorf("case %d: want: %+v, got: %+v", i, tt.want, *c)
+		}
+	}
+}
+
+func TestSetCookieMaxAge(t *testing.T) {
+	c := NewCookie("foo", "bar", CookieOptions{MaxAge: 90*time.Second + time.Millisecond})
+	if c.MaxAge != 90 {
+		t.Errorf("unexpected cookie max-age, want: %d, got: %d", 90, c.MaxAge)
+	}
+	if d := time.Until(c.Expires); d <= 85*time.Second || d > 90*time.Second {
+		t.Errorf("unexpected cookie expires: %v", c.Expires)
+	}
+
+	SetCookieMaxAge(c, 0)
+	if c.MaxAge != -1 {
+		t.Errorf("unexpected cookie max-age, want: %d, got: %d", -1, c.MaxAge)
+	}
+	if !c.Expires.Before(time.Now()) {
+		t.Errorf("unexpected cookie expires: %v", c.Expires)
+	}
+}
+
+func TestCookieCodec(t *testing.T) {
+	hashKey := []byte("0123456789abcdef0123456789abcdef")
+	blockKey := []byte("fedcba9876543210")
+
+	tests := []struct {
+		blockKey []byte
+	}{
+		// signed only
+		{blockKey: nil},
+		// signed and encrypted
+		{blockKey: blockKey},
+	}
+
+	for i, tt := range tests {
+		cc, err := NewCookieCodec(hashKey, tt.blockKey)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		enc, err := cc.Encode("session", "user=42")
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if tt.blockKey != nil && strings.Contains(enc, "dXNlcj00Mg") {
+			t.Errorf("case %d: encrypted value contains plaintext: %q", i, enc)
+		}
+
+		got, err := cc.Decode("session", enc)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if got != "user=42" {
+			t.Errorf("case %d: want: %q, got: %q", i, "user=42", got)
+		}
+
+		// Values are bound to the cookie name.
+		if _, err := cc.Decode("other", enc); err != ErrInvalidCookie {
+			t.Errorf("case %d: want ErrInvalidCookie for wrong name, got: %v", i, err)
+		}
+
+		// Any modification is detected.
+		tampered := []byte(enc)
+		tampered[0] ^= 1
+		if _, err := cc.Decode("session", string(tampered)); err != ErrInvalidCookie {
+			t.Errorf("case %d: want ErrInvalidCookie for tampered value, got: %v", i, err)
+		}
+		if _, err := cc.Decode("session", "garbage"); err != ErrInvalidCookie {
+			t.Errorf("case %d: want ErrInvalidCookie for garbage, got: %v", i, err)
+		}
+
+		// A codec with another key rejects the value.
+		other, _ := NewCookieCodec([]byte("another key that is long enough!"), tt.blockKey)
+		if _, err := other.Decode("session", enc); err != ErrInvalidCookie {
+			t.Errorf("case %d: want ErrInvalidCookie for wrong key, got: %v", i, err)
+		}
+	}
+}
+
+func TestNewCookieCodecBadKeys(t *testing.T) {
+	if _, err := NewCookieCodec([]byte("short"), nil); err != ErrCookieHashKeyTooShort {
+		t.Errorf("want ErrCookieHashKeyTooShort, got: %v", err)
+	}
+	if _, err := NewCookieCodec(make([]byte, 32), make([]byte, 7)); err == nil {
+		t.Errorf("expected error for bad block key size")
+	}
+}
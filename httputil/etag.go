@@ -0,0 +1,112 @@
+package httputil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns a quoted entity tag derived from the SHA-256 of data. If weak
+// is true, the tag is marked as a weak validator.
+func ETag(data []byte, weak bool) string {
+	sum := sha256.Sum256(data)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// CheckConditionalRequest evaluates the conditional headers of r against the
+// current etag and modification time of the resource being served, following
+// the precedence rules of RFC 7232 section 6. Either validator may be left
+// empty or zero if the resource does not have one.
+//
+// The ETag and Last-Modified headers are set on w. If the request's
+// preconditions mean the body should not be sent, a 304 Not Modified or 412
+// Precondition Failed is written and true is returned; the caller should then
+// not write anything further.
+func CheckConditionalRequest(w http.ResponseWriter, r *http.Request, etag string, modtime time.Time) bool {
+	modtime = modtime.Truncate(time.Second)
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !modtime.IsZero() && !modtime.Equal(time.Unix(0, 0)) {
+		w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+
+	// Step 1 and 2: If-Match, then If-Unmodified-Since.
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !ETagMatch(im, etag, false) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+	} else if ius, ok := parseHTTPTime(r.Header.Get("If-Unmodified-Since")); ok && !modtime.IsZero() {
+		if modtime.After(ius) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+	}
+
+	getOrHead := r.Method == "GET" || r.Method == "HEAD" || r.Method == ""
+
+	// Step 3 and 4: If-None-Match, then If-Modified-Since.
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if ETagMatch(inm, etag, true) {
+			if getOrHead {
+				writeNotModified(w)
+			} else {
+				w.WriteHeader(http.StatusPreconditionFailed)
+			}
+			return true
+		}
+	} else if ims, ok := parseHTTPTime(r.Header.Get("If-Modified-Since")); ok && getOrHead && !modtime.IsZero() {
+		if !modtime.After(ims) {
+			writeNotModified(w)
+			return true
+		}
+	}
+	return false
+}
+
+// ETagMatch reports whether etag is matched by a list of entity tags as
+// found in If-Match or If-None-Match headers. A list of "*" matches any
+// existing entity. Weak comparison ignores the weakness indicator; strong
+// comparison never matches weak tags.
+func ETagMatch(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func parseHTTPTime(v string) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(v)
+	return t, err == nil
+}
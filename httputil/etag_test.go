@@ -0,0 +1,84 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	strong := ETag([]byte("hello"), false)
+	weak := ETag([]byte("hello"), true)
+	if strong != `"2cf24dba5fb0a30e26e83b2ac5b9e29e"` {
+		t.Errorf("unexpected strong etag: %s", strong)
+	}
+	if weak != "W/"+strong {
+		t.Errorf("unexpected weak etag: %s", weak)
+	}
+	if ETag([]byte("world"), false) == strong {
+		t.Errorf("different content produced the same etag")
+	}
+}
+
+func TestCheckConditionalRequest(t *testing.T) {
+	etag := `"abc"`
+	modtime := time.Date(2016, 1, 2, 3, 4, 5, 600, time.UTC)
+	before := modtime.Add(-time.Hour).Format(http.TimeFormat)
+	at := modtime.Format(http.TimeFormat)
+
+	for i, test := range []struct {
+		method       string
+		headers      map[string]string
+		etag         string
+		expectedDone bool
+		expectedCode int
+	}{
+		{"GET", nil, etag, false, http.StatusOK},
+		{"GET", map[string]string{"If-None-Match": `"xyz", "abc"`}, etag, true, http.StatusNotModified},
+		{"HEAD", map[string]string{"If-None-Match": `W/"abc"`}, etag, true, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": "*"}, etag, true, http.StatusNotModified},
+		{"GET", map[string]string{"If-None-Match": `"xyz"`}, etag, false, http.StatusOK},
+		{"PUT", map[string]string{"If-None-Match": `"abc"`}, etag, true, http.StatusPreconditionFailed},
+		// If-None-Match takes precedence over If-Modified-Since.
+		{"GET", map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": at}, etag, false, http.StatusOK},
+		{"GET", map[string]string{"If-Modified-Since": at}, etag, true, http.StatusNotModified},
+		{"GET", map[string]string{"If-Modified-Since": before}, etag, false, http.StatusOK},
+		{"POST", map[string]string{"If-Modified-Since": at}, etag, false, http.StatusOK},
+		{"GET", map[string]string{"If-Modified-Since": "garbage"}, etag, false, http.StatusOK},
+		// If-Match uses strong comparison.
+		{"PUT", map[string]string{"If-Match": `"abc"`}, etag, false, http.StatusOK},
+		{"PUT", map[string]string{"If-Match": `"xyz"`}, etag, true, http.StatusPreconditionFailed},
+		{"PUT", map[string]string{"If-Match": `W/"abc"`}, `W/"abc"`, true, http.StatusPreconditionFailed},
+		// If-Match takes precedence over If-Unmodified-Since.
+		{"PUT", map[string]string{"If-Match": `"abc"`, "If-Unmodified-Since": before}, etag, false, http.StatusOK},
+		{"PUT", map[string]string{"If-Unmodified-Since": before}, etag, true, http.StatusPreconditionFailed},
+		{"PUT", map[string]string{"If-Unmodified-Since": at}, etag, false, http.StatusOK},
+		// No etag at all never matches.
+		{"GET", map[string]string{"If-None-Match": "*"}, "", false, http.StatusOK},
+	} {
+		r := httptest.NewRequest(test.method, "/", nil)
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "text/plain")
+
+		done := CheckConditionalRequest(w, r, test.etag, modtime)
+		if done != test.expectedDone {
+			t.Errorf("case %d: done == %t, want %t", i, done, test.expectedDone)
+		}
+		if w.Code != test.expectedCode {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, test.expectedCode)
+		}
+		if got := w.Header().Get("Last-Modified"); got != at {
+			t.Errorf("case %d: Last-Modified == %q, want %q", i, got, at)
+		}
+		if got := w.Header().Get("ETag"); got != test.etag {
+			t.Errorf("case %d: ETag == %q, want %q", i, got, test.etag)
+		}
+		if w.Code == http.StatusNotModified && w.Header().Get("Content-Type") != "" {
+			t.Errorf("case %d: Content-Type should be removed from 304s", i)
+		}
+	}
+}
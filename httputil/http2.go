@@ -0,0 +1,77 @@
+package httputil
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTP2Options holds the HTTP/2 specific settings applied by ConfigureHTTP2.
+// Zero values leave the net/http defaults in place.
+type HTTP2Options struct {
+	// MaxConcurrentStreams limits the number of streams each client may
+	// have open at once.
+	MaxConcurrentStreams int
+
+	// MaxReadFrameSize is the largest frame the server is willing to read.
+	MaxReadFrameSize int
+
+	// IdleTimeout closes connections with no active streams after the
+	// given duration. It is applied to http.Server.IdleTimeout, and so
+	// affects HTTP/1 keep-alive connections too.
+	IdleTimeout time.Duration
+
+	// PingTimeout, if set, makes the server ping connections which have
+	// been idle for SendPingTimeout, closing them if no answer is received
+	// within PingTimeout.
+	SendPingTimeout time.Duration
+	PingTimeout     time.Duration
+
+	// H2C enables HTTP/2 over cleartext TCP connections using prior
+	// knowledge, as gRPC clients do. The HTTP/1.1 Upgrade mechanism is not
+	// supported.
+	H2C bool
+}
+
+// ConfigureHTTP2 enables HTTP/2 on srv, alongside HTTP/1, and applies opts.
+func ConfigureHTTP2(srv *http.Server, opts HTTP2Options) {
+	if srv.Protocols == nil {
+		srv.Protocols = new(http.Protocols)
+	}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+	if opts.H2C {
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	if srv.HTTP2 == nil {
+		srv.HTTP2 = new(http.HTTP2Config)
+	}
+	if opts.MaxConcurrentStreams != 0 {
+		srv.HTTP2.MaxConcurrentStreams = opts.MaxConcurrentStreams
+	}
+	if opts.MaxReadFrameSize != 0 {
+		srv.HTTP2.MaxReadFrameSize = opts.MaxReadFrameSize
+	}
+	if opts.SendPingTimeout != 0 {
+		srv.HTTP2.SendPingTimeout = opts.SendPingTimeout
+	}
+	if opts.PingTimeout != 0 {
+		srv.HTTP2.PingTimeout = opts.PingTimeout
+	}
+	if opts.IdleTimeout != 0 {
+		srv.IdleTimeout = opts.IdleTimeout
+	}
+}
+
+// NewH2CServer returns a server for handler listening on addr which accepts
+// both HTTP/1 and cleartext HTTP/2 connections.
+func NewH2CServer(addr string, handler http.Handler, opts HTTP2Options) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	opts.H2C = true
+	ConfigureHTTP2(srv, opts)
+	return srv
+}
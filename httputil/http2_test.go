@@ -0,0 +1,62 @@
+package httputil
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConfigureHTTP2(t *testing.T) {
+	srv := &http.Server{}
+	ConfigureHTTP2(srv, HTTP2Options{
+		MaxConcurrentStreams: 50,
+		IdleTimeout:          time.Minute,
+	})
+	if !srv.Protocols.HTTP1() || !srv.Protocols.HTTP2() || srv.Protocols.UnencryptedHTTP2() {
+		t.Errorf("unexpected protocols: %v", srv.Protocols)
+	}
+	if srv.HTTP2.MaxConcurrentStreams != 50 {
+		t.Errorf("MaxConcurrentStreams == %d, want %d", srv.HTTP2.MaxConcurrentStreams, 50)
+	}
+	if srv.IdleTimeout != time.Minute {
+		t.Errorf("IdleTimeout == %v, want %v", srv.IdleTimeout, time.Minute)
+	}
+}
+
+func TestNewH2CServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv := NewH2CServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+	}), HTTP2Options{})
+	go srv.Serve(l)
+	defer srv.Close()
+
+	for i, test := range []struct {
+		h2c      bool
+		expected string
+	}{
+		{false, "HTTP/1.1"},
+		{true, "HTTP/2.0"},
+	} {
+		tr := &http.Transport{Protocols: new(http.Protocols)}
+		if test.h2c {
+			tr.Protocols.SetUnencryptedHTTP2(true)
+		} else {
+			tr.Protocols.SetHTTP1(true)
+		}
+		resp, err := (&http.Client{Transport: tr}).Get("http://" + l.Addr().String())
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Proto"); got != test.expected {
+			t.Errorf("case %d: server saw %q, want %q", i, got, test.expected)
+		}
+		tr.CloseIdleConnections()
+	}
+}
@@ -2,15 +2,32 @@ package httputil
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
 )
 
 const (
 	JSONContentType = "application/json"
+
+	// DefaultMaxJSONBodySize is the body size limit applied by ReadJSONBody
+	// when it is called with a maxBytes of 0.
+	DefaultMaxJSONBodySize = 1 << 20
 )
 
+// WriteJSONResponse is equivalent to WriteJSON.
 func WriteJSONResponse(w http.ResponseWriter, code int, resp interface{}) error {
-	enc, err := json.Marshal(resp)
+	return WriteJSON(w, code, resp)
+}
+
+// WriteJSON marshals v and writes it to w with the given status code and a
+// JSON Content-Type. If v cannot be marshaled, a 500 is written instead and
+// the marshaling error is returned.
+func WriteJSON(w http.ResponseWriter, code int, v interface{}) error {
+	enc, err := json.Marshal(v)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return err
@@ -25,3 +42,119 @@ func WriteJSONResponse(w http.ResponseWriter, code int, resp interface{}) error
 	}
 	return nil
 }
+
+// ErrorResponse is the envelope used for all JSON error responses written by
+// WriteJSONError.
+type ErrorResponse struct {
+	Error ErrorResponseDetails `json:"error"`
+}
+
+type ErrorResponseDetails struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RequestError describes a client error, along with the HTTP status code the
+// request should be rejected with.
+type RequestError struct {
+	Code    int
+	Message string
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// WriteJSONError writes err to w wrapped in an ErrorResponse. If err is a
+// *RequestError, its code and message are used. Any other error is reported
+// as a generic 500 so that internal details are not leaked to clients.
+func WriteJSONError(w http.ResponseWriter, err error) error {
+	details := ErrorResponseDetails{
+		Code:    http.StatusInternalServerError,
+		Message: http.StatusText(http.StatusInternalServerError),
+	}
+	var rerr *RequestError
+	if errors.As(err, &rerr) {
+		details.Code = rerr.Code
+		details.Message = rerr.Message
+	}
+	return WriteJSON(w, details.Code, ErrorResponse{Error: details})
+}
+
+// ReadJSONBody decodes the JSON body of r into v. The request must have a
+// JSON Content-Type and a body of at most maxBytes bytes containing exactly
+// one JSON value. Unknown fields are ignored. All failures are reported as a
+// *RequestError suitable for passing to WriteJSONError. On error, v may have
+// been partially filled in.
+func ReadJSONBody(r *http.Request, v interface{}, maxBytes int64) error {
+	return readJSONBody(r, v, maxBytes, false)
+}
+
+// ReadJSONBodyStrict is like ReadJSONBody, but rejects bodies containing
+// object keys which do not match a field in v.
+func ReadJSONBodyStrict(r *http.Request, v interface{}, maxBytes int64) error {
+	return readJSONBody(r, v, maxBytes, true)
+}
+
+func readJSONBody(r *http.Request, v interface{}, maxBytes int64, strict bool) error {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return &RequestError{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("Content-Type must be %s", JSONContentType),
+		}
+	}
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxJSONBodySize
+	}
+	if r.Body == nil {
+		return &RequestError{Code: http.StatusBadRequest, Message: "request body must not be empty"}
+	}
+
+	lr := &io.LimitedReader{R: r.Body, N: maxBytes + 1}
+	dec := json.NewDecoder(lr)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("request body must contain a single JSON value")
+	}
+	if lr.N <= 0 {
+		return &RequestError{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("request body must not be larger than %d bytes", maxBytes),
+		}
+	}
+	if err != nil {
+		return &RequestError{Code: http.StatusBadRequest, Message: jsonErrorMessage(err)}
+	}
+	return nil
+}
+
+func isJSONContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == JSONContentType || (strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json"))
+}
+
+func jsonErrorMessage(err error) string {
+	var serr *json.SyntaxError
+	var terr *json.UnmarshalTypeError
+	switch {
+	case err == io.EOF:
+		return "request body must not be empty"
+	case err == io.ErrUnexpectedEOF:
+		return "request body contains truncated JSON"
+	case errors.As(err, &serr):
+		return fmt.Sprintf("request body contains malformed JSON at offset %d", serr.Offset)
+	case errors.As(err, &terr):
+		if terr.Field != "" {
+			return fmt.Sprintf("request body contains an invalid value for field %q", terr.Field)
+		}
+		return fmt.Sprintf("request body contains an invalid value at offset %d", terr.Offset)
+	}
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
@@ -1,7 +1,11 @@
 package httputil
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -54,3 +58,103 @@ func TestWriteJSONResponse(t *testing.T) {
 	}
 
 }
+
+func TestReadJSONBody(t *testing.T) {
+	type payload struct {
+		A string `json:"a"`
+		B int    `json:"b"`
+	}
+
+	for i, test := range []struct {
+		contentType  string
+		body         string
+		maxBytes     int64
+		strict       bool
+		expected     payload
+		expectedCode int
+	}{
+		{JSONContentType, `{"a":"foo","b":2}`, 0, false, payload{A: "foo", B: 2}, 0},
+		{"application/json; charset=utf-8", `{"a":"foo"}`, 0, false, payload{A: "foo"}, 0},
+		{"application/merge-patch+json", `{"b":3}`, 0, false, payload{B: 3}, 0},
+		{JSONContentType, `{"a":"foo","c":true}`, 0, false, payload{A: "foo"}, 0},
+		{JSONContentType, `{"a":"foo"}`, 11, false, payload{A: "foo"}, 0},
+
+		// Unknown fields in strict mode.
+		{JSONContentType, `{"a":"foo","c":true}`, 0, true, payload{A: "foo"}, http.StatusBadRequest},
+		// Wrong or missing content type.
+		{"text/plain", `{"a":"foo"}`, 0, false, payload{}, http.StatusUnsupportedMediaType},
+		{"", `{"a":"foo"}`, 0, false, payload{}, http.StatusUnsupportedMediaType},
+		// Too large.
+		{JSONContentType, `{"a":"foo"}`, 10, false, payload{A: "foo"}, http.StatusRequestEntityTooLarge},
+		// Malformed, truncated, empty, trailing data and type mismatch.
+		{JSONContentType, `{"a":}`, 0, false, payload{}, http.StatusBadRequest},
+		{JSONContentType, `{"a":"foo"`, 0, false, payload{}, http.StatusBadRequest},
+		{JSONContentType, ``, 0, false, payload{}, http.StatusBadRequest},
+		{JSONContentType, `{"a":"foo"}{}`, 0, false, payload{A: "foo"}, http.StatusBadRequest},
+		{JSONContentType, `{"b":"foo"}`, 0, false, payload{}, http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+
+		var got payload
+		var err error
+		if test.strict {
+			err = ReadJSONBodyStrict(r, &got, test.maxBytes)
+		} else {
+			err = ReadJSONBody(r, &got, test.maxBytes)
+		}
+
+		if test.expectedCode == 0 {
+			if err != nil {
+				t.Errorf("case %d: unexpected error: %v", i, err)
+			}
+		} else {
+			rerr, ok := err.(*RequestError)
+			if !ok {
+				t.Errorf("case %d: err == %#v, want *RequestError", i, err)
+			} else if rerr.Code != test.expectedCode {
+				t.Errorf("case %d: err.Code == %d, want %d (%v)", i, rerr.Code, test.expectedCode, rerr)
+			}
+		}
+		if got != test.expected {
+			t.Errorf("case %d: got %+v, want %+v", i, got, test.expected)
+		}
+	}
+}
+
+func TestWriteJSONError(t *testing.T) {
+	for i, test := range []struct {
+		err          error
+		expectedCode int
+		expectedJSON string
+	}{
+		{
+			&RequestError{Code: http.StatusBadRequest, Message: "bad"},
+			http.StatusBadRequest,
+			`{"error":{"code":400,"message":"bad"}}`,
+		},
+		{
+			fmt.Errorf("wrapped: %w", &RequestError{Code: http.StatusConflict, Message: "taken"}),
+			http.StatusConflict,
+			`{"error":{"code":409,"message":"taken"}}`,
+		},
+		{
+			errors.New("database password is hunter2"),
+			http.StatusInternalServerError,
+			`{"error":{"code":500,"message":"Internal Server Error"}}`,
+		},
+	} {
+		w := httptest.NewRecorder()
+		if err := WriteJSONError(w, test.err); err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if w.Code != test.expectedCode {
+			t.Errorf("case %d: w.code == %v, want %v", i, w.Code, test.expectedCode)
+		}
+		if w.Body.String() != test.expectedJSON {
+			t.Errorf("case %d: body == %q, want %q", i, w.Body.String(), test.expectedJSON)
+		}
+	}
+}
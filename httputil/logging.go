@@ -0,0 +1,108 @@
+package httputil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+// LoggingHandler is middleware which writes an access log line for every
+// request passed to Handler. Entries are written to Logger as space
+// separated key=value pairs, e.g.:
+//
+//	method=GET path="/v1/keys" status=200 size=512 duration=1.2ms client=10.0.0.1
+//
+// If the request carries an ID (see RequestIDHandler), it is appended as
+// request_id.
+//
+// Requests which fail with a 5xx status are logged at ERROR and are always
+// written. All other requests are logged at INFO, subject to SampleSuccess.
+type LoggingHandler struct {
+	Handler http.Handler
+	Logger  *capnslog.PackageLogger
+
+	// SampleSuccess, when greater than one, causes only one in every
+	// SampleSuccess requests with a non-5xx status to be logged.
+	SampleSuccess int
+
+	// TrustedProxies lists the networks whose forwarding headers are
+	// believed when logging the client address. See ClientIP.
+	TrustedProxies []*net.IPNet
+
+	count uint64
+}
+
+func (lh *LoggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rw := &responseRecorder{ResponseWriter: w}
+	lh.Handler.ServeHTTP(rw, r)
+	dur := time.Since(start)
+
+	status := rw.Status()
+	level := capnslog.INFO
+	if status >= 500 {
+		level = capnslog.ERROR
+	} else {
+		if !lh.Logger.LevelAt(level) {
+			return
+		}
+		if lh.SampleSuccess > 1 && (atomic.AddUint64(&lh.count, 1)-1)%uint64(lh.SampleSuccess) != 0 {
+			return
+		}
+	}
+
+	msg := fmt.Sprintf("method=%s path=%q status=%d size=%d duration=%s client=%s",
+		r.Method, r.URL.Path, status, rw.size, dur, ClientIP(r, lh.TrustedProxies))
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		msg += " request_id=" + id
+	}
+	lh.Logger.Log(level, msg)
+}
+
+// responseRecorder wraps an http.ResponseWriter, keeping track of the
+// status code and number of bytes written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// Status returns the status code sent to the client. As with net/http, a
+// handler which never calls WriteHeader is considered to have sent a 200.
+func (rr *responseRecorder) Status() int {
+	if rr.status == 0 {
+		return http.StatusOK
+	}
+	return rr.status
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if rr.status == 0 {
+		rr.status = code
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.size += int64(n)
+	return n, err
+}
+
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
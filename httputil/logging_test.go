@@ -0,0 +1,74 @@
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+func TestLoggingHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	capnslog.SetFormatter(capnslog.NewStringFormatter(buf))
+	defer capnslog.SetFormatter(capnslog.NewNilFormatter())
+	plog := capnslog.NewPackageLogger("github.com/coreos/pkg", "httputil_test")
+
+	status := http.StatusOK
+	lh := &LoggingHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte("hello"))
+		}),
+		Logger:        plog,
+		SampleSuccess: 2,
+	}
+
+	for i, test := range []struct {
+		status   int
+		expected string
+	}{
+		// First successful request is logged, second is sampled out.
+		{http.StatusOK, "method=GET path=\"/foo\" status=200 size=5 duration="},
+		{http.StatusOK, ""},
+		// Server errors are always logged.
+		{http.StatusBadGateway, "method=GET path=\"/foo\" status=502 size=5 duration="},
+		{http.StatusInternalServerError, "status=500"},
+		{http.StatusNotFound, "status=404"},
+	} {
+		buf.Reset()
+		status = test.status
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.RemoteAddr = "10.0.0.1:4321"
+		w := httptest.NewRecorder()
+		lh.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, test.status)
+		}
+		got := buf.String()
+		if test.expected == "" {
+			if got != "" {
+				t.Errorf("case %d: unexpected log output: %q", i, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, test.expected) || !strings.Contains(got, "client=10.0.0.1\n") {
+			t.Errorf("case %d: log output %q does not contain %q", i, got, test.expected)
+		}
+	}
+}
+
+func TestResponseRecorderImplicitStatus(t *testing.T) {
+	rr := &responseRecorder{ResponseWriter: httptest.NewRecorder()}
+	if rr.Status() != http.StatusOK {
+		t.Errorf("status == %d, want %d", rr.Status(), http.StatusOK)
+	}
+	rr.Write([]byte("abc"))
+	rr.WriteHeader(http.StatusTeapot)
+	if rr.Status() != http.StatusOK || rr.size != 3 {
+		t.Errorf("got status %d size %d, want %d and %d", rr.Status(), rr.size, http.StatusOK, 3)
+	}
+}
@@ -0,0 +1,275 @@
+package httputil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/coreos/pkg/progressutil"
+)
+
+// DefaultMaxMultipartFieldSize is the default MaxFieldSize of a
+// MultipartReader.
+const DefaultMaxMultipartFieldSize = 1 << 20
+
+// MultipartFile is a file sent as a part of a MultipartUpload.
+type MultipartFile struct {
+	// Field is the name of the form field, and Name the file name given
+	// to the server.
+	Field string
+	Name  string
+
+	// ContentType defaults to application/octet-stream.
+	ContentType string
+
+	Body io.Reader
+
+	// Size is the size of Body if known, and 0 otherwise. It is only
+	// used to report progress.
+	Size int64
+}
+
+// MultipartUpload is a multipart/form-data request body which is streamed
+// as the request is sent, so that files are never held in memory.
+type MultipartUpload struct {
+	// Fields are sent before the files, in the order of their names.
+	Fields url.Values
+	Files  []MultipartFile
+
+	// Progress, if set, reports the progress of each file.
+	// NewRequest adds a copy of each file to it, so PrintAndWait is
+	// then called while the request is sent.
+	Progress *progressutil.CopyProgressPrinter
+}
+
+// NewRequest returns a request to target with the upload as its body. The
+// body is read from the files as it is sent, so the request cannot be
+// retried, and it is only sent once.
+func (u *MultipartUpload) NewRequest(ctx context.Context, method, target string) (*http.Request, error) {
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]io.Reader, len(u.Files))
+	var pipes []*io.PipeReader
+	for i, f := range u.Files {
+		sources[i] = f.Body
+		if u.Progress == nil {
+			continue
+		}
+		// The progress printer copies each file on its own goroutine,
+		// through a pipe which the body reads in turn.
+		pr, pw := io.Pipe()
+		src := &closingReader{r: f.Body, w: pw}
+		if err := u.Progress.AddCopyContext(ctx, src, f.Name, f.Size, pw); err != nil {
+			for _, p := range pipes {
+				p.Close()
+			}
+			return nil, err
+		}
+		sources[i] = pr
+		pipes = append(pipes, pr)
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	req.Body = pr
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	done := make(chan struct{})
+	go func() {
+		err := u.write(mw, sources)
+		if err == nil {
+			err = mw.Close()
+		}
+		close(done)
+		// Unblock the copies of files which were not sent.
+		for _, p := range pipes {
+			p.CloseWithError(errUnsent(err))
+		}
+		pw.CloseWithError(err)
+	}()
+	if len(pipes) > 0 && ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				for _, p := range pipes {
+					p.CloseWithError(ctx.Err())
+				}
+			case <-done:
+			}
+		}()
+	}
+	return req.WithContext(ctx), nil
+}
+
+func (u *MultipartUpload) write(mw *multipart.Writer, sources []io.Reader) error {
+	names := make([]string, 0, len(u.Fields))
+	for name := range u.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range u.Fields[name] {
+			if err := mw.WriteField(name, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, f := range u.Files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+			"name":     f.Field,
+			"filename": f.Name,
+		}))
+		ct := f.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		h.Set("Content-Type", ct)
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, sources[i]); err != nil {
+			return fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// errUnsent returns the error with which the copy of a file fails when the
+// body was finished or aborted before the file was read.
+func errUnsent(err error) error {
+	if err == nil {
+		return io.ErrClosedPipe
+	}
+	return err
+}
+
+// closingReader closes w once r is exhausted, so that a copy from r into w
+// ends the pipe w writes to. Data read along with an error is returned
+// first, so that it is written before the pipe is closed.
+type closingReader struct {
+	r   io.Reader
+	w   *io.PipeWriter
+	err error
+}
+
+func (c *closingReader) Read(p []byte) (int, error) {
+	if c.err == nil {
+		var n int
+		n, c.err = c.r.Read(p)
+		if n > 0 || c.err == nil {
+			return n, nil
+		}
+	}
+	c.w.CloseWithError(c.err)
+	return 0, c.err
+}
+
+// MultipartReader reads multipart/form-data request bodies part by part, so
+// that uploaded files are streamed rather than held in memory, as
+// http.Request.ParseMultipartForm would.
+type MultipartReader struct {
+	// MaxFileSize, if set, limits the size of each file part.
+	MaxFileSize int64
+
+	// MaxFieldSize limits the size of each other part, and defaults to
+	// DefaultMaxMultipartFieldSize.
+	MaxFieldSize int64
+
+	// MaxParts, if set, limits the number of parts.
+	MaxParts int
+}
+
+// MultipartPart is a part of a body read by a MultipartReader. Reading it
+// past its size limit fails with a *RequestError with the code 413.
+type MultipartPart struct {
+	*multipart.Part
+
+	n, limit int64 // limit is negative if unlimited
+}
+
+func (p *MultipartPart) Read(b []byte) (int, error) {
+	if p.limit < 0 {
+		return p.Part.Read(b)
+	}
+	if p.n > p.limit {
+		return 0, p.tooLarge()
+	}
+	n, err := p.Part.Read(b)
+	p.n += int64(n)
+	if p.n > p.limit {
+		return n - int(p.n-p.limit), p.tooLarge()
+	}
+	return n, err
+}
+
+func (p *MultipartPart) tooLarge() error {
+	return &RequestError{
+		Code:    http.StatusRequestEntityTooLarge,
+		Message: fmt.Sprintf("part %q must not be larger than %d bytes", p.FormName(), p.limit),
+	}
+}
+
+// ReadParts calls fn with each part of the body of r, in order, until fn
+// returns an error, which ReadParts returns. A part need not be read to its
+// end. Failures of the request itself, such as a body which is not
+// multipart/form-data, are reported as a *RequestError suitable for passing
+// to WriteJSONError.
+func (mr MultipartReader) ReadParts(r *http.Request, fn func(p *MultipartPart) error) error {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.EqualFold(mt, "multipart/form-data") {
+		return &RequestError{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: "Content-Type must be multipart/form-data",
+		}
+	}
+	rd, err := r.MultipartReader()
+	if err != nil {
+		return &RequestError{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	maxField := mr.MaxFieldSize
+	if maxField == 0 {
+		maxField = DefaultMaxMultipartFieldSize
+	}
+	for i := 0; ; i++ {
+		part, err := rd.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &RequestError{Code: http.StatusBadRequest, Message: fmt.Sprintf("malformed multipart body: %v", err)}
+		}
+		if mr.MaxParts > 0 && i == mr.MaxParts {
+			return &RequestError{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf("request body must not have more than %d parts", mr.MaxParts),
+			}
+		}
+
+		p := &MultipartPart{Part: part, limit: maxField}
+		if part.FileName() != "" {
+			p.limit = mr.MaxFileSize
+			if p.limit == 0 {
+				p.limit = -1
+			}
+		}
+		err = fn(p)
+		part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
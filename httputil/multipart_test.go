@@ -0,0 +1,177 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/progressutil"
+)
+
+type uploadedPart struct {
+	Field, Name, ContentType string
+	Size                     int
+	Sum                      [sha256.Size]byte
+}
+
+func multipartServer(t *testing.T, mr MultipartReader, parts *[]uploadedPart) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*parts = nil
+		err := mr.ReadParts(r, func(p *MultipartPart) error {
+			h := sha256.New()
+			n, err := io.Copy(h, p)
+			if err != nil {
+				return err
+			}
+			up := uploadedPart{Field: p.FormName(), Name: p.FileName(), Size: int(n)}
+			if up.Name != "" {
+				up.ContentType = p.Header.Get("Content-Type")
+			}
+			copy(up.Sum[:], h.Sum(nil))
+			*parts = append(*parts, up)
+			return nil
+		})
+		if err != nil {
+			WriteJSONError(w, err)
+		}
+	}))
+}
+
+func TestMultipartUpload(t *testing.T) {
+	var parts []uploadedPart
+	ts := multipartServer(t, MultipartReader{MaxFileSize: 2 << 20}, &parts)
+	defer ts.Close()
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	u := &MultipartUpload{
+		Fields: url.Values{"tag": {"v1", "latest"}, "arch": {"amd64"}},
+		Files: []MultipartFile{
+			{Field: "image", Name: "image.tar", Body: bytes.NewReader(big), Size: int64(len(big))},
+			{Field: "sig", Name: "image.tar.asc", ContentType: "application/pgp-signature", Body: strings.NewReader("signature")},
+		},
+		Progress: progressutil.NewCopyProgressPrinter(),
+	}
+	req, err := u.NewRequest(context.Background(), "POST", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	printed := make(chan error, 1)
+	var out bytes.Buffer
+	go func() { printed <- u.Progress.PrintAndWait(&out, time.Millisecond, nil) }()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s", res.Status)
+	}
+	if err := <-printed; err != nil {
+		t.Errorf("progress: %v", err)
+	}
+	if !strings.Contains(out.String(), "Copied") {
+		t.Errorf("progress summary missing: %q", out.String())
+	}
+
+	want := []uploadedPart{
+		{Field: "arch", Size: 5, Sum: sha256.Sum256([]byte("amd64"))},
+		{Field: "tag", Size: 2, Sum: sha256.Sum256([]byte("v1"))},
+		{Field: "tag", Size: 6, Sum: sha256.Sum256([]byte("latest"))},
+		{Field: "image", Name: "image.tar", ContentType: "application/octet-stream", Size: len(big), Sum: sha256.Sum256(big)},
+		{Field: "sig", Name: "image.tar.asc", ContentType: "application/pgp-signature", Size: 9, Sum: sha256.Sum256([]byte("signature"))},
+	}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("want=%+v\ngot=%+v", want, parts)
+	}
+}
+
+func TestMultipartReaderLimits(t *testing.T) {
+	tests := []struct {
+		mr   MultipartReader
+		code int
+	}{
+		{MultipartReader{}, http.StatusOK},
+		{MultipartReader{MaxFileSize: 10}, http.StatusOK},
+		{MultipartReader{MaxFileSize: 9}, http.StatusRequestEntityTooLarge},
+		{MultipartReader{MaxFieldSize: 4}, http.StatusRequestEntityTooLarge},
+		{MultipartReader{MaxParts: 2}, http.StatusOK},
+		{MultipartReader{MaxParts: 1}, http.StatusRequestEntityTooLarge},
+	}
+	for i, tt := range tests {
+		var parts []uploadedPart
+		ts := multipartServer(t, tt.mr, &parts)
+		u := &MultipartUpload{
+			Fields: url.Values{"name": {"hello"}},
+			Files:  []MultipartFile{{Field: "f", Name: "f.txt", Body: strings.NewReader("0123456789")}},
+		}
+		req, err := u.NewRequest(context.Background(), "PUT", ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.code {
+			t.Errorf("case %d: want code %d, got %d", i, tt.code, res.StatusCode)
+		}
+		ts.Close()
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("{}"))
+	r.Header.Set("Content-Type", JSONContentType)
+	err := MultipartReader{}.ReadParts(r, func(*MultipartPart) error { return nil })
+	var rerr *RequestError
+	if !errors.As(err, &rerr) || rerr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("disk on fire") }
+
+func TestMultipartUploadAborted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	u := &MultipartUpload{
+		Files: []MultipartFile{
+			{Field: "a", Name: "a", Body: failingReader{}},
+			{Field: "b", Name: "b", Body: strings.NewReader("never sent")},
+		},
+		Progress: progressutil.NewCopyProgressPrinter(),
+	}
+	req, err := u.NewRequest(context.Background(), "POST", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed := make(chan error, 1)
+	go func() { printed <- u.Progress.PrintAndWait(ioutil.Discard, time.Millisecond, nil) }()
+	if res, err := http.DefaultClient.Do(req); err == nil {
+		res.Body.Close()
+		t.Errorf("upload of a failing file succeeded")
+	}
+	select {
+	case err := <-printed:
+		if err == nil || !strings.Contains(err.Error(), "disk on fire") {
+			t.Errorf("unexpected progress error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("progress printer did not finish")
+	}
+}
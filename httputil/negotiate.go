@@ -0,0 +1,131 @@
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AcceptSpec is one entry of an Accept-style header, such as "text/html;q=0.8".
+type AcceptSpec struct {
+	// Value is the media range or coding, lower cased, without parameters.
+	Value string
+	// Q is the quality value, between 0 and 1.
+	Q float64
+}
+
+// ParseAccept parses an Accept, Accept-Encoding, Accept-Charset or
+// Accept-Language header value. Entries are returned in the order they
+// appear; entries with malformed quality values are dropped.
+func ParseAccept(header string) []AcceptSpec {
+	var specs []AcceptSpec
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(fields[0]))
+		if value == "" {
+			continue
+		}
+		spec := AcceptSpec{Value: value, Q: 1}
+		valid := true
+		for _, param := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || strings.ToLower(strings.TrimSpace(kv[0])) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			spec.Q = q
+		}
+		if valid {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// NegotiateContentType returns the entry of offers, a list of media types
+// in the server's order of preference, which best satisfies the Accept
+// header of r. Each offer is weighed by the most specific media range
+// matching it. If r has no Accept header the first offer is returned; if
+// nothing acceptable is offered, "" is returned.
+func NegotiateContentType(r *http.Request, offers ...string) string {
+	header := strings.Join(r.Header["Accept"], ",")
+	if strings.TrimSpace(header) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	specs := ParseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		o := strings.ToLower(offer)
+		slash := strings.IndexByte(o, '/')
+		if slash < 0 {
+			continue
+		}
+		q, specificity := 0.0, -1
+		for _, s := range specs {
+			var sp int
+			switch {
+			case s.Value == o:
+				sp = 2
+			case s.Value == o[:slash]+"/*":
+				sp = 1
+			case s.Value == "*/*":
+				sp = 0
+			default:
+				continue
+			}
+			if sp > specificity {
+				q, specificity = s.Q, sp
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// NegotiateEncoding returns the entry of offers, a list of content codings
+// in the server's order of preference, which best satisfies the
+// Accept-Encoding header of r. "identity" is acceptable unless explicitly
+// refused, and is the only acceptable coding when the header is absent. If
+// nothing acceptable is offered, "" is returned.
+func NegotiateEncoding(r *http.Request, offers ...string) string {
+	return negotiateEncoding(strings.Join(r.Header["Accept-Encoding"], ","), offers)
+}
+
+func negotiateEncoding(header string, offers []string) string {
+	specs := ParseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		o := strings.ToLower(offer)
+		q, explicit := 0.0, false
+		wildcard, hasWildcard := 0.0, false
+		for _, s := range specs {
+			if s.Value == o {
+				q, explicit = s.Q, true
+			} else if s.Value == "*" {
+				wildcard, hasWildcard = s.Q, true
+			}
+		}
+		switch {
+		case explicit:
+		case hasWildcard:
+			q = wildcard
+		case o == "identity":
+			// Lowest preference, so any other accepted coding wins.
+			q = 0.001
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,75 @@
+package httputil
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	got := ParseAccept("text/HTML;level=1;q=0.5, application/json , */*;q=abc, ,gzip;q=0")
+	want := []AcceptSpec{
+		{Value: "text/html", Q: 0.5},
+		{Value: "application/json", Q: 1},
+		{Value: "gzip", Q: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"application/json", "application/x-protobuf", "text/plain"}
+
+	for i, test := range []struct {
+		accept   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"application/x-protobuf", "application/x-protobuf"},
+		{"text/*", "text/plain"},
+		{"*/*", "application/json"},
+		{"application/json;q=0.5, application/x-protobuf", "application/x-protobuf"},
+		{"application/*;q=0.2, text/plain;q=0.6", "text/plain"},
+		// The most specific range wins, even if a broader one has a higher q.
+		{"*/*, application/json;q=0", "application/x-protobuf"},
+		{"image/png", ""},
+		{"*/*;q=0", ""},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if got := NegotiateContentType(r, offers...); got != test.expected {
+			t.Errorf("case %d: got %q, want %q", i, got, test.expected)
+		}
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offers := []string{"br", "gzip", "identity"}
+
+	for i, test := range []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", "identity"},
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"gzip;q=1, br;q=0.5", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"deflate", "identity"},
+		{"gzip;q=0, br;q=0", "identity"},
+		{"identity;q=0", ""},
+		{"*;q=0", ""},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		if got := NegotiateEncoding(r, offers...); got != test.expected {
+			t.Errorf("case %d: got %q, want %q", i, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package httputil
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	stdhttputil "net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+// ReverseProxyOptions configures a proxy created by NewReverseProxy. The zero
+// value is usable.
+type ReverseProxyOptions struct {
+	// PreserveHost passes the inbound Host header to the upstream instead
+	// of replacing it with the target's host.
+	PreserveHost bool
+
+	// TrustedProxies lists networks whose X-Forwarded-For headers are
+	// preserved and appended to. Headers from any other peer are discarded
+	// so clients cannot spoof their address to the upstream.
+	TrustedProxies []*net.IPNet
+
+	// TLSClientConfig is used when connecting to an https target.
+	TLSClientConfig *tls.Config
+
+	// DialTimeout bounds both connecting and the TLS handshake. It and
+	// IdleConnTimeout default as for NewTransport; ResponseHeaderTimeout
+	// defaults to 30 seconds.
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+
+	// FlushInterval is passed through to httputil.ReverseProxy.
+	FlushInterval time.Duration
+
+	// Logger receives upstream errors. If nil, errors are not logged.
+	Logger *capnslog.PackageLogger
+}
+
+// NewReverseProxy returns a ReverseProxy which forwards every request to
+// target, joining request paths onto the target's path. X-Forwarded-For,
+// X-Forwarded-Host and X-Forwarded-Proto are set on outbound requests.
+// Upstream failures are answered with a JSON 502, or 504 if the upstream
+// timed out.
+func NewReverseProxy(target *url.URL, opts ReverseProxyOptions) *stdhttputil.ReverseProxy {
+	transport := NewTransport(ClientOptions{
+		DialTimeout:           opts.DialTimeout,
+		TLSHandshakeTimeout:   opts.DialTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		ResponseHeaderTimeout: orDuration(opts.ResponseHeaderTimeout, 30*time.Second),
+		MaxIdleConnsPerHost:   32,
+		TLSConfig:             opts.TLSClientConfig,
+	})
+
+	return &stdhttputil.ReverseProxy{
+		Rewrite: func(pr *stdhttputil.ProxyRequest) {
+			pr.SetURL(target)
+			if opts.PreserveHost {
+				pr.Out.Host = pr.In.Host
+			}
+			if peer := parseHostIP(pr.In.RemoteAddr); peer != nil && containsIP(opts.TrustedProxies, peer) {
+				pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+			}
+			pr.SetXForwarded()
+		},
+		Transport:     transport,
+		FlushInterval: opts.FlushInterval,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			code := http.StatusBadGateway
+			var nerr net.Error
+			if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout()) {
+				code = http.StatusGatewayTimeout
+			}
+			if errors.Is(err, context.Canceled) {
+				// The client went away; nobody is listening for an answer.
+				return
+			}
+			if opts.Logger != nil {
+				opts.Logger.Errorf("proxy error: method=%s path=%q upstream=%s: %v", r.Method, r.URL.Path, target.Host, err)
+			}
+			WriteJSONError(w, &RequestError{Code: code, Message: http.StatusText(code)})
+		},
+	}
+}
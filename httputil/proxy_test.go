@@ -0,0 +1,94 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewReverseProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]string{
+			"host": r.Host,
+			"path": r.URL.Path,
+			"xff":  r.Header.Get("X-Forwarded-For"),
+		})
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL + "/base")
+	trusted, _ := ParseCIDRs([]string{"10.0.0.0/8"})
+
+	for i, test := range []struct {
+		opts         ReverseProxyOptions
+		remoteAddr   string
+		xff          string
+		expectedHost string
+		expectedXFF  string
+	}{
+		{ReverseProxyOptions{}, "1.2.3.4:5", "", target.Host, "1.2.3.4"},
+		// Spoofed header from an untrusted client is replaced.
+		{ReverseProxyOptions{}, "1.2.3.4:5", "6.6.6.6", target.Host, "1.2.3.4"},
+		// Trusted proxies are appended to.
+		{ReverseProxyOptions{TrustedProxies: trusted}, "10.0.0.1:5", "5.6.7.8", target.Host, "5.6.7.8, 10.0.0.1"},
+		{ReverseProxyOptions{PreserveHost: true}, "1.2.3.4:5", "", "example.com", "1.2.3.4"},
+	} {
+		p := NewReverseProxy(target, test.opts)
+		r := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.xff != "" {
+			r.Header.Set("X-Forwarded-For", test.xff)
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, http.StatusOK)
+			continue
+		}
+		var got map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("case %d: failed to unmarshal response: %v", i, err)
+		}
+		if got["host"] != test.expectedHost {
+			t.Errorf("case %d: host == %q, want %q", i, got["host"], test.expectedHost)
+		}
+		if got["path"] != "/base/foo" {
+			t.Errorf("case %d: path == %q, want %q", i, got["path"], "/base/foo")
+		}
+		if got["xff"] != test.expectedXFF {
+			t.Errorf("case %d: X-Forwarded-For == %q, want %q", i, got["xff"], test.expectedXFF)
+		}
+	}
+}
+
+func TestNewReverseProxyErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	for i, test := range []struct {
+		target       string
+		expectedCode int
+	}{
+		{dead.URL, http.StatusBadGateway},
+		{slow.URL, http.StatusGatewayTimeout},
+	} {
+		target, _ := url.Parse(test.target)
+		p := NewReverseProxy(target, ReverseProxyOptions{ResponseHeaderTimeout: 20 * time.Millisecond})
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != test.expectedCode {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, test.expectedCode)
+		}
+		var er ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &er); err != nil || er.Error.Code != test.expectedCode {
+			t.Errorf("case %d: unexpected body %q", i, w.Body.String())
+		}
+	}
+}
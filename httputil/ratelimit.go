@@ -0,0 +1,116 @@
+package httputil
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+const (
+	// rateLimitSweepInterval is how often idle buckets are discarded.
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimitHandler is middleware which applies a token bucket rate limit to
+// each client of Handler. Requests over the limit are rejected with a 429,
+// a Retry-After header and a JSON error body as written by WriteJSONError.
+type RateLimitHandler struct {
+	Handler http.Handler
+
+	// Rate is the sustained number of requests per second allowed for each
+	// key, and Burst is the number of requests which may be made at once.
+	Rate  float64
+	Burst int
+
+	// KeyFunc returns the key requests are limited by. If nil, requests
+	// are limited per client IP, as returned by ClientIP, or per
+	// RemoteAddr if that is not an IP address, e.g. for Unix sockets.
+	KeyFunc func(r *http.Request) string
+
+	// TrustedProxies is passed to ClientIP when KeyFunc is nil.
+	TrustedProxies []*net.IPNet
+
+	mu        sync.Mutex
+	buckets   map[string]*timeutil.TokenBucket
+	lastSweep time.Time
+	allowed   uint64
+	limited   uint64
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// RateLimitStats holds the request counters of a RateLimitHandler.
+type RateLimitStats struct {
+	Allowed uint64
+	Limited uint64
+	// Keys is the number of clients currently being tracked.
+	Keys int
+}
+
+// Stats returns the number of requests allowed and rejected so far.
+func (rl *RateLimitHandler) Stats() RateLimitStats {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return RateLimitStats{Allowed: rl.allowed, Limited: rl.limited, Keys: len(rl.buckets)}
+}
+
+func (rl *RateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var key string
+	if rl.KeyFunc != nil {
+		key = rl.KeyFunc(r)
+	} else if ip := ClientIP(r, rl.TrustedProxies); ip != nil {
+		key = ip.String()
+	} else {
+		key = r.RemoteAddr
+	}
+
+	ok, wait := rl.take(key)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		WriteJSONError(w, &RequestError{Code: http.StatusTooManyRequests, Message: "rate limit exceeded"})
+		return
+	}
+	rl.Handler.ServeHTTP(w, r)
+}
+
+func (rl *RateLimitHandler) take(key string) (bool, time.Duration) {
+	now := time.Now()
+	if rl.now != nil {
+		now = rl.now()
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.buckets == nil {
+		rl.buckets = make(map[string]*timeutil.TokenBucket)
+		rl.lastSweep = now
+	}
+	if now.Sub(rl.lastSweep) > rateLimitSweepInterval {
+		// A bucket which has refilled completely is indistinguishable
+		// from a new one, so there is no need to keep it around.
+		for k, b := range rl.buckets {
+			if b.Tokens(now) >= float64(rl.Burst) {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	b, found := rl.buckets[key]
+	if !found {
+		b = timeutil.NewTokenBucket(rl.Rate, rl.Burst)
+		rl.buckets[key] = b
+	}
+	if b.AllowN(now, 1) {
+		rl.allowed++
+		return true, 0
+	}
+	rl.limited++
+	return false, b.DelayN(now, 1)
+}
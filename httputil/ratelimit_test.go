@@ -0,0 +1,112 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	now := time.Unix(1000, 0)
+	rl := &RateLimitHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		Rate:  0.5,
+		Burst: 2,
+		now:   func() time.Time { return now },
+	}
+
+	for i, test := range []struct {
+		advance            time.Duration
+		remoteAddr         string
+		expectedCode       int
+		expectedRetryAfter string
+	}{
+		{0, "1.1.1.1:1", http.StatusOK, ""},
+		{0, "1.1.1.1:2", http.StatusOK, ""},
+		{0, "1.1.1.1:3", http.StatusTooManyRequests, "2"},
+		// Other clients have their own bucket.
+		{0, "2.2.2.2:1", http.StatusOK, ""},
+		// Partially refilled.
+		{time.Second, "1.1.1.1:1", http.StatusTooManyRequests, "1"},
+		{time.Second, "1.1.1.1:1", http.StatusOK, ""},
+	} {
+		now = now.Add(test.advance)
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		w := httptest.NewRecorder()
+		rl.ServeHTTP(w, r)
+
+		if w.Code != test.expectedCode {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, test.expectedCode)
+		}
+		if got := w.Header().Get("Retry-After"); got != test.expectedRetryAfter {
+			t.Errorf("case %d: Retry-After == %q, want %q", i, got, test.expectedRetryAfter)
+		}
+	}
+
+	stats := rl.Stats()
+	if stats.Allowed != 4 || stats.Limited != 2 || stats.Keys != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Idle buckets are swept away once they have refilled.
+	now = now.Add(2 * rateLimitSweepInterval)
+	rl.take("3.3.3.3")
+	if stats := rl.Stats(); stats.Keys != 1 {
+		t.Errorf("want 1 tracked key after sweep, got %d", stats.Keys)
+	}
+}
+
+func TestRateLimitHandlerKeyFunc(t *testing.T) {
+	rl := &RateLimitHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		Rate:    1,
+		Burst:   1,
+		KeyFunc: func(r *http.Request) string { return r.Header.Get("X-API-Key") },
+	}
+
+	for i, test := range []struct {
+		key          string
+		expectedCode int
+	}{
+		{"a", http.StatusOK},
+		{"a", http.StatusTooManyRequests},
+		{"b", http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-API-Key", test.key)
+		w := httptest.NewRecorder()
+		rl.ServeHTTP(w, r)
+		if w.Code != test.expectedCode {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, test.expectedCode)
+		}
+	}
+}
+
+func TestRateLimitHandlerUnparsedPeer(t *testing.T) {
+	rl := &RateLimitHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		Rate:    1,
+		Burst:   1,
+	}
+	// Peers whose addresses are not IPs don't share a bucket.
+	for i, test := range []struct {
+		remoteAddr   string
+		expectedCode int
+	}{
+		{"@peer-a", http.StatusOK},
+		{"@peer-a", http.StatusTooManyRequests},
+		{"@peer-b", http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		w := httptest.NewRecorder()
+		rl.ServeHTTP(w, r)
+		if w.Code != test.expectedCode {
+			t.Errorf("case %d: w.Code == %d, want %d", i, w.Code, test.expectedCode)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package httputil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLength bounds the size of client supplied request IDs.
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx by
+// RequestIDHandler or WithRequestID, or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDHandler is middleware which ensures every request has an ID. An
+// ID supplied by the client in the X-Request-ID header is kept if it is
+// reasonably sized and printable; otherwise a new random one is generated.
+// The ID is stored in the request context, where LoggingHandler and other
+// code can retrieve it with RequestIDFromContext, and is echoed back in the
+// response header.
+type RequestIDHandler struct {
+	Handler http.Handler
+
+	// Generate returns new request IDs. If nil, NewRequestID is used.
+	Generate func() string
+}
+
+func (rh *RequestIDHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		if rh.Generate != nil {
+			id = rh.Generate()
+		} else {
+			id = NewRequestID()
+		}
+	}
+	w.Header().Set(RequestIDHeader, id)
+	rh.Handler.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+}
+
+// NewRequestID returns a random 128-bit request ID, hex encoded.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("httputil: unable to read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
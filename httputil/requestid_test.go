@@ -0,0 +1,73 @@
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+func TestRequestIDHandler(t *testing.T) {
+	for i, test := range []struct {
+		incoming    string
+		generate    func() string
+		expectedID  string
+		expectedLen int
+	}{
+		{"abc-123", nil, "abc-123", 0},
+		{"", nil, "", 32},
+		{"has space", nil, "", 32},
+		{strings.Repeat("x", maxRequestIDLength+1), nil, "", 32},
+		{"", func() string { return "custom" }, "custom", 0},
+	} {
+		var seen string
+		h := &RequestIDHandler{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}),
+			Generate: test.generate,
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.incoming != "" {
+			r.Header.Set(RequestIDHeader, test.incoming)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		echoed := w.Header().Get(RequestIDHeader)
+		if echoed != seen {
+			t.Errorf("case %d: echoed ID %q does not match context ID %q", i, echoed, seen)
+		}
+		if test.expectedID != "" && seen != test.expectedID {
+			t.Errorf("case %d: ID == %q, want %q", i, seen, test.expectedID)
+		}
+		if test.expectedLen != 0 && len(seen) != test.expectedLen {
+			t.Errorf("case %d: len(ID) == %d, want %d", i, len(seen), test.expectedLen)
+		}
+	}
+
+	if NewRequestID() == NewRequestID() {
+		t.Errorf("NewRequestID returned the same ID twice")
+	}
+}
+
+func TestRequestIDLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	capnslog.SetFormatter(capnslog.NewStringFormatter(buf))
+	defer capnslog.SetFormatter(capnslog.NewNilFormatter())
+
+	h := &RequestIDHandler{Handler: &LoggingHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		Logger:  capnslog.NewPackageLogger("github.com/coreos/pkg", "httputil_test"),
+	}}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(RequestIDHeader, "req-42")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.Contains(buf.String(), " request_id=req-42\n") {
+		t.Errorf("log output %q does not contain the request ID", buf.String())
+	}
+}
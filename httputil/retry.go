@@ -0,0 +1,157 @@
+package httputil
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+const (
+	DefaultMaxRetries     = 3
+	DefaultInitialBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff     = 10 * time.Second
+)
+
+// RetryTransport is an http.RoundTripper which retries failed requests made
+// through an underlying RoundTripper, sleeping with exponential backoff and
+// full jitter between attempts. A Retry-After header on a 429 or 503
+// response overrides the computed delay.
+//
+// Requests with a body are only retried if their GetBody field is set, as is
+// done by http.NewRequest for common body types.
+type RetryTransport struct {
+	// Transport is used to make the actual requests. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// MaxRetries is the number of retries allowed for each request, on top
+	// of the initial attempt. If zero, DefaultMaxRetries is used; set it to
+	// a negative value to disable retries.
+	MaxRetries int
+
+	// InitialBackoff and MaxBackoff bound the delay between attempts. If
+	// zero, DefaultInitialBackoff and DefaultMaxBackoff are used. A
+	// Retry-After larger than MaxBackoff causes the response to be returned
+	// to the caller rather than retried.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// ShouldRetry reports whether an attempt which produced resp or err
+	// should be retried. If nil, DefaultShouldRetry is used.
+	ShouldRetry func(req *http.Request, resp *http.Response, err error) bool
+}
+
+// DefaultShouldRetry retries idempotent requests which failed with a
+// transport error, or with a 429, 502, 503 or 504 response.
+func DefaultShouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if !IsIdempotent(req.Method) {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// IsIdempotent reports whether requests using method may safely be repeated.
+func IsIdempotent(method string) bool {
+	switch method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	shouldRetry := t.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = DefaultShouldRetry
+	}
+	maxRetries := t.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	initialBackoff := t.InitialBackoff
+	if initialBackoff == 0 {
+		initialBackoff = DefaultInitialBackoff
+	}
+	maxBackoff := t.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	backoff := timeutil.Backoff{Initial: initialBackoff, Max: maxBackoff, Jitter: timeutil.FullJitter}
+
+	canRewind := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		resp, err := transport.RoundTrip(attemptReq)
+		if attempt >= maxRetries || !canRewind || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		delay, _ := backoff.NextDelay()
+		if resp != nil {
+			if ra, ok := retryAfter(resp); ok {
+				if ra > maxBackoff {
+					return resp, err
+				}
+				delay = ra
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		attemptReq = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if attemptReq.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryAfter parses the Retry-After header of a 429 or 503 response, which
+// may either be a number of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		d := time.Until(at)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
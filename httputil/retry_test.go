@@ -0,0 +1,102 @@
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	for i, test := range []struct {
+		method         string
+		failures       int32
+		failStatus     int
+		retryAfter     string
+		maxRetries     int
+		expectedCode   int
+		expectedServed int32
+	}{
+		// Succeeds after transient failures.
+		{"GET", 2, http.StatusServiceUnavailable, "", 0, http.StatusOK, 3},
+		// Retry budget exhausted.
+		{"GET", 10, http.StatusBadGateway, "", 2, http.StatusBadGateway, 3},
+		// Non-idempotent methods are not retried.
+		{"POST", 1, http.StatusServiceUnavailable, "", 0, http.StatusServiceUnavailable, 1},
+		// Non-retryable status.
+		{"GET", 1, http.StatusInternalServerError, "", 0, http.StatusInternalServerError, 1},
+		// Retries disabled.
+		{"GET", 1, http.StatusServiceUnavailable, "", -1, http.StatusServiceUnavailable, 1},
+		// Retry-After honored.
+		{"PUT", 1, http.StatusTooManyRequests, "0", 0, http.StatusOK, 2},
+		// Retry-After beyond MaxBackoff is handed back to the caller.
+		{"GET", 1, http.StatusTooManyRequests, "3600", 0, http.StatusTooManyRequests, 1},
+	} {
+		var served int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&served, 1)
+			if b, _ := ioutil.ReadAll(r.Body); r.Method != "GET" && string(b) != "payload" {
+				t.Errorf("case %d: attempt %d got body %q", i, n, b)
+			}
+			if n <= test.failures {
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(test.failStatus)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		client := &http.Client{Transport: &RetryTransport{
+			MaxRetries:     test.maxRetries,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     10 * time.Millisecond,
+		}}
+		var body *strings.Reader
+		if test.method != "GET" {
+			body = strings.NewReader("payload")
+		} else {
+			body = strings.NewReader("")
+		}
+		req, _ := http.NewRequest(test.method, ts.URL, body)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode != test.expectedCode {
+				t.Errorf("case %d: status == %d, want %d", i, resp.StatusCode, test.expectedCode)
+			}
+		}
+		if served != test.expectedServed {
+			t.Errorf("case %d: served %d requests, want %d", i, served, test.expectedServed)
+		}
+		ts.Close()
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for i, test := range []struct {
+		status   int
+		header   string
+		expected time.Duration
+		ok       bool
+	}{
+		{http.StatusServiceUnavailable, "5", 5 * time.Second, true},
+		{http.StatusTooManyRequests, time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+		{http.StatusTooManyRequests, "", 0, false},
+		{http.StatusTooManyRequests, "soon", 0, false},
+		{http.StatusBadGateway, "5", 0, false},
+	} {
+		resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+		resp.Header.Set("Retry-After", test.header)
+		d, ok := retryAfter(resp)
+		if d != test.expected || ok != test.ok {
+			t.Errorf("case %d: got (%v, %t), want (%v, %t)", i, d, ok, test.expected, test.ok)
+		}
+	}
+}
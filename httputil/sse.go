@@ -0,0 +1,181 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamClosed is returned when sending on an SSEWriter after Close.
+var ErrStreamClosed = errors.New("event stream has been closed")
+
+// Event is a single Server-Sent Event. Only Data is required.
+type Event struct {
+	// ID sets the client's last event ID, sent back in the Last-Event-ID
+	// header when the client reconnects.
+	ID string
+	// Event is the event type; clients receive untyped events as "message".
+	Event string
+	// Data is the event payload. It may contain newlines.
+	Data string
+	// Retry, if non-zero, tells the client how long to wait before
+	// reconnecting.
+	Retry time.Duration
+}
+
+// SSEWriter streams Server-Sent Events to a client. It is safe for
+// concurrent use.
+type SSEWriter struct {
+	ctx context.Context
+	rc  *http.ResponseController
+
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewSSEWriter prepares w for streaming events in response to r, writing
+// the response headers immediately. If w does not support flushing, as
+// events could not then be delivered promptly, an error wrapping
+// http.ErrNotSupported is returned and w is left untouched, so that an error
+// response can still be sent. The stream is considered finished once r's
+// context is done, which happens when the client disconnects.
+func NewSSEWriter(w http.ResponseWriter, r *http.Request) (*SSEWriter, error) {
+	if !canFlush(w) {
+		return nil, fmt.Errorf("event stream: %w", http.ErrNotSupported)
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+
+	s := &SSEWriter{
+		ctx:  r.Context(),
+		rc:   http.NewResponseController(w),
+		w:    w,
+		stop: make(chan struct{}),
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := s.rc.Flush(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// canFlush reports whether w, or a ResponseWriter it wraps, can be flushed
+// with http.ResponseController.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case http.Flusher, interface{ FlushError() error }:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// Done returns a channel which is closed when the client has gone away.
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes e to the stream and flushes it to the client. If the client
+// has disconnected, the context's error is returned.
+func (s *SSEWriter) Send(e Event) error {
+	var buf bytes.Buffer
+	if e.ID != "" {
+		buf.WriteString("id: " + sseField(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + sseField(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(e.Retry/time.Millisecond), 10) + "\n")
+	}
+	data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(e.Data)
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.Bytes())
+}
+
+// SendJSON sends v, encoded as JSON, as the data of an event of the given
+// type.
+func (s *SSEWriter) SendJSON(event string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Send(Event{Event: event, Data: string(b)})
+}
+
+// Heartbeat starts sending a comment line every interval, which keeps
+// intermediaries from timing out an otherwise idle stream. Heartbeats stop
+// when the client disconnects or Close is called.
+func (s *SSEWriter) Heartbeat(interval time.Duration) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-s.stop:
+				return
+			case <-t.C:
+				if s.write([]byte(":\n\n")) != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close stops any heartbeats and causes further sends to fail with
+// ErrStreamClosed. It must be called before the handler returns if
+// Heartbeat was used.
+func (s *SSEWriter) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *SSEWriter) write(b []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// sseField strips line breaks, which would otherwise end the field early.
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,98 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nonFlusher struct {
+	http.ResponseWriter
+}
+
+func TestSSEWriterSend(t *testing.T) {
+	w := httptest.NewRecorder()
+	s, err := NewSSEWriter(w, httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type == %q, want %q", got, "text/event-stream")
+	}
+	if !w.Flushed {
+		t.Errorf("headers were not flushed")
+	}
+
+	for i, test := range []struct {
+		event    Event
+		expected string
+	}{
+		{Event{Data: "hello"}, "data: hello\n\n"},
+		{Event{Data: ""}, "data: \n\n"},
+		{Event{ID: "7", Event: "status", Data: "a\nb\r\nc"}, "id: 7\nevent: status\ndata: a\ndata: b\ndata: c\n\n"},
+		{Event{Event: "bad\nname", Data: "x", Retry: 1500 * time.Millisecond}, "event: badname\nretry: 1500\ndata: x\n\n"},
+	} {
+		w.Body.Reset()
+		if err := s.Send(test.event); err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if got := w.Body.String(); got != test.expected {
+			t.Errorf("case %d: wrote %q, want %q", i, got, test.expected)
+		}
+	}
+
+	w.Body.Reset()
+	if err := s.SendJSON("update", map[string]int{"n": 1}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got, want := w.Body.String(), "event: update\ndata: {\"n\":1}\n\n"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+
+	s.Close()
+	if err := s.Send(Event{Data: "late"}); err != ErrStreamClosed {
+		t.Errorf("want ErrStreamClosed after Close, got %v", err)
+	}
+}
+
+func TestSSEWriterDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s, err := NewSSEWriter(w, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.Heartbeat(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Done was not closed after disconnect")
+	}
+	if err := s.Send(Event{Data: "x"}); err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	s.Close()
+
+	if !strings.HasPrefix(w.Body.String(), ":\n\n") {
+		t.Errorf("expected heartbeats, got %q", w.Body.String())
+	}
+}
+
+func TestSSEWriterNotFlushable(t *testing.T) {
+	w := httptest.NewRecorder()
+	if _, err := NewSSEWriter(nonFlusher{w}, httptest.NewRequest("GET", "/", nil)); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("want ErrNotSupported for a ResponseWriter without Flush, got %v", err)
+	}
+	// The caller can still send an error response.
+	if w.Header().Get("Content-Type") != "" || w.Flushed {
+		t.Errorf("response written: %v", w.Header())
+	}
+}
@@ -0,0 +1,53 @@
+package multierror
+
+import "fmt"
+
+// omitted stands in for errors dropped by Cap. It is always the last element
+// of an Error, is rendered as "... and N more errors" and contributes N to
+// Len.
+type omitted int
+
+func (n omitted) Error() string {
+	if n == 1 {
+		return "... and 1 more error"
+	}
+	return fmt.Sprintf("... and %d more errors", int(n))
+}
+
+// Cap returns me with nested Errors expanded and at most max of its errors
+// retained. The rest are replaced by a single element rendering as
+// "... and N more errors", so the message stays bounded while Len still
+// reports the total. A max of zero or less retains every error. Cap returns
+// nil if me has no errors; me is never modified.
+func (me Error) Cap(max int) Error {
+	var (
+		out  Error
+		more omitted
+	)
+	for _, err := range appendFlat(nil, me) {
+		if n, ok := err.(omitted); ok {
+			more += n
+			continue
+		}
+		if max > 0 && len(out) >= max {
+			more++
+			continue
+		}
+		out = append(out, err)
+	}
+	if more > 0 {
+		out = append(out, more)
+	}
+	return out
+}
+
+// Omitted returns the number of errors dropped from me by Cap.
+func (me Error) Omitted() int {
+	n := 0
+	for _, err := range me {
+		if o, ok := err.(omitted); ok {
+			n += int(o)
+		}
+	}
+	return n
+}
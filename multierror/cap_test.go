@@ -0,0 +1,116 @@
+package multierror
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func numbered(n int) Error {
+	var multierr Error
+	for i := 0; i < n; i++ {
+		multierr = append(multierr, fmt.Errorf("err %d", i))
+	}
+	return multierr
+}
+
+func TestCap(t *testing.T) {
+	tests := []struct {
+		multierr Error
+		max      int
+		want     Error
+		len      int
+	}{
+		{multierr: nil, max: 2, want: nil, len: 0},
+		{multierr: numbered(2), max: 2, want: numbered(2), len: 2},
+		{multierr: numbered(2), max: 0, want: numbered(2), len: 2},
+		{multierr: numbered(3), max: 2, want: append(numbered(2), omitted(1)), len: 3},
+		{multierr: numbered(10000), max: 20, want: append(numbered(20), omitted(9980)), len: 10000},
+		{
+			multierr: Error{append(numbered(3), omitted(5)), errors.New("foo")},
+			max:      2,
+			want:     append(numbered(2), omitted(7)),
+			len:      9,
+		},
+	}
+
+	for i, tt := range tests {
+		got := tt.multierr.Cap(tt.max)
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: incorrect error value: want=%+v got=%+v", i, tt.want, got)
+		}
+		if got.Len() != tt.len {
+			t.Errorf("case %d: incorrect length: want=%d got=%d", i, tt.len, got.Len())
+		}
+	}
+}
+
+func TestCapRender(t *testing.T) {
+	multierr := numbered(5).Cap(2)
+	if got := multierr.Omitted(); got != 3 {
+		t.Errorf("incorrect omitted count: want=3 got=%d", got)
+	}
+
+	tests := []struct {
+		opts RenderOptions
+		want string
+	}{
+		{
+			opts: RenderOptions{},
+			want: "[0] err 0 [1] err 1 ... and 3 more errors",
+		},
+		{
+			opts: RenderOptions{Style: StyleLine, Count: true},
+			want: "5 errors occurred: err 0; err 1; ... and 3 more errors",
+		},
+		{
+			opts: RenderOptions{Style: StyleBullets},
+			want: "* err 0\n* err 1\n... and 3 more errors",
+		},
+		{
+			opts: RenderOptions{Style: StyleJSON},
+			want: `["err 0","err 1","... and 3 more errors"]`,
+		},
+		{
+			opts: RenderOptions{Style: StyleJSON, Count: true},
+			want: `{"count":5,"errors":["err 0","err 1"],"omitted":3}`,
+		},
+	}
+
+	for i, tt := range tests {
+		if got := multierr.Render(tt.opts); got != tt.want {
+			t.Errorf("case %d: incorrect output: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestCapAppend(t *testing.T) {
+	err := Append(numbered(3).Cap(1), errors.New("foo"), numbered(2).Cap(1))
+	want := Error{errors.New("err 0"), errors.New("foo"), omitted(3)}
+	if !reflect.DeepEqual(want, err) {
+		t.Fatalf("incorrect error value: want=%+v got=%+v", want, err)
+	}
+	if got := err.(Error).Len(); got != 5 {
+		t.Fatalf("incorrect length: want=5 got=%d", got)
+	}
+}
+
+func TestGroupMax(t *testing.T) {
+	g := Group{Max: 5}
+	for i := 0; i < 100; i++ {
+		i := i
+		g.Go(func() error { return fmt.Errorf("err %d", i) })
+	}
+
+	me, ok := g.Wait().(Error)
+	if !ok {
+		t.Fatalf("want an Error, got %T", me)
+	}
+	if len(me) != 6 {
+		t.Errorf("want 5 errors and a summary, got %d elements", len(me))
+	}
+	if me.Len() != 100 || me.Omitted() != 95 {
+		t.Errorf("want 100 errors with 95 omitted, got %d with %d omitted", me.Len(), me.Omitted())
+	}
+}
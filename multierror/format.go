@@ -0,0 +1,122 @@
+package multierror
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Style selects how Render lays out the errors in an Error.
+type Style int
+
+const (
+	// StyleIndexed numbers each error on a single line, as in
+	// "[0] foo [1] bar". It is the format used by the Error method.
+	StyleIndexed Style = iota
+
+	// StyleLine joins the errors with semicolons, as in "foo; bar".
+	StyleLine
+
+	// StyleBullets puts each error on its own line, prefixed with "* ".
+	// Continuation lines of multi-line errors are indented to match.
+	StyleBullets
+
+	// StyleJSON renders a JSON array of the error messages.
+	StyleJSON
+)
+
+// RenderOptions configure Render.
+type RenderOptions struct {
+	Style Style
+
+	// Count prefixes the errors with the number of errors, as in
+	// "2 errors occurred: foo; bar". In StyleJSON, Count instead produces
+	// an object of the form {"count": 2, "errors": ["foo", "bar"]}, with an
+	// "omitted" member giving the number of errors dropped by Cap, if any.
+	// The count always includes errors dropped by Cap.
+	Count bool
+}
+
+// Render returns the errors in me laid out according to opts. An empty Error
+// always renders as "".
+func (me Error) Render(opts RenderOptions) string {
+	if len(me) == 0 {
+		return ""
+	}
+
+	var prefix string
+	if opts.Count {
+		prefix = countPrefix(me.Len())
+	}
+
+	switch opts.Style {
+	case StyleLine:
+		strs := me.messages()
+		if prefix != "" {
+			return prefix + ": " + strings.Join(strs, "; ")
+		}
+		return strings.Join(strs, "; ")
+	case StyleBullets:
+		var b strings.Builder
+		if prefix != "" {
+			b.WriteString(prefix + ":\n")
+		}
+		for i, err := range me {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			if n, ok := err.(omitted); ok {
+				b.WriteString(n.Error())
+				continue
+			}
+			b.WriteString("* " + strings.Replace(fmt.Sprint(err), "\n", "\n  ", -1))
+		}
+		return b.String()
+	case StyleJSON:
+		var v interface{} = me.messages()
+		if opts.Count {
+			n := me.Omitted()
+			strs := me.messages()
+			if n > 0 {
+				strs = strs[:len(strs)-1]
+			}
+			v = struct {
+				Count   int      `json:"count"`
+				Errors  []string `json:"errors"`
+				Omitted int      `json:"omitted,omitempty"`
+			}{me.Len(), strs, n}
+		}
+		// Marshalling strings cannot fail.
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+
+	s := me.Error()
+	if prefix != "" {
+		return prefix + ": " + s
+	}
+	return s
+}
+
+// MarshalJSON encodes me as a JSON array of its error messages.
+func (me Error) MarshalJSON() ([]byte, error) {
+	if me == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(me.messages())
+}
+
+func (me Error) messages() []string {
+	strs := make([]string, len(me))
+	for i, err := range me {
+		strs[i] = fmt.Sprint(err)
+	}
+	return strs
+}
+
+func countPrefix(n int) string {
+	if n == 1 {
+		return "1 error occurred"
+	}
+	return fmt.Sprintf("%d errors occurred", n)
+}
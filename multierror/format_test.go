@@ -0,0 +1,92 @@
+package multierror
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	multierr := Error{errors.New("foo"), errors.New("bar\nbaz"), errors.New("qux")}
+	tests := []struct {
+		multierr Error
+		opts     RenderOptions
+		want     string
+	}{
+		{
+			multierr: multierr,
+			opts:     RenderOptions{},
+			want:     "[0] foo [1] bar\nbaz [2] qux",
+		},
+		{
+			multierr: multierr,
+			opts:     RenderOptions{Style: StyleIndexed, Count: true},
+			want:     "3 errors occurred: [0] foo [1] bar\nbaz [2] qux",
+		},
+		{
+			multierr: multierr,
+			opts:     RenderOptions{Style: StyleLine},
+			want:     "foo; bar\nbaz; qux",
+		},
+		{
+			multierr: Error{errors.New("foo")},
+			opts:     RenderOptions{Style: StyleLine, Count: true},
+			want:     "1 error occurred: foo",
+		},
+		{
+			multierr: multierr,
+			opts:     RenderOptions{Style: StyleBullets},
+			want:     "* foo\n* bar\n  baz\n* qux",
+		},
+		{
+			multierr: multierr,
+			opts:     RenderOptions{Style: StyleBullets, Count: true},
+			want:     "3 errors occurred:\n* foo\n* bar\n  baz\n* qux",
+		},
+		{
+			multierr: multierr,
+			opts:     RenderOptions{Style: StyleJSON},
+			want:     `["foo","bar\nbaz","qux"]`,
+		},
+		{
+			multierr: multierr,
+			opts:     RenderOptions{Style: StyleJSON, Count: true},
+			want:     `{"count":3,"errors":["foo","bar\nbaz","qux"]}`,
+		},
+		{
+			multierr: nil,
+			opts:     RenderOptions{Style: StyleJSON, Count: true},
+			want:     "",
+		},
+	}
+
+	for i, tt := range tests {
+		if got := tt.multierr.Render(tt.opts); got != tt.want {
+			t.Errorf("case %d: incorrect output: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		multierr Error
+		want     string
+	}{
+		{nil, `{"errors":null}`},
+		{Error{}, `{"errors":[]}`},
+		{Error{errors.New("foo"), errors.New("bar")}, `{"errors":["foo","bar"]}`},
+	}
+
+	for i, tt := range tests {
+		b, err := json.Marshal(struct {
+			Errors Error `json:"errors"`
+		}{tt.multierr})
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := string(b); got != tt.want {
+			t.Errorf("case %d: incorrect output: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package multierror
+
+import "sync"
+
+// Group runs functions in their own goroutines and collects every error they
+// return. Unlike errgroup, a failure does not hide the others: Wait returns
+// all of them. The zero Group is ready to use and must not be copied after
+// first use.
+type Group struct {
+	// Limit, if positive, bounds the number of functions running at once;
+	// Go blocks until a slot is free. It must not be changed after the
+	// first call to Go.
+	Limit int
+
+	// Max, if positive, caps the number of errors retained as described
+	// by Error.Cap. Errors past the cap are only counted. When more than
+	// Max functions fail, which errors are retained depends on the order
+	// in which the functions finish.
+	Max int
+
+	wg   sync.WaitGroup
+	once sync.Once
+	sem  chan struct{}
+
+	mu       sync.Mutex
+	errs     []error
+	retained int
+	omitted  omitted
+}
+
+// Go calls f in a new goroutine.
+func (g *Group) Go(f func() error) {
+	g.once.Do(func() {
+		if g.Limit > 0 {
+			g.sem = make(chan struct{}, g.Limit)
+		}
+	})
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+
+	g.mu.Lock()
+	i := len(g.errs)
+	g.errs = append(g.errs, nil)
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+		if err := f(); err != nil {
+			g.mu.Lock()
+			if g.Max > 0 && g.retained >= g.Max {
+				g.omitted++
+			} else {
+				g.errs[i] = err
+				g.retained++
+			}
+			g.mu.Unlock()
+		}
+	}()
+}
+
+// Wait blocks until all functions started by Go have returned, then returns
+// their errors as an Error, in the order the functions were started, or nil
+// if none failed. Nested Errors are expanded, but unlike Append, Wait keeps
+// errors with duplicate messages so that every failure is accounted for.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	me := appendFlat(nil, Error(g.errs))
+	if g.omitted > 0 {
+		me = append(me, g.omitted)
+	}
+	return me.Cap(g.Max).ErrorOrNil()
+}
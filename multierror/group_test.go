@@ -0,0 +1,77 @@
+package multierror
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	var g Group
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Go(func() error {
+			// Finish in the reverse order of starting.
+			time.Sleep(time.Duration(10-i) * time.Millisecond)
+			if i%3 == 0 {
+				return fmt.Errorf("err %d", i)
+			}
+			return nil
+		})
+	}
+
+	got := g.Wait()
+	want := Error{
+		errors.New("err 0"),
+		errors.New("err 3"),
+		errors.New("err 6"),
+		errors.New("err 9"),
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("incorrect error value: want=%+v got=%+v", want, got)
+	}
+}
+
+func TestGroupNoErrors(t *testing.T) {
+	var g Group
+	if err := g.Wait(); err != nil {
+		t.Fatalf("empty group: unexpected error: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		g.Go(func() error { return nil })
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGroupLimit(t *testing.T) {
+	const limit = 3
+	g := Group{Limit: limit}
+	var running, max int32
+	for i := 0; i < 20; i++ {
+		g.Go(func() error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return errors.New("fail")
+		})
+	}
+
+	err := g.Wait()
+	if me, ok := err.(Error); !ok || len(me) != 20 {
+		t.Fatalf("want 20 errors, got %v", err)
+	}
+	if max > limit {
+		t.Fatalf("want at most %d concurrent functions, got %d", limit, max)
+	}
+}
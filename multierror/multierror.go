@@ -18,15 +18,96 @@ func (me Error) Error() string {
 
 	strs := make([]string, len(me))
 	for i, err := range me {
+		if n, ok := err.(omitted); ok {
+			strs[i] = n.Error()
+			continue
+		}
 		strs[i] = fmt.Sprintf("[%d] %v", i, err)
 	}
 	return strings.Join(strs, " ")
 }
 
+// AsError is equivalent to ErrorOrNil.
 func (me Error) AsError() error {
-	if len([]error(me)) <= 0 {
+	return me.ErrorOrNil()
+}
+
+// ErrorOrNil returns me as an error, or nil if me is empty. Functions
+// collecting into an Error should return through it: an empty Error
+// converted directly to an error is non-nil, so callers checking
+// err != nil would see a failure with an empty message.
+func (me Error) ErrorOrNil() error {
+	if len(me) == 0 {
 		return nil
 	}
 
 	return me
 }
+
+// Len returns the number of errors in me, including any dropped by Cap.
+func (me Error) Len() int {
+	n := len(me)
+	for _, err := range me {
+		if o, ok := err.(omitted); ok {
+			n += int(o) - 1
+		}
+	}
+	return n
+}
+
+// Flatten returns the errors in me with nested Errors expanded in place, at
+// any depth, and nil errors removed. An error whose message is identical to
+// that of an earlier error is dropped. Errors dropped by Cap are still
+// accounted for at the end. Flatten returns nil if no errors remain; me is
+// never modified.
+func (me Error) Flatten() Error {
+	flat := appendFlat(nil, me)
+	if len(flat) == 0 {
+		return nil
+	}
+
+	var more omitted
+	seen := make(map[string]bool, len(flat))
+	out := flat[:0]
+	for _, err := range flat {
+		if n, ok := err.(omitted); ok {
+			more += n
+			continue
+		}
+		msg := err.Error()
+		if seen[msg] {
+			continue
+		}
+		seen[msg] = true
+		out = append(out, err)
+	}
+	if more > 0 {
+		out = append(out, more)
+	}
+	return out
+}
+
+// Append appends errs to err and flattens the result as described by
+// Flatten, so nil errors are skipped, nested Errors are expanded and
+// duplicate messages are dropped. Append returns nil if there was nothing
+// to append, and an Error otherwise; err is never modified.
+func Append(err error, errs ...error) error {
+	me := appendFlat(nil, err)
+	for _, e := range errs {
+		me = appendFlat(me, e)
+	}
+	return me.Flatten().ErrorOrNil()
+}
+
+func appendFlat(me Error, err error) Error {
+	switch e := err.(type) {
+	case nil:
+		return me
+	case Error:
+		for _, err := range e {
+			me = appendFlat(me, err)
+		}
+		return me
+	}
+	return append(me, err)
+}
@@ -57,3 +57,116 @@ func TestErrorString(t *testing.T) {
 		t.Fatalf("incorrect output: want=%q got=%q", want, got)
 	}
 }
+
+func TestAppend(t *testing.T) {
+	foo, bar, baz := errors.New("foo"), errors.New("bar"), errors.New("baz")
+	tests := []struct {
+		err  error
+		errs []error
+		want error
+	}{
+		{err: nil, errs: nil, want: nil},
+		{err: nil, errs: []error{nil, nil}, want: nil},
+		{err: Error{}, errs: []error{Error(nil)}, want: nil},
+		{err: foo, errs: nil, want: Error{foo}},
+		{err: nil, errs: []error{nil, foo, nil, bar}, want: Error{foo, bar}},
+		{err: Error{foo}, errs: []error{bar}, want: Error{foo, bar}},
+		{err: foo, errs: []error{Error{bar, Error{baz, nil}}}, want: Error{foo, bar, baz}},
+		{err: Error{foo, bar}, errs: []error{errors.New("foo"), baz}, want: Error{foo, bar, baz}},
+	}
+
+	for i, tt := range tests {
+		got := Append(tt.err, tt.errs...)
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: incorrect error value: want=%+v got=%+v", i, tt.want, got)
+		}
+	}
+}
+
+func TestAppendDoesNotModify(t *testing.T) {
+	foo, bar, baz := errors.New("foo"), errors.New("bar"), errors.New("baz")
+	base := make(Error, 1, 4)
+	base[0] = foo
+
+	a := Append(base, bar)
+	b := Append(base, baz)
+	if want := (Error{foo, bar}); !reflect.DeepEqual(want, a) {
+		t.Errorf("incorrect error value: want=%+v got=%+v", want, a)
+	}
+	if want := (Error{foo, baz}); !reflect.DeepEqual(want, b) {
+		t.Errorf("incorrect error value: want=%+v got=%+v", want, b)
+	}
+	if len(base) != 1 {
+		t.Errorf("base was modified: %+v", base)
+	}
+}
+
+func TestErrorOrNil(t *testing.T) {
+	collect := func(errs ...error) error {
+		var multierr Error
+		for _, err := range errs {
+			if err != nil {
+				multierr = append(multierr, err)
+			}
+		}
+		return multierr.ErrorOrNil()
+	}
+
+	if err := collect(); err != nil {
+		t.Errorf("no errors: want nil, got %#v", err)
+	}
+	if err := collect(nil, nil); err != nil {
+		t.Errorf("nil errors: want nil, got %#v", err)
+	}
+	if err := (Error{}).ErrorOrNil(); err != nil {
+		t.Errorf("empty Error: want nil, got %#v", err)
+	}
+	want := Error{errors.New("foo")}
+	if got := collect(nil, errors.New("foo")); !reflect.DeepEqual(want, got) {
+		t.Errorf("incorrect error value: want=%+v got=%+v", want, got)
+	}
+}
+
+func TestLen(t *testing.T) {
+	tests := []struct {
+		multierr Error
+		want     int
+	}{
+		{nil, 0},
+		{Error{}, 0},
+		{Error{errors.New("foo"), errors.New("bar")}, 2},
+	}
+
+	for i, tt := range tests {
+		if got := tt.multierr.Len(); got != tt.want {
+			t.Errorf("case %d: want=%d got=%d", i, tt.want, got)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	foo, bar, baz := errors.New("foo"), errors.New("bar"), errors.New("baz")
+	tests := []struct {
+		multierr Error
+		want     Error
+	}{
+		{multierr: nil, want: nil},
+		{multierr: Error{nil, Error{}, Error{nil}}, want: nil},
+		{multierr: Error{foo, bar}, want: Error{foo, bar}},
+		{
+			multierr: Error{foo, Error{bar, Error{baz}}, nil},
+			want:     Error{foo, bar, baz},
+		},
+		{
+			multierr: Error{foo, Error{bar, errors.New("foo")}, Error{Error{bar}}, baz},
+			want:     Error{foo, bar, baz},
+		},
+	}
+
+	for i, tt := range tests {
+		got := tt.multierr.Flatten()
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: incorrect error value: want=%+v got=%+v", i, tt.want, got)
+		}
+	}
+}
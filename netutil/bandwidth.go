@@ -0,0 +1,152 @@
+package netutil
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+// BandwidthLimiter limits the rate at which bytes are transferred, using a
+// token bucket holding up to burst bytes which refills at a fixed rate. A
+// single limiter may be shared by several connections to give them a common
+// budget, or each connection may be given its own.
+type BandwidthLimiter struct {
+	bucket *timeutil.TokenBucket
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewBandwidthLimiter returns a limiter allowing bytesPerSec bytes per second
+// on average, with bursts of up to burst bytes. If burst is zero, one second's
+// worth of bytes is allowed. A bytesPerSec of zero or less means no limit.
+func NewBandwidthLimiter(bytesPerSec float64, burst int) *BandwidthLimiter {
+	rate, burst := bandwidthLimit(bytesPerSec, burst)
+	return &BandwidthLimiter{bucket: timeutil.NewTokenBucket(rate, burst)}
+}
+
+// SetLimit changes the rate and burst of l, taking effect for subsequent
+// reads and writes. The arguments are as for NewBandwidthLimiter.
+func (l *BandwidthLimiter) SetLimit(bytesPerSec float64, burst int) {
+	l.bucket.SetLimit(bandwidthLimit(bytesPerSec, burst))
+}
+
+func bandwidthLimit(bytesPerSec float64, burst int) (float64, int) {
+	if bytesPerSec <= 0 {
+		return timeutil.Inf, math.MaxInt32
+	}
+	if burst <= 0 {
+		burst = int(math.Min(bytesPerSec, math.MaxInt32))
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return bytesPerSec, burst
+}
+
+// Limit returns the current rate and burst of l. The rate is zero if there
+// is no limit.
+func (l *BandwidthLimiter) Limit() (bytesPerSec float64, burst int) {
+	rate, burst := l.bucket.Limit()
+	if math.IsInf(rate, 1) {
+		return 0, 0
+	}
+	return rate, burst
+}
+
+func (l *BandwidthLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// chunk returns how many of n bytes may be transferred in one go.
+func (l *BandwidthLimiter) chunk(n int) int {
+	if _, burst := l.bucket.Limit(); n > burst {
+		return burst
+	}
+	return n
+}
+
+// reserve takes n bytes from the budget, going into debt if there isn't
+// enough, and returns how long the caller must wait for the debt to be
+// repaid.
+func (l *BandwidthLimiter) reserve(n int) time.Duration {
+	return l.bucket.ReserveN(l.clock(), n)
+}
+
+// NewBandwidthLimitedConn returns a connection wrapping c whose reads and
+// writes are limited by read and write respectively. Either may be nil for
+// no limit, and both may be the same limiter to limit the total of reads and
+// writes.
+func NewBandwidthLimitedConn(c net.Conn, read, write *BandwidthLimiter) net.Conn {
+	return &bandwidthConn{Conn: c, read: read, write: write, closed: make(chan struct{})}
+}
+
+type bandwidthConn struct {
+	net.Conn
+	read, write *BandwidthLimiter
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (c *bandwidthConn) Read(b []byte) (int, error) {
+	if c.read == nil {
+		return c.Conn.Read(b)
+	}
+	// Wait until any debt from previous reads is repaid, then read no
+	// more than a burst's worth.
+	if err := c.wait(c.read.reserve(0)); err != nil {
+		return 0, err
+	}
+	if len(b) > 0 {
+		b = b[:c.read.chunk(len(b))]
+	}
+	n, err := c.Conn.Read(b)
+	c.read.reserve(n)
+	return n, err
+}
+
+func (c *bandwidthConn) Write(b []byte) (int, error) {
+	if c.write == nil {
+		return c.Conn.Write(b)
+	}
+	var written int
+	for len(b) > 0 {
+		chunk := b[:c.write.chunk(len(b))]
+		if err := c.wait(c.write.reserve(len(chunk))); err != nil {
+			return written, err
+		}
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (c *bandwidthConn) wait(d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.closed:
+		return net.ErrClosed
+	}
+}
+
+func (c *bandwidthConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,95 @@
+package netutil
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimiterReserve(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewBandwidthLimiter(100, 50)
+	l.now = func() time.Time { return now }
+
+	for i, tt := range []struct {
+		advance time.Duration
+		n       int
+		w       time.Duration
+	}{
+		// The bucket starts full.
+		{0, 50, 0},
+		// Empty: 10 bytes take 100ms to repay.
+		{0, 10, 100 * time.Millisecond},
+		// 100ms later the debt is repaid.
+		{100 * time.Millisecond, 0, 0},
+		// Refilling never exceeds the burst.
+		{time.Hour, 60, 100 * time.Millisecond},
+	} {
+		now = now.Add(tt.advance)
+		if d := l.reserve(tt.n); d != tt.w {
+			t.Errorf("case %d: want delay %v, got %v", i, tt.w, d)
+		}
+	}
+
+	l.SetLimit(0, 0)
+	if d := l.reserve(1 << 20); d != 0 {
+		t.Errorf("want no delay when unlimited, got %v", d)
+	}
+	if n := l.chunk(1 << 20); n != 1<<20 {
+		t.Errorf("want no chunking when unlimited, got %d", n)
+	}
+
+	l.SetLimit(1000, 0)
+	if rate, burst := l.Limit(); rate != 1000 || burst != 1000 {
+		t.Errorf("want 1000/1000, got %v/%v", rate, burst)
+	}
+}
+
+func TestBandwidthLimitedConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	// 2000 bytes at 10000 bytes a second, after an initial burst of 1000,
+	// take at least 100ms.
+	limited := NewBandwidthLimitedConn(client, nil, NewBandwidthLimiter(10000, 1000))
+	go func() {
+		limited.Write(make([]byte, 2000))
+		limited.Close()
+	}()
+
+	start := time.Now()
+	data, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2000 {
+		t.Errorf("read %d bytes, want 2000", len(data))
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("transfer took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestBandwidthLimitedConnClose(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go io.Copy(io.Discard, server)
+
+	limited := NewBandwidthLimitedConn(client, nil, NewBandwidthLimiter(1, 1))
+	done := make(chan error)
+	go func() {
+		_, err := limited.Write(make([]byte, 10))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	limited.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected error writing to closed connection")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Write did not return after Close")
+	}
+}
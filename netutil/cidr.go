@@ -0,0 +1,161 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+)
+
+var (
+	// ErrNoAvailableSubnet is returned by NextAvailableSubnet when the pool
+	// is exhausted.
+	ErrNoAvailableSubnet = errors.New("no available subnet in pool")
+
+	// ErrMixedAddressFamilies is returned when IPv4 and IPv6 addresses are
+	// combined in one operation.
+	ErrMixedAddressFamilies = errors.New("mixed IPv4 and IPv6 addresses")
+)
+
+// maxSplitSubnets bounds the number of subnets SplitCIDR will return.
+const maxSplitSubnets = 1 << 16
+
+// ipInt returns ip as an integer, along with its length in bits.
+func ipInt(ip net.IP) (*big.Int, int) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return new(big.Int).SetBytes(ip4), 8 * net.IPv4len
+	}
+	return new(big.Int).SetBytes(ip.To16()), 8 * net.IPv6len
+}
+
+func intIP(i *big.Int, bits int) net.IP {
+	ip := make(net.IP, bits/8)
+	return i.FillBytes(ip)
+}
+
+// cidrBounds returns the first and last addresses of n as integers, the
+// prefix length and the address length in bits.
+func cidrBounds(n *net.IPNet) (first, last *big.Int, ones, bits int) {
+	ones, bits = n.Mask.Size()
+	first, _ = ipInt(n.IP.Mask(n.Mask))
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last = new(big.Int).Add(first, size)
+	last.Sub(last, big.NewInt(1))
+	return first, last, ones, bits
+}
+
+func cidr(first *big.Int, ones, bits int) *net.IPNet {
+	return &net.IPNet{IP: intIP(first, bits), Mask: net.CIDRMask(ones, bits)}
+}
+
+// CIDRRange returns the first and last addresses in n.
+func CIDRRange(n *net.IPNet) (first, last net.IP) {
+	f, l, _, bits := cidrBounds(n)
+	return intIP(f, bits), intIP(l, bits)
+}
+
+// CIDRContains reports whether every address in inner is also in outer.
+func CIDRContains(outer, inner *net.IPNet) bool {
+	of, ol, _, obits := cidrBounds(outer)
+	inf, il, _, ibits := cidrBounds(inner)
+	return obits == ibits && of.Cmp(inf) <= 0 && il.Cmp(ol) <= 0
+}
+
+// CIDROverlaps reports whether a and b have any addresses in common.
+func CIDROverlaps(a, b *net.IPNet) bool {
+	af, al, _, abits := cidrBounds(a)
+	bf, bl, _, bbits := cidrBounds(b)
+	return abits == bbits && af.Cmp(bl) <= 0 && bf.Cmp(al) <= 0
+}
+
+// SplitCIDR divides n into subnets with the given prefix length, in address
+// order. At most 65536 subnets are returned; splitting into more is an
+// error.
+func SplitCIDR(n *net.IPNet, prefixLen int) ([]*net.IPNet, error) {
+	first, _, ones, bits := cidrBounds(n)
+	if prefixLen < ones || prefixLen > bits {
+		return nil, fmt.Errorf("cannot split %v into /%d subnets", n, prefixLen)
+	}
+	if prefixLen-ones > 16 {
+		return nil, fmt.Errorf("splitting %v into /%d subnets would create more than %d subnets", n, prefixLen, maxSplitSubnets)
+	}
+	count := 1 << uint(prefixLen-ones)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen))
+	subnets := make([]*net.IPNet, 0, count)
+	cur := new(big.Int).Set(first)
+	for i := 0; i < count; i++ {
+		subnets = append(subnets, cidr(cur, prefixLen, bits))
+		cur.Add(cur, step)
+	}
+	return subnets, nil
+}
+
+// NextAvailableSubnet returns the lowest subnet of pool with the given prefix
+// length which does not overlap any of the used networks.
+func NextAvailableSubnet(pool *net.IPNet, prefixLen int, used []*net.IPNet) (*net.IPNet, error) {
+	first, last, ones, bits := cidrBounds(pool)
+	if prefixLen < ones || prefixLen > bits {
+		return nil, fmt.Errorf("cannot allocate a /%d subnet from %v", prefixLen, pool)
+	}
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen))
+
+	cur := new(big.Int).Set(first)
+	for cur.Cmp(last) <= 0 {
+		candidate := cidr(cur, prefixLen, bits)
+		var blocker *net.IPNet
+		for _, u := range used {
+			if CIDROverlaps(candidate, u) {
+				blocker = u
+				break
+			}
+		}
+		if blocker == nil {
+			return candidate, nil
+		}
+		// Skip to the first aligned subnet after whichever of the
+		// candidate and the blocking network ends last.
+		_, end, _, _ := cidrBounds(blocker)
+		if _, cend, _, _ := cidrBounds(candidate); cend.Cmp(end) > 0 {
+			end = cend
+		}
+		cur.Add(end, big.NewInt(1))
+		rem := new(big.Int).Mod(cur, step)
+		if rem.Sign() != 0 {
+			cur.Add(cur, rem.Sub(step, rem))
+		}
+	}
+	return nil, ErrNoAvailableSubnet
+}
+
+// RangeToCIDRs returns the smallest list of networks which together cover
+// exactly the addresses from first to last inclusive.
+func RangeToCIDRs(first, last net.IP) ([]*net.IPNet, error) {
+	start, sbits := ipInt(first)
+	end, ebits := ipInt(last)
+	if sbits != ebits {
+		return nil, ErrMixedAddressFamilies
+	}
+	if start.Cmp(end) > 0 {
+		return nil, fmt.Errorf("invalid range: %v is after %v", first, last)
+	}
+	bits := sbits
+
+	var nets []*net.IPNet
+	one := big.NewInt(1)
+	for start.Cmp(end) <= 0 {
+		// The block size is limited both by the alignment of start and by
+		// the number of addresses remaining.
+		size := bits
+		if start.Sign() != 0 {
+			size = int(start.TrailingZeroBits())
+		}
+		remaining := new(big.Int).Sub(end, start)
+		remaining.Add(remaining, one)
+		if n := remaining.BitLen() - 1; n < size {
+			size = n
+		}
+		nets = append(nets, cidr(start, bits-size, bits))
+		start = new(big.Int).Add(start, new(big.Int).Lsh(one, uint(size)))
+	}
+	return nets, nil
+}
@@ -0,0 +1,153 @@
+package netutil
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func cidrStrings(nets []*net.IPNet) []string {
+	var ss []string
+	for _, n := range nets {
+		ss = append(ss, n.String())
+	}
+	return ss
+}
+
+func TestCIDRRange(t *testing.T) {
+	tests := []struct {
+		n, first, last string
+	}{
+		{"10.0.0.0/8", "10.0.0.0", "10.255.255.255"},
+		{"192.168.1.7/32", "192.168.1.7", "192.168.1.7"},
+		{"2001:db8::/126", "2001:db8::", "2001:db8::3"},
+	}
+	for i, tt := range tests {
+		first, last := CIDRRange(mustCIDR(t, tt.n))
+		if first.String() != tt.first || last.String() != tt.last {
+			t.Errorf("case %d: want: %s-%s, got: %s-%s", i, tt.first, tt.last, first, last)
+		}
+	}
+}
+
+func TestCIDRContainsOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		contains bool
+		overlaps bool
+	}{
+		{"10.0.0.0/8", "10.1.0.0/16", true, true},
+		{"10.1.0.0/16", "10.0.0.0/8", false, true},
+		{"10.0.0.0/24", "10.0.1.0/24", false, false},
+		{"10.0.0.0/24", "10.0.0.0/24", true, true},
+		{"0.0.0.0/0", "::/0", false, false},
+		{"2001:db8::/32", "2001:db8:1::/48", true, true},
+	}
+	for i, tt := range tests {
+		a, b := mustCIDR(t, tt.a), mustCIDR(t, tt.b)
+		if got := CIDRContains(a, b); got != tt.contains {
+			t.Errorf("case %d: contains: want: %v, got: %v", i, tt.contains, got)
+		}
+		if got := CIDROverlaps(a, b); got != tt.overlaps {
+			t.Errorf("case %d: overlaps: want: %v, got: %v", i, tt.overlaps, got)
+		}
+	}
+}
+
+func TestSplitCIDR(t *testing.T) {
+	got, err := SplitCIDR(mustCIDR(t, "10.0.0.0/22"), 24)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"}
+	if !reflect.DeepEqual(cidrStrings(got), want) {
+		t.Errorf("want: %v, got: %v", want, cidrStrings(got))
+	}
+
+	for i, prefix := range []int{21, 33, 30} {
+		n := mustCIDR(t, "10.0.0.0/22")
+		if prefix == 30 {
+			n = mustCIDR(t, "10.0.0.0/8")
+		}
+		if _, err := SplitCIDR(n, prefix); err == nil {
+			t.Errorf("case %d: expected error splitting %v into /%d", i, n, prefix)
+		}
+	}
+}
+
+func TestNextAvailableSubnet(t *testing.T) {
+	tests := []struct {
+		pool   string
+		prefix int
+		used   []string
+		w      string
+	}{
+		{"10.0.0.0/16", 24, nil, "10.0.0.0/24"},
+		{"10.0.0.0/16", 24, []string{"10.0.0.0/24", "10.0.1.0/24"}, "10.0.2.0/24"},
+		// A small used network blocks the whole candidate.
+		{"10.0.0.0/16", 24, []string{"10.0.0.128/25"}, "10.0.1.0/24"},
+		// A large used network is skipped in one step.
+		{"10.0.0.0/16", 24, []string{"10.0.0.0/20"}, "10.0.16.0/24"},
+		// Networks outside the pool don't matter.
+		{"10.0.0.0/16", 24, []string{"192.168.0.0/16"}, "10.0.0.0/24"},
+		{"10.0.0.0/23", 24, []string{"10.0.0.0/24", "10.0.1.0/24"}, ""},
+		{"2001:db8::/32", 48, []string{"2001:db8::/48"}, "2001:db8:1::/48"},
+	}
+	for i, tt := range tests {
+		var used []*net.IPNet
+		for _, u := range tt.used {
+			used = append(used, mustCIDR(t, u))
+		}
+		got, err := NextAvailableSubnet(mustCIDR(t, tt.pool), tt.prefix, used)
+		if tt.w == "" {
+			if err != ErrNoAvailableSubnet {
+				t.Errorf("case %d: want ErrNoAvailableSubnet, got %v, %v", i, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got.String() != tt.w {
+			t.Errorf("case %d: want: %v, got: %v", i, tt.w, got)
+		}
+	}
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	tests := []struct {
+		first, last string
+		w           []string
+	}{
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"2001:db8::", "2001:db8::2", []string{"2001:db8::/127", "2001:db8::2/128"}},
+	}
+	for i, tt := range tests {
+		got, err := RangeToCIDRs(net.ParseIP(tt.first), net.ParseIP(tt.last))
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(cidrStrings(got), tt.w) {
+			t.Errorf("case %d: want: %v, got: %v", i, tt.w, cidrStrings(got))
+		}
+	}
+
+	if _, err := RangeToCIDRs(net.ParseIP("10.0.0.1"), net.ParseIP("::1")); err != ErrMixedAddressFamilies {
+		t.Errorf("want ErrMixedAddressFamilies, got %v", err)
+	}
+	if _, err := RangeToCIDRs(net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")); err == nil {
+		t.Errorf("expected error for reversed range")
+	}
+}
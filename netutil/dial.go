@@ -0,0 +1,105 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+// DialRetryPolicy controls how DialContextWithRetry retries failed dials.
+type DialRetryPolicy struct {
+	// Dialer is used to make each attempt. If nil, a zero net.Dialer is
+	// used.
+	Dialer *net.Dialer
+
+	// MaxAttempts is the maximum number of dials, including the first.
+	// If zero, dials are retried until the context is done.
+	MaxAttempts int
+
+	// InitialBackoff is the maximum delay before the first retry, doubling
+	// on each subsequent retry up to MaxBackoff. The actual delay is chosen
+	// at random up to that maximum. If zero, 100ms and 5s are used.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// RetryUnreachable retries dials which fail because there is no route
+	// to the host or network. By default these are permanent errors, but
+	// they may be transient while networking is being brought up.
+	RetryUnreachable bool
+
+	// IsRetryable, if set, overrides the default classification of errors
+	// described at DialContextWithRetry.
+	IsRetryable func(error) bool
+}
+
+// DialContextWithRetry dials addr, retrying transient failures with
+// exponential backoff and jitter until a dial succeeds, policy.MaxAttempts is
+// reached, or ctx is done. It is meant for services which may start before
+// the services they depend on.
+//
+// By default refused connections, timeouts and temporary DNS failures are
+// retried. Unknown hosts and malformed addresses are permanent errors, as are
+// unreachable hosts and networks unless policy.RetryUnreachable is set. The
+// error from the last attempt is returned.
+func DialContextWithRetry(ctx context.Context, network, addr string, policy DialRetryPolicy) (net.Conn, error) {
+	d := policy.Dialer
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	backoff := timeutil.Backoff{
+		Initial:     policy.InitialBackoff,
+		Max:         policy.MaxBackoff,
+		Jitter:      timeutil.FullJitter,
+		IsRetryable: policy.IsRetryable,
+	}
+	if backoff.IsRetryable == nil {
+		backoff.IsRetryable = func(err error) bool { return isTransientDialError(err, policy.RetryUnreachable) }
+	}
+	if backoff.Initial == 0 {
+		backoff.Initial = 100 * time.Millisecond
+	}
+	if backoff.Max == 0 {
+		backoff.Max = 5 * time.Second
+	}
+	// The attempts are counted here rather than with MaxRetries, for
+	// which 0 would mean no limit rather than a single attempt.
+	attempts := 0
+
+	var conn net.Conn
+	err := timeutil.Do(ctx, &backoff, func(ctx context.Context) error {
+		var err error
+		conn, err = d.DialContext(ctx, network, addr)
+		attempts++
+		if err != nil && policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+			return timeutil.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func isTransientDialError(err error, retryUnreachable bool) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+	}
+	var addrErr *net.AddrError
+	if errors.As(err, &addrErr) {
+		return false
+	}
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return true
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return retryUnreachable
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,120 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientDialError(t *testing.T) {
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+	}
+	tests := []struct {
+		err              error
+		retryUnreachable bool
+		w                bool
+	}{
+		{opErr(syscall.ECONNREFUSED), false, true},
+		{opErr(syscall.EHOSTUNREACH), false, false},
+		{opErr(syscall.EHOSTUNREACH), true, true},
+		{opErr(syscall.ENETUNREACH), true, true},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, false, false},
+		{&net.DNSError{Err: "server misbehaving", IsTemporary: true}, false, true},
+		{&net.AddrError{Err: "missing port in address"}, false, false},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, false, true},
+		{errors.New("something else"), false, false},
+	}
+	for i, tt := range tests {
+		if got := isTransientDialError(tt.err, tt.retryUnreachable); got != tt.w {
+			t.Errorf("case %d: want: %v, got: %v", i, tt.w, got)
+		}
+	}
+}
+
+func TestDialContextWithRetry(t *testing.T) {
+	// Reserve a port, then start listening on it only after the first
+	// dials have been refused.
+	port, err := FreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	attempts := 0
+	policy := DialRetryPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		MaxAttempts:    100,
+	}
+	var ln net.Listener
+	policy.IsRetryable = func(err error) bool {
+		attempts++
+		if attempts == 2 {
+			var lerr error
+			if ln, lerr = net.Listen("tcp", addr); lerr != nil {
+				t.Skipf("port %s was taken: %v", addr, lerr)
+			}
+		}
+		return isTransientDialError(err, false)
+	}
+
+	c, err := DialContextWithRetry(context.Background(), "tcp", addr, policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Close()
+	ln.Close()
+	if attempts != 2 {
+		t.Errorf("want 2 failed attempts, got %d", attempts)
+	}
+}
+
+func TestDialContextWithRetryLimits(t *testing.T) {
+	port, err := FreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	policy := DialRetryPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 3}
+	attempts := 0
+	policy.IsRetryable = func(err error) bool {
+		attempts++
+		return true
+	}
+	if _, err := DialContextWithRetry(context.Background(), "tcp", addr, policy); err == nil {
+		t.Fatalf("expected error")
+	}
+	if attempts != 2 {
+		t.Errorf("want retryability checked twice, got %d", attempts)
+	}
+
+	// A single attempt is not retried.
+	attempts = 0
+	policy.MaxAttempts = 1
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := DialContextWithRetry(ctx, "tcp", addr, policy); err == nil {
+		t.Fatalf("expected error")
+	}
+	if attempts != 0 || ctx.Err() != nil {
+		t.Errorf("retried a single attempt: attempts=%d ctx=%v", attempts, ctx.Err())
+	}
+
+	// Permanent errors are not retried.
+	if _, err := DialContextWithRetry(context.Background(), "tcp", "no-port", DialRetryPolicy{}); err == nil {
+		t.Errorf("expected error for malformed address")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := DialContextWithRetry(ctx, "tcp", addr, DialRetryPolicy{InitialBackoff: time.Hour}); err == nil {
+		t.Errorf("expected error once the context is done")
+	}
+}
@@ -0,0 +1,249 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DrainListener wraps a net.Listener, tracking the connections it accepts so
+// that they can be drained for a graceful shutdown or restart.
+//
+// Connections are considered active until marked idle with SetIdle. Servers
+// for protocols with persistent connections should mark connections idle
+// between requests; for an http.Server this can be done from ConnState:
+//
+//	srv.ConnState = func(c net.Conn, s http.ConnState) {
+//		l.SetIdle(c, s == http.StateIdle)
+//	}
+type DrainListener struct {
+	net.Listener
+
+	mu       sync.Mutex
+	conns    map[*drainConn]bool // value is whether the conn is idle
+	draining bool
+	drained  chan struct{}
+}
+
+// NewDrainListener returns a DrainListener wrapping l.
+func NewDrainListener(l net.Listener) *DrainListener {
+	return &DrainListener{
+		Listener: l,
+		conns:    make(map[*drainConn]bool),
+		drained:  make(chan struct{}),
+	}
+}
+
+func (l *DrainListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	dc := &drainConn{Conn: c, l: l}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.draining {
+		c.Close()
+		return nil, net.ErrClosed
+	}
+	l.conns[dc] = false
+	return dc, nil
+}
+
+// SetIdle marks a connection accepted from l as idle or active. Idle
+// connections are closed when the listener drains, and connections marked
+// idle while draining are closed immediately. c may also be a connection
+// wrapping one accepted from l, such as a *tls.Conn.
+func (l *DrainListener) SetIdle(c net.Conn, idle bool) {
+	dc := unwrapDrainConn(c)
+	if dc == nil || dc.l != l {
+		return
+	}
+	l.mu.Lock()
+	if _, ok := l.conns[dc]; !ok {
+		l.mu.Unlock()
+		return
+	}
+	l.conns[dc] = idle
+	closeNow := idle && l.draining
+	l.mu.Unlock()
+	if closeNow {
+		dc.Close()
+	}
+}
+
+func unwrapDrainConn(c net.Conn) *drainConn {
+	for {
+		switch cc := c.(type) {
+		case *drainConn:
+			return cc
+		case interface{ NetConn() net.Conn }:
+			c = cc.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+// Conns returns the number of open connections accepted from l.
+func (l *DrainListener) Conns() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// Drain stops accepting new connections, closes idle connections and waits
+// for active ones to be closed. If ctx is done first the remaining
+// connections are closed forcibly and ctx.Err() is returned.
+//
+// The listening socket is closed, so to hand it to a successor process call
+// File or PassListeners before Drain.
+func (l *DrainListener) Drain(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.draining {
+		l.draining = true
+		l.Listener.Close()
+		if len(l.conns) == 0 {
+			close(l.drained)
+		}
+	}
+	idle := l.connsLocked(true)
+	l.mu.Unlock()
+	for _, c := range idle {
+		c.Close()
+	}
+
+	select {
+	case <-l.drained:
+		return nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	remaining := l.connsLocked(false)
+	l.mu.Unlock()
+	for _, c := range remaining {
+		c.Close()
+	}
+	return ctx.Err()
+}
+
+// connsLocked returns the idle connections, or all of them if onlyIdle is
+// false. l.mu must be held.
+func (l *DrainListener) connsLocked(onlyIdle bool) []*drainConn {
+	var cs []*drainConn
+	for c, idle := range l.conns {
+		if idle || !onlyIdle {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+func (l *DrainListener) remove(c *drainConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.conns[c]; !ok {
+		return
+	}
+	delete(l.conns, c)
+	if l.draining && len(l.conns) == 0 {
+		close(l.drained)
+	}
+}
+
+// File returns a duplicate of the listening socket's file descriptor, which
+// remains valid after the listener is closed.
+func (l *DrainListener) File() (*os.File, error) {
+	return listenerFile(l.Listener)
+}
+
+type drainConn struct {
+	net.Conn
+	l    *DrainListener
+	once sync.Once
+	err  error
+}
+
+func (c *drainConn) Close() error {
+	c.once.Do(func() {
+		c.err = c.Conn.Close()
+		c.l.remove(c)
+	})
+	return c.err
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+func listenerFile(l net.Listener) (*os.File, error) {
+	f, ok := l.(filer)
+	if !ok {
+		return nil, errors.New("listener does not support file descriptor passing")
+	}
+	return f.File()
+}
+
+// PassListeners configures cmd, which has not yet been started, to inherit
+// listeners using the systemd socket activation protocol, so the new process
+// can pick them up with ListenersFromSystemd or ListenSystemd. As a process
+// started this way cannot know its own PID in advance, LISTEN_PID is left
+// unset; ListenersFromSystemd accepts this.
+//
+// Files are passed in order of name. PassListeners returns a function which
+// closes this process's duplicates of them, to be called once cmd has
+// started or failed to start; otherwise each handover leaks a descriptor per
+// listener. The listeners themselves may be closed, or drained, once cmd has
+// started.
+func PassListeners(cmd *exec.Cmd, listeners map[string]net.Listener) (closeFiles func(), err error) {
+	names := make([]string, 0, len(listeners))
+	for name := range listeners {
+		if strings.Contains(name, ":") {
+			return nil, errors.New("listener name cannot contain ':': " + name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files []*os.File
+	for _, name := range names {
+		f, err := listenerFile(listeners[name])
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	var kept []string
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "LISTEN_PID=") && !strings.HasPrefix(kv, "LISTEN_FDS=") &&
+			!strings.HasPrefix(kv, "LISTEN_FDNAMES=") {
+			kept = append(kept, kv)
+		}
+	}
+	// ExtraFiles start at descriptor 3, which is where socket activated
+	// descriptors are expected, so any existing ExtraFiles must come after.
+	cmd.ExtraFiles = append(files, cmd.ExtraFiles...)
+	cmd.Env = append(kept,
+		"LISTEN_FDS="+strconv.Itoa(len(files)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+	)
+	return func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}, nil
+}
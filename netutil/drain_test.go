@@ -0,0 +1,87 @@
+package netutil
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func dialDrain(t *testing.T, l *DrainListener) (client, server net.Conn) {
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestDrainListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewDrainListener(ln)
+
+	idleClient, idle := dialDrain(t, l)
+	defer idleClient.Close()
+	activeClient, active := dialDrain(t, l)
+	defer activeClient.Close()
+	l.SetIdle(idle, true)
+	if n := l.Conns(); n != 2 {
+		t.Fatalf("want 2 connections, got %d", n)
+	}
+
+	done := make(chan error)
+	go func() { done <- l.Drain(context.Background()) }()
+
+	// The idle connection is closed straight away.
+	idleClient.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := idleClient.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("want EOF on idle connection, got %v", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Drain returned early: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// No new connections are accepted.
+	if _, err := l.Accept(); err == nil {
+		t.Errorf("expected error accepting while draining")
+	}
+
+	// Drain returns once the active connection finishes.
+	active.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Drain did not return")
+	}
+}
+
+func TestDrainListenerTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewDrainListener(ln)
+	client, _ := dialDrain(t, l)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+	if n := l.Conns(); n != 0 {
+		t.Errorf("want active connection closed, %d remain", n)
+	}
+}
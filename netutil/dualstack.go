@@ -0,0 +1,129 @@
+package netutil
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ListenDualStack listens for TCP connections on port on all IPv4 and IPv6
+// addresses, returning a listener which accepts connections from both.
+//
+// Separate sockets are used for each family, with the IPv6 socket marked
+// IPV6_V6ONLY, so the behaviour does not depend on whether the platform
+// defaults to dual-stack sockets (Linux with net.ipv6.bindv6only=0) or
+// doesn't support them at all (OpenBSD). If either family is unavailable on
+// this host a listener for the other alone is returned. If port is zero the
+// same system-chosen port is used for both families.
+func ListenDualStack(port int, opts SocketOptions) (net.Listener, error) {
+	const attempts = 5
+	var err error
+	for i := 0; i < attempts; i++ {
+		var l net.Listener
+		l, err = listenDualStack(port, opts)
+		// With a system-chosen port the IPv6 port may already be in use;
+		// try again with a different one.
+		if port == 0 && errors.Is(err, syscall.EADDRINUSE) {
+			continue
+		}
+		return l, err
+	}
+	return nil, err
+}
+
+func listenDualStack(port int, opts SocketOptions) (net.Listener, error) {
+	l4, err4 := Listen("tcp4", net.JoinHostPort("0.0.0.0", strconv.Itoa(port)), opts)
+	if err4 == nil && port == 0 {
+		port = l4.Addr().(*net.TCPAddr).Port
+	}
+	l6, err6 := Listen("tcp6", net.JoinHostPort("::", strconv.Itoa(port)), opts)
+
+	switch {
+	case err4 != nil && err6 != nil:
+		return nil, err4
+	case err4 != nil:
+		if errors.Is(err4, syscall.EADDRINUSE) {
+			l6.Close()
+			return nil, err4
+		}
+		return l6, nil
+	case err6 != nil:
+		if errors.Is(err6, syscall.EADDRINUSE) {
+			l4.Close()
+			return nil, err6
+		}
+		return l4, nil
+	}
+	return newMultiListener(l4, l6), nil
+}
+
+// multiListener accepts connections from several listeners.
+type multiListener struct {
+	listeners []net.Listener
+	accepted  chan acceptResult
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+func newMultiListener(listeners ...net.Listener) *multiListener {
+	ml := &multiListener{
+		listeners: listeners,
+		accepted:  make(chan acceptResult),
+		done:      make(chan struct{}),
+	}
+	for _, l := range listeners {
+		go ml.serve(l)
+	}
+	return ml
+}
+
+func (ml *multiListener) serve(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		select {
+		case ml.accepted <- acceptResult{c, err}:
+		case <-ml.done:
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+func (ml *multiListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-ml.accepted:
+		return r.conn, r.err
+	case <-ml.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (ml *multiListener) Close() error {
+	var err error
+	ml.closeOnce.Do(func() {
+		close(ml.done)
+		for _, l := range ml.listeners {
+			if cerr := l.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// Addr returns the address of the first listener.
+func (ml *multiListener) Addr() net.Addr {
+	return ml.listeners[0].Addr()
+}
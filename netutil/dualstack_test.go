@@ -0,0 +1,45 @@
+package netutil
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestListenDualStack(t *testing.T) {
+	l, err := ListenDualStack(0, SocketOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	_, dual := l.(*multiListener)
+	hosts := []string{"127.0.0.1"}
+	if dual {
+		hosts = append(hosts, "::1")
+	}
+	for _, host := range hosts {
+		c, err := net.Dial("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			t.Errorf("%s: dial failed: %v", host, err)
+			continue
+		}
+		s, err := l.Accept()
+		if err != nil {
+			t.Fatalf("%s: accept failed: %v", host, err)
+		}
+		if got := s.LocalAddr().(*net.TCPAddr).IP; !got.Equal(net.ParseIP(host)) {
+			t.Errorf("%s: accepted connection on %v", host, got)
+		}
+		s.Close()
+		c.Close()
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("unexpected error closing listener: %v", err)
+	}
+	if _, err := l.Accept(); err == nil {
+		t.Errorf("expected error accepting on closed listener")
+	}
+}
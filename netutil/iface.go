@@ -0,0 +1,111 @@
+package netutil
+
+import (
+	"errors"
+	"net"
+	"path"
+)
+
+// ErrNoRoutableIP is returned when no interface has a routable address of the
+// requested family.
+var ErrNoRoutableIP = errors.New("no routable IP address found")
+
+// RoutableIPv4 returns the first routable IPv4 address of an interface which
+// is up. Loopback, link-local, multicast and unspecified addresses are not
+// considered routable; private addresses are.
+func RoutableIPv4() (net.IP, error) {
+	return routableIP(false)
+}
+
+// RoutableIPv6 is like RoutableIPv4 but returns an IPv6 address.
+func RoutableIPv6() (net.IP, error) {
+	return routableIP(true)
+}
+
+func routableIP(v6 bool) (net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		if ip := firstRoutable(addrs, v6); ip != nil {
+			return ip, nil
+		}
+	}
+	return nil, ErrNoRoutableIP
+}
+
+func firstRoutable(addrs []net.Addr, v6 bool) net.IP {
+	for _, a := range addrs {
+		ip := addrIP(a)
+		if ip == nil || !isRoutable(ip) {
+			continue
+		}
+		if (ip.To4() == nil) == v6 {
+			return ip
+		}
+	}
+	return nil
+}
+
+func isRoutable(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsUnspecified() && !ip.IsMulticast() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast()
+}
+
+func addrIP(a net.Addr) net.IP {
+	switch a := a.(type) {
+	case *net.IPNet:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// InterfaceAddrs returns the addresses of every interface whose name matches
+// pattern, using the syntax of path.Match (e.g. "eth*" or "en[0-9]").
+func InterfaceAddrs(pattern string) ([]net.IP, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, iface := range ifaces {
+		if ok, _ := path.Match(pattern, iface.Name); !ok {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if ip := addrIP(a); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// OutboundIP returns the local address the system would use to reach peer,
+// given as "host:port". No packets are sent: connecting a UDP socket only
+// consults the routing table.
+func OutboundIP(peer string) (net.IP, error) {
+	conn, err := net.Dial("udp", peer)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -0,0 +1,72 @@
+package netutil
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFirstRoutable(t *testing.T) {
+	cidr := func(s string) net.Addr {
+		ip, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.IP = ip
+		return n
+	}
+	addrs := []net.Addr{
+		cidr("127.0.0.1/8"),
+		cidr("169.254.1.1/16"),
+		cidr("::1/128"),
+		cidr("fe80::1/64"),
+		cidr("10.0.0.5/24"),
+		cidr("2001:db8::5/64"),
+	}
+
+	tests := []struct {
+		addrs []net.Addr
+		v6    bool
+		w     string
+	}{
+		{addrs, false, "10.0.0.5"},
+		{addrs, true, "2001:db8::5"},
+		{addrs[:4], false, ""},
+		{addrs[:4], true, ""},
+		{[]net.Addr{&net.IPAddr{IP: net.ParseIP("192.168.1.1")}}, false, "192.168.1.1"},
+	}
+	for i, tt := range tests {
+		got := firstRoutable(tt.addrs, tt.v6)
+		if tt.w == "" {
+			if got != nil {
+				t.Errorf("case %d: want: nil, got: %v", i, got)
+			}
+			continue
+		}
+		if !got.Equal(net.ParseIP(tt.w)) {
+			t.Errorf("case %d: want: %v, got: %v", i, tt.w, got)
+		}
+	}
+}
+
+func TestInterfaceAddrs(t *testing.T) {
+	if _, err := InterfaceAddrs("["); err == nil {
+		t.Errorf("expected error for bad pattern")
+	}
+	ips, err := InterfaceAddrs("no-such-interface*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 0 {
+		t.Errorf("expected no addresses, got %v", ips)
+	}
+}
+
+func TestOutboundIP(t *testing.T) {
+	ip, err := OutboundIP("127.0.0.1:9")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ip.IsLoopback() {
+		t.Errorf("want loopback address, got %v", ip)
+	}
+}
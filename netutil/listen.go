@@ -0,0 +1,88 @@
+package netutil
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+)
+
+// SocketOptions configures TCP sockets created by Listen and NewDialer.
+type SocketOptions struct {
+	// KeepAliveIdle is how long a connection must be idle before the
+	// first keep-alive probe is sent, KeepAliveInterval the time between
+	// probes, and KeepAliveCount how many unanswered probes cause the
+	// connection to be dropped. Zero values use the system defaults.
+	// Keep-alives are disabled if KeepAliveIdle is negative.
+	KeepAliveIdle     time.Duration
+	KeepAliveInterval time.Duration
+	KeepAliveCount    int
+
+	// UserTimeout sets TCP_USER_TIMEOUT: the maximum time transmitted data
+	// may remain unacknowledged before the connection is dropped. It is
+	// only supported on Linux and is ignored elsewhere.
+	UserTimeout time.Duration
+
+	// ReusePort sets SO_REUSEPORT on listening sockets, allowing several
+	// processes to listen on the same address.
+	ReusePort bool
+}
+
+func (o SocketOptions) keepAlive() net.KeepAliveConfig {
+	if o.KeepAliveIdle < 0 {
+		return net.KeepAliveConfig{Enable: false}
+	}
+	return net.KeepAliveConfig{
+		Enable:   true,
+		Idle:     o.KeepAliveIdle,
+		Interval: o.KeepAliveInterval,
+		Count:    o.KeepAliveCount,
+	}
+}
+
+// Listen is like net.Listen but applies opts to the listening socket and to
+// every accepted connection.
+func Listen(network, address string, opts SocketOptions) (net.Listener, error) {
+	lc := net.ListenConfig{
+		KeepAliveConfig: opts.keepAlive(),
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			cerr := c.Control(func(fd uintptr) {
+				if opts.ReusePort {
+					if err = setReusePort(fd); err != nil {
+						return
+					}
+				}
+				// Accepted connections inherit TCP_USER_TIMEOUT from the
+				// listening socket.
+				if opts.UserTimeout > 0 {
+					err = setUserTimeout(fd, opts.UserTimeout)
+				}
+			})
+			if cerr != nil {
+				return cerr
+			}
+			return err
+		},
+	}
+	return lc.Listen(context.Background(), network, address)
+}
+
+// NewDialer returns a net.Dialer which applies opts to outgoing connections.
+// ReusePort is ignored.
+func NewDialer(opts SocketOptions) *net.Dialer {
+	d := &net.Dialer{KeepAliveConfig: opts.keepAlive()}
+	if opts.UserTimeout > 0 {
+		d.Control = func(network, address string, c syscall.RawConn) error {
+			var err error
+			cerr := c.Control(func(fd uintptr) {
+				err = setUserTimeout(fd, opts.UserTimeout)
+			})
+			if cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+	return d
+}
@@ -0,0 +1,86 @@
+//go:build linux
+// +build linux
+
+package netutil
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func getsockoptInt(t *testing.T, c syscallConn, level, opt int) int {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		v, serr = unix.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return v
+}
+
+type syscallConn interface {
+	SyscallConn() (syscall.RawConn, error)
+}
+
+func TestListenSocketOptions(t *testing.T) {
+	opts := SocketOptions{
+		KeepAliveIdle:     30 * time.Second,
+		KeepAliveInterval: 5 * time.Second,
+		KeepAliveCount:    3,
+		UserTimeout:       45 * time.Second,
+		ReusePort:         true,
+	}
+	l, err := Listen("tcp", "127.0.0.1:0", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// A second listener can share the address.
+	l2, err := Listen("tcp", l.Addr().String(), opts)
+	if err != nil {
+		t.Fatalf("SO_REUSEPORT not applied: %v", err)
+	}
+	l2.Close()
+
+	c, err := NewDialer(opts).Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i, conn := range []net.Conn{c, s} {
+		tc := conn.(*net.TCPConn)
+		for _, tt := range []struct {
+			level, opt int
+			w          int
+		}{
+			{unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1},
+			{unix.IPPROTO_TCP, unix.TCP_KEEPIDLE, 30},
+			{unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, 5},
+			{unix.IPPROTO_TCP, unix.TCP_KEEPCNT, 3},
+			{unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, 45000},
+		} {
+			if got := getsockoptInt(t, tc, tt.level, tt.opt); got != tt.w {
+				t.Errorf("conn %d: option %d == %d, want %d", i, tt.opt, got, tt.w)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrNoFreePort is returned when every port in a range is already in use.
+var ErrNoFreePort = errors.New("no free port in range")
+
+// PortRange is an inclusive range of ports.
+type PortRange struct {
+	First int
+	Last  int
+}
+
+func (r PortRange) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// Contains reports whether port falls within the range.
+func (r PortRange) Contains(port int) bool {
+	return port >= r.First && port <= r.Last
+}
+
+// ParsePortRanges parses a comma separated list of ports and inclusive port
+// ranges, such as "9000-9100,9200".
+func ParsePortRanges(spec string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty port range in %q", spec)
+		}
+		first, last := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			first, last = part[:i], part[i+1:]
+		}
+		f, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		l, err := parsePort(last)
+		if err != nil {
+			return nil, err
+		}
+		if f > l {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, PortRange{First: f, Last: l})
+	}
+	return ranges, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
+
+// ListenPortRange listens on host at the first port in ranges which is free,
+// returning the bound listener. Holding the listener, rather than just the
+// port number, avoids racing with other processes for the port. If ranges is
+// empty a port from the system's ephemeral range is used.
+func ListenPortRange(network, host string, ranges []PortRange) (net.Listener, error) {
+	if len(ranges) == 0 {
+		return net.Listen(network, net.JoinHostPort(host, "0"))
+	}
+	for _, r := range ranges {
+		for p := r.First; p <= r.Last; p++ {
+			l, err := net.Listen(network, net.JoinHostPort(host, strconv.Itoa(p)))
+			if err == nil {
+				return l, nil
+			}
+		}
+	}
+	return nil, ErrNoFreePort
+}
+
+// FreePort returns a TCP port on the loopback interface which was free at the
+// time of the call. As the port is released before returning, it may be taken
+// by another process before it is used; prefer ListenPortRange where the
+// listener can be used directly.
+func FreePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
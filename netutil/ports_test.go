@@ -0,0 +1,86 @@
+package netutil
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParsePortRanges(t *testing.T) {
+	tests := []struct {
+		spec  string
+		w     []PortRange
+		isErr bool
+	}{
+		{
+			spec: "80",
+			w:    []PortRange{{80, 80}},
+		},
+		{
+			spec: "9000-9100,9200",
+			w:    []PortRange{{9000, 9100}, {9200, 9200}},
+		},
+		{
+			spec: " 1 - 2 , 3 ",
+			w:    []PortRange{{1, 2}, {3, 3}},
+		},
+		{spec: "", isErr: true},
+		{spec: "80,", isErr: true},
+		{spec: "0", isErr: true},
+		{spec: "65536", isErr: true},
+		{spec: "9100-9000", isErr: true},
+		{spec: "http", isErr: true},
+		{spec: "1-2-3", isErr: true},
+	}
+
+	for i, tt := range tests {
+		got, err := ParsePortRanges(tt.spec)
+		if tt.isErr {
+			if err == nil {
+				t.Errorf("case %d: expected error parsing %q", i, tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.w, got) {
+			t.Errorf("case %d: want: %v, got: %v", i, tt.w, got)
+		}
+	}
+}
+
+func TestPortRangeString(t *testing.T) {
+	if s := (PortRange{9000, 9100}).String(); s != "9000-9100" {
+		t.Errorf("want: 9000-9100, got: %v", s)
+	}
+	if s := (PortRange{80, 80}).String(); s != "80" {
+		t.Errorf("want: 80, got: %v", s)
+	}
+}
+
+func TestListenPortRange(t *testing.T) {
+	port, err := FreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		t.Skipf("port %d was taken: %v", port, err)
+	}
+	defer held.Close()
+
+	// The only port in the range is taken.
+	r := []PortRange{{port, port}}
+	if _, err := ListenPortRange("tcp", "127.0.0.1", r); err != ErrNoFreePort {
+		t.Errorf("want ErrNoFreePort, got %v", err)
+	}
+
+	l, err := ListenPortRange("tcp", "127.0.0.1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.Close()
+}
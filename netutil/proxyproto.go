@@ -0,0 +1,244 @@
+package netutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidProxyHeader is returned when reading from a connection whose
+// PROXY protocol header is malformed.
+var ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+// DefaultProxyHeaderTimeout is the default time allowed for a PROXY protocol
+// header to arrive.
+const DefaultProxyHeaderTimeout = 10 * time.Second
+
+var (
+	proxyV1Prefix = []byte("PROXY ")
+	proxyV2Sig    = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// maxProxyV1Len is the maximum length of a v1 header, including the CRLF.
+const maxProxyV1Len = 107
+
+// ProxyProtocolListener wraps a net.Listener, parsing HAProxy PROXY protocol
+// (version 1 or 2) headers sent by load balancers so that the original client
+// and destination addresses are returned by RemoteAddr and LocalAddr of
+// accepted connections.
+//
+// Headers are only honoured on connections from Allowed networks, so clients
+// cannot spoof their address by sending a header themselves; if Allowed is
+// empty, headers are honoured from no source. A connection from an allowed
+// network which doesn't begin with a header is passed through unchanged.
+//
+// The header is read lazily, on the first call to Read, RemoteAddr or
+// LocalAddr, so a slow client doesn't hold up Accept. Reading the header sets
+// a read deadline of HeaderTimeout (DefaultProxyHeaderTimeout if zero), or
+// the read deadline set on the connection if that is sooner, which is
+// restored once the header has been read.
+type ProxyProtocolListener struct {
+	net.Listener
+	Allowed       []*net.IPNet
+	HeaderTimeout time.Duration
+}
+
+func (l *ProxyProtocolListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.allowed(c.RemoteAddr()) {
+		return c, nil
+	}
+	timeout := l.HeaderTimeout
+	if timeout == 0 {
+		timeout = DefaultProxyHeaderTimeout
+	}
+	return &proxyConn{Conn: c, r: bufio.NewReader(c), timeout: timeout}, nil
+}
+
+func (l *ProxyProtocolListener) allowed(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range l.Allowed {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	// mu protects deadline, the read deadline set by the user of the
+	// connection.
+	mu       sync.Mutex
+	deadline time.Time
+
+	once   sync.Once
+	err    error
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *proxyConn) readHeader() {
+	c.mu.Lock()
+	d := time.Now().Add(c.timeout)
+	if !c.deadline.IsZero() && c.deadline.Before(d) {
+		d = c.deadline
+	}
+	c.Conn.SetReadDeadline(d)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.Conn.SetReadDeadline(c.deadline)
+		c.mu.Unlock()
+	}()
+
+	switch {
+	case c.hasPrefix(proxyV1Prefix):
+		c.remote, c.local, c.err = readProxyV1(c.r)
+	case c.hasPrefix(proxyV2Sig):
+		c.remote, c.local, c.err = readProxyV2(c.r)
+	}
+	if c.err != nil && c.err != ErrInvalidProxyHeader {
+		c.err = ErrInvalidProxyHeader
+	}
+}
+
+// hasPrefix reports whether the buffered stream begins with prefix. Only as
+// many bytes as are needed to rule the prefix out are waited for, so clients
+// which send a short message and wait for a reply aren't stalled.
+func (c *proxyConn) hasPrefix(prefix []byte) bool {
+	for n := 1; n <= len(prefix); n++ {
+		b, err := c.r.Peek(n)
+		if err != nil || b[n-1] != prefix[n-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func readProxyV1(r *bufio.Reader) (src, dst net.Addr, err error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil || len(line) > maxProxyV1Len || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrInvalidProxyHeader
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, ErrInvalidProxyHeader
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	if srcIP == nil || dstIP == nil || (srcIP.To4() != nil) != (fields[1] == "TCP4") {
+		return nil, nil, ErrInvalidProxyHeader
+	}
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if err1 != nil || err2 != nil {
+		return nil, nil, ErrInvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+func readProxyV2(r *bufio.Reader) (src, dst net.Addr, err error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, nil, err
+	}
+	verCmd, fam := hdr[12], hdr[13]
+	if verCmd>>4 != 2 {
+		return nil, nil, ErrInvalidProxyHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch verCmd & 0xf {
+	case 0:
+		// LOCAL: the connection was made by the proxy itself, e.g. for
+		// health checks, so the real addresses apply.
+		return nil, nil, nil
+	case 1:
+		// PROXY
+	default:
+		return nil, nil, ErrInvalidProxyHeader
+	}
+
+	var ipLen int
+	switch fam >> 4 {
+	case 1:
+		ipLen = net.IPv4len
+	case 2:
+		ipLen = net.IPv6len
+	default:
+		// AF_UNSPEC or AF_UNIX: no usable addresses.
+		return nil, nil, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, nil, ErrInvalidProxyHeader
+	}
+	srcIP := net.IP(payload[:ipLen])
+	dstIP := net.IP(payload[ipLen : 2*ipLen])
+	srcPort := int(binary.BigEndian.Uint16(payload[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(payload[2*ipLen+2:]))
+	// Any TLVs following the addresses are ignored.
+	if fam&0xf == 2 {
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}, nil
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}, nil
+}
@@ -0,0 +1,179 @@
+package netutil
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func proxyV2Header(cmd, fam byte, addrs []byte) []byte {
+	h := append([]byte{}, proxyV2Sig...)
+	h = append(h, 0x20|cmd, fam, byte(len(addrs)>>8), byte(len(addrs)))
+	return append(h, addrs...)
+}
+
+var _, loopback, _ = net.ParseCIDR("127.0.0.0/8")
+
+func TestProxyProtocolListener(t *testing.T) {
+	v4 := []byte{
+		192, 0, 2, 1, // source
+		198, 51, 100, 1, // destination
+		0x04, 0xd2, // 1234
+		0x00, 0x50, // 80
+		0x01, 0x00, 0x01, 0x00, // trailing TLV, ignored
+	}
+	v6 := make([]byte, 36)
+	copy(v6, net.ParseIP("2001:db8::1"))
+	copy(v6[16:], net.ParseIP("2001:db8::2"))
+	v6[33], v6[35] = 1, 2
+
+	tests := []struct {
+		header  string
+		allowed string
+		remote  string
+		local   string
+		isErr   bool
+	}{
+		// v1
+		{
+			header: "PROXY TCP4 192.0.2.1 198.51.100.1 1234 80\r\n",
+			remote: "192.0.2.1:1234",
+			local:  "198.51.100.1:80",
+		},
+		{
+			header: "PROXY TCP6 2001:db8::1 2001:db8::2 1 2\r\n",
+			remote: "[2001:db8::1]:1",
+			local:  "[2001:db8::2]:2",
+		},
+		{
+			header: "PROXY UNKNOWN\r\n",
+		},
+		{header: "PROXY TCP4 192.0.2.1 198.51.100.1 1234\r\n", isErr: true},
+		{header: "PROXY TCP4 2001:db8::1 198.51.100.1 1234 80\r\n", isErr: true},
+		{header: "PROXY TCP4 192.0.2.1 198.51.100.1 1234 80\n", isErr: true},
+		// v2
+		{
+			header: string(proxyV2Header(1, 0x11, v4)),
+			remote: "192.0.2.1:1234",
+			local:  "198.51.100.1:80",
+		},
+		{
+			header: string(proxyV2Header(1, 0x21, v6)),
+			remote: "[2001:db8::1]:1",
+			local:  "[2001:db8::2]:2",
+		},
+		{
+			header: string(proxyV2Header(0, 0x00, nil)),
+		},
+		{header: string(proxyV2Header(1, 0x11, v4[:8])), isErr: true},
+		// Not from a load balancer: the header is passed through as data.
+		{
+			header:  "PROXY TCP4 192.0.2.1 198.51.100.1 1234 80\r\n",
+			allowed: "10.0.0.0/8",
+		},
+		// Without allowed networks headers are never honoured.
+		{
+			header:  "PROXY TCP4 192.0.2.1 198.51.100.1 1234 80\r\n",
+			allowed: "none",
+		},
+		// No header.
+		{},
+	}
+
+	for i, tt := range tests {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pl := &ProxyProtocolListener{Listener: ln, HeaderTimeout: time.Second}
+		switch tt.allowed {
+		case "":
+			pl.Allowed = []*net.IPNet{loopback}
+		case "none":
+		default:
+			_, n, _ := net.ParseCIDR(tt.allowed)
+			pl.Allowed = []*net.IPNet{n}
+		}
+
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Write([]byte(tt.header + "hello"))
+		c.Close()
+
+		s, err := pl.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		remote, local := tt.remote, tt.local
+		if remote == "" {
+			remote, local = c.LocalAddr().String(), c.RemoteAddr().String()
+		}
+		if got := s.RemoteAddr().String(); got != remote {
+			t.Errorf("case %d: remote address == %s, want %s", i, got, remote)
+		}
+		if got := s.LocalAddr().String(); got != local && !tt.isErr {
+			t.Errorf("case %d: local address == %s, want %s", i, got, local)
+		}
+
+		data, err := io.ReadAll(s)
+		switch {
+		case tt.isErr:
+			if err != ErrInvalidProxyHeader {
+				t.Errorf("case %d: want ErrInvalidProxyHeader, got %v", i, err)
+			}
+		case err != nil:
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		case tt.allowed != "":
+			if string(data) != tt.header+"hello" {
+				t.Errorf("case %d: read %q", i, data)
+			}
+		case string(data) != "hello":
+			t.Errorf("case %d: read %q, want %q", i, data, "hello")
+		}
+		s.Close()
+		ln.Close()
+	}
+}
+
+func TestProxyProtocolListenerDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	pl := &ProxyProtocolListener{Listener: ln, Allowed: []*net.IPNet{loopback}}
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 1234 80\r\n"))
+
+	s, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// The deadline set before the header is read still applies after it.
+	s.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	errc := make(chan error, 1)
+	go func() {
+		_, err := s.Read(make([]byte, 1))
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Errorf("want timeout, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read deadline cleared by reading the header")
+	}
+	if got := s.RemoteAddr().String(); got != "192.0.2.1:1234" {
+		t.Errorf("remote address == %s", got)
+	}
+}
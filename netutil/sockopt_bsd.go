@@ -0,0 +1,18 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package netutil
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func setReusePort(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+}
+
+func setUserTimeout(fd uintptr, d time.Duration) error {
+	return nil
+}
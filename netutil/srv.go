@@ -0,0 +1,154 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoSRVTargets is returned when a service has no usable SRV targets,
+// including when it is explicitly marked unavailable with a target of ".".
+var ErrNoSRVTargets = errors.New("no SRV targets found")
+
+// DefaultSRVCacheTTL is how long SRVResolver caches lookups by default.
+const DefaultSRVCacheTTL = 30 * time.Second
+
+// SRVResolver discovers service endpoints from DNS SRV records.
+//
+// Records are cached for TTL (DefaultSRVCacheTTL if zero). The resolver in
+// the standard library does not expose the TTLs of the records themselves,
+// so a fixed TTL is used for every lookup. Failed lookups are not cached;
+// if a cached result has expired and a fresh lookup fails, the stale result
+// is returned rather than the error.
+type SRVResolver struct {
+	Resolver *net.Resolver
+	TTL      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]srvCacheEntry
+
+	// now and lookup are overridden in tests.
+	now    func() time.Time
+	lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+type srvCacheEntry struct {
+	records []*net.SRV
+	expires time.Time
+}
+
+// Endpoints looks up the SRV records for _service._proto.name and returns the
+// targets as "host:port" strings, ordered as described in RFC 2782: by
+// ascending priority and, within a priority, randomly in proportion to
+// weight. The order is recomputed on every call, so load is spread even when
+// the records come from the cache.
+func (r *SRVResolver) Endpoints(ctx context.Context, service, proto, name string) ([]string, error) {
+	records, err := r.records(ctx, service, proto, name)
+	if err != nil {
+		return nil, err
+	}
+	ordered := orderSRV(records, rand.Intn)
+	endpoints := make([]string, 0, len(ordered))
+	for _, srv := range ordered {
+		host := strings.TrimSuffix(srv.Target, ".")
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+	return endpoints, nil
+}
+
+func (r *SRVResolver) records(ctx context.Context, service, proto, name string) ([]*net.SRV, error) {
+	key := service + "/" + proto + "/" + name
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+
+	r.mu.Lock()
+	entry, cached := r.cache[key]
+	r.mu.Unlock()
+	if cached && now().Before(entry.expires) {
+		return entry.records, nil
+	}
+
+	lookup := r.lookup
+	if lookup == nil {
+		resolver := r.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		lookup = resolver.LookupSRV
+	}
+	_, addrs, err := lookup(ctx, service, proto, name)
+	if err == nil {
+		// A single target of "." means the service is not available.
+		if len(addrs) == 0 || (len(addrs) == 1 && addrs[0].Target == ".") {
+			err = ErrNoSRVTargets
+		}
+	}
+	if err != nil {
+		if cached {
+			return entry.records, nil
+		}
+		return nil, err
+	}
+
+	ttl := r.TTL
+	if ttl == 0 {
+		ttl = DefaultSRVCacheTTL
+	}
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]srvCacheEntry)
+	}
+	r.cache[key] = srvCacheEntry{records: addrs, expires: now().Add(ttl)}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+// orderSRV returns records ordered by priority, with records of equal
+// priority in a weighted random order. intn is rand.Intn or a replacement
+// for tests.
+func orderSRV(records []*net.SRV, intn func(int) int) []*net.SRV {
+	sorted := make([]*net.SRV, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].Priority == sorted[i].Priority {
+			j++
+		}
+		shuffleByWeight(sorted[i:j], intn)
+		i = j
+	}
+	return sorted
+}
+
+// shuffleByWeight orders records by repeatedly picking one at random with
+// probability proportional to its weight. Records of zero weight have a
+// small chance of being picked before others, as RFC 2782 requires.
+func shuffleByWeight(records []*net.SRV, intn func(int) int) {
+	total := 0
+	for _, srv := range records {
+		total += int(srv.Weight) + 1
+	}
+	for i := range records {
+		n := intn(total)
+		for j := i; j < len(records); j++ {
+			n -= int(records[j].Weight) + 1
+			if n < 0 {
+				records[i], records[j] = records[j], records[i]
+				break
+			}
+		}
+		total -= int(records[i].Weight) + 1
+	}
+}
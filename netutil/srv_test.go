@@ -0,0 +1,99 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrderSRV(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "c.", Priority: 20, Weight: 0},
+		{Target: "a.", Priority: 10, Weight: 1},
+		{Target: "b.", Priority: 10, Weight: 100},
+	}
+	targets := func(rs []*net.SRV) []string {
+		var ts []string
+		for _, r := range rs {
+			ts = append(ts, r.Target)
+		}
+		return ts
+	}
+
+	// Always picking the first candidate keeps the order of equal
+	// priorities; picking the last possible value favours the heavier one.
+	first := func(int) int { return 0 }
+	if got, want := targets(orderSRV(records, first)), []string{"a.", "b.", "c."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	last := func(n int) int { return n - 1 }
+	if got, want := targets(orderSRV(records, last)), []string{"b.", "a.", "c."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	// The input is not modified.
+	if records[0].Target != "c." {
+		t.Errorf("input records were reordered")
+	}
+}
+
+func TestSRVResolver(t *testing.T) {
+	now := time.Unix(1000, 0)
+	lookups := 0
+	var lookupErr error
+	r := &SRVResolver{
+		TTL: time.Minute,
+		now: func() time.Time { return now },
+		lookup: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			lookups++
+			if service != "etcd-server" || proto != "tcp" || name != "example.com" {
+				t.Errorf("unexpected lookup of %s/%s/%s", service, proto, name)
+			}
+			if lookupErr != nil {
+				return "", nil, lookupErr
+			}
+			return "", []*net.SRV{{Target: "infra0.example.com.", Port: 2380}}, nil
+		},
+	}
+	want := []string{"infra0.example.com:2380"}
+
+	for i, tt := range []struct {
+		advance time.Duration
+		err     error
+		lookups int
+	}{
+		{0, nil, 1},
+		// Cached.
+		{30 * time.Second, nil, 1},
+		// Expired.
+		{time.Minute, nil, 2},
+		// Expired, and the lookup fails: the stale result is used.
+		{time.Minute, errors.New("timeout"), 3},
+	} {
+		now = now.Add(tt.advance)
+		lookupErr = tt.err
+		got, err := r.Endpoints(context.Background(), "etcd-server", "tcp", "example.com")
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("case %d: want: %v, got: %v", i, want, got)
+		}
+		if lookups != tt.lookups {
+			t.Errorf("case %d: %d lookups, want %d", i, lookups, tt.lookups)
+		}
+	}
+}
+
+func TestSRVResolverUnavailable(t *testing.T) {
+	r := &SRVResolver{
+		lookup: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			return "", []*net.SRV{{Target: "."}}, nil
+		},
+	}
+	if _, err := r.Endpoints(context.Background(), "x", "tcp", "example.com"); err != ErrNoSRVTargets {
+		t.Errorf("want ErrNoSRVTargets, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+//go:build !windows
+// +build !windows
+
+package netutil
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd.
+const listenFdsStart = 3
+
+var systemdListeners struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners map[string][]net.Listener
+	err       error
+}
+
+// ListenersFromSystemd returns the listening sockets passed to this process
+// by systemd socket activation, or by PassListeners, keyed by the names given
+// in LISTEN_FDNAMES (sockets without an explicit FileDescriptorName are named
+// after their socket unit). It returns an empty map if no sockets were
+// passed.
+//
+// Sockets are handed out only once: listeners returned here, or previously
+// claimed by ListenSystemd, are not returned again. The LISTEN_* environment
+// variables are unset so they are not inherited by child processes.
+func ListenersFromSystemd() (map[string][]net.Listener, error) {
+	loadSystemdListeners()
+	systemdListeners.mu.Lock()
+	defer systemdListeners.mu.Unlock()
+	if systemdListeners.err != nil {
+		return nil, systemdListeners.err
+	}
+	ls := systemdListeners.listeners
+	systemdListeners.listeners = make(map[string][]net.Listener)
+	return ls, nil
+}
+
+// ListenSystemd returns the first unclaimed socket passed by systemd with the
+// given name. If the process was not socket activated, or no such socket was
+// passed, it falls back to net.Listen(network, address), so the same code
+// serves both activated and manually started daemons.
+func ListenSystemd(name, network, address string) (net.Listener, error) {
+	loadSystemdListeners()
+	systemdListeners.mu.Lock()
+	if err := systemdListeners.err; err != nil {
+		systemdListeners.mu.Unlock()
+		return nil, err
+	}
+	if ls := systemdListeners.listeners[name]; len(ls) > 0 {
+		systemdListeners.listeners[name] = ls[1:]
+		systemdListeners.mu.Unlock()
+		return ls[0], nil
+	}
+	systemdListeners.mu.Unlock()
+	return net.Listen(network, address)
+}
+
+func loadSystemdListeners() {
+	systemdListeners.once.Do(func() {
+		systemdListeners.listeners, systemdListeners.err = listenersFromEnv(listenFdsStart, os.Getenv)
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+}
+
+func listenersFromEnv(start int, getenv func(string) string) (map[string][]net.Listener, error) {
+	listeners := make(map[string][]net.Listener)
+	// LISTEN_PID is absent when the sockets were handed over by a
+	// predecessor process with PassListeners.
+	if v := getenv("LISTEN_PID"); v != "" {
+		pid, err := strconv.Atoi(v)
+		if err != nil || pid != os.Getpid() {
+			return listeners, nil
+		}
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return listeners, nil
+	}
+	var names []string
+	if v := getenv("LISTEN_FDNAMES"); v != "" {
+		names = strings.Split(v, ":")
+	}
+
+	for i := 0; i < n; i++ {
+		fd := start + i
+		syscall.CloseOnExec(fd)
+		name := "unknown"
+		if i < len(names) {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		// FileListener dups the descriptor, so the original is closed
+		// whether or not it succeeded.
+		f.Close()
+		if err != nil {
+			// Not a listening socket, e.g. a datagram socket or FIFO.
+			continue
+		}
+		listeners[name] = append(listeners[name], l)
+	}
+	return listeners, nil
+}
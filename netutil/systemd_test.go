@@ -0,0 +1,158 @@
+//go:build !windows
+// +build !windows
+
+package netutil
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestListenersFromEnv(t *testing.T) {
+	// Pass two consecutive descriptors: a TCP listener and a UDP socket,
+	// which is not a listener and should be skipped.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lf, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+	u, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	uf, err := u.(*net.UDPConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uf.Close()
+
+	// listenersFromEnv takes ownership of the descriptors, so hand it
+	// duplicates.
+	start, err := syscall.Dup(int(lf.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := syscall.Dup(int(uf.Fd()))
+	if err != nil {
+		syscall.Close(start)
+		t.Fatal(err)
+	}
+	if next != start+1 {
+		syscall.Close(start)
+		syscall.Close(next)
+		t.Skip("could not allocate consecutive file descriptors")
+	}
+
+	env := map[string]string{
+		"LISTEN_PID":     strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS":     "2",
+		"LISTEN_FDNAMES": "http:dns",
+	}
+	ls, err := listenersFromEnv(start, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ls["http"]) != 1 || len(ls["dns"]) != 0 {
+		t.Fatalf("unexpected listeners: %v", ls)
+	}
+	defer ls["http"][0].Close()
+	if got, want := ls["http"][0].Addr().String(), l.Addr().String(); got != want {
+		t.Errorf("listener address == %v, want %v", got, want)
+	}
+}
+
+func TestListenersFromEnvNotActivated(t *testing.T) {
+	for i, env := range []map[string]string{
+		{},
+		{"LISTEN_PID": "1", "LISTEN_FDS": "1"},
+		{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "0"},
+	} {
+		ls, err := listenersFromEnv(listenFdsStart, func(k string) string { return env[k] })
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if len(ls) != 0 {
+			t.Errorf("case %d: expected no listeners, got %v", i, ls)
+		}
+	}
+}
+
+func TestListenSystemdFallback(t *testing.T) {
+	l, err := ListenSystemd("http", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.Close()
+}
+
+// TestHelperPassedListener is run as a child process by TestPassListeners.
+func TestHelperPassedListener(t *testing.T) {
+	if os.Getenv("NETUTIL_HELPER_PROCESS") != "1" {
+		t.Skip("only run as a helper process")
+	}
+	l, err := ListenSystemd("http", "tcp", "127.0.0.1:0")
+	if err != nil {
+		os.Exit(2)
+	}
+	c, err := l.Accept()
+	if err != nil {
+		os.Exit(3)
+	}
+	c.Write([]byte("ok"))
+	c.Close()
+	os.Exit(0)
+}
+
+func TestPassListeners(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewDrainListener(ln)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperPassedListener$")
+	cmd.Env = append(os.Environ(), "NETUTIL_HELPER_PROCESS=1", "LISTEN_PID=1")
+	closeFiles, err := PassListeners(cmd, map[string]net.Listener{"http": l})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = cmd.Start()
+	closeFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmd.ExtraFiles[0].Stat(); err == nil {
+		t.Errorf("passed file left open")
+	}
+	// Stop accepting here; the successor takes over the socket.
+	if err := l.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial after handoff failed: %v", err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	data, err := io.ReadAll(c)
+	if err != nil || string(data) != "ok" {
+		t.Errorf("read %q, %v from successor", data, err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("successor failed: %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package netutil
+
+import (
+	"net"
+)
+
+// ListenersFromSystemd always returns an empty map, since systemd is not
+// available on Windows.
+func ListenersFromSystemd() (map[string][]net.Listener, error) {
+	return make(map[string][]net.Listener), nil
+}
+
+// ListenSystemd is equivalent to net.Listen(network, address) on Windows.
+func ListenSystemd(name, network, address string) (net.Listener, error) {
+	return net.Listen(network, address)
+}
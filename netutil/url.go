@@ -2,6 +2,7 @@ package netutil
 
 import (
 	"net/url"
+	"strings"
 )
 
 // MergeQuery appends additional query values to an existing URL.
@@ -15,3 +16,49 @@ func MergeQuery(u url.URL, q url.Values) url.URL {
 	u.RawQuery = uv.Encode()
 	return u
 }
+
+// JoinPath appends path segments to the path of an existing URL. Each
+// element is a single segment and is escaped as such, so any "/" within an
+// element is escaped rather than treated as a separator. Empty elements are
+// skipped, and exactly one "/" separates each segment from the next. The
+// existing path, query and fragment of u are otherwise left alone.
+func JoinPath(u url.URL, elem ...string) url.URL {
+	p := strings.TrimRight(u.EscapedPath(), "/")
+	for _, e := range elem {
+		if e == "" {
+			continue
+		}
+		p += "/" + url.PathEscape(e)
+	}
+	if p == "" && len(elem) == 0 {
+		return u
+	}
+	if p == "" {
+		p = "/"
+	}
+	// p was built from escaped segments, so unescaping cannot fail.
+	u.Path, _ = url.PathUnescape(p)
+	u.RawPath = p
+	return u
+}
+
+// BuildURL returns base with the given path segments appended and query
+// values merged, as by JoinPath and MergeQuery.
+func BuildURL(base url.URL, q url.Values, elem ...string) url.URL {
+	u := JoinPath(base, elem...)
+	if len(q) > 0 {
+		u = MergeQuery(u, q)
+	}
+	return u
+}
+
+// ResolveReference resolves ref, which may be relative, against base as
+// described in RFC 3986. Note that as in a browser the last segment of the
+// base path is replaced unless the base path ends in "/".
+func ResolveReference(base url.URL, ref string) (url.URL, error) {
+	r, err := url.Parse(ref)
+	if err != nil {
+		return url.URL{}, err
+	}
+	return *base.ResolveReference(r), nil
+}
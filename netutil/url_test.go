@@ -84,3 +84,111 @@ func TestMergeQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		u    string
+		elem []string
+		w    string
+	}{
+		// No segments
+		{
+			u: "http://example.com/foo",
+			w: "http://example.com/foo",
+		},
+		// Empty base path
+		{
+			u:    "http://example.com",
+			elem: []string{"v1", "users"},
+			w:    "http://example.com/v1/users",
+		},
+		// Trailing slash on the base path is not duplicated
+		{
+			u:    "http://example.com/api/",
+			elem: []string{"v1"},
+			w:    "http://example.com/api/v1",
+		},
+		// Empty segments are skipped
+		{
+			u:    "http://example.com/api",
+			elem: []string{"", "v1", ""},
+			w:    "http://example.com/api/v1",
+		},
+		// Segments are escaped
+		{
+			u:    "http://example.com",
+			elem: []string{"a b", "c/d", "e?f"},
+			w:    "http://example.com/a%20b/c%2Fd/e%3Ff",
+		},
+		// Escaping in the base path is preserved
+		{
+			u:    "http://example.com/x%2Fy",
+			elem: []string{"z"},
+			w:    "http://example.com/x%2Fy/z",
+		},
+		// Query and fragment are kept
+		{
+			u:    "http://example.com/foo?a=b#frag",
+			elem: []string{"bar"},
+			w:    "http://example.com/foo/bar?a=b#frag",
+		},
+	}
+
+	for i, tt := range tests {
+		ur, err := url.Parse(tt.u)
+		if err != nil {
+			t.Fatalf("case %d: failed parsing test url: %v, error: %v", i, tt.u, err)
+		}
+
+		got := JoinPath(*ur, tt.elem...)
+		if got.String() != tt.w {
+			t.Errorf("case %d: want: %v, got: %v", i, tt.w, got.String())
+		}
+	}
+}
+
+func TestBuildURL(t *testing.T) {
+	ur, err := url.Parse("https://example.com/api/?dog=boo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := BuildURL(*ur, url.Values{"foo": []string{"a&b"}}, "v1", "my thing")
+	want := "https://example.com/api/v1/my%20thing?dog=boo&foo=a%26b"
+	if got.String() != want {
+		t.Errorf("want: %v, got: %v", want, got.String())
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	tests := []struct {
+		base string
+		ref  string
+		w    string
+	}{
+		{"http://example.com/a/b", "c", "http://example.com/a/c"},
+		{"http://example.com/a/b/", "c", "http://example.com/a/b/c"},
+		{"http://example.com/a/b/", "../c?x=y", "http://example.com/a/c?x=y"},
+		{"http://example.com/a/b", "/c", "http://example.com/c"},
+		{"http://example.com/a/b", "//other.com/c", "http://other.com/c"},
+		{"http://example.com/a/b", "https://other.com/", "https://other.com/"},
+	}
+
+	for i, tt := range tests {
+		base, err := url.Parse(tt.base)
+		if err != nil {
+			t.Fatalf("case %d: failed parsing base url: %v, error: %v", i, tt.base, err)
+		}
+		got, err := ResolveReference(*base, tt.ref)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got.String() != tt.w {
+			t.Errorf("case %d: want: %v, got: %v", i, tt.w, got.String())
+		}
+	}
+
+	if _, err := ResolveReference(url.URL{}, "%zz"); err == nil {
+		t.Errorf("expected error resolving invalid reference")
+	}
+}
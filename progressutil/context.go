@@ -0,0 +1,70 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"context"
+	"io"
+)
+
+// CopyContext is like io.Copy, but returns ctx.Err() as soon as ctx is done,
+// even if a Read from src is blocked. A Read in progress when ctx is done is
+// left to finish in the background and its data is discarded, so src must
+// not be used again after CopyContext returns ctx.Err().
+func CopyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	if ctx.Done() == nil {
+		return io.Copy(dst, src)
+	}
+	return io.Copy(dst, &contextReader{ctx: ctx, r: src})
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+// contextReader reads from r in a separate goroutine, so that Read can
+// return as soon as ctx is done. Reads are made into buf, which is only
+// reused once the previous Read has completed.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+	buf []byte
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(cr.buf) < len(p) {
+		cr.buf = make([]byte, len(p))
+	}
+	buf := cr.buf[:len(p)]
+
+	res := make(chan readResult, 1)
+	go func() {
+		n, err := cr.r.Read(buf)
+		res <- readResult{n, err}
+	}()
+
+	select {
+	case r := <-res:
+		return copy(p, buf[:r.n]), r.err
+	case <-cr.ctx.Done():
+		// The goroutine still owns buf.
+		cr.buf = nil
+		return 0, cr.ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingReader returns data, then blocks until unblock is closed.
+type blockingReader struct {
+	data    []byte
+	unblock chan struct{}
+}
+
+func (br *blockingReader) Read(p []byte) (int, error) {
+	if len(br.data) > 0 {
+		n := copy(p, br.data)
+		br.data = br.data[n:]
+		return n, nil
+	}
+	<-br.unblock
+	return 0, io.EOF
+}
+
+func TestCopyContext(t *testing.T) {
+	n, err := CopyContext(context.Background(), ioutil.Discard, strings.NewReader("hello"))
+	if n != 5 || err != nil {
+		t.Errorf("background context: want 5 bytes and no error, got %d and %v", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dst := &bytes.Buffer{}
+	n, err = CopyContext(ctx, dst, strings.NewReader("hello"))
+	if n != 5 || err != nil || dst.String() != "hello" {
+		t.Errorf("want %q and no error, got %q and %v", "hello", dst.String(), err)
+	}
+
+	br := &blockingReader{data: []byte("partial"), unblock: make(chan struct{})}
+	defer close(br.unblock)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	n, err = CopyContext(ctx, ioutil.Discard, br)
+	if err != context.Canceled {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+	if n != int64(len("partial")) {
+		t.Errorf("want %d bytes copied before cancellation, got %d", len("partial"), n)
+	}
+
+	n, err = CopyContext(ctx, ioutil.Discard, strings.NewReader("hello"))
+	if n != 0 || err != context.Canceled {
+		t.Errorf("cancelled context: want 0 bytes and %v, got %d and %v", context.Canceled, n, err)
+	}
+}
+
+func TestAddCopyContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	br := &blockingReader{unblock: make(chan struct{})}
+	defer close(br.unblock)
+
+	cpp := NewCopyProgressPrinter()
+	if err := cpp.AddCopyContext(ctx, br, "download", 100, ioutil.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cpp.PrintAndWait(ioutil.Discard, time.Hour, nil); err != context.DeadlineExceeded {
+		t.Fatalf("want %v, got %v", context.DeadlineExceeded, err)
+	}
+}
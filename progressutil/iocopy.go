@@ -15,28 +15,82 @@
 package progressutil
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
 	"time"
+
+	"github.com/coreos/pkg/timeutil"
 )
 
 var (
 	ErrAlreadyStarted = errors.New("cannot add copies after PrintAndWait has been called")
 )
 
+const (
+	// rateSampleInterval is the minimum time between samples of the
+	// transfer rate of a copy.
+	rateSampleInterval = 500 * time.Millisecond
+
+	// rateSmoothing is the weight given to each new sample in the
+	// exponential moving average of the transfer rate.
+	rateSmoothing = 0.3
+)
+
+// rateEstimator keeps a smoothed estimate of a transfer rate.
+type rateEstimator struct {
+	lastTime  time.Time
+	lastBytes int64
+	sampled   bool
+	rate      float64 // bytes per second
+}
+
+// update records that n bytes in total had been transferred at now.
+func (r *rateEstimator) update(now time.Time, n int64) {
+	dt := now.Sub(r.lastTime)
+	if dt < rateSampleInterval {
+		return
+	}
+	sample := float64(n-r.lastBytes) / dt.Seconds()
+	if r.sampled {
+		r.rate = rateSmoothing*sample + (1-rateSmoothing)*r.rate
+	} else {
+		r.rate = sample
+		r.sampled = true
+	}
+	r.lastTime = now
+	r.lastBytes = n
+}
+
 type copyReader struct {
-	reader  io.Reader
+	reader      io.Reader
+	name        string
+	total       int64
+	pb          *ProgressBar
+	clock       timeutil.Clock
+	formatBytes func(int64) string
+
+	// mu protects current, rate and done, which are read by the printer
+	// while the copy is running.
+	mu      sync.Mutex
 	current int64
-	total   int64
-	pb      *ProgressBar
+	rate    rateEstimator
+	done    bool
+
+	// reported is set by the printer once it has reported the copy as
+	// done.
+	reported bool
 }
 
 func (cr *copyReader) Read(p []byte) (int, error) {
 	n, err := cr.reader.Read(p)
+	cr.mu.Lock()
 	cr.current += int64(n)
+	cr.rate.update(cr.clock.Now(), cr.current)
 	err1 := cr.updateProgressBar()
+	cr.mu.Unlock()
 	if err == nil {
 		err = err1
 	}
@@ -45,6 +99,11 @@ func (cr *copyReader) Read(p []byte) (int, error) {
 
 func (cr *copyReader) updateProgressBar() error {
 	cr.pb.SetPrintAfter(cr.formattedProgress())
+	if cr.total == 0 {
+		// The size is unknown; the bar is indeterminate until the copy
+		// finishes.
+		return nil
+	}
 
 	progress := float64(cr.current) / float64(cr.total)
 	if progress > 1 {
@@ -63,8 +122,34 @@ func NewCopyProgressPrinter() *CopyProgressPrinter {
 }
 
 // CopyProgressPrinter will perform an arbitrary number of io.Copy calls, while
-// continually printing the progress of each copy.
+// continually printing the progress of each copy. Once the rate of a copy
+// is known, its progress includes the smoothed transfer rate and, if its
+// size is known, the estimated time remaining.
 type CopyProgressPrinter struct {
+	// Clock defaults to timeutil.RealClock. It must not be changed after
+	// the first call to AddCopy.
+	Clock timeutil.Clock
+
+	// JSON, if set, makes PrintAndWait write the progress of each copy
+	// as a line of JSON, as described by CopyProgress, instead of drawing
+	// progress bars, and omit the final summary. Every interval, a line is
+	// written for each copy which has not finished, and once more when it
+	// finishes.
+	JSON bool
+
+	// Renderer draws the progress bars, as described by
+	// ProgressBarPrinter.Renderer.
+	Renderer Renderer
+
+	// FormatBytes formats sizes and rates, and defaults to ByteUnitStr.
+	// It must not be changed after the first call to AddCopy.
+	FormatBytes func(int64) string
+
+	// LogInterval is the minimum time between progress lines when
+	// PrintAndWait is not printing to a terminal, as described by
+	// ProgressBarPrinter.LogInterval.
+	LogInterval time.Duration
+
 	results chan error
 	cancel  chan struct{}
 
@@ -72,9 +157,17 @@ type CopyProgressPrinter struct {
 	lock    sync.Mutex
 	readers []*copyReader
 	started bool
+	start   time.Time
 	pbp     *ProgressBarPrinter
 }
 
+func (cpp *CopyProgressPrinter) clock() timeutil.Clock {
+	if cpp.Clock == nil {
+		return timeutil.RealClock
+	}
+	return cpp.Clock
+}
+
 // AddCopy adds a copy for this CopyProgressPrinter to perform. An io.Copy call
 // will be made to copy bytes from reader to dest, and name and size will be
 // used to label the progress bar and display how much progress has been made.
@@ -82,6 +175,13 @@ type CopyProgressPrinter struct {
 // AddCopy can only be called before PrintAndWait; otherwise, ErrAlreadyStarted
 // will be returned.
 func (cpp *CopyProgressPrinter) AddCopy(reader io.Reader, name string, size int64, dest io.Writer) error {
+	return cpp.AddCopyContext(context.Background(), reader, name, size, dest)
+}
+
+// AddCopyContext is like AddCopy, but the copy is made with CopyContext, so it
+// is aborted with ctx.Err() once ctx is done. PrintAndWait then returns that
+// error.
+func (cpp *CopyProgressPrinter) AddCopyContext(ctx context.Context, reader io.Reader, name string, size int64, dest io.Writer) error {
 	cpp.lock.Lock()
 	defer cpp.lock.Unlock()
 
@@ -89,19 +189,38 @@ func (cpp *CopyProgressPrinter) AddCopy(reader io.Reader, name string, size int6
 		return ErrAlreadyStarted
 	}
 
+	now := cpp.clock().Now()
+	if len(cpp.readers) == 0 {
+		cpp.start = now
+	}
 	cr := &copyReader{
 		reader:  reader,
+		name:    name,
 		current: 0,
 		total:   size,
 		pb:      cpp.pbp.AddProgressBar(),
+		clock:   cpp.clock(),
+		rate:    rateEstimator{lastTime: now},
+
+		formatBytes: cpp.FormatBytes,
+	}
+	if cr.formatBytes == nil {
+		cr.formatBytes = ByteUnitStr
 	}
 	cr.pb.SetPrintBefore(name)
+	cr.pb.SetIndeterminate(size == 0)
 	cr.pb.SetPrintAfter(cr.formattedProgress())
 
 	cpp.readers = append(cpp.readers, cr)
 
 	go func() {
-		_, err := io.Copy(dest, cr)
+		_, err := CopyContext(ctx, dest, cr)
+		if err == nil {
+			cr.mu.Lock()
+			cr.done = true
+			cr.mu.Unlock()
+			cr.pb.SetCurrentProgress(1)
+		}
 		select {
 		case <-cpp.cancel:
 			return
@@ -114,7 +233,9 @@ func (cpp *CopyProgressPrinter) AddCopy(reader io.Reader, name string, size int6
 
 // PrintAndWait will print the progress for each copy operation added with
 // AddCopy to printTo every printInterval. This will continue until every added
-// copy is finished, or until cancel is written to.
+// copy is finished, or until cancel is written to. Once every copy has
+// finished, a summary line with the total bytes copied and the elapsed time
+// is printed.
 // PrintAndWait may only be called once; any subsequent calls will immediately
 // return ErrAlreadyStarted.  After PrintAndWait has been called, no more
 // copies may be added to the CopyProgressPrinter.
@@ -133,6 +254,17 @@ func (cpp *CopyProgressPrinter) PrintAndWait(printTo io.Writer, printInterval ti
 		return nil
 	}
 
+	cpp.pbp.lock.Lock()
+	cpp.pbp.Clock = cpp.Clock
+	cpp.pbp.LogInterval = cpp.LogInterval
+	cpp.pbp.Renderer = cpp.Renderer
+	cpp.pbp.lock.Unlock()
+
+	draw := cpp.pbp.Print
+	if cpp.JSON {
+		draw = cpp.printJSON
+	}
+
 	defer close(cpp.cancel)
 	t := time.NewTicker(printInterval)
 	allDone := false
@@ -141,7 +273,7 @@ func (cpp *CopyProgressPrinter) PrintAndWait(printTo io.Writer, printInterval ti
 		case <-cancel:
 			return nil
 		case <-t.C:
-			_, err := cpp.pbp.Print(printTo)
+			_, err := draw(printTo)
 			if err != nil {
 				return err
 			}
@@ -150,14 +282,36 @@ func (cpp *CopyProgressPrinter) PrintAndWait(printTo io.Writer, printInterval ti
 			// Once completion is signaled, further on this just drains
 			// (unlikely) errors from the channel.
 			if err == nil && !allDone {
-				allDone, err = cpp.pbp.Print(printTo)
+				allDone, err = draw(printTo)
 			}
 			if err != nil {
 				return err
 			}
 		}
 	}
-	return nil
+	if cpp.JSON {
+		return nil
+	}
+	_, err := fmt.Fprintln(printTo, cpp.summary())
+	return err
+}
+
+// summary describes the completed copies.
+func (cpp *CopyProgressPrinter) summary() string {
+	var total int64
+	for _, cr := range cpp.readers {
+		total += cr.current
+	}
+	format := cpp.FormatBytes
+	if format == nil {
+		format = ByteUnitStr
+	}
+	elapsed := cpp.clock().Since(cpp.start)
+	s := fmt.Sprintf("Copied %s in %s", format(total), timeutil.HumanizeDuration(elapsed))
+	if elapsed > 0 {
+		s += fmt.Sprintf(" (%s/s)", format(int64(float64(total)/elapsed.Seconds())))
+	}
+	return s
 }
 
 func (cr *copyReader) formattedProgress() string {
@@ -165,9 +319,27 @@ func (cr *copyReader) formattedProgress() string {
 	if cr.total == 0 {
 		totalStr = "?"
 	} else {
-		totalStr = ByteUnitStr(cr.total)
+		totalStr = cr.formatBytes(cr.total)
+	}
+	s := fmt.Sprintf("%s / %s", cr.formatBytes(cr.current), totalStr)
+	if !cr.rate.sampled {
+		return s
+	}
+	s += fmt.Sprintf(" (%s/s", cr.formatBytes(int64(cr.rate.rate)))
+	if eta, ok := cr.eta(); ok {
+		s += ", ETA " + timeutil.HumanizeDuration(eta.Round(time.Second))
+	}
+	return s + ")"
+}
+
+// eta estimates the time remaining from the current rate. It reports false
+// if the size or rate needed for the estimate is unknown, or the copy has
+// already reached its size. cr.mu must be held.
+func (cr *copyReader) eta() (time.Duration, bool) {
+	if !cr.rate.sampled || cr.rate.rate <= 0 || cr.total <= cr.current {
+		return 0, false
 	}
-	return fmt.Sprintf("%s / %s", ByteUnitStr(cr.current), totalStr)
+	return time.Duration(float64(cr.total-cr.current) / cr.rate.rate * float64(time.Second)), true
 }
 
 var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB"}
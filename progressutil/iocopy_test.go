@@ -18,8 +18,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/coreos/pkg/timeutil"
 )
 
 type fakeReader struct {
@@ -38,6 +41,8 @@ func (fr *fakeReader) Read(p []byte) (int, error) {
 func TestCopyOne(t *testing.T) {
 	cpp := NewCopyProgressPrinter()
 	cpp.pbp.printToTTYAlways = true
+	// A stopped clock keeps the rate, and so the output, out of the test.
+	cpp.Clock = timeutil.NewFakeClock(time.Unix(0, 0))
 
 	sampleData := []byte("this is a test!")
 
@@ -65,9 +70,9 @@ func TestCopyOne(t *testing.T) {
 		bar := renderExpectedBar(80, "download", float64(i)/10, sizeString)
 		var expectedOutput string
 		if i == 0 {
-			expectedOutput = fmt.Sprintf("%s\n", bar)
+			expectedOutput = fmt.Sprintf("\033[2K%s\n", bar)
 		} else {
-			expectedOutput = fmt.Sprintf("\033[1A%s\n", bar)
+			expectedOutput = fmt.Sprintf("\033[1A\033[2K%s\n", bar)
 		}
 		if string(printedData) != expectedOutput {
 			t.Errorf("unexpected output:\nexpected:\n\n%sactual:\n\n%s", expectedOutput, string(printedData))
@@ -138,3 +143,74 @@ func TestErrAlreadyStarted(t *testing.T) {
 		t.Errorf("%v\n", err)
 	}
 }
+
+// chunkReader returns at most size bytes from each Read.
+type chunkReader struct {
+	r    io.Reader
+	size int
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	if len(p) > cr.size {
+		p = p[:cr.size]
+	}
+	return cr.r.Read(p)
+}
+
+func TestCopyRate(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := timeutil.NewFakeClock(start)
+	cr := &copyReader{
+		reader: &chunkReader{bytes.NewReader(make([]byte, 10000)), 1000},
+		total:  10000,
+		pb:     &ProgressBar{},
+		clock:  clock,
+		rate:   rateEstimator{lastTime: start},
+
+		formatBytes: ByteUnitStr,
+	}
+
+	buf := make([]byte, 1000)
+	for i, tt := range []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, "1 KB / 10 KB"},
+		{time.Second, "2 KB / 10 KB (2 KB/s, ETA 4s)"},
+		{time.Second, "3 KB / 10 KB (1.7 KB/s, ETA 4s)"},
+		// Too soon for another sample.
+		{100 * time.Millisecond, "4 KB / 10 KB (1.7 KB/s, ETA 4s)"},
+		{900 * time.Millisecond, "5 KB / 10 KB (1.79 KB/s, ETA 3s)"},
+	} {
+		clock.Advance(tt.advance)
+		if _, err := cr.Read(buf); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if got := cr.pb.GetPrintAfter(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestCopySummary(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	cpp := NewCopyProgressPrinter()
+	cpp.Clock = clock
+
+	for _, name := range []string{"one", "two"} {
+		err := cpp.AddCopy(bytes.NewReader(make([]byte, 1500)), name, 1500, &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	clock.Advance(2 * time.Second)
+
+	out := &bytes.Buffer{}
+	if err := cpp.PrintAndWait(out, time.Second, nil); err != nil {
+		t.Fatalf("error from PrintAndWait: %v", err)
+	}
+	want := "Copied 3 KB in 2s (1.5 KB/s)\n"
+	if got := out.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("output does not end with the summary %q:\n%s", want, got)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// CopyProgress is the progress of one copy, as written by a
+// CopyProgressPrinter in JSON mode.
+type CopyProgress struct {
+	// Name is the name given to AddCopy.
+	Name string `json:"name"`
+	// Bytes is the number of bytes copied so far.
+	Bytes int64 `json:"bytes"`
+	// Total is the size given to AddCopy, or zero if it is unknown.
+	Total int64 `json:"total,omitempty"`
+	// Rate is the smoothed transfer rate in bytes per second, or zero if
+	// it is not yet known.
+	Rate float64 `json:"rate,omitempty"`
+	// ETA is the estimated number of seconds remaining, or zero if it is
+	// unknown.
+	ETA float64 `json:"eta,omitempty"`
+	// Done is set once the copy has finished successfully.
+	Done bool `json:"done"`
+}
+
+func (cr *copyReader) progress() CopyProgress {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	p := CopyProgress{
+		Name:  cr.name,
+		Bytes: cr.current,
+		Total: cr.total,
+		Done:  cr.done,
+	}
+	if cr.rate.sampled {
+		p.Rate = cr.rate.rate
+	}
+	if eta, ok := cr.eta(); ok {
+		p.ETA = eta.Seconds()
+	}
+	return p
+}
+
+// printJSON writes a line of JSON for each copy not yet reported as done,
+// and reports whether every copy is done.
+func (cpp *CopyProgressPrinter) printJSON(printTo io.Writer) (bool, error) {
+	cpp.lock.Lock()
+	readers := cpp.readers
+	cpp.lock.Unlock()
+
+	allDone := true
+	enc := json.NewEncoder(printTo)
+	for _, cr := range readers {
+		if cr.reported {
+			continue
+		}
+		p := cr.progress()
+		if err := enc.Encode(p); err != nil {
+			return false, err
+		}
+		if p.Done {
+			cr.reported = true
+		} else {
+			allDone = false
+		}
+	}
+	return allDone, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+func TestCopyProgressJSON(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := timeutil.NewFakeClock(start)
+	cr := &copyReader{
+		reader: &chunkReader{bytes.NewReader(make([]byte, 3000)), 1000},
+		name:   "image.aci",
+		total:  3000,
+		pb:     &ProgressBar{},
+		clock:  clock,
+		rate:   rateEstimator{lastTime: start},
+
+		formatBytes: ByteUnitStr,
+	}
+
+	buf := make([]byte, 1000)
+	for i, tt := range []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, `{"name":"image.aci","bytes":1000,"total":3000,"done":false}`},
+		{time.Second, `{"name":"image.aci","bytes":2000,"total":3000,"rate":2000,"eta":0.5,"done":false}`},
+	} {
+		clock.Advance(tt.advance)
+		if _, err := cr.Read(buf); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		b, err := json.Marshal(cr.progress())
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if got := string(b); got != tt.want {
+			t.Errorf("case %d: want=%s got=%s", i, tt.want, got)
+		}
+	}
+}
+
+func TestCopyJSON(t *testing.T) {
+	cpp := NewCopyProgressPrinter()
+	cpp.JSON = true
+	sizes := map[string]int64{"one": 1500, "two": 2500}
+	for name, size := range sizes {
+		err := cpp.AddCopy(bytes.NewReader(make([]byte, size)), name, size, &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if err := cpp.PrintAndWait(out, time.Hour, nil); err != nil {
+		t.Fatalf("error from PrintAndWait: %v", err)
+	}
+
+	done := make(map[string]int)
+	s := bufio.NewScanner(out)
+	for s.Scan() {
+		var p CopyProgress
+		if err := json.Unmarshal(s.Bytes(), &p); err != nil {
+			t.Fatalf("line %q is not JSON: %v", s.Text(), err)
+		}
+		if p.Total != sizes[p.Name] {
+			t.Errorf("%s: want total %d, got %d", p.Name, sizes[p.Name], p.Total)
+		}
+		if p.Done {
+			done[p.Name]++
+			if p.Bytes != p.Total {
+				t.Errorf("%s: done after %d of %d bytes", p.Name, p.Bytes, p.Total)
+			}
+		}
+	}
+	for name := range sizes {
+		if done[name] != 1 {
+			t.Errorf("%s: want one done line, got %d", name, done[name])
+		}
+	}
+}
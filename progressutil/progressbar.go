@@ -15,15 +15,20 @@
 package progressutil
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 	"sync"
+	"time"
 
+	"github.com/coreos/pkg/timeutil"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
+// DefaultLogInterval is the default LogInterval of a ProgressBarPrinter.
+const DefaultLogInterval = 10 * time.Second
+
 var (
 	// ErrorProgressOutOfBounds is returned if the progress is set to a value
 	// not between 0 and 1.
@@ -44,6 +49,7 @@ type ProgressBar struct {
 	printBefore     string
 	printAfter      string
 	done            bool
+	indeterminate   bool
 }
 
 func (pb *ProgressBar) clone() *ProgressBar {
@@ -53,6 +59,7 @@ func (pb *ProgressBar) clone() *ProgressBar {
 		printBefore:     pb.printBefore,
 		printAfter:      pb.printAfter,
 		done:            pb.done,
+		indeterminate:   pb.indeterminate,
 	}
 	pb.lock.Unlock()
 	return pbClone
@@ -92,6 +99,23 @@ func (pb *ProgressBar) SetDone(val bool) {
 	pb.lock.Unlock()
 }
 
+// GetIndeterminate returns whether the progress of this bar is unknown.
+func (pb *ProgressBar) GetIndeterminate() bool {
+	pb.lock.Lock()
+	val := pb.indeterminate
+	pb.lock.Unlock()
+	return val
+}
+
+// SetIndeterminate sets whether the progress of this bar is unknown, as when
+// the total size of a copy is unknown. Until its progress reaches 1, an
+// indeterminate bar is drawn as a spinner rather than a bar.
+func (pb *ProgressBar) SetIndeterminate(val bool) {
+	pb.lock.Lock()
+	pb.indeterminate = val
+	pb.lock.Unlock()
+}
+
 // GetPrintBefore gets the text printed on the line before the progress bar.
 func (pb *ProgressBar) GetPrintBefore() string {
 	pb.lock.Lock()
@@ -130,13 +154,28 @@ type ProgressBarPrinter struct {
 	// DisplayWidth can be set to influence how large the progress bars are.
 	// The bars will be scaled to attempt to produce lines of this number of
 	// characters, but lines of different lengths may still be printed. When
-	// this value is 0 (aka unset), 80 character columns are assumed.
+	// this value is 0 (aka unset), the width of the terminal is used, or 80
+	// character columns if it cannot be determined. The width is queried on
+	// every draw, so bars follow the terminal as it is resized.
 	DisplayWidth int
 	// PadToBeEven, when set to true, will make Print pad the printBefore text
 	// with trailing spaces and the printAfter text with leading spaces to make
 	// the progress bars the same length.
-	PadToBeEven         bool
+	PadToBeEven bool
+	// LogInterval is the minimum time between lines printed when not
+	// printing to a terminal, where redrawn bars would only fill log
+	// files. A bar's line is still printed once as soon as it finishes.
+	// When this value is 0, DefaultLogInterval is used.
+	LogInterval time.Duration
+	// Clock defaults to timeutil.RealClock.
+	Clock timeutil.Clock
+	// Renderer draws each bar when printing to a terminal. When this value
+	// is nil, DefaultRenderer is used.
+	Renderer Renderer
+
 	numLinesInLastPrint int
+	frame               int
+	lastLog             time.Time
 	progressBars        []*ProgressBar
 	maxBefore           int
 	maxAfter            int
@@ -162,31 +201,32 @@ func (pbp *ProgressBarPrinter) AddProgressBar() *ProgressBar {
 // and if printTo is a terminal it will draw progress bars.  AddProgressBar
 // must be called at least once before Print is called. If printing to a
 // terminal, all draws after the first one will move the cursor up to draw over
-// the previously printed bars.
+// the previously printed bars. Each bar keeps its own line, in the order the
+// bars were added, and every line is cleared before it is redrawn and
+// truncated to DisplayWidth so it cannot wrap. Each draw is made with a
+// single write, and concurrent calls are serialized, so draws are never
+// interleaved with each other.
 func (pbp *ProgressBarPrinter) Print(printTo io.Writer) (bool, error) {
 	pbp.lock.Lock()
-	var bars []*ProgressBar
-	for _, bar := range pbp.progressBars {
-		bars = append(bars, bar.clone())
-	}
-	numColumns := pbp.DisplayWidth
-	pbp.lock.Unlock()
+	defer pbp.lock.Unlock()
 
-	if len(bars) == 0 {
+	if len(pbp.progressBars) == 0 {
 		return false, ErrorNoBarsAdded
 	}
 
+	numColumns := pbp.DisplayWidth
 	if numColumns == 0 {
-		numColumns = 80
+		numColumns = terminalWidth(printTo)
 	}
 
-	if pbp.isTerminal(printTo) {
-		moveCursorUp(printTo, pbp.numLinesInLastPrint)
+	bars := make([]*ProgressBar, len(pbp.progressBars))
+	for i, bar := range pbp.progressBars {
+		bars[i] = bar.clone()
 	}
 
 	for _, bar := range bars {
-		beforeSize := len(bar.GetPrintBefore())
-		afterSize := len(bar.GetPrintAfter())
+		beforeSize := textWidth(bar.printBefore)
+		afterSize := textWidth(bar.printAfter)
 		if beforeSize > pbp.maxBefore {
 			pbp.maxBefore = beforeSize
 		}
@@ -195,21 +235,60 @@ func (pbp *ProgressBarPrinter) Print(printTo io.Writer) (bool, error) {
 		}
 	}
 
+	var buf bytes.Buffer
+	terminal := pbp.isTerminal(printTo)
+	logDue := false
+	if terminal {
+		moveCursorUp(&buf, pbp.numLinesInLastPrint)
+	} else {
+		logDue = pbp.logDue()
+	}
+
+	renderer := pbp.Renderer
+	if renderer == nil {
+		renderer = DefaultRenderer
+	}
+	pbp.frame++
+
 	allDone := true
-	for _, bar := range bars {
-		if pbp.isTerminal(printTo) {
-			bar.printToTerminal(printTo, numColumns, pbp.PadToBeEven, pbp.maxBefore, pbp.maxAfter)
-		} else {
-			bar.printToNonTerminal(printTo)
+	for i, bar := range bars {
+		if terminal {
+			state := BarState{
+				Before:        bar.printBefore,
+				After:         bar.printAfter,
+				Progress:      bar.currentProgress,
+				Indeterminate: bar.indeterminate && bar.currentProgress < 1,
+				Frame:         pbp.frame,
+			}
+			if pbp.PadToBeEven {
+				state.BeforeWidth = pbp.maxBefore
+				state.AfterWidth = pbp.maxAfter
+			}
+			buf.WriteString(clearLine)
+			buf.WriteString(truncate(renderer.RenderBar(state, numColumns), numColumns))
+			buf.WriteByte('\n')
+		} else if !bar.done && (logDue || bar.currentProgress == 1) {
+			fmt.Fprintf(&buf, "%s %s\n", bar.printBefore, bar.printAfter)
+			if bar.currentProgress == 1 {
+				// Mark the bar itself, not the clone, so that the
+				// finished line is only printed once.
+				pbp.progressBars[i].SetDone(true)
+			}
 		}
-		allDone = allDone && bar.GetCurrentProgress() == 1
+		allDone = allDone && bar.currentProgress == 1
 	}
 
-	pbp.numLinesInLastPrint = len(bars)
+	if terminal {
+		pbp.numLinesInLastPrint = len(bars)
+	}
 
-	return allDone, nil
+	_, err := printTo.Write(buf.Bytes())
+	return allDone, err
 }
 
+// clearLine is the escape sequence clearing the line the cursor is on.
+const clearLine = "\033[2K"
+
 // moveCursorUp moves the cursor up numLines in the terminal
 func moveCursorUp(printTo io.Writer, numLines int) {
 	if numLines > 0 {
@@ -217,38 +296,48 @@ func moveCursorUp(printTo io.Writer, numLines int) {
 	}
 }
 
-func (pb *ProgressBar) printToTerminal(printTo io.Writer, numColumns int, padding bool, maxBefore, maxAfter int) {
-	before := pb.GetPrintBefore()
-	after := pb.GetPrintAfter()
-
-	if padding {
-		before = before + strings.Repeat(" ", maxBefore-len(before))
-		after = strings.Repeat(" ", maxAfter-len(after)) + after
+// truncate shortens s to at most n runes. A line longer than the terminal
+// would wrap, and the cursor would no longer return to the first bar.
+func truncate(s string, n int) string {
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j]
+		}
+		i++
 	}
+	return s
+}
 
-	progressBarSize := numColumns - (len(fmt.Sprintf("%s [] %s", before, after)))
-	progressBar := ""
-	if progressBarSize > 0 {
-		currentProgress := int(pb.GetCurrentProgress() * float64(progressBarSize))
-		progressBar = fmt.Sprintf("[%s%s] ",
-			strings.Repeat("=", currentProgress),
-			strings.Repeat(" ", progressBarSize-currentProgress))
-	} else {
-		// If we can't fit the progress bar, better to not pad the before/after.
-		before = pb.GetPrintBefore()
-		after = pb.GetPrintAfter()
+// logDue reports whether LogInterval has passed since lines were last
+// printed to a non-terminal, and if so restarts the interval.
+func (pbp *ProgressBarPrinter) logDue() bool {
+	clock := pbp.Clock
+	if clock == nil {
+		clock = timeutil.RealClock
+	}
+	interval := pbp.LogInterval
+	if interval == 0 {
+		interval = DefaultLogInterval
 	}
 
-	fmt.Fprintf(printTo, "%s %s%s\n", before, progressBar, after)
+	now := clock.Now()
+	if !pbp.lastLog.IsZero() && now.Sub(pbp.lastLog) < interval {
+		return false
+	}
+	pbp.lastLog = now
+	return true
 }
 
-func (pb *ProgressBar) printToNonTerminal(printTo io.Writer) {
-	if !pb.GetDone() {
-		fmt.Fprintf(printTo, "%s %s\n", pb.printBefore, pb.printAfter)
-		if pb.GetCurrentProgress() == 1 {
-			pb.SetDone(true)
+// terminalWidth returns the width of the terminal w is going to, or 80 if
+// it cannot be determined.
+func terminalWidth(w io.Writer) int {
+	if f, ok := w.(*os.File); ok {
+		if width, _, err := terminal.GetSize(int(f.Fd())); err == nil && width > 0 {
+			return width
 		}
 	}
+	return 80
 }
 
 // isTerminal returns True when w is going to a tty, and false otherwise.
@@ -19,7 +19,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
 )
 
 func TestNoBarsAdded(t *testing.T) {
@@ -97,7 +101,7 @@ func TestDrawOne(t *testing.T) {
 
 		bar := renderExpectedBar(80, testcase.beforeText, testcase.progress, testcase.afterText)
 
-		expectedOutput := fmt.Sprintf("\033[1A%s\n", bar)
+		expectedOutput := fmt.Sprintf("\033[1A\033[2K%s\n", bar)
 
 		if output != expectedOutput {
 			t.Errorf("unexpected output:\nexpected:\n\n%sactual:\n\n%s", expectedOutput, output)
@@ -105,6 +109,192 @@ func TestDrawOne(t *testing.T) {
 	}
 }
 
+func TestDrawMany(t *testing.T) {
+	pbp := ProgressBarPrinter{PadToBeEven: true}
+	pbp.printToTTYAlways = true
+	one := pbp.AddProgressBar()
+	one.SetPrintBefore("one")
+	one.SetPrintAfter("a")
+
+	buf := &bytes.Buffer{}
+	if _, err := pbp.Print(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf("\033[2K%s\n", renderExpectedBar(80, "one", 0, "a"))
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\nexpected:\n\n%sactual:\n\n%s", want, got)
+	}
+
+	// A bar added later gets a new line below the existing ones, and the
+	// padding of every line grows to match it.
+	two := pbp.AddProgressBar()
+	two.SetPrintBefore("second")
+	two.SetPrintAfter("bb")
+	two.SetCurrentProgress(0.5)
+
+	buf.Reset()
+	if _, err := pbp.Print(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = fmt.Sprintf("\033[1A\033[2K%s\n\033[2K%s\n",
+		renderExpectedBar(80, "one   ", 0, " a"),
+		renderExpectedBar(80, "second", 0.5, "bb"))
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\nexpected:\n\n%sactual:\n\n%s", want, got)
+	}
+
+	buf.Reset()
+	if _, err := pbp.Print(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "\033[2A") {
+		t.Errorf("expected the cursor to move up two lines, got %q", got)
+	}
+}
+
+func TestDrawTruncated(t *testing.T) {
+	pbp := ProgressBarPrinter{DisplayWidth: 20}
+	pbp.printToTTYAlways = true
+	pb := pbp.AddProgressBar()
+	pb.SetPrintBefore("a-very-long-file-name.tar.gz")
+	pb.SetPrintAfter("1 MB / 2 MB")
+
+	buf := &bytes.Buffer{}
+	if _, err := pbp.Print(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "\033[2Ka-very-long-file-nam\n"
+	if got := buf.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
+
+func TestDrawNonTerminal(t *testing.T) {
+	pbp := ProgressBarPrinter{}
+	pb := pbp.AddProgressBar()
+	pb.SetPrintBefore("download")
+	pb.SetPrintAfter("done")
+	pb.SetCurrentProgress(1)
+
+	for i, want := range []string{"download done\n", ""} {
+		buf := &bytes.Buffer{}
+		done, err := pbp.Print(buf)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if !done {
+			t.Errorf("case %d: expected done", i)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("case %d: want=%q got=%q", i, want, got)
+		}
+	}
+}
+
+func TestDrawNonTerminalInterval(t *testing.T) {
+	clock := timeutil.NewFakeClock(time.Unix(0, 0))
+	pbp := ProgressBarPrinter{LogInterval: 10 * time.Second, Clock: clock}
+	one := pbp.AddProgressBar()
+	one.SetPrintBefore("one")
+	two := pbp.AddProgressBar()
+	two.SetPrintBefore("two")
+
+	for i, tt := range []struct {
+		advance  time.Duration
+		one, two float64
+		want     string
+	}{
+		{0, 0, 0, "one 0\ntwo 0\n"},
+		{time.Second, 0.1, 0.1, ""},
+		// A finished bar is printed straight away.
+		{time.Second, 0.2, 1, "two 100\n"},
+		{8 * time.Second, 0.3, 1, "one 30\n"},
+		{time.Second, 0.4, 1, ""},
+		{10 * time.Second, 0.5, 1, "one 50\n"},
+	} {
+		clock.Advance(tt.advance)
+		one.SetCurrentProgress(tt.one)
+		one.SetPrintAfter(fmt.Sprint(tt.one * 100))
+		two.SetCurrentProgress(tt.two)
+		two.SetPrintAfter(fmt.Sprint(tt.two * 100))
+
+		buf := &bytes.Buffer{}
+		if _, err := pbp.Print(buf); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestTerminalWidth(t *testing.T) {
+	if got := terminalWidth(&bytes.Buffer{}); got != 80 {
+		t.Errorf("non-terminal: want=80 got=%d", got)
+	}
+}
+
+// lockedBuffer records each Write separately.
+type lockedBuffer struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (lb *lockedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	lb.writes = append(lb.writes, string(p))
+	lb.mu.Unlock()
+	return len(p), nil
+}
+
+func TestDrawConcurrent(t *testing.T) {
+	pbp := ProgressBarPrinter{}
+	pbp.printToTTYAlways = true
+	const numBars = 4
+	var bars []*ProgressBar
+	for i := 0; i < numBars; i++ {
+		pb := pbp.AddProgressBar()
+		pb.SetPrintBefore(fmt.Sprintf("bar%d", i))
+		bars = append(bars, pb)
+	}
+	pbp.Print(ioutil.Discard)
+
+	out := &lockedBuffer{}
+	var wg sync.WaitGroup
+	for i, pb := range bars {
+		wg.Add(1)
+		go func(i int, pb *ProgressBar) {
+			defer wg.Done()
+			for j := 0; j <= 10; j++ {
+				pb.SetCurrentProgress(float64(j) / 10)
+				pb.SetPrintAfter(fmt.Sprintf("%d%%", j*10))
+				if _, err := pbp.Print(out); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i, pb)
+	}
+	wg.Wait()
+
+	// Every draw must be a complete frame: a cursor move followed by one
+	// cleared line per bar.
+	prefix := fmt.Sprintf("\033[%dA", numBars)
+	for i, w := range out.writes {
+		if !strings.HasPrefix(w, prefix) {
+			t.Fatalf("write %d does not start by moving the cursor up: %q", i, w)
+		}
+		lines := strings.Split(strings.TrimSuffix(strings.TrimPrefix(w, prefix), "\n"), "\n")
+		if len(lines) != numBars {
+			t.Fatalf("write %d has %d lines, want %d: %q", i, len(lines), numBars, w)
+		}
+		for j, line := range lines {
+			if !strings.HasPrefix(line, "\033[2Kbar"+fmt.Sprint(j)) {
+				t.Fatalf("write %d: line %d is not bar%d: %q", i, j, j, line)
+			}
+		}
+	}
+}
+
 func renderExpectedBar(numColumns int, before string, progress float64, after string) string {
 	progressBarSize := numColumns - len(fmt.Sprintf("%s [] %s", before, after))
 	currentProgress := int(progress * float64(progressBarSize))
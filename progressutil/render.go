@@ -0,0 +1,165 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// BarState is the state of a ProgressBar passed to a Renderer.
+type BarState struct {
+	// Before and After are the texts set with SetPrintBefore and
+	// SetPrintAfter.
+	Before string
+	After  string
+	// BeforeWidth and AfterWidth are the widths Before and After should be
+	// padded to so that the bars of a ProgressBarPrinter line up, or zero
+	// if PadToBeEven is not set.
+	BeforeWidth int
+	AfterWidth  int
+
+	// Progress is between 0 and 1 inclusive.
+	Progress float64
+	// Indeterminate is set while the progress is unknown; see
+	// SetIndeterminate.
+	Indeterminate bool
+	// Frame counts the draws made by the ProgressBarPrinter, for animating
+	// spinners.
+	Frame int
+}
+
+// Renderer draws progress bars on a terminal.
+type Renderer interface {
+	// RenderBar returns the line for a bar, without a trailing newline,
+	// ideally width characters wide. Longer lines are truncated.
+	RenderBar(state BarState, width int) string
+}
+
+// DefaultRenderer is the Renderer used by a ProgressBarPrinter without one.
+var DefaultRenderer Renderer = BarRenderer{}
+
+// LabelPlacement selects where a BarRenderer puts the texts of a bar.
+type LabelPlacement int
+
+const (
+	// LabelsAround puts the text before the bar on its left and the text
+	// after it on its right.
+	LabelsAround LabelPlacement = iota
+	// LabelsLeft puts both texts on the left of the bar.
+	LabelsLeft
+	// LabelsRight puts both texts on the right of the bar.
+	LabelsRight
+)
+
+// BarRenderer is a Renderer drawing bars such as
+//
+//	download [==========          ] 1.5 MB / 3 MB
+//
+// Its zero value draws bars as above; any unset field takes the default
+// shown. Texts wider than the line are printed without the bar.
+type BarRenderer struct {
+	// Left and Right enclose the bar. They default to "[" and "]".
+	Left, Right string
+	// Fill draws the completed part of the bar and Empty the rest. They
+	// default to "=" and " ".
+	Fill, Empty string
+	// Head, if set, is drawn at the end of the completed part, as in
+	// "[====>     ]".
+	Head string
+	// Spinner is animated in place of the bar while the progress is
+	// indeterminate. It defaults to the frames |, /, - and \.
+	Spinner []string
+	// Labels places the texts; the default is LabelsAround.
+	Labels LabelPlacement
+}
+
+var defaultSpinner = []string{"|", "/", "-", "\\"}
+
+// RenderBar implements Renderer.
+func (r BarRenderer) RenderBar(state BarState, width int) string {
+	before := padRight(state.Before, state.BeforeWidth)
+	after := padLeft(state.After, state.AfterWidth)
+
+	if state.Indeterminate {
+		spinner := r.Spinner
+		if len(spinner) == 0 {
+			spinner = defaultSpinner
+		}
+		return r.layout(before, spinner[state.Frame%len(spinner)], after)
+	}
+
+	left, right := or(r.Left, "["), or(r.Right, "]")
+	size := width - textWidth(r.layout(before, left+right, after))
+	if size <= 0 {
+		// If we can't fit the progress bar, better to not pad the
+		// before/after.
+		return r.layout(state.Before, "", state.After)
+	}
+
+	filled := int(state.Progress * float64(size))
+	bar := strings.Repeat(or(r.Fill, "="), filled)
+	if r.Head != "" && filled > 0 && filled < size {
+		bar = strings.Repeat(or(r.Fill, "="), filled-1) + r.Head
+	}
+	bar += strings.Repeat(or(r.Empty, " "), size-filled)
+	return r.layout(before, left+bar+right, after)
+}
+
+// layout joins the texts and the bar according to r.Labels.
+func (r BarRenderer) layout(before, bar, after string) string {
+	var parts []string
+	switch r.Labels {
+	case LabelsLeft:
+		parts = []string{before, after, bar}
+	case LabelsRight:
+		parts = []string{bar, before, after}
+	default:
+		parts = []string{before, bar, after}
+	}
+
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
+
+func or(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func textWidth(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+func padRight(s string, width int) string {
+	if n := width - textWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+func padLeft(s string, width int) string {
+	if n := width - textWidth(s); n > 0 {
+		return strings.Repeat(" ", n) + s
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 CoreOS Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progressutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBarRenderer(t *testing.T) {
+	tests := []struct {
+		renderer BarRenderer
+		state    BarState
+		width    int
+		want     string
+	}{
+		{
+			renderer: BarRenderer{},
+			state:    BarState{Before: "a", After: "b", Progress: 0.5},
+			width:    14,
+			want:     "a [====    ] b",
+		},
+		{
+			renderer: BarRenderer{},
+			state:    BarState{Before: "a", After: "b", BeforeWidth: 3, AfterWidth: 2, Progress: 1},
+			width:    16,
+			want:     "a   [=======]  b",
+		},
+		{
+			renderer: BarRenderer{Left: "|", Right: "|", Fill: "█", Empty: "░"},
+			state:    BarState{Before: "a", After: "b", Progress: 0.25},
+			width:    14,
+			want:     "a |██░░░░░░| b",
+		},
+		{
+			renderer: BarRenderer{Head: ">"},
+			state:    BarState{Before: "a", After: "b", Progress: 0.5},
+			width:    14,
+			want:     "a [===>    ] b",
+		},
+		{
+			renderer: BarRenderer{Labels: LabelsLeft},
+			state:    BarState{Before: "a", After: "b", Progress: 0.5},
+			width:    14,
+			want:     "a b [====    ]",
+		},
+		{
+			renderer: BarRenderer{Labels: LabelsRight},
+			state:    BarState{Before: "a", After: "b", Progress: 0.5},
+			width:    14,
+			want:     "[====    ] a b",
+		},
+		{
+			renderer: BarRenderer{},
+			state:    BarState{Before: "a", After: "b", Indeterminate: true, Frame: 5},
+			width:    14,
+			want:     "a / b",
+		},
+		{
+			renderer: BarRenderer{Spinner: []string{".", "o", "O"}},
+			state:    BarState{Before: "a", After: "b", Indeterminate: true, Frame: 5},
+			width:    14,
+			want:     "a O b",
+		},
+		{
+			// Texts too wide for a bar are printed unpadded.
+			renderer: BarRenderer{},
+			state:    BarState{Before: "before", After: "after", BeforeWidth: 10, Progress: 0.5},
+			width:    12,
+			want:     "before after",
+		},
+	}
+
+	for i, tt := range tests {
+		if got := tt.renderer.RenderBar(tt.state, tt.width); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+type testRenderer struct{}
+
+func (testRenderer) RenderBar(state BarState, width int) string {
+	return fmt.Sprintf("%s %.0f%% %s", state.Before, state.Progress*100, strings.Repeat("-", width))
+}
+
+func TestCustomRenderer(t *testing.T) {
+	pbp := ProgressBarPrinter{DisplayWidth: 12, Renderer: testRenderer{}}
+	pbp.printToTTYAlways = true
+	pb := pbp.AddProgressBar()
+	pb.SetPrintBefore("x")
+	pb.SetCurrentProgress(0.5)
+
+	buf := &bytes.Buffer{}
+	if _, err := pbp.Print(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The line is truncated to the display width.
+	want := "\033[2Kx 50% ------\n"
+	if got := buf.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+}
+
+func TestCopyUnknownSize(t *testing.T) {
+	cpp := NewCopyProgressPrinter()
+	cpp.pbp.printToTTYAlways = true
+	cpp.FormatBytes = func(n int64) string { return fmt.Sprintf("%d bytes", n) }
+
+	br := &blockingReader{data: []byte("hello"), unblock: make(chan struct{})}
+	out := &bytes.Buffer{}
+	if err := cpp.AddCopy(br, "stream", 0, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Wait for the data to be read.
+	for cpp.readers[0].pb.GetPrintAfter() != "5 bytes / ?" {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := cpp.pbp.Print(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "\033[2Kstream / 5 bytes / ?\n"
+	if got := out.String(); got != want {
+		t.Errorf("want=%q got=%q", want, got)
+	}
+
+	close(br.unblock)
+	out.Reset()
+	if err := cpp.PrintAndWait(out, time.Hour, nil); err != nil {
+		t.Fatalf("error from PrintAndWait: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "stream [") || !strings.Contains(got, "\nCopied 5 bytes in ") {
+		t.Errorf("expected a full bar and a summary, got %q", got)
+	}
+}
@@ -1,6 +1,9 @@
 package timeutil
 
 import (
+	"context"
+	"errors"
+	"math/rand"
 	"time"
 )
 
@@ -13,3 +16,206 @@ func ExpBackoff(prev, max time.Duration) time.Duration {
 	}
 	return 2 * prev
 }
+
+// Jitter selects how randomness is added to Backoff delays, which stops
+// clients that failed at the same time from retrying in lockstep.
+type Jitter int
+
+const (
+	// NoJitter uses the exponential delay unchanged.
+	NoJitter Jitter = iota
+	// FullJitter picks a delay at random between zero and the exponential
+	// delay.
+	FullJitter
+	// EqualJitter picks a delay at random between half of and the full
+	// exponential delay.
+	EqualJitter
+)
+
+const (
+	DefaultBackoffInitial    = 100 * time.Millisecond
+	DefaultBackoffMax        = 10 * time.Second
+	DefaultBackoffMultiplier = 2
+)
+
+// Backoff computes delays between successive retries of an operation, growing
+// exponentially from Initial by Multiplier up to Max. Zero values of Initial,
+// Max and Multiplier are replaced by the defaults above.
+//
+// A Backoff is stateful and not safe for concurrent use; use one per
+// operation being retried.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     Jitter
+
+	// MaxRetries, if positive, limits the number of delays returned.
+	MaxRetries int
+	// MaxElapsed, if positive, limits the total time since the first call
+	// to NextDelay: no delay is returned which would end after it.
+	MaxElapsed time.Duration
+
+	// IsRetryable, if set, is consulted by Do and Retry for each error;
+	// errors for which it returns false are returned without retrying.
+	IsRetryable func(err error) bool
+	// OnRetry, if set, is called by Do and Retry before waiting to retry,
+	// with the number of the upcoming retry (starting at 1), the error
+	// which caused it and the delay before it. It suits logging.
+	OnRetry func(retry int, err error, delay time.Duration)
+
+	// Clock is used to measure MaxElapsed and to wait in Do and Retry. It
+	// defaults to RealClock.
+	Clock Clock
+
+	retries int
+	start   time.Time
+
+	// randInt63n is overridden in tests.
+	randInt63n func(int64) int64
+}
+
+// NextDelay returns how long to wait before the next retry, or false if no
+// more retries should be made.
+func (b *Backoff) NextDelay() (time.Duration, bool) {
+	if b.MaxRetries > 0 && b.retries >= b.MaxRetries {
+		return 0, false
+	}
+
+	d := b.delay(b.retries)
+	if b.MaxElapsed > 0 {
+		now := b.clock().Now()
+		if b.start.IsZero() {
+			b.start = now
+		}
+		if now.Sub(b.start)+d > b.MaxElapsed {
+			return 0, false
+		}
+	}
+	b.retries++
+	return d, true
+}
+
+// Retries returns the number of delays returned since the Backoff was
+// created or last Reset.
+func (b *Backoff) Retries() int {
+	return b.retries
+}
+
+// Reset returns the Backoff to its initial state.
+func (b *Backoff) Reset() {
+	b.retries = 0
+	b.start = time.Time{}
+}
+
+func (b *Backoff) delay(retry int) time.Duration {
+	initial := b.Initial
+	if initial <= 0 {
+		initial = DefaultBackoffInitial
+	}
+	max := b.Max
+	if max <= 0 {
+		max = DefaultBackoffMax
+	}
+	mult := b.Multiplier
+	if mult <= 1 {
+		mult = DefaultBackoffMultiplier
+	}
+
+	d := float64(initial)
+	for i := 0; i < retry && d < float64(max); i++ {
+		d *= mult
+	}
+	if d > float64(max) {
+		d = float64(max)
+	}
+
+	randInt63n := b.randInt63n
+	if randInt63n == nil {
+		randInt63n = rand.Int63n
+	}
+	switch b.Jitter {
+	case FullJitter:
+		return time.Duration(randInt63n(int64(d) + 1))
+	case EqualJitter:
+		half := int64(d) / 2
+		return time.Duration(half + randInt63n(int64(d)-half+1))
+	}
+	return time.Duration(d)
+}
+
+func (b *Backoff) clock() Clock {
+	if b.Clock != nil {
+		return b.Clock
+	}
+	return RealClock
+}
+
+// Do calls fn until it succeeds, waiting between calls according to b,
+// which is Reset first. It gives up when b allows no more retries, when fn
+// returns an error wrapped with Permanent or one which b.IsRetryable rejects,
+// or when ctx is done, returning the last error from fn. An error returned
+// by Permanent itself is unwrapped; one fn wrapped further is returned as is.
+// If ctx is done before fn is first called, ctx.Err() is returned.
+func Do(ctx context.Context, b *Backoff, fn func(ctx context.Context) error) error {
+	b.Reset()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	clock := b.clock()
+	for {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if perm, ok := err.(*permanentError); ok {
+			return perm.err
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) || ctx.Err() != nil {
+			return err
+		}
+		if b.IsRetryable != nil && !b.IsRetryable(err) {
+			return err
+		}
+		d, ok := b.NextDelay()
+		if !ok {
+			return err
+		}
+		if b.OnRetry != nil {
+			b.OnRetry(b.retries, err, d)
+		}
+		t := clock.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C():
+		}
+	}
+}
+
+// Retry is like Do, for functions which don't take a context.
+func (b *Backoff) Retry(ctx context.Context, fn func() error) error {
+	return Do(ctx, b, func(context.Context) error { return fn() })
+}
+
+// Permanent wraps err to stop Backoff.Retry from retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
@@ -1,6 +1,10 @@
 package timeutil
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -50,3 +54,196 @@ func TestExpBackoff(t *testing.T) {
 		}
 	}
 }
+
+func TestBackoffNextDelay(t *testing.T) {
+	tests := []struct {
+		b    Backoff
+		want []time.Duration
+	}{
+		// Defaults, limited by MaxRetries.
+		{
+			b: Backoff{MaxRetries: 4},
+			want: []time.Duration{
+				100 * time.Millisecond, 200 * time.Millisecond,
+				400 * time.Millisecond, 800 * time.Millisecond,
+			},
+		},
+		// Capped at Max.
+		{
+			b:    Backoff{Initial: time.Second, Max: 3 * time.Second, MaxRetries: 4},
+			want: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+		{
+			b:    Backoff{Initial: time.Second, Multiplier: 3, MaxRetries: 3},
+			want: []time.Duration{time.Second, 3 * time.Second, 9 * time.Second},
+		},
+		// The random source returns its maximum here.
+		{
+			b:    Backoff{Initial: time.Second, Jitter: FullJitter, MaxRetries: 2},
+			want: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			b:    Backoff{Initial: time.Second, Jitter: EqualJitter, MaxRetries: 2},
+			want: []time.Duration{time.Second, 2 * time.Second},
+		},
+	}
+
+	for i, tt := range tests {
+		tt.b.randInt63n = func(n int64) int64 { return n - 1 }
+		var got []time.Duration
+		for {
+			d, ok := tt.b.NextDelay()
+			if !ok {
+				break
+			}
+			got = append(got, d)
+			if len(got) > 10 {
+				t.Fatalf("case %d: too many delays", i)
+			}
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, got)
+		}
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	for _, j := range []Jitter{FullJitter, EqualJitter} {
+		b := Backoff{Initial: time.Second, Max: time.Second, Jitter: j}
+		b.randInt63n = func(n int64) int64 { return 0 }
+		d, _ := b.NextDelay()
+		min := time.Duration(0)
+		if j == EqualJitter {
+			min = 500 * time.Millisecond
+		}
+		if d != min {
+			t.Errorf("jitter %d: want=%v got=%v", j, min, d)
+		}
+	}
+}
+
+func TestBackoffMaxElapsed(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	b := Backoff{Initial: time.Second, Multiplier: 2, MaxElapsed: 5 * time.Second, Clock: f}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		d, ok := b.NextDelay()
+		if !ok || d != want {
+			t.Fatalf("case %d: want=%v got=%v, %t", i, want, d, ok)
+		}
+		f.Advance(d)
+	}
+	// 3s have elapsed, and the next delay of 4s would end after 5s.
+	if d, ok := b.NextDelay(); ok {
+		t.Errorf("want no more delays, got %v", d)
+	}
+
+	b.Reset()
+	if _, ok := b.NextDelay(); !ok || b.Retries() != 1 {
+		t.Errorf("Reset did not restart the backoff")
+	}
+}
+
+func TestBackoffRetry(t *testing.T) {
+	b := &Backoff{Initial: time.Millisecond, MaxRetries: 3}
+	calls := 0
+	err := b.Retry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("want success after 3 calls, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	errFail := errors.New("fail")
+	err = b.Retry(context.Background(), func() error {
+		calls++
+		return errFail
+	})
+	if err != errFail || calls != 4 {
+		t.Errorf("want errFail after 4 calls, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	err = b.Retry(context.Background(), func() error {
+		calls++
+		return Permanent(errFail)
+	})
+	if err != errFail || calls != 1 {
+		t.Errorf("want errFail after 1 call, got %v after %d", err, calls)
+	}
+
+	// Context wrapped around a permanent error is kept.
+	calls = 0
+	err = b.Retry(context.Background(), func() error {
+		calls++
+		return fmt.Errorf("fetch x: %w", Permanent(errFail))
+	})
+	if !errors.Is(err, errFail) || err.Error() != "fetch x: fail" || calls != 1 {
+		t.Errorf("want wrapped errFail after 1 call, got %v after %d", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Retry(ctx, func() error { return nil }); err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	var retries []string
+	b := &Backoff{
+		Initial:     time.Second,
+		Clock:       f,
+		IsRetryable: func(err error) bool { return err == errTransient },
+		OnRetry: func(retry int, err error, delay time.Duration) {
+			retries = append(retries, fmt.Sprintf("%d %v %v", retry, err, delay))
+		},
+	}
+
+	calls := 0
+	done := make(chan error)
+	go func() {
+		done <- Do(context.Background(), b, func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return errFatal
+		})
+	}()
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	f.BlockUntil(1)
+	f.Advance(2 * time.Second)
+
+	if err := <-done; err != errFatal {
+		t.Errorf("want errFatal, got %v", err)
+	}
+	want := []string{"1 transient 1s", "2 transient 2s"}
+	if !reflect.DeepEqual(want, retries) {
+		t.Errorf("want=%q got=%q", want, retries)
+	}
+	// The error which was not retried used up no retry.
+	if b.retries != 2 {
+		t.Errorf("want 2 retries used, got %d", b.retries)
+	}
+
+	// Cancelling while waiting returns the last error.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- Do(ctx, b, func(ctx context.Context) error { return errTransient })
+	}()
+	f.BlockUntil(1)
+	cancel()
+	if err := <-done; err != errTransient {
+		t.Errorf("want errTransient, got %v", err)
+	}
+}
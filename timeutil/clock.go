@@ -0,0 +1,263 @@
+package timeutil
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the time and timers. Code which takes a Clock rather than
+// using the time package directly can be tested with a FakeClock.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	Sleep(d time.Duration)
+}
+
+// Timer is the Clock equivalent of time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the Clock equivalent of time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// RealClock is a Clock backed by the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock is a Clock whose time only changes when Advance or Set is
+// called, firing any timers and tickers which become due. It is safe for
+// concurrent use, so the code under test can wait on it in one goroutine
+// while the test advances it from another.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	when   time.Time
+	period time.Duration // non-zero for tickers
+	c      chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to t.
+func NewFakeClock(t time.Time) *FakeClock {
+	f := &FakeClock{now: t}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *FakeClock) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{c: make(chan time.Time, 1)}
+	f.addLocked(w, d)
+	return &fakeTimer{f: f, w: w}
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{period: d, c: make(chan time.Time, 1)}
+	f.addLocked(w, d)
+	return &fakeTicker{f: f, w: w}
+}
+
+// Advance moves the clock forward by d, firing timers and tickers in order
+// of when they are due. A ticker which is due several times fires for each,
+// although as with time.Ticker ticks are dropped if the last one has not
+// been received.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setLocked(f.now.Add(d))
+}
+
+// Set moves the clock to t, which must not be before the current time,
+// firing timers and tickers as Advance does.
+func (f *FakeClock) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setLocked(t)
+}
+
+// Waiters returns the number of timers and tickers which have not yet fired
+// or been stopped. A goroutine blocked in Sleep counts as one.
+func (f *FakeClock) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil waits until there are at least n pending timers and tickers,
+// letting a test be sure the code under test is waiting on the clock before
+// advancing it.
+func (f *FakeClock) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+func (f *FakeClock) setLocked(t time.Time) {
+	for len(f.waiters) > 0 && !f.waiters[0].when.After(t) {
+		w := f.waiters[0]
+		f.now = w.when
+		select {
+		case w.c <- w.when:
+		default:
+		}
+		f.removeLocked(w)
+		if w.period > 0 {
+			f.addLocked(w, w.period)
+		}
+	}
+	if t.After(f.now) {
+		f.now = t
+	}
+}
+
+// addLocked schedules w to fire after d. f.mu must be held.
+func (f *FakeClock) addLocked(w *fakeWaiter, d time.Duration) {
+	w.when = f.now.Add(d)
+	if d <= 0 {
+		select {
+		case w.c <- f.now:
+		default:
+		}
+		return
+	}
+	i := sort.Search(len(f.waiters), func(i int) bool {
+		return f.waiters[i].when.After(w.when)
+	})
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = w
+	f.cond.Broadcast()
+}
+
+// removeLocked unschedules w, reporting whether it was scheduled. f.mu must
+// be held.
+func (f *FakeClock) removeLocked(w *fakeWaiter) bool {
+	for i, x := range f.waiters {
+		if x == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	f *FakeClock
+	w *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	return t.f.removeLocked(t.w)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	active := t.f.removeLocked(t.w)
+	t.f.addLocked(t.w, d)
+	return active
+}
+
+type fakeTicker struct {
+	f *FakeClock
+	w *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.removeLocked(t.w)
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.f.removeLocked(t.w)
+	t.w.period = d
+	t.f.addLocked(t.w, d)
+}
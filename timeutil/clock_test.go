@@ -0,0 +1,116 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func expectFired(t *testing.T, c <-chan time.Time, want time.Time) {
+	t.Helper()
+	select {
+	case got := <-c:
+		if !got.Equal(want) {
+			t.Errorf("fired at %v, want %v", got, want)
+		}
+	default:
+		t.Errorf("did not fire, want %v", want)
+	}
+}
+
+func expectNotFired(t *testing.T, c <-chan time.Time) {
+	t.Helper()
+	select {
+	case got := <-c:
+		t.Errorf("unexpectedly fired at %v", got)
+	default:
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Unix(1000, 0)
+	f := NewFakeClock(start)
+
+	timer := f.NewTimer(time.Second)
+	after := f.After(2 * time.Second)
+	if n := f.Waiters(); n != 2 {
+		t.Fatalf("want 2 waiters, got %d", n)
+	}
+
+	f.Advance(999 * time.Millisecond)
+	expectNotFired(t, timer.C())
+	f.Advance(time.Millisecond)
+	expectFired(t, timer.C(), start.Add(time.Second))
+	expectNotFired(t, after)
+
+	// Reset after firing.
+	if timer.Reset(time.Second) {
+		t.Errorf("Reset of fired timer returned true")
+	}
+	if !timer.Stop() {
+		t.Errorf("Stop of pending timer returned false")
+	}
+	f.Advance(5 * time.Second)
+	expectNotFired(t, timer.C())
+	expectFired(t, after, start.Add(2*time.Second))
+
+	if got, want := f.Since(start), 6*time.Second; got != want {
+		t.Errorf("Since == %v, want %v", got, want)
+	}
+	if n := f.Waiters(); n != 0 {
+		t.Errorf("want no waiters, got %d", n)
+	}
+
+	expectFired(t, f.After(0), start.Add(6*time.Second))
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	start := time.Unix(1000, 0)
+	f := NewFakeClock(start)
+	ticker := f.NewTicker(time.Second)
+
+	f.Advance(time.Second)
+	expectFired(t, ticker.C(), start.Add(time.Second))
+	// Ticks are dropped while the receiver is behind.
+	f.Advance(3 * time.Second)
+	expectFired(t, ticker.C(), start.Add(2*time.Second))
+	expectNotFired(t, ticker.C())
+
+	ticker.Reset(10 * time.Second)
+	f.Advance(9 * time.Second)
+	expectNotFired(t, ticker.C())
+	f.Advance(time.Second)
+	expectFired(t, ticker.C(), start.Add(14*time.Second))
+
+	ticker.Stop()
+	f.Advance(time.Minute)
+	expectNotFired(t, ticker.C())
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	done := make(chan struct{})
+	go func() {
+		f.Sleep(time.Minute)
+		close(done)
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Sleep did not return")
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	var c Clock = RealClock
+	timer := c.NewTimer(time.Millisecond)
+	<-timer.C()
+	ticker := c.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+	if c.Since(c.Now()) < 0 {
+		t.Errorf("time went backwards")
+	}
+}
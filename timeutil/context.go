@@ -0,0 +1,62 @@
+package timeutil
+
+import (
+	"context"
+	"time"
+)
+
+// SleepContext pauses for d, returning early with ctx.Err() if ctx is done
+// first.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	return SleepContextClock(ctx, RealClock, d)
+}
+
+// SleepContextClock is like SleepContext but waits on clock.
+func SleepContextClock(ctx context.Context, clock Clock, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	t := clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TickUntil calls fn every interval, starting one interval from now, until
+// ctx is done or fn returns an error. It returns ctx.Err() or the error from
+// fn. The underlying ticker is always stopped. As with time.Ticker, ticks
+// are dropped if fn takes longer than interval.
+func TickUntil(ctx context.Context, interval time.Duration, fn func() error) error {
+	return TickUntilClock(ctx, RealClock, interval, fn)
+}
+
+// TickUntilClock is like TickUntil but ticks on clock.
+func TickUntilClock(ctx context.Context, clock Clock, interval time.Duration, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t := clock.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+			// Don't run fn if ctx was cancelled while it was waiting;
+			// select picks among ready cases at random.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package timeutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSleepContext(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	done := make(chan error)
+	go func() { done <- SleepContextClock(context.Background(), f, time.Minute) }()
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- SleepContextClock(ctx, f, time.Minute) }()
+	f.BlockUntil(1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	// The timer is stopped.
+	if n := f.Waiters(); n != 0 {
+		t.Errorf("want no waiters, got %d", n)
+	}
+
+	if err := SleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTickUntil(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	ticks := make(chan struct{})
+	errStop := errors.New("stop")
+	n := 0
+	done := make(chan error)
+	go func() {
+		done <- TickUntilClock(context.Background(), f, time.Second, func() error {
+			n++
+			ticks <- struct{}{}
+			if n == 3 {
+				return errStop
+			}
+			return nil
+		})
+	}()
+
+	for i := 0; i < 3; i++ {
+		f.BlockUntil(1)
+		f.Advance(time.Second)
+		<-ticks
+	}
+	if err := <-done; err != errStop {
+		t.Errorf("want errStop, got %v", err)
+	}
+	if w := f.Waiters(); w != 0 {
+		t.Errorf("ticker was not stopped: %d waiters", w)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- TickUntilClock(ctx, f, time.Second, func() error { return nil })
+	}()
+	f.BlockUntil(1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
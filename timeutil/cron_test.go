@@ -0,0 +1,76 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, time.January, 15, 10, 30, 0, 0, time.UTC) // a Monday
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"* * * * *", []string{"2024-01-15T10:31:00Z", "2024-01-15T10:32:00Z"}},
+		{"0 * * * *", []string{"2024-01-15T11:00:00Z", "2024-01-15T12:00:00Z"}},
+		{"*/20 9-17 * * *", []string{"2024-01-15T10:40:00Z", "2024-01-15T11:00:00Z"}},
+		{"5/30 * * * *", []string{"2024-01-15T10:35:00Z", "2024-01-15T11:05:00Z"}},
+		{"0 2 * * sat,sun", []string{"2024-01-20T02:00:00Z", "2024-01-21T02:00:00Z"}},
+		{"0 0 * * 7", []string{"2024-01-21T00:00:00Z", "2024-01-28T00:00:00Z"}},
+		{"0 0 1 jan-mar *", []string{"2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z"}},
+		{"0 0 29 2 *", []string{"2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}},
+		// Both day fields restricted: either matches.
+		{"0 0 20 * mon", []string{"2024-01-20T00:00:00Z", "2024-01-22T00:00:00Z"}},
+		{"@daily", []string{"2024-01-16T00:00:00Z", "2024-01-17T00:00:00Z"}},
+		{"@weekly", []string{"2024-01-21T00:00:00Z", "2024-01-28T00:00:00Z"}},
+		{"@every 90m", []string{"2024-01-15T12:00:00Z", "2024-01-15T13:30:00Z"}},
+		{"TZ=America/New_York 0 9 * * *", []string{"2024-01-15T14:00:00Z", "2024-01-16T14:00:00Z"}},
+	}
+
+	for i, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("case %d: unexpected error parsing %q: %v", i, tt.expr, err)
+			continue
+		}
+		next := base
+		for j, w := range tt.want {
+			next = s.Next(next)
+			if got := next.UTC().Format(time.RFC3339); got != w {
+				t.Errorf("case %d: run %d of %q: want=%s got=%s", i, j, tt.expr, w, got)
+			}
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for i, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every",
+		"@every -1m",
+		"TZ=Nowhere/Special * * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("case %d: expected error parsing %q", i, expr)
+		}
+	}
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	s, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("want zero time, got %v", next)
+	}
+}
@@ -0,0 +1,226 @@
+package timeutil
+
+import (
+	"sync"
+	"time"
+)
+
+// A pendingCall is a call of a function once a timer fires, which is
+// abandoned if it is cancelled first.
+type pendingCall struct {
+	timer  Timer
+	cancel chan struct{}
+}
+
+// callAfter calls f with the returned pendingCall once d has passed on
+// clock, unless it is cancelled first.
+func callAfter(clock Clock, d time.Duration, f func(*pendingCall)) *pendingCall {
+	p := &pendingCall{timer: clock.NewTimer(d), cancel: make(chan struct{})}
+	go func() {
+		select {
+		case <-p.timer.C():
+			f(p)
+		case <-p.cancel:
+		}
+	}()
+	return p
+}
+
+func (p *pendingCall) stop() {
+	p.timer.Stop()
+	close(p.cancel)
+}
+
+// Debouncer collapses a burst of calls into one call of a function, made
+// once the calls have stopped for a duration, as when a burst of file
+// changes should trigger a single reload. It is safe for concurrent use.
+type Debouncer struct {
+	clock Clock
+	d     time.Duration
+	fn    func()
+
+	// run serializes the calls of fn.
+	run sync.Mutex
+
+	mu      sync.Mutex
+	pending *pendingCall
+	stopped bool
+}
+
+// Debounce returns a Debouncer of fn, which calls it once d has passed
+// without further calls.
+func Debounce(d time.Duration, fn func()) *Debouncer {
+	return DebounceWithClock(RealClock, d, fn)
+}
+
+// DebounceWithClock returns a Debouncer timed by clock.
+func DebounceWithClock(clock Clock, d time.Duration, fn func()) *Debouncer {
+	return &Debouncer{clock: clock, d: d, fn: fn}
+}
+
+// Call calls fn once d has passed without another call of Call. fn is
+// called on its own goroutine, but never concurrently with itself.
+func (db *Debouncer) Call() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.stopped {
+		return
+	}
+	if db.pending != nil {
+		db.pending.stop()
+	}
+	db.pending = callAfter(db.clock, db.d, db.fire)
+}
+
+func (db *Debouncer) fire(p *pendingCall) {
+	db.mu.Lock()
+	if db.pending != p {
+		// Superseded while waiting for the lock.
+		db.mu.Unlock()
+		return
+	}
+	db.pending = nil
+	db.mu.Unlock()
+	db.call()
+}
+
+func (db *Debouncer) call() {
+	db.run.Lock()
+	defer db.run.Unlock()
+	db.fn()
+}
+
+// Flush calls fn right away if a call is pending, instead of once d has
+// passed, and reports whether it did.
+func (db *Debouncer) Flush() bool {
+	db.mu.Lock()
+	p := db.pending
+	if p != nil {
+		p.stop()
+		db.pending = nil
+	}
+	db.mu.Unlock()
+	if p == nil {
+		return false
+	}
+	db.call()
+	return true
+}
+
+// Stop drops the pending call, if any, and makes later calls of Call do
+// nothing. It reports whether a call was dropped. A call of fn which has
+// already begun is not waited for.
+func (db *Debouncer) Stop() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.stopped = true
+	p := db.pending
+	if p != nil {
+		p.stop()
+		db.pending = nil
+	}
+	return p != nil
+}
+
+// Throttler limits the calls of a function to one per duration: the first
+// call is made right away, and calls during the following duration are
+// collapsed into one call made at its end, which starts another. This
+// bounds the rate of calls during a long burst, which a Debouncer would
+// defer until its end. It is safe for concurrent use.
+type Throttler struct {
+	clock Clock
+	d     time.Duration
+	fn    func()
+
+	// run serializes the calls of fn.
+	run sync.Mutex
+
+	mu      sync.Mutex
+	window  *pendingCall // ends the current window, if any
+	pending bool         // a call was made during the window
+	stopped bool
+}
+
+// Throttle returns a Throttler calling fn at most once per d.
+func Throttle(d time.Duration, fn func()) *Throttler {
+	return ThrottleWithClock(RealClock, d, fn)
+}
+
+// ThrottleWithClock returns a Throttler timed by clock.
+func ThrottleWithClock(clock Clock, d time.Duration, fn func()) *Throttler {
+	return &Throttler{clock: clock, d: d, fn: fn}
+}
+
+// Call calls fn before returning if no call was made in the last d, and
+// otherwise once d has passed since the last call, on its own goroutine.
+// fn is never called concurrently with itself.
+func (th *Throttler) Call() {
+	th.mu.Lock()
+	if th.stopped {
+		th.mu.Unlock()
+		return
+	}
+	if th.window != nil {
+		th.pending = true
+		th.mu.Unlock()
+		return
+	}
+	th.window = callAfter(th.clock, th.d, th.endWindow)
+	th.mu.Unlock()
+	th.call()
+}
+
+func (th *Throttler) endWindow(p *pendingCall) {
+	th.mu.Lock()
+	if th.window != p {
+		th.mu.Unlock()
+		return
+	}
+	if !th.pending {
+		th.window = nil
+		th.mu.Unlock()
+		return
+	}
+	th.pending = false
+	th.window = callAfter(th.clock, th.d, th.endWindow)
+	th.mu.Unlock()
+	th.call()
+}
+
+func (th *Throttler) call() {
+	th.run.Lock()
+	defer th.run.Unlock()
+	th.fn()
+}
+
+// Flush calls fn right away if a call is pending, starting a new window,
+// and reports whether it did.
+func (th *Throttler) Flush() bool {
+	th.mu.Lock()
+	if !th.pending {
+		th.mu.Unlock()
+		return false
+	}
+	th.pending = false
+	th.window.stop()
+	th.window = callAfter(th.clock, th.d, th.endWindow)
+	th.mu.Unlock()
+	th.call()
+	return true
+}
+
+// Stop drops the pending call, if any, and makes later calls of Call do
+// nothing. It reports whether a call was dropped. A call of fn which has
+// already begun is not waited for.
+func (th *Throttler) Stop() bool {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.stopped = true
+	if th.window != nil {
+		th.window.stop()
+		th.window = nil
+	}
+	dropped := th.pending
+	th.pending = false
+	return dropped
+}
@@ -0,0 +1,99 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+// expectCalls fails t unless calls receives n values, and no more shortly
+// after.
+func expectCalls(t *testing.T, calls <-chan struct{}, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-calls:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d calls, want %d", i, n)
+		}
+	}
+	select {
+	case <-calls:
+		t.Fatalf("got more than %d calls", n)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	f := NewFakeClock(time.Unix(0, 0))
+	calls := make(chan struct{}, 10)
+	db := DebounceWithClock(f, 100*time.Millisecond, func() { calls <- struct{}{} })
+
+	for i := 0; i < 3; i++ {
+		db.Call()
+		f.Advance(50 * time.Millisecond)
+	}
+	expectCalls(t, calls, 0)
+	f.Advance(50 * time.Millisecond)
+	expectCalls(t, calls, 1)
+
+	db.Call()
+	if !db.Flush() {
+		t.Errorf("pending call not flushed")
+	}
+	expectCalls(t, calls, 1)
+	if db.Flush() {
+		t.Errorf("flushed without a pending call")
+	}
+	f.Advance(time.Second)
+	expectCalls(t, calls, 0)
+
+	db.Call()
+	if !db.Stop() {
+		t.Errorf("pending call not dropped")
+	}
+	db.Call()
+	f.Advance(time.Second)
+	expectCalls(t, calls, 0)
+}
+
+func TestThrottle(t *testing.T) {
+	f := NewFakeClock(time.Unix(0, 0))
+	calls := make(chan struct{}, 10)
+	th := ThrottleWithClock(f, 100*time.Millisecond, func() { calls <- struct{}{} })
+
+	// The first call is made right away, and those during the window
+	// once at its end.
+	th.Call()
+	expectCalls(t, calls, 1)
+	th.Call()
+	th.Call()
+	f.Advance(50 * time.Millisecond)
+	th.Call()
+	expectCalls(t, calls, 0)
+	f.Advance(50 * time.Millisecond)
+	expectCalls(t, calls, 1)
+
+	// The trailing call started another window, which ends quietly.
+	f.BlockUntil(1)
+	f.Advance(100 * time.Millisecond)
+	expectCalls(t, calls, 0)
+	th.Call()
+	expectCalls(t, calls, 1)
+
+	th.Call()
+	if !th.Flush() {
+		t.Errorf("pending call not flushed")
+	}
+	expectCalls(t, calls, 1)
+	if th.Flush() {
+		t.Errorf("flushed without a pending call")
+	}
+
+	th.Call()
+	if !th.Stop() {
+		t.Errorf("pending call not dropped")
+	}
+	th.Call()
+	f.Advance(time.Second)
+	expectCalls(t, calls, 0)
+}
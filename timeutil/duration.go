@@ -0,0 +1,197 @@
+package timeutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	Day  = 24 * time.Hour
+	Week = 7 * Day
+)
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond, // U+00B5 micro sign
+	"μs": time.Microsecond, // U+03BC Greek letter mu
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  Day,
+	"w":  Week,
+}
+
+var errDurationOverflow = errors.New("duration out of range")
+
+// ParseDuration is like time.ParseDuration but also accepts the units "d"
+// for days of 24 hours and "w" for weeks of 7 days, as in "3d12h" or
+// "1.5w".
+func ParseDuration(s string) (time.Duration, error) {
+	orig := s
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	if s == "" {
+		return 0, fmt.Errorf("timeutil: invalid duration %q", orig)
+	}
+
+	// As in time.ParseDuration, the total is kept in integer nanoseconds;
+	// only the fractional part of each component is scaled in floating
+	// point.
+	var total uint64
+	for s != "" {
+		i := 0
+		for i < len(s) && (s[i] == '.' || ('0' <= s[i] && s[i] <= '9')) {
+			i++
+		}
+		num := s[:i]
+		if num == "" || num == "." || strings.Count(num, ".") > 1 {
+			return 0, fmt.Errorf("timeutil: invalid duration %q", orig)
+		}
+		s = s[i:]
+
+		j := 0
+		for j < len(s) && s[j] != '.' && (s[j] < '0' || s[j] > '9') {
+			j++
+		}
+		unit, ok := durationUnits[s[:j]]
+		if !ok {
+			if j == 0 {
+				return 0, fmt.Errorf("timeutil: missing unit in duration %q", orig)
+			}
+			return 0, fmt.Errorf("timeutil: unknown unit %q in duration %q", s[:j], orig)
+		}
+		s = s[j:]
+
+		v, ok := componentNanos(num, uint64(unit))
+		if !ok || v > 1<<63-total {
+			return 0, fmt.Errorf("timeutil: invalid duration %q: %v", orig, errDurationOverflow)
+		}
+		total += v
+	}
+
+	if neg {
+		return -time.Duration(total), nil
+	}
+	if total > math.MaxInt64 {
+		return 0, fmt.Errorf("timeutil: invalid duration %q: %v", orig, errDurationOverflow)
+	}
+	return time.Duration(total), nil
+}
+
+// componentNanos returns the number num, of digits with at most one
+// decimal point, of units of unit nanoseconds, in nanoseconds. It reports
+// false if that exceeds 1<<63.
+func componentNanos(num string, unit uint64) (uint64, bool) {
+	integer, frac := num, ""
+	if i := strings.IndexByte(num, '.'); i >= 0 {
+		integer, frac = num[:i], num[i+1:]
+	}
+	var v uint64
+	for _, c := range integer {
+		if v > 1<<63/10 {
+			return 0, false
+		}
+		v = v*10 + uint64(c-'0')
+		if v > 1<<63 {
+			return 0, false
+		}
+	}
+	if v > 1<<63/unit {
+		return 0, false
+	}
+	v *= unit
+
+	// Digits beyond those which fit are too small to matter.
+	var f uint64
+	scale := 1.0
+	for _, c := range frac {
+		if f > 1<<63/10 {
+			break
+		}
+		f = f*10 + uint64(c-'0')
+		scale *= 10
+	}
+	v += uint64(float64(f) * (float64(unit) / scale))
+	return v, v <= 1<<63
+}
+
+// HumanizeDuration formats d using its two most significant units of weeks,
+// days, hours, minutes and seconds, as in "2h 3m" or "3d 12h". Durations
+// under a second are formatted as by time.Duration.String, rounded to the
+// millisecond.
+func HumanizeDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + HumanizeDuration(-d)
+	}
+	if d < time.Second {
+		if d >= time.Millisecond {
+			d = d.Round(time.Millisecond)
+		}
+		return d.String()
+	}
+
+	units := []struct {
+		d    time.Duration
+		name string
+	}{
+		{Week, "w"}, {Day, "d"}, {time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"},
+	}
+	var parts []string
+	for _, u := range units {
+		if len(parts) == 2 {
+			break
+		}
+		if n := d / u.d; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.name))
+			d -= n * u.d
+		} else if len(parts) > 0 {
+			// Don't skip a unit between the two shown, so "1d 5m" is
+			// reported as just "1d".
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ApproximateDuration describes d in approximate words, as in "about 3
+// days" or "less than a minute", for status output where precision would be
+// noise. Months are taken to be 30 days and years 365.
+func ApproximateDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	scales := []struct {
+		d              time.Duration
+		single, plural string
+	}{
+		{365 * Day, "about a year", "about %d years"},
+		{30 * Day, "about a month", "about %d months"},
+		{Week, "about a week", "about %d weeks"},
+		{Day, "about a day", "about %d days"},
+		{time.Hour, "about an hour", "about %d hours"},
+		{time.Minute, "a minute", "%d minutes"},
+	}
+	for _, sc := range scales {
+		// Use a unit once the duration rounds to at least one of it.
+		n := int64(math.Round(float64(d) / float64(sc.d)))
+		if n < 1 || (sc.d > time.Minute && d < sc.d*9/10) {
+			continue
+		}
+		if n == 1 {
+			return sc.single
+		}
+		return fmt.Sprintf(sc.plural, n)
+	}
+	return "less than a minute"
+}
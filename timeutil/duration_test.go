@@ -0,0 +1,105 @@
+package timeutil
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "0", want: 0},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "3d12h", want: 84 * time.Hour},
+		{in: "2w", want: 14 * Day},
+		{in: "1.5w", want: 252 * time.Hour},
+		{in: "-1d", want: -Day},
+		{in: "+10s", want: 10 * time.Second},
+		{in: "100ms", want: 100 * time.Millisecond},
+		{in: "3µs", want: 3 * time.Microsecond},
+		{in: "1w2d3h4m5s", want: 9*Day + 3*time.Hour + 4*time.Minute + 5*time.Second},
+		{in: "", err: true},
+		{in: "d", err: true},
+		{in: "3", err: true},
+		{in: "3y", err: true},
+		{in: "1..5d", err: true},
+		{in: "100000000w", err: true},
+		// Near the limit of about 292 years.
+		{in: "2562047h", want: 2562047 * time.Hour},
+		{in: "2562048h", err: true},
+		{in: "2562047h1ns", want: 2562047*time.Hour + 1},
+		{in: "9223372036854775807ns", want: math.MaxInt64},
+		{in: "-9223372036854775808ns", want: math.MinInt64},
+		{in: "9223372036854775808ns", err: true},
+		{in: "9223372036.854775807s", want: math.MaxInt64},
+		{in: "0.000000001s", want: 1},
+		{in: ".5d", want: 12 * time.Hour},
+	}
+
+	for i, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("case %d: expected error parsing %q, got %v", i, tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, got)
+		}
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{1234567 * time.Nanosecond, "1ms"},
+		{350 * time.Microsecond, "350µs"},
+		{45 * time.Second, "45s"},
+		{2*time.Hour + 3*time.Minute + 4*time.Second, "2h 3m"},
+		{3*Day + 12*time.Hour, "3d 12h"},
+		{Day + 5*time.Minute, "1d"},
+		{15 * Day, "2w 1d"},
+		{-90 * time.Second, "-1m 30s"},
+	}
+	for i, tt := range tests {
+		if got := HumanizeDuration(tt.in); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
+
+func TestApproximateDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{10 * time.Second, "less than a minute"},
+		{50 * time.Second, "a minute"},
+		{7 * time.Minute, "7 minutes"},
+		{55 * time.Minute, "about an hour"},
+		{5 * time.Hour, "about 5 hours"},
+		{3*Day + 2*time.Hour, "about 3 days"},
+		{9 * Day, "about a week"},
+		{20 * Day, "about 3 weeks"},
+		{70 * Day, "about 2 months"},
+		{800 * Day, "about 2 years"},
+		{-5 * time.Hour, "about 5 hours"},
+	}
+	for i, tt := range tests {
+		if got := ApproximateDuration(tt.in); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+}
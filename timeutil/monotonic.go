@@ -0,0 +1,103 @@
+package timeutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoMonotonic is returned when a time has no monotonic clock
+	// reading, for example because it was parsed, unmarshalled or
+	// stripped, so durations computed from it would follow the wall clock.
+	ErrNoMonotonic = errors.New("time has no monotonic clock reading")
+
+	// ErrDifferentBoot is returned when a BootTimestamp was taken before
+	// the system last booted.
+	ErrDifferentBoot = errors.New("timestamp is from a different boot")
+
+	// ErrBootTimeUnsupported is returned on platforms without a boot
+	// relative clock.
+	ErrBootTimeUnsupported = errors.New("boot relative time is not supported on this platform")
+)
+
+// HasMonotonic reports whether t carries a monotonic clock reading. Times
+// from time.Now do, unless stripped; parsed and unmarshalled times, and
+// results of Round, Truncate, In, UTC and Local, do not.
+func HasMonotonic(t time.Time) bool {
+	return t != t.Round(0)
+}
+
+// StripMonotonic returns t without its monotonic clock reading, so that
+// comparisons and subtraction use the wall clock.
+func StripMonotonic(t time.Time) time.Time {
+	return t.Round(0)
+}
+
+// MonotonicSince returns the time elapsed since start as measured by the
+// monotonic clock, which is unaffected by changes to the wall clock. It
+// returns ErrNoMonotonic rather than a wall clock duration if start has no
+// monotonic reading.
+func MonotonicSince(start time.Time) (time.Duration, error) {
+	if !HasMonotonic(start) {
+		return 0, ErrNoMonotonic
+	}
+	return time.Since(start), nil
+}
+
+// BootTimestamp is a point in time measured from when the system booted,
+// including time spent suspended. Unlike monotonic readings in time.Time it
+// can be stored and restored by another process, and stays meaningful until
+// the next reboot regardless of changes to the wall clock.
+type BootTimestamp struct {
+	// BootID identifies the boot the timestamp belongs to.
+	BootID string
+	// SinceBoot is the time between boot and the timestamp.
+	SinceBoot time.Duration
+}
+
+// NowBoot returns the current BootTimestamp.
+func NowBoot() (BootTimestamp, error) {
+	id, err := bootID()
+	if err != nil {
+		return BootTimestamp{}, err
+	}
+	since, err := sinceBoot()
+	if err != nil {
+		return BootTimestamp{}, err
+	}
+	return BootTimestamp{BootID: id, SinceBoot: since}, nil
+}
+
+// Elapsed returns the time since b, or ErrDifferentBoot if the system has
+// rebooted since b was taken.
+func (b BootTimestamp) Elapsed() (time.Duration, error) {
+	now, err := NowBoot()
+	if err != nil {
+		return 0, err
+	}
+	if now.BootID != b.BootID {
+		return 0, ErrDifferentBoot
+	}
+	return now.SinceBoot - b.SinceBoot, nil
+}
+
+// MarshalText encodes b as "<boot ID>/<nanoseconds since boot>".
+func (b BootTimestamp) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s/%d", b.BootID, int64(b.SinceBoot))), nil
+}
+
+func (b *BootTimestamp) UnmarshalText(text []byte) error {
+	s := string(text)
+	i := strings.LastIndex(s, "/")
+	if i <= 0 {
+		return fmt.Errorf("timeutil: invalid boot timestamp %q", s)
+	}
+	var ns int64
+	if _, err := fmt.Sscanf(s[i+1:], "%d", &ns); err != nil || ns < 0 {
+		return fmt.Errorf("timeutil: invalid boot timestamp %q", s)
+	}
+	b.BootID, b.SinceBoot = s[:i], time.Duration(ns)
+	return nil
+}
@@ -0,0 +1,25 @@
+package timeutil
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func bootID() (string, error) {
+	b, err := ioutil.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func sinceBoot() (time.Duration, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_BOOTTIME, &ts); err != nil {
+		return 0, err
+	}
+	return time.Duration(ts.Nano()), nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package timeutil
+
+import (
+	"time"
+)
+
+func bootID() (string, error) {
+	return "", ErrBootTimeUnsupported
+}
+
+func sinceBoot() (time.Duration, error) {
+	return 0, ErrBootTimeUnsupported
+}
@@ -0,0 +1,69 @@
+package timeutil
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestMonotonic(t *testing.T) {
+	now := time.Now()
+	if !HasMonotonic(now) {
+		t.Fatalf("time.Now() has no monotonic reading")
+	}
+	stripped := StripMonotonic(now)
+	if HasMonotonic(stripped) || !stripped.Equal(now) {
+		t.Errorf("StripMonotonic(%v) == %v", now, stripped)
+	}
+	if HasMonotonic(now.UTC()) {
+		t.Errorf("UTC() kept the monotonic reading")
+	}
+
+	if d, err := MonotonicSince(now); err != nil || d < 0 {
+		t.Errorf("MonotonicSince == %v, %v", d, err)
+	}
+	if _, err := MonotonicSince(stripped); err != ErrNoMonotonic {
+		t.Errorf("want ErrNoMonotonic, got %v", err)
+	}
+}
+
+func TestBootTimestampText(t *testing.T) {
+	b := BootTimestamp{BootID: "8e3b2c1a-0000-4000-8000-000000000001", SinceBoot: 90 * time.Second}
+	text, err := b.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "8e3b2c1a-0000-4000-8000-000000000001/90000000000"; string(text) != want {
+		t.Errorf("want=%q got=%q", want, text)
+	}
+	var got BootTimestamp
+	if err := got.UnmarshalText(text); err != nil || got != b {
+		t.Errorf("UnmarshalText == %v, %v", got, err)
+	}
+
+	for i, in := range []string{"", "noslash", "/5", "id/-1", "id/x"} {
+		if err := got.UnmarshalText([]byte(in)); err == nil {
+			t.Errorf("case %d: expected error unmarshalling %q", i, in)
+		}
+	}
+}
+
+func TestNowBoot(t *testing.T) {
+	b, err := NowBoot()
+	if runtime.GOOS != "linux" {
+		if err != ErrBootTimeUnsupported {
+			t.Errorf("want ErrBootTimeUnsupported, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d, err := b.Elapsed(); err != nil || d < 0 {
+		t.Errorf("Elapsed == %v, %v", d, err)
+	}
+	b.BootID = "some-other-boot"
+	if _, err := b.Elapsed(); err != ErrDifferentBoot {
+		t.Errorf("want ErrDifferentBoot, got %v", err)
+	}
+}
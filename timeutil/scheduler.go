@@ -0,0 +1,221 @@
+package timeutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+// OverlapPolicy decides what happens when a job is due to run while its
+// previous run is still in progress.
+type OverlapPolicy int
+
+const (
+	// SkipIfRunning skips the run.
+	SkipIfRunning OverlapPolicy = iota
+	// DelayIfRunning runs the job again as soon as the previous run
+	// finishes. Several skipped runs result in only one delayed run.
+	DelayIfRunning
+	// AllowOverlap starts the run regardless.
+	AllowOverlap
+)
+
+// Job is a task run by a Scheduler.
+type Job struct {
+	// Name identifies the job in logs. It must be unique within a
+	// Scheduler.
+	Name     string
+	Schedule Schedule
+	Overlap  OverlapPolicy
+
+	// Run is called with a context which is cancelled when the
+	// Scheduler stops.
+	Run func(ctx context.Context) error
+}
+
+// Scheduler runs Jobs according to their Schedules. Each run happens in its
+// own goroutine; a run which panics is recovered and logged as an error, and
+// the job keeps its schedule.
+type Scheduler struct {
+	// Clock defaults to RealClock.
+	Clock Clock
+	// Logger, if set, receives a line for each run: at DEBUG when it
+	// starts, INFO when it succeeds, and ERROR when it fails or panics.
+	// Skipped runs are logged at WARNING.
+	Logger *capnslog.PackageLogger
+
+	mu      sync.Mutex
+	jobs    []*scheduledJob
+	wake    chan struct{}
+	running bool
+	wg      sync.WaitGroup
+}
+
+type scheduledJob struct {
+	Job
+	next    time.Time
+	active  int
+	pending bool
+}
+
+// Add registers job with the scheduler. Jobs may be added before or while
+// the scheduler runs.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return errors.New("timeutil: job must have a Name, Schedule and Run function")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Name == job.Name {
+			return fmt.Errorf("timeutil: job %q already added", job.Name)
+		}
+	}
+	sj := &scheduledJob{Job: job}
+	if s.running {
+		sj.next = job.Schedule.Next(s.clock().Now())
+		s.poke()
+	}
+	s.jobs = append(s.jobs, sj)
+	return nil
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return RealClock
+	}
+	return s.Clock
+}
+
+// poke wakes the Run loop to recompute its timer. s.mu must be held.
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs jobs until ctx is done, then waits for runs in progress to finish
+// and returns ctx.Err(). It returns an error immediately if the scheduler
+// is already running.
+func (s *Scheduler) Run(ctx context.Context) error {
+	clock := s.clock()
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("timeutil: scheduler is already running")
+	}
+	s.running = true
+	s.wake = make(chan struct{}, 1)
+	now := clock.Now()
+	for _, j := range s.jobs {
+		j.next = j.Schedule.Next(now)
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.wg.Wait()
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		var next time.Time
+		for _, j := range s.jobs {
+			if !j.next.IsZero() && (next.IsZero() || j.next.Before(next)) {
+				next = j.next
+			}
+		}
+		s.mu.Unlock()
+
+		var timer Timer
+		var fired <-chan time.Time
+		if !next.IsZero() {
+			timer = clock.NewTimer(next.Sub(clock.Now()))
+			fired = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-s.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case now := <-fired:
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+// runDue starts every job due at or before now.
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.next.IsZero() || j.next.After(now) {
+			continue
+		}
+		j.next = j.Schedule.Next(now)
+		if j.active > 0 {
+			switch j.Overlap {
+			case SkipIfRunning:
+				s.logf(capnslog.WARNING, "job %s skipped: previous run still in progress", j.Name)
+				continue
+			case DelayIfRunning:
+				j.pending = true
+				continue
+			}
+		}
+		s.start(ctx, j)
+	}
+}
+
+// start runs j in a new goroutine. s.mu must be held.
+func (s *Scheduler) start(ctx context.Context, j *scheduledJob) {
+	j.active++
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runJob(ctx, j)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		j.active--
+		if j.pending && ctx.Err() == nil {
+			j.pending = false
+			s.start(ctx, j)
+		}
+	}()
+}
+
+func (s *Scheduler) runJob(ctx context.Context, j *scheduledJob) {
+	clock := s.clock()
+	start := clock.Now()
+	s.logf(capnslog.DEBUG, "job %s started", j.Name)
+	defer func() {
+		if r := recover(); r != nil {
+			s.logf(capnslog.ERROR, "job %s panicked after %v: %v\n%s", j.Name, clock.Since(start), r, debug.Stack())
+		}
+	}()
+	if err := j.Run(ctx); err != nil {
+		s.logf(capnslog.ERROR, "job %s failed after %v: %v", j.Name, clock.Since(start), err)
+		return
+	}
+	s.logf(capnslog.INFO, "job %s finished in %v", j.Name, clock.Since(start))
+}
+
+func (s *Scheduler) logf(l capnslog.LogLevel, format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Logf(l, format, args...)
+	}
+}
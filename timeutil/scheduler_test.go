@@ -0,0 +1,129 @@
+package timeutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+func TestScheduler(t *testing.T) {
+	f := NewFakeClock(time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC))
+	s := &Scheduler{
+		Clock:  f,
+		Logger: capnslog.NewPackageLogger("github.com/coreos/pkg", "timeutil_test"),
+	}
+
+	runs := make(chan string, 10)
+	if err := s.Add(Job{
+		Name:     "every-minute",
+		Schedule: Every(time.Minute),
+		Run: func(ctx context.Context) error {
+			runs <- "every-minute"
+			return nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(Job{
+		Name:     "panics",
+		Schedule: Every(90 * time.Second),
+		Run: func(ctx context.Context) error {
+			runs <- "panics"
+			panic("boom")
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(Job{Name: "panics", Schedule: Every(time.Second), Run: func(context.Context) error { return nil }}); err == nil {
+		t.Errorf("expected error adding duplicate job")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-runs:
+			if got != want {
+				t.Errorf("want run of %s, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	expect("every-minute")
+	f.BlockUntil(1)
+	f.Advance(30 * time.Second)
+	expect("panics")
+	// The panic didn't stop the scheduler.
+	f.BlockUntil(1)
+	f.Advance(30 * time.Second)
+	expect("every-minute")
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	for _, tt := range []struct {
+		policy OverlapPolicy
+		runs   int
+	}{
+		{SkipIfRunning, 1},
+		{DelayIfRunning, 2},
+		{AllowOverlap, 3},
+	} {
+		f := NewFakeClock(time.Unix(1000, 0))
+		s := &Scheduler{Clock: f}
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		s.Add(Job{
+			Name:     "slow",
+			Schedule: Every(time.Second),
+			Overlap:  tt.policy,
+			Run: func(ctx context.Context) error {
+				started <- struct{}{}
+				<-release
+				return errors.New("done")
+			},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Run(ctx) }()
+
+		// Three runs are due while the first is still going.
+		f.BlockUntil(1)
+		f.Advance(time.Second)
+		<-started
+		for i := 0; i < 2; i++ {
+			f.BlockUntil(1)
+			f.Advance(time.Second)
+		}
+		// Let the scheduler handle the last tick before releasing.
+		f.BlockUntil(1)
+		close(release)
+		n := 1
+		if tt.policy == DelayIfRunning {
+			// Wait for the delayed run before stopping the scheduler.
+			<-started
+			n++
+		}
+		cancel()
+		<-done
+
+		if n += len(started); n != tt.runs {
+			t.Errorf("policy %d: want %d runs, got %d", tt.policy, tt.runs, n)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package timeutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+)
+
+// Lap is the time taken by one phase measured by a Stopwatch.
+type Lap struct {
+	Name string
+	// Duration is the time since the previous checkpoint, or the start.
+	Duration time.Duration
+	// Elapsed is the time since the Stopwatch was started.
+	Elapsed time.Duration
+}
+
+// Stopwatch times the phases of a multi-step operation. Call Checkpoint at
+// the end of each phase, then Summary or Log for a breakdown. It is safe for
+// concurrent use.
+type Stopwatch struct {
+	mu    sync.Mutex
+	clock Clock
+	start time.Time
+	last  time.Time
+	laps  []Lap
+}
+
+// NewStopwatch returns a Stopwatch started now.
+func NewStopwatch() *Stopwatch {
+	return NewStopwatchWithClock(RealClock)
+}
+
+// NewStopwatchWithClock returns a Stopwatch timed by clock.
+func NewStopwatchWithClock(clock Clock) *Stopwatch {
+	sw := &Stopwatch{clock: clock}
+	sw.Reset()
+	return sw
+}
+
+// Reset discards all laps and restarts the Stopwatch.
+func (sw *Stopwatch) Reset() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.start = sw.clock.Now()
+	sw.last = sw.start
+	sw.laps = nil
+}
+
+// Checkpoint ends the current phase, recording it under name, and returns
+// how long it took.
+func (sw *Stopwatch) Checkpoint(name string) time.Duration {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	now := sw.clock.Now()
+	lap := Lap{Name: name, Duration: now.Sub(sw.last), Elapsed: now.Sub(sw.start)}
+	sw.laps = append(sw.laps, lap)
+	sw.last = now
+	return lap.Duration
+}
+
+// Elapsed returns the time since the Stopwatch was started.
+func (sw *Stopwatch) Elapsed() time.Duration {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.clock.Since(sw.start)
+}
+
+// Laps returns the phases recorded so far.
+func (sw *Stopwatch) Laps() []Lap {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	laps := make([]Lap, len(sw.laps))
+	copy(laps, sw.laps)
+	return laps
+}
+
+// Summary describes the recorded phases and their share of the total time
+// up to the last checkpoint, as in
+// "total=1.5s load=500ms (33.3%) replay=1s (66.7%)".
+func (sw *Stopwatch) Summary() string {
+	laps := sw.Laps()
+	var total time.Duration
+	if len(laps) > 0 {
+		total = laps[len(laps)-1].Elapsed
+	}
+	parts := []string{"total=" + total.String()}
+	for _, l := range laps {
+		pct := 0.0
+		if total > 0 {
+			pct = 100 * float64(l.Duration) / float64(total)
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v (%.1f%%)", l.Name, l.Duration, pct))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Log writes msg followed by the Summary to logger at level.
+func (sw *Stopwatch) Log(logger *capnslog.PackageLogger, level capnslog.LogLevel, msg string) {
+	if !logger.LevelAt(level) {
+		return
+	}
+	logger.Logf(level, "%s: %s", msg, sw.Summary())
+}
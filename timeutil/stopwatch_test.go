@@ -0,0 +1,43 @@
+package timeutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStopwatch(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	sw := NewStopwatchWithClock(f)
+
+	if s := sw.Summary(); s != "total=0s" {
+		t.Errorf("want=%q got=%q", "total=0s", s)
+	}
+
+	f.Advance(500 * time.Millisecond)
+	if d := sw.Checkpoint("load"); d != 500*time.Millisecond {
+		t.Errorf("load: want=500ms got=%v", d)
+	}
+	f.Advance(time.Second)
+	sw.Checkpoint("replay")
+	f.Advance(time.Second)
+
+	want := []Lap{
+		{Name: "load", Duration: 500 * time.Millisecond, Elapsed: 500 * time.Millisecond},
+		{Name: "replay", Duration: time.Second, Elapsed: 1500 * time.Millisecond},
+	}
+	if got := sw.Laps(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want=%v got=%v", want, got)
+	}
+	if d := sw.Elapsed(); d != 2500*time.Millisecond {
+		t.Errorf("elapsed: want=2.5s got=%v", d)
+	}
+	if s, w := sw.Summary(), "total=1.5s load=500ms (33.3%) replay=1s (66.7%)"; s != w {
+		t.Errorf("want=%q got=%q", w, s)
+	}
+
+	sw.Reset()
+	if n := len(sw.Laps()); n != 0 {
+		t.Errorf("want no laps after Reset, got %d", n)
+	}
+}
@@ -0,0 +1,183 @@
+package timeutil
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Inf is a TokenBucket rate which allows any number of events.
+var Inf = math.Inf(1)
+
+// TokenBucket is a rate limiter holding up to burst tokens which refills at
+// rate tokens per second. Each event takes a token, so on average rate events
+// per second are allowed, with bursts of up to burst at once. It starts full.
+//
+// Methods taking a time use it as the current time, which lets callers which
+// already have the time, or are making many decisions at once, avoid reading
+// the clock repeatedly. Checking whether an event is allowed does not
+// allocate.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+// NewTokenBucket returns a full TokenBucket using RealClock. A rate of Inf
+// allows every event; a rate of zero allows no more than burst events ever.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return NewTokenBucketWithClock(rate, burst, RealClock)
+}
+
+// NewTokenBucketWithClock is like NewTokenBucket but uses clock for Allow
+// and Wait.
+func NewTokenBucketWithClock(rate float64, burst int, clock Clock) *TokenBucket {
+	return &TokenBucket{rate: rate, burst: burst, tokens: float64(burst), clock: clock}
+}
+
+// Limit returns the rate and burst of b.
+func (b *TokenBucket) Limit() (rate float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate, b.burst
+}
+
+// SetLimit changes the rate and burst of b. Tokens accrued at the old rate
+// are kept, up to the new burst.
+func (b *TokenBucket) SetLimit(rate float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(b.clock.Now())
+	b.rate, b.burst = rate, burst
+	b.tokens = math.Min(b.tokens, float64(burst))
+}
+
+// Tokens returns the number of tokens available at now. It is negative if
+// tokens have been reserved in advance with ReserveN.
+func (b *TokenBucket) Tokens(now time.Time) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	return b.tokens
+}
+
+// Allow is shorthand for AllowN(now, 1).
+func (b *TokenBucket) Allow() bool {
+	return b.AllowN(b.clock.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at now, taking n tokens if so.
+func (b *TokenBucket) AllowN(now time.Time, n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if math.IsInf(b.rate, 1) {
+		return true
+	}
+	b.refill(now)
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// DelayN returns how long after now it will be until n tokens are
+// available, or zero if they already are. It does not take any tokens. If
+// the tokens will never be available, the maximum time.Duration is
+// returned.
+func (b *TokenBucket) DelayN(now time.Time, n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if math.IsInf(b.rate, 1) {
+		return 0
+	}
+	b.refill(now)
+	return b.delay(float64(n))
+}
+
+// ReserveN takes n tokens at now whether or not they are available, and
+// returns how long the caller must wait before acting so as to stay within
+// the limit. It suits callers which must go ahead, such as a write which
+// has already been read from its source.
+func (b *TokenBucket) ReserveN(now time.Time, n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if math.IsInf(b.rate, 1) {
+		return 0
+	}
+	b.refill(now)
+	b.tokens -= float64(n)
+	return b.delay(0)
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	return b.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events may happen, or ctx is done. It returns an
+// error without waiting if n exceeds the burst, or if ctx would expire
+// first.
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rate, burst := b.Limit()
+	if n > burst && !math.IsInf(rate, 1) {
+		return fmt.Errorf("timeutil: wait for %d tokens exceeds burst of %d", n, burst)
+	}
+
+	now := b.clock.Now()
+	d := b.ReserveN(now, n)
+	if d == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(d)) {
+		b.unreserve(n)
+		return context.DeadlineExceeded
+	}
+	t := b.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		b.unreserve(n)
+		return ctx.Err()
+	}
+}
+
+func (b *TokenBucket) unreserve(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(float64(b.burst), b.tokens+float64(n))
+}
+
+// refill adds the tokens accrued since the last refill. b.mu must be held.
+func (b *TokenBucket) refill(now time.Time) {
+	if b.last.IsZero() {
+		b.last = now
+		return
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.burst), b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// delay returns how long until the bucket holds want tokens. b.mu must be
+// held.
+func (b *TokenBucket) delay(want float64) time.Duration {
+	if b.tokens >= want {
+		return 0
+	}
+	if b.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil((want - b.tokens) / b.rate * float64(time.Second)))
+}
@@ -0,0 +1,123 @@
+package timeutil
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAllow(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	b := NewTokenBucketWithClock(2, 3, f)
+
+	for i, tt := range []struct {
+		advance time.Duration
+		n       int
+		allowed bool
+		delay   time.Duration
+	}{
+		// Starts full.
+		{0, 3, true, 0},
+		{0, 1, false, 500 * time.Millisecond},
+		{500 * time.Millisecond, 1, true, 0},
+		// Never refills beyond the burst.
+		{time.Hour, 4, false, 500 * time.Millisecond},
+		{0, 3, true, 0},
+	} {
+		f.Advance(tt.advance)
+		if d := b.DelayN(f.Now(), tt.n); d != tt.delay {
+			t.Errorf("case %d: delay == %v, want %v", i, d, tt.delay)
+		}
+		if ok := b.AllowN(f.Now(), tt.n); ok != tt.allowed {
+			t.Errorf("case %d: allowed == %t, want %t", i, ok, tt.allowed)
+		}
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	b := NewTokenBucketWithClock(10, 10, f)
+	if d := b.ReserveN(f.Now(), 10); d != 0 {
+		t.Errorf("want no delay, got %v", d)
+	}
+	if d := b.ReserveN(f.Now(), 5); d != 500*time.Millisecond {
+		t.Errorf("want 500ms delay, got %v", d)
+	}
+	if n := b.Tokens(f.Now()); n != -5 {
+		t.Errorf("want -5 tokens, got %v", n)
+	}
+}
+
+func TestTokenBucketLimits(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+
+	b := NewTokenBucketWithClock(Inf, 0, f)
+	for i := 0; i < 100; i++ {
+		if !b.Allow() {
+			t.Fatalf("infinite rate denied event %d", i)
+		}
+	}
+
+	b = NewTokenBucketWithClock(0, 1, f)
+	b.Allow()
+	if d := b.DelayN(f.Now(), 1); d != time.Duration(math.MaxInt64) {
+		t.Errorf("want maximum delay for zero rate, got %v", d)
+	}
+
+	b = NewTokenBucketWithClock(1, 10, f)
+	b.AllowN(f.Now(), 10)
+	b.SetLimit(100, 5)
+	if rate, burst := b.Limit(); rate != 100 || burst != 5 {
+		t.Errorf("Limit == %v, %v, want 100, 5", rate, burst)
+	}
+	f.Advance(time.Second)
+	if n := b.Tokens(f.Now()); n != 5 {
+		t.Errorf("want 5 tokens at new rate, got %v", n)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	f := NewFakeClock(time.Unix(1000, 0))
+	b := NewTokenBucketWithClock(1, 1, f)
+	ctx := context.Background()
+
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- b.Wait(ctx) }()
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := b.WaitN(ctx, 2); err == nil {
+		t.Errorf("expected error waiting for more than the burst")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.Wait(cctx); err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+
+	// The deadline is before a token will be available, so Wait gives up
+	// straight away and returns the token it reserved.
+	rb := NewTokenBucket(0.1, 1)
+	rb.Allow()
+	dctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := rb.Wait(dctx); err != context.DeadlineExceeded {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Wait did not return immediately")
+	}
+	if n := rb.Tokens(time.Now()); n < 0 {
+		t.Errorf("reserved token was not returned: %v tokens", n)
+	}
+}
@@ -0,0 +1,166 @@
+package timeutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Window is a recurring window of time on certain days of the week, such as
+// a maintenance window.
+type Window struct {
+	days       uint8 // bit n set for time.Weekday(n)
+	start, end int   // minutes from midnight
+	loc        *time.Location
+}
+
+// ParseWindow parses a window of the form "[days] HH:MM-HH:MM [zone]", for
+// example "Sat,Sun 02:00-04:00 UTC" or "Mon-Fri 22:00-01:00
+// Europe/Berlin". Days are given by their first three letters, as a list
+// and/or ranges; if omitted the window occurs every day. A window which ends
+// before it starts runs past midnight, and belongs to the day it starts on.
+// The end may be given as 24:00. If the zone is omitted, UTC is used.
+func ParseWindow(s string) (*Window, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("timeutil: empty time window")
+	}
+	w := &Window{days: 0x7f, loc: time.UTC}
+
+	if !strings.Contains(fields[0], ":") {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("timeutil: invalid time window %q: %v", s, err)
+		}
+		w.days = days
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("timeutil: invalid time window %q", s)
+	}
+
+	times := strings.SplitN(fields[0], "-", 2)
+	if len(times) != 2 {
+		return nil, fmt.Errorf("timeutil: invalid time range in window %q", s)
+	}
+	var err error
+	if w.start, err = parseClock(times[0], false); err != nil {
+		return nil, fmt.Errorf("timeutil: invalid time window %q: %v", s, err)
+	}
+	if w.end, err = parseClock(times[1], true); err != nil {
+		return nil, fmt.Errorf("timeutil: invalid time window %q: %v", s, err)
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("timeutil: empty time window %q", s)
+	}
+
+	if len(fields) == 2 {
+		if w.loc, err = time.LoadLocation(fields[1]); err != nil {
+			return nil, fmt.Errorf("timeutil: invalid time zone in window %q: %v", s, err)
+		}
+	}
+	return w, nil
+}
+
+func parseWeekdays(s string) (uint8, error) {
+	var days uint8
+	for _, part := range strings.Split(s, ",") {
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		l, ok1 := weekdayNames[strings.ToLower(lo)]
+		h, ok2 := weekdayNames[strings.ToLower(hi)]
+		if !ok1 || !ok2 {
+			return 0, fmt.Errorf("invalid days %q", part)
+		}
+		// Ranges may wrap around the end of the week, as in "Fri-Mon".
+		for d := l; ; d = (d + 1) % 7 {
+			days |= 1 << uint(d)
+			if d == h {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseClock(s string, allow24 bool) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if h > 23 && !(allow24 && h == 24 && m == 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// bounds returns the window starting on the given day, if there is one.
+func (w *Window) bounds(year int, month time.Month, day int) (start, end time.Time, ok bool) {
+	start = time.Date(year, month, day, 0, w.start, 0, 0, w.loc)
+	if w.days&(1<<uint(start.Weekday())) == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	endDay := day
+	if w.end <= w.start {
+		endDay++
+	}
+	end = time.Date(year, month, endDay, 0, w.end, 0, 0, w.loc)
+	return start, end, true
+}
+
+// Contains reports whether t falls within an occurrence of the window.
+func (w *Window) Contains(t time.Time) bool {
+	lt := t.In(w.loc)
+	y, m, d := lt.Date()
+	// An occurrence starting the previous day may run past midnight.
+	for _, offset := range []int{0, -1} {
+		if start, end, ok := w.bounds(y, m, d+offset); ok && !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStart returns the start of the first occurrence of the window at or
+// after t.
+func (w *Window) NextStart(t time.Time) time.Time {
+	lt := t.In(w.loc)
+	y, m, d := lt.Date()
+	for i := 0; i <= 7; i++ {
+		if start, _, ok := w.bounds(y, m, d+i); ok && !start.Before(t) {
+			return start
+		}
+	}
+	// Unreachable: every window occurs at least once a week.
+	return time.Time{}
+}
+
+// String returns the window in the form accepted by ParseWindow.
+func (w *Window) String() string {
+	var days []string
+	if w.days != 0x7f {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if w.days&(1<<uint(d)) != 0 {
+				days = append(days, d.String()[:3])
+			}
+		}
+	}
+	s := fmt.Sprintf("%02d:%02d-%02d:%02d %s", w.start/60, w.start%60, w.end/60, w.end%60, w.loc)
+	if len(days) > 0 {
+		s = strings.Join(days, ",") + " " + s
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Sat,Sun 02:00-04:00 UTC", "Sun,Sat 02:00-04:00 UTC"},
+		{"02:00-04:00", "02:00-04:00 UTC"},
+		{"mon-fri 22:00-01:30 Europe/Berlin", "Mon,Tue,Wed,Thu,Fri 22:00-01:30 Europe/Berlin"},
+		{"Fri-Mon 00:00-24:00", "Sun,Mon,Fri,Sat 00:00-24:00 UTC"},
+	}
+	for i, tt := range tests {
+		w, err := ParseWindow(tt.in)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := w.String(); got != tt.want {
+			t.Errorf("case %d: want=%q got=%q", i, tt.want, got)
+		}
+	}
+
+	for i, in := range []string{
+		"",
+		"Sat",
+		"Sat 02:00",
+		"Sat 2:00-4:0",
+		"Sat 25:00-26:00",
+		"Sat 02:00-02:00",
+		"Sat 24:00-02:00",
+		"Someday 02:00-04:00",
+		"Sat 02:00-04:00 Nowhere/Special",
+		"Sat 02:00-04:00 UTC extra",
+	} {
+		if _, err := ParseWindow(in); err == nil {
+			t.Errorf("case %d: expected error parsing %q", i, in)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	w, err := ParseWindow("Fri,Sat 22:00-02:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	// 2024-01-19 is a Friday.
+	tests := []struct {
+		t         string
+		contains  bool
+		nextStart string
+	}{
+		{"2024-01-19T21:59:59Z", false, "2024-01-19T22:00:00Z"},
+		{"2024-01-19T22:00:00Z", true, "2024-01-19T22:00:00Z"},
+		{"2024-01-20T01:59:00Z", true, "2024-01-20T22:00:00Z"},
+		{"2024-01-20T02:00:00Z", false, "2024-01-20T22:00:00Z"},
+		// Sunday morning is in Saturday's window; Sunday night isn't.
+		{"2024-01-21T01:00:00Z", true, "2024-01-26T22:00:00Z"},
+		{"2024-01-21T23:00:00Z", false, "2024-01-26T22:00:00Z"},
+		// The zone of t doesn't matter.
+		{"2024-01-19T23:30:00+01:00", true, "2024-01-20T22:00:00Z"},
+	}
+	for i, tt := range tests {
+		tm := at(tt.t)
+		if got := w.Contains(tm); got != tt.contains {
+			t.Errorf("case %d: Contains(%s) == %t, want %t", i, tt.t, got, tt.contains)
+		}
+		if got := w.NextStart(tm); !got.Equal(at(tt.nextStart)) {
+			t.Errorf("case %d: NextStart(%s) == %v, want %s", i, tt.t, got, tt.nextStart)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultCAValidity is the validity of a CA when none is given.
+	DefaultCAValidity = 10 * 365 * 24 * time.Hour
+	// DefaultCertValidity is the validity of a leaf certificate when none
+	// is given.
+	DefaultCertValidity = 365 * 24 * time.Hour
+
+	// notBeforeSkew backdates certificates to tolerate clock skew between
+	// the issuer and its peers.
+	notBeforeSkew = 5 * time.Minute
+)
+
+// CertOptions describe a certificate to generate. Zero values get
+// sensible defaults for the kind of certificate being generated.
+type CertOptions struct {
+	CommonName   string
+	Organization []string
+
+	// Subject alternative names.
+	DNSNames       []string
+	IPAddresses    []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
+
+	// Validity is how long the certificate is valid for, starting now. A
+	// leaf certificate never outlives its issuer.
+	Validity time.Duration
+
+	// KeyUsage and ExtKeyUsage override the usages implied by the kind of
+	// certificate.
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+
+	// KeyType selects the algorithm of the generated key.
+	KeyType KeyType
+}
+
+// KeyPair is a certificate and its private key.
+type KeyPair struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// NewCA generates a self-signed CA certificate and key.
+func NewCA(opts CertOptions) (*KeyPair, error) {
+	key, err := GenerateKey(opts.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := newTemplate(opts, DefaultCAValidity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	if tmpl.KeyUsage == 0 {
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	}
+	return createKeyPair(tmpl, tmpl, key, key)
+}
+
+// NewServerCert generates a key and a certificate for it signed by ca,
+// usable for TLS server authentication. If no SANs are given, the common
+// name is used as a DNS name.
+func (ca *KeyPair) NewServerCert(opts CertOptions) (*KeyPair, error) {
+	if len(opts.DNSNames) == 0 && len(opts.IPAddresses) == 0 && opts.CommonName != "" {
+		if ip := net.ParseIP(opts.CommonName); ip != nil {
+			opts.IPAddresses = []net.IP{ip}
+		} else {
+			opts.DNSNames = []string{opts.CommonName}
+		}
+	}
+	return ca.newLeaf(opts, x509.ExtKeyUsageServerAuth)
+}
+
+// NewClientCert generates a key and a certificate for it signed by ca,
+// usable for TLS client authentication.
+func (ca *KeyPair) NewClientCert(opts CertOptions) (*KeyPair, error) {
+	return ca.newLeaf(opts, x509.ExtKeyUsageClientAuth)
+}
+
+func (ca *KeyPair) newLeaf(opts CertOptions, usage x509.ExtKeyUsage) (*KeyPair, error) {
+	if !ca.Cert.IsCA {
+		return nil, errors.New("issuer is not a CA")
+	}
+	key, err := GenerateKey(opts.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := newTemplate(opts, DefaultCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.ExtKeyUsage) == 0 {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	}
+	if tmpl.KeyUsage == 0 {
+		tmpl.KeyUsage = leafKeyUsage(key.Public())
+	}
+	if tmpl.NotAfter.After(ca.Cert.NotAfter) {
+		tmpl.NotAfter = ca.Cert.NotAfter
+	}
+	return createKeyPair(tmpl, ca.Cert, key, ca.Key)
+}
+
+// leafKeyUsage returns the key usages of a TLS leaf certificate. RSA keys
+// also need key encipherment for RSA key exchange.
+func leafKeyUsage(pub crypto.PublicKey) x509.KeyUsage {
+	usage := x509.KeyUsageDigitalSignature
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		usage |= x509.KeyUsageKeyEncipherment
+	}
+	return usage
+}
+
+func newTemplate(opts CertOptions, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Validity != 0 {
+		validity = opts.Validity
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   opts.CommonName,
+			Organization: opts.Organization,
+		},
+		DNSNames:       opts.DNSNames,
+		IPAddresses:    opts.IPAddresses,
+		EmailAddresses: opts.EmailAddresses,
+		URIs:           opts.URIs,
+		NotBefore:      now.Add(-notBeforeSkew),
+		NotAfter:       now.Add(validity),
+		KeyUsage:       opts.KeyUsage,
+		ExtKeyUsage:    opts.ExtKeyUsage,
+	}, nil
+}
+
+// newSerial returns a random 128 bit serial number.
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func createKeyPair(tmpl, parent *x509.Certificate, key, parentKey crypto.Signer) (*KeyPair, error) {
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Cert: cert, Key: key}, nil
+}
+
+// ParseKeyPair parses a PEM encoded certificate and private key, e.g. to
+// load a CA generated earlier.
+func ParseKeyPair(certPEM, keyPEM []byte) (*KeyPair, error) {
+	certs, err := ParseCertificatesPEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	// Check the key matches using the standard library.
+	if _, err := tls.X509KeyPair(EncodeCertificatePEM(certs[0]), keyPEM); err != nil {
+		return nil, err
+	}
+	return &KeyPair{Cert: certs[0], Key: key}, nil
+}
+
+// CertPEM returns the PEM encoded certificate.
+func (kp *KeyPair) CertPEM() []byte {
+	return EncodeCertificatePEM(kp.Cert)
+}
+
+// KeyPEM returns the PEM encoded PKCS#8 private key.
+func (kp *KeyPair) KeyPEM() ([]byte, error) {
+	return EncodePrivateKeyPEM(kp.Key)
+}
+
+// TLSCertificate returns the key pair for use in a tls.Config.
+func (kp *KeyPair) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{kp.Cert.Raw},
+		PrivateKey:  kp.Key,
+		Leaf:        kp.Cert,
+	}
+}
+
+// CertPool returns a pool containing only kp's certificate, for use as
+// RootCAs or ClientCAs when kp is a CA.
+func (kp *KeyPair) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(kp.Cert)
+	return pool
+}
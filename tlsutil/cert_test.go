@@ -0,0 +1,152 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNewCA(t *testing.T) {
+	for _, kt := range []KeyType{ECDSAP256, ECDSAP384, RSA2048, Ed25519} {
+		ca, err := NewCA(CertOptions{CommonName: "test-ca", KeyType: kt})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", kt, err)
+		}
+		if !ca.Cert.IsCA || ca.Cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+			t.Errorf("%v: certificate is not a CA", kt)
+		}
+		if d := ca.Cert.NotAfter.Sub(time.Now()); d < DefaultCAValidity-time.Hour || d > DefaultCAValidity {
+			t.Errorf("%v: unexpected validity %v", kt, d)
+		}
+
+		keyPEM, err := ca.KeyPEM()
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", kt, err)
+		}
+		parsed, err := ParseKeyPair(ca.CertPEM(), keyPEM)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", kt, err)
+		}
+		if !parsed.Cert.Equal(ca.Cert) {
+			t.Errorf("%v: parsed certificate differs", kt)
+		}
+	}
+}
+
+func TestParseKeyPairMismatch(t *testing.T) {
+	a, _ := NewCA(CertOptions{CommonName: "a"})
+	b, _ := NewCA(CertOptions{CommonName: "b"})
+	keyPEM, _ := b.KeyPEM()
+	if _, err := ParseKeyPair(a.CertPEM(), keyPEM); err == nil {
+		t.Errorf("expected error for mismatched key")
+	}
+	if _, err := ParseKeyPair(nil, keyPEM); err == nil {
+		t.Errorf("expected error for missing certificate")
+	}
+}
+
+func TestLeafCerts(t *testing.T) {
+	ca, err := NewCA(CertOptions{CommonName: "test-ca", Validity: 48 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts   CertOptions
+		client bool
+		dns    []string
+		ips    int
+	}{
+		{opts: CertOptions{CommonName: "example.com"}, dns: []string{"example.com"}},
+		{opts: CertOptions{CommonName: "127.0.0.1"}, ips: 1},
+		{opts: CertOptions{CommonName: "x", DNSNames: []string{"a", "b"}}, dns: []string{"a", "b"}},
+		{opts: CertOptions{CommonName: "alice"}, client: true},
+	}
+	for i, tt := range tests {
+		newCert, usage := ca.NewServerCert, x509.ExtKeyUsageServerAuth
+		if tt.client {
+			newCert, usage = ca.NewClientCert, x509.ExtKeyUsageClientAuth
+		}
+		kp, err := newCert(tt.opts)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if len(kp.Cert.DNSNames) != len(tt.dns) || len(kp.Cert.IPAddresses) != tt.ips {
+			t.Errorf("case %d: got SANs %v %v", i, kp.Cert.DNSNames, kp.Cert.IPAddresses)
+		}
+		_, err = kp.Cert.Verify(x509.VerifyOptions{
+			Roots:     ca.CertPool(),
+			KeyUsages: []x509.ExtKeyUsage{usage},
+		})
+		if err != nil {
+			t.Errorf("case %d: verify failed: %v", i, err)
+		}
+		// Leaf validity is capped at the CA's.
+		if kp.Cert.NotAfter.After(ca.Cert.NotAfter) {
+			t.Errorf("case %d: leaf outlives CA", i)
+		}
+	}
+
+	if _, err := (&KeyPair{Cert: &x509.Certificate{}}).NewServerCert(CertOptions{}); err == nil {
+		t.Errorf("expected error signing with a non-CA")
+	}
+}
+
+func TestMutualTLSHandshake(t *testing.T) {
+	ca, err := NewCA(CertOptions{CommonName: "test-ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ca.NewServerCert(CertOptions{CommonName: "127.0.0.1", KeyType: RSA2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := ca.NewClientCert(CertOptions{CommonName: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server.TLSCertificate()},
+		ClientCAs:    ca.CertPool(),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	peer := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			peer <- err.Error()
+			return
+		}
+		defer conn.Close()
+		tc := conn.(*tls.Conn)
+		if err := tc.Handshake(); err != nil {
+			peer <- err.Error()
+			return
+		}
+		peer <- tc.ConnectionState().PeerCertificates[0].Subject.CommonName
+		io.WriteString(conn, "ok")
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{client.TLSCertificate()},
+		RootCAs:      ca.CertPool(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-peer; got != "alice" {
+		t.Errorf("want peer alice, got %q", got)
+	}
+}
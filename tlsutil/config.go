@@ -0,0 +1,235 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Profile is a preset of TLS versions and cipher suites, following the
+// Mozilla server side TLS recommendations.
+type Profile int
+
+const (
+	// ProfileIntermediate allows TLS 1.2 with forward secret AEAD cipher
+	// suites, and TLS 1.3. It is the default.
+	ProfileIntermediate Profile = iota
+	// ProfileModern allows only TLS 1.3.
+	ProfileModern
+)
+
+func (p Profile) String() string {
+	switch p {
+	case ProfileIntermediate:
+		return "intermediate"
+	case ProfileModern:
+		return "modern"
+	}
+	return fmt.Sprintf("Profile(%d)", int(p))
+}
+
+// intermediateCipherSuites are the TLS 1.2 suites of the intermediate
+// profile. TLS 1.3 suites are not configurable.
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+func (p Profile) apply(cfg *tls.Config) error {
+	switch p {
+	case ProfileIntermediate:
+		cfg.MinVersion = tls.VersionTLS12
+		cfg.CipherSuites = intermediateCipherSuites
+	case ProfileModern:
+		cfg.MinVersion = tls.VersionTLS13
+	default:
+		return fmt.Errorf("unknown TLS profile %v", p)
+	}
+	return nil
+}
+
+// ConfigOptions configures the tls.Configs built by NewServerConfig and
+// NewClientConfig for mutual TLS.
+type ConfigOptions struct {
+	// CertFile and KeyFile are the local certificate and key, loaded
+	// once. Set Reloader instead to pick up rotated files.
+	CertFile string
+	KeyFile  string
+	Reloader *CertReloader
+
+	// CAFiles are files or directories of PEM certificates trusted to
+	// sign the peer's certificate. For a directory, every file ending in
+	// .pem, .crt or .cer is loaded. A client with no CAFiles uses the
+	// system roots.
+	CAFiles []string
+
+	// ClientAuth is the server's client certificate policy. It defaults
+	// to tls.RequireAndVerifyClientCert when CAFiles are given, so only
+	// needs setting to make client certificates optional.
+	ClientAuth tls.ClientAuthType
+
+	Profile Profile
+
+	// ServerName is the name a client verifies the server certificate
+	// against. It defaults to the host being dialed.
+	ServerName string
+
+	// VerifyPeer, if set, is called with the peer's leaf certificate
+	// after its chain has been verified, and may reject it, e.g. using
+	// VerifyPeerSANs. It is not called when a client presents no
+	// certificate and none is required.
+	VerifyPeer func(cert *x509.Certificate) error
+}
+
+// NewServerConfig returns a server tls.Config for opts.
+func NewServerConfig(opts ConfigOptions) (*tls.Config, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Reloader != nil {
+		cfg.GetCertificate = opts.Reloader.GetCertificate
+	} else if cfg.Certificates == nil {
+		return nil, errors.New("server TLS config needs a certificate")
+	}
+	if len(opts.CAFiles) > 0 {
+		if cfg.ClientCAs, err = LoadCertPool(opts.CAFiles...); err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = opts.ClientAuth
+		if cfg.ClientAuth == tls.NoClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg, nil
+}
+
+// NewClientConfig returns a client tls.Config for opts. The client
+// certificate is optional.
+func NewClientConfig(opts ConfigOptions) (*tls.Config, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Reloader != nil {
+		cfg.GetClientCertificate = opts.Reloader.GetClientCertificate
+	}
+	if len(opts.CAFiles) > 0 {
+		if cfg.RootCAs, err = LoadCertPool(opts.CAFiles...); err != nil {
+			return nil, err
+		}
+	}
+	cfg.ServerName = opts.ServerName
+	return cfg, nil
+}
+
+func newConfig(opts ConfigOptions) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if err := opts.Profile.apply(cfg); err != nil {
+		return nil, err
+	}
+
+	if opts.Reloader == nil && (opts.CertFile != "" || opts.KeyFile != "") {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if verify := opts.VerifyPeer; verify != nil {
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return nil
+			}
+			return verify(cs.PeerCertificates[0])
+		}
+	}
+	return cfg, nil
+}
+
+// VerifyPeerSANs returns a VerifyPeer function accepting certificates with
+// at least one DNS SAN matching dnsNames or URI SAN in uris. DNS patterns
+// may start with "*." to match one leading label.
+func VerifyPeerSANs(dnsNames, uris []string) func(*x509.Certificate) error {
+	return func(cert *x509.Certificate) error {
+		for _, name := range cert.DNSNames {
+			if matchAny(dnsNames, name) {
+				return nil
+			}
+		}
+		for _, u := range cert.URIs {
+			for _, allowed := range uris {
+				if u.String() == allowed {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("peer certificate %q has no allowed SAN", cert.Subject.CommonName)
+	}
+}
+
+// LoadCertPool returns a pool of the PEM certificates in paths, which may
+// be files or directories. For a directory, every file ending in .pem,
+// .crt or .cer is loaded.
+func LoadCertPool(paths ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	n := 0
+	for _, p := range paths {
+		files, err := certFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			certs, err := ParseCertificatesPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f, err)
+			}
+			for _, c := range certs {
+				pool.AddCert(c)
+			}
+			n += len(certs)
+		}
+	}
+	if n == 0 {
+		return nil, errors.New("no CA certificates found")
+	}
+	return pool, nil
+}
+
+func certFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".pem", ".crt", ".cer":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
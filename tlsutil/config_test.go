@@ -0,0 +1,171 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// handshake runs a TLS handshake between server and client over loopback
+// and returns the client and server errors.
+func handshake(server, client *tls.Config) (error, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err, err
+	}
+	defer ln.Close()
+	errc := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		s := tls.Server(conn, server)
+		err = s.Handshake()
+		s.Close()
+		errc <- err
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return err, <-errc
+	}
+	c := tls.Client(conn, client)
+	err = c.Handshake()
+	if err == nil {
+		// TLS 1.3 client certificate errors arrive after the handshake.
+		_, err = c.Read(make([]byte, 1))
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	c.Close()
+	return err, <-errc
+}
+
+func TestMutualTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	other, _ := NewCA(CertOptions{CommonName: "other-ca"})
+	server, _ := ca.NewServerCert(CertOptions{CommonName: "etcd.cluster.local"})
+	client, _ := ca.NewClientCert(CertOptions{CommonName: "alice", DNSNames: []string{"alice.cluster.local"}})
+	stranger, _ := other.NewClientCert(CertOptions{CommonName: "mallory"})
+
+	caDir := filepath.Join(dir, "ca")
+	os.Mkdir(caDir, 0700)
+	ioutil.WriteFile(filepath.Join(caDir, "ca.crt"), ca.CertPEM(), 0600)
+	ioutil.WriteFile(filepath.Join(caDir, "README"), []byte("not a cert"), 0600)
+	writeKeyPair(t, server, filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	writeKeyPair(t, client, filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	writeKeyPair(t, stranger, filepath.Join(dir, "stranger.crt"), filepath.Join(dir, "stranger.key"))
+
+	serverOpts := ConfigOptions{
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+		CAFiles:  []string{caDir},
+	}
+	clientOpts := func(name string) ConfigOptions {
+		return ConfigOptions{
+			CertFile:   filepath.Join(dir, name+".crt"),
+			KeyFile:    filepath.Join(dir, name+".key"),
+			CAFiles:    []string{filepath.Join(caDir, "ca.crt")},
+			ServerName: "etcd.cluster.local",
+		}
+	}
+
+	sanCheck := serverOpts
+	sanCheck.VerifyPeer = VerifyPeerSANs([]string{"*.cluster.local"}, nil)
+	sanReject := serverOpts
+	sanReject.VerifyPeer = VerifyPeerSANs([]string{"bob.cluster.local"}, []string{"spiffe://x"})
+	modern := serverOpts
+	modern.Profile = ProfileModern
+
+	tests := []struct {
+		server ConfigOptions
+		client ConfigOptions
+		ok     bool
+	}{
+		{serverOpts, clientOpts("client"), true},
+		{sanCheck, clientOpts("client"), true},
+		{modern, clientOpts("client"), true},
+		{sanReject, clientOpts("client"), false},
+		{serverOpts, clientOpts("stranger"), false},
+		{serverOpts, ConfigOptions{CAFiles: []string{caDir}, ServerName: "etcd.cluster.local"}, false},
+		{serverOpts, ConfigOptions{CAFiles: []string{caDir}, ServerName: "wrong.name"}, false},
+	}
+	for i, tt := range tests {
+		scfg, err := NewServerConfig(tt.server)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		ccfg, err := NewClientConfig(tt.client)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if scfg.ClientAuth != tls.RequireAndVerifyClientCert {
+			t.Errorf("case %d: client auth %v", i, scfg.ClientAuth)
+		}
+		cerr, serr := handshake(scfg, ccfg)
+		if ok := cerr == nil && serr == nil; ok != tt.ok {
+			t.Errorf("case %d: want ok=%t, got client=%v server=%v", i, tt.ok, cerr, serr)
+		}
+	}
+}
+
+func TestConfigProfile(t *testing.T) {
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	server, _ := ca.NewServerCert(CertOptions{CommonName: "localhost"})
+
+	for _, p := range []Profile{ProfileIntermediate, ProfileModern} {
+		cfg := &tls.Config{}
+		if err := p.apply(cfg); err != nil {
+			t.Fatal(err)
+		}
+		cfg.Certificates = []tls.Certificate{server.TLSCertificate()}
+		_, serr := handshake(cfg, &tls.Config{
+			RootCAs:    ca.CertPool(),
+			ServerName: "localhost",
+			MaxVersion: tls.VersionTLS12,
+		})
+		if want := p == ProfileIntermediate; (serr == nil) != want {
+			t.Errorf("%v: TLS 1.2 client accepted=%t", p, serr == nil)
+		}
+	}
+
+	if _, err := NewServerConfig(ConfigOptions{}); err == nil {
+		t.Errorf("expected error for server without certificate")
+	}
+	if _, err := NewClientConfig(ConfigOptions{Profile: Profile(9)}); err == nil {
+		t.Errorf("expected error for unknown profile")
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadCertPool(dir); err == nil {
+		t.Errorf("expected error for empty directory")
+	}
+	if _, err := LoadCertPool(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+	bad := filepath.Join(dir, "bad.pem")
+	ioutil.WriteFile(bad, []byte("junk"), 0600)
+	if _, err := LoadCertPool(bad); err == nil {
+		t.Errorf("expected error for file without certificates")
+	}
+}
@@ -0,0 +1,242 @@
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// PolicyError is returned by SignCSR when a request is rejected by the
+// signing policy.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return "certificate request rejected: " + e.Reason
+}
+
+func policyErrorf(format string, args ...interface{}) error {
+	return &PolicyError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// NewCSR creates a PEM encoded certificate signing request for key with
+// the subject and SANs of opts. The validity, usage and key type fields
+// of opts are ignored; they are decided by the signer.
+func NewCSR(opts CertOptions, key crypto.Signer) ([]byte, error) {
+	tmpl := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   opts.CommonName,
+			Organization: opts.Organization,
+		},
+		DNSNames:       opts.DNSNames,
+		IPAddresses:    opts.IPAddresses,
+		EmailAddresses: opts.EmailAddresses,
+		URIs:           opts.URIs,
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// ParseCSRPEM parses a PEM encoded certificate signing request and checks
+// its signature.
+func ParseCSRPEM(pemdata []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(pemdata)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("no certificate request PEM data found")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	return csr, nil
+}
+
+// SigningPolicy restricts the certificates SignCSR will issue. Each kind of
+// SAN must be explicitly allowed: a request with a DNS name is rejected
+// unless AllowedDNSNames matches it, and so on. The subject is held to the
+// same rule.
+type SigningPolicy struct {
+	// MaxValidity caps the validity of issued certificates. It defaults
+	// to DefaultCertValidity.
+	MaxValidity time.Duration
+
+	// AllowedCommonNames are patterns the subject common name must match,
+	// unless it is one of the request's DNS, IP or email SANs.
+	AllowedCommonNames []string
+
+	// AllowedOrganizations are the subject organizations that are copied
+	// from requests. Any others are dropped.
+	AllowedOrganizations []string
+
+	// AllowedDNSNames are patterns DNS SANs must match. A pattern may
+	// start with "*." to match exactly one extra leading label, so
+	// "*.example.com" matches "a.example.com" but not "a.b.example.com".
+	AllowedDNSNames []string
+
+	// AllowedIPNets are the networks IP SANs must fall within.
+	AllowedIPNets []*net.IPNet
+
+	// AllowedEmailDomains are the domains email SANs must belong to.
+	AllowedEmailDomains []string
+
+	// AllowURIs permits URI SANs, e.g. SPIFFE IDs, without restriction.
+	AllowURIs bool
+
+	// ExtKeyUsage are the extended key usages of issued certificates. It
+	// defaults to server and client authentication.
+	ExtKeyUsage []x509.ExtKeyUsage
+}
+
+func (p SigningPolicy) check(csr *x509.CertificateRequest) error {
+	if cn := csr.Subject.CommonName; cn != "" && !matchAny(p.AllowedCommonNames, cn) && !isSAN(csr, cn) {
+		return policyErrorf("common name %q not allowed", cn)
+	}
+	for _, name := range csr.DNSNames {
+		if !matchAny(p.AllowedDNSNames, name) {
+			return policyErrorf("DNS name %q not allowed", name)
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if !containsIP(p.AllowedIPNets, ip) {
+			return policyErrorf("IP address %s not allowed", ip)
+		}
+	}
+	for _, email := range csr.EmailAddresses {
+		i := strings.LastIndex(email, "@")
+		if i < 0 || !matchAny(p.AllowedEmailDomains, email[i+1:]) {
+			return policyErrorf("email address %q not allowed", email)
+		}
+	}
+	if len(csr.URIs) > 0 && !p.AllowURIs {
+		return policyErrorf("URI %s not allowed", csr.URIs[0])
+	}
+	return nil
+}
+
+// isSAN reports whether name is one of the DNS, IP or email SANs of csr,
+// which check allows separately.
+func isSAN(csr *x509.CertificateRequest, name string) bool {
+	for _, n := range csr.DNSNames {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if ip.String() == name {
+			return true
+		}
+	}
+	for _, e := range csr.EmailAddresses {
+		if strings.EqualFold(e, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// organizations returns the organizations of csr allowed by p.
+func (p SigningPolicy) organizations(csr *x509.CertificateRequest) []string {
+	var orgs []string
+	for _, o := range csr.Subject.Organization {
+		for _, a := range p.AllowedOrganizations {
+			if o == a {
+				orgs = append(orgs, o)
+				break
+			}
+		}
+	}
+	return orgs
+}
+
+// matchAny reports whether name matches any of patterns, ignoring case.
+func matchAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == name {
+			return true
+		}
+		if strings.HasPrefix(p, "*.") {
+			i := strings.Index(name, ".")
+			if i > 0 && name[i:] == p[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignCSR issues a certificate for a PEM encoded certificate signing
+// request, signed by ca. Only the subject and SANs are taken from the
+// request, and only after they pass policy; subject organizations the
+// policy doesn't allow are dropped. The certificate is valid for
+// validity, capped by the policy's MaxValidity and the CA's own expiry; a
+// zero validity means the maximum. Issued certificates are never CAs.
+func (ca *KeyPair) SignCSR(csrPEM []byte, validity time.Duration, policy SigningPolicy) (*x509.Certificate, error) {
+	if !ca.Cert.IsCA {
+		return nil, errors.New("issuer is not a CA")
+	}
+	csr, err := ParseCSRPEM(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.check(csr); err != nil {
+		return nil, err
+	}
+
+	max := policy.MaxValidity
+	if max == 0 {
+		max = DefaultCertValidity
+	}
+	if validity == 0 || validity > max {
+		validity = max
+	}
+	tmpl, err := newTemplate(CertOptions{
+		CommonName:     csr.Subject.CommonName,
+		Organization:   policy.organizations(csr),
+		DNSNames:       csr.DNSNames,
+		IPAddresses:    csr.IPAddresses,
+		EmailAddresses: csr.EmailAddresses,
+		URIs:           csr.URIs,
+		KeyUsage:       leafKeyUsage(csr.PublicKey),
+		ExtKeyUsage:    policy.ExtKeyUsage,
+	}, validity)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.ExtKeyUsage) == 0 {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+	if tmpl.NotAfter.After(ca.Cert.NotAfter) {
+		tmpl.NotAfter = ca.Cert.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
@@ -0,0 +1,123 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignCSR(t *testing.T) {
+	ca, err := NewCA(CertOptions{CommonName: "test-ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, podNet, _ := net.ParseCIDR("10.0.0.0/8")
+	policy := SigningPolicy{
+		MaxValidity:         24 * time.Hour,
+		AllowedDNSNames:     []string{"*.cluster.local", "api.example.com"},
+		AllowedIPNets:       []*net.IPNet{podNet},
+		AllowedEmailDomains: []string{"example.com"},
+	}
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/default")
+
+	tests := []struct {
+		opts CertOptions
+		ok   bool
+	}{
+		{CertOptions{CommonName: "etcd.cluster.local", DNSNames: []string{"etcd.cluster.local"}}, true},
+		{CertOptions{CommonName: "api.example.com", DNSNames: []string{"API.example.com"}}, true},
+		{CertOptions{CommonName: "10.1.2.3", IPAddresses: []net.IP{net.ParseIP("10.1.2.3")}}, true},
+		{CertOptions{CommonName: "ops@example.com", EmailAddresses: []string{"ops@example.com"}}, true},
+		{CertOptions{DNSNames: []string{"etcd.cluster.local"}}, true},
+		{CertOptions{}, true},
+
+		// The common name must be a SAN or allowed separately.
+		{CertOptions{CommonName: "x"}, false},
+		{CertOptions{CommonName: "x", DNSNames: []string{"etcd.cluster.local"}}, false},
+		{CertOptions{CommonName: "evil.com", DNSNames: []string{"etcd.cluster.local"}}, false},
+
+		{CertOptions{DNSNames: []string{"a.b.cluster.local"}}, false},
+		{CertOptions{DNSNames: []string{"cluster.local"}}, false},
+		{CertOptions{DNSNames: []string{"evil.com"}}, false},
+		{CertOptions{IPAddresses: []net.IP{net.ParseIP("192.168.0.1")}}, false},
+		{CertOptions{EmailAddresses: []string{"ops@example.org"}}, false},
+		{CertOptions{URIs: []*url.URL{spiffe}}, false},
+	}
+	for i, tt := range tests {
+		key, err := GenerateKey(ECDSAP256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csr, err := NewCSR(tt.opts, key)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		cert, err := ca.SignCSR(csr, 0, policy)
+		if !tt.ok {
+			if _, ok := err.(*PolicyError); !ok {
+				t.Errorf("case %d: want PolicyError, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if cert.IsCA {
+			t.Errorf("case %d: issued a CA", i)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: ca.CertPool()}); err != nil {
+			t.Errorf("case %d: verify failed: %v", i, err)
+		}
+		if d := cert.NotAfter.Sub(time.Now()); d > policy.MaxValidity {
+			t.Errorf("case %d: validity %v exceeds policy", i, d)
+		}
+	}
+}
+
+func TestSignCSRPolicy(t *testing.T) {
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	key, _ := GenerateKey(ECDSAP256)
+
+	csr, _ := NewCSR(CertOptions{CommonName: "node-1"}, key)
+	if _, err := ca.SignCSR(csr, 0, SigningPolicy{AllowedCommonNames: []string{"node-2"}}); err == nil {
+		t.Errorf("expected common name to be rejected")
+	}
+	if _, err := ca.SignCSR(csr, 0, SigningPolicy{AllowedCommonNames: []string{"node-1"}}); err != nil {
+		t.Errorf("expected common name to be allowed: %v", err)
+	}
+
+	csr, _ = NewCSR(CertOptions{Organization: []string{"system:nodes", "system:masters"}}, key)
+	cert, err := ca.SignCSR(csr, 0, SigningPolicy{AllowedOrganizations: []string{"system:nodes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := cert.Subject.Organization; len(o) != 1 || o[0] != "system:nodes" {
+		t.Errorf("unexpected organizations %v", o)
+	}
+	if cert, err := ca.SignCSR(csr, 0, SigningPolicy{}); err != nil || len(cert.Subject.Organization) != 0 {
+		t.Errorf("organizations not dropped: %v", err)
+	}
+
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/default")
+	csr, _ = NewCSR(CertOptions{URIs: []*url.URL{spiffe}}, key)
+	cert, err = ca.SignCSR(csr, time.Hour, SigningPolicy{AllowURIs: true, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.URIs) != 1 || cert.URIs[0].String() != spiffe.String() {
+		t.Errorf("unexpected URIs %v", cert.URIs)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth {
+		t.Errorf("unexpected usages %v", cert.ExtKeyUsage)
+	}
+	if d := cert.NotAfter.Sub(time.Now()); d > time.Hour {
+		t.Errorf("requested validity not honored: %v", d)
+	}
+
+	if _, err := ca.SignCSR([]byte("garbage"), 0, SigningPolicy{}); err == nil {
+		t.Errorf("expected error for invalid CSR")
+	}
+}
@@ -0,0 +1,128 @@
+// Package tlsutil generates, issues and loads the certificates and keys
+// needed to run TLS inside a cluster.
+package tlsutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// KeyType selects the algorithm of a generated private key.
+type KeyType int
+
+const (
+	// ECDSAP256 is an ECDSA key on the NIST P-256 curve, and the default.
+	ECDSAP256 KeyType = iota
+	ECDSAP384
+	RSA2048
+	RSA4096
+	Ed25519
+)
+
+func (t KeyType) String() string {
+	switch t {
+	case ECDSAP256:
+		return "ecdsa-p256"
+	case ECDSAP384:
+		return "ecdsa-p384"
+	case RSA2048:
+		return "rsa-2048"
+	case RSA4096:
+		return "rsa-4096"
+	case Ed25519:
+		return "ed25519"
+	}
+	return fmt.Sprintf("KeyType(%d)", int(t))
+}
+
+// GenerateKey generates a new private key of type t.
+func GenerateKey(t KeyType) (crypto.Signer, error) {
+	switch t {
+	case ECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case RSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unsupported key type %v", t)
+}
+
+// EncodePrivateKeyPEM encodes key as a PKCS#8 "PRIVATE KEY" PEM block.
+func EncodePrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKeyPEM parses the first private key in pemdata, which may
+// be PKCS#8, PKCS#1 RSA or SEC 1 EC encoded.
+func ParsePrivateKeyPEM(pemdata []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, pemdata = pem.Decode(pemdata)
+		if block == nil {
+			return nil, errors.New("no private key PEM data found")
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type %T", key)
+			}
+			return signer, nil
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		}
+	}
+}
+
+// EncodeCertificatePEM encodes cert as a "CERTIFICATE" PEM block.
+func EncodeCertificatePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// ParseCertificatesPEM parses every certificate in pemdata, in order.
+func ParseCertificatesPEM(pemdata []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemdata = pem.Decode(pemdata)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate PEM data found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,267 @@
+package tlsutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/pkg/timeutil"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// DefaultOCSPRetryInterval is how long an OCSPStapler waits after a
+	// failed fetch when no RetryInterval is set.
+	DefaultOCSPRetryInterval = 5 * time.Minute
+
+	// maxOCSPResponseSize bounds how much of a responder's reply is read.
+	maxOCSPResponseSize = 1 << 20
+)
+
+// OCSPStats describes the state of an OCSPStapler. It is returned by
+// OCSPStapler.Stats, and published as JSON when the stapler is registered
+// with expvar.Publish.
+type OCSPStats struct {
+	Fetches     uint64    `json:"fetches"`
+	Failures    uint64    `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastSuccess time.Time `json:"last_success"`
+	// ThisUpdate and NextUpdate bound the validity of the current
+	// staple.
+	ThisUpdate time.Time `json:"this_update"`
+	NextUpdate time.Time `json:"next_update"`
+	// Stale is set when there is no staple or it has expired; no staple
+	// is served then.
+	Stale bool `json:"stale"`
+}
+
+// OCSPStapler keeps a fresh OCSP response for a certificate and staples it
+// to the certificate served by GetCertificate. Run fetches a response in
+// the background and refreshes it halfway through its validity, retrying
+// failures every RetryInterval. An expired response is never stapled.
+type OCSPStapler struct {
+	// Issuer signed the certificate. It defaults to the second
+	// certificate in the chain.
+	Issuer *x509.Certificate
+	// Client sends requests to the OCSP responder. It defaults to a
+	// client with a 10 second timeout.
+	Client *http.Client
+	// RetryInterval defaults to DefaultOCSPRetryInterval.
+	RetryInterval time.Duration
+	// Clock defaults to timeutil.RealClock.
+	Clock timeutil.Clock
+	// Logger, if set, logs failed fetches at WARNING and revoked
+	// certificates at ERROR.
+	Logger *capnslog.PackageLogger
+
+	cert tls.Certificate
+
+	mu     sync.RWMutex
+	staple *tls.Certificate
+	resp   *ocsp.Response
+	stats  OCSPStats
+}
+
+// NewOCSPStapler returns a stapler for cert, which must include its leaf
+// and name an OCSP server. Call Run to start fetching responses.
+func NewOCSPStapler(cert tls.Certificate) (*OCSPStapler, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("certificate is empty")
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+		cert.Leaf = leaf
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("certificate %q names no OCSP server", leaf.Subject.CommonName)
+	}
+	return &OCSPStapler{cert: cert}, nil
+}
+
+func (s *OCSPStapler) clock() timeutil.Clock {
+	if s.Clock == nil {
+		return timeutil.RealClock
+	}
+	return s.Clock
+}
+
+func (s *OCSPStapler) issuer() (*x509.Certificate, error) {
+	if s.Issuer != nil {
+		return s.Issuer, nil
+	}
+	if len(s.cert.Certificate) < 2 {
+		return nil, errors.New("no issuer certificate given or in chain")
+	}
+	return x509.ParseCertificate(s.cert.Certificate[1])
+}
+
+// Refresh fetches a new OCSP response now, and staples it if it is valid.
+func (s *OCSPStapler) Refresh(ctx context.Context) error {
+	resp, raw, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Fetches++
+	if err != nil {
+		s.stats.Failures++
+		s.stats.LastError = err.Error()
+		return err
+	}
+	staple := s.cert
+	staple.OCSPStaple = raw
+	s.staple = &staple
+	s.resp = resp
+	s.stats.LastError = ""
+	s.stats.LastSuccess = s.clock().Now()
+	s.stats.ThisUpdate = resp.ThisUpdate
+	s.stats.NextUpdate = resp.NextUpdate
+	if resp.Status == ocsp.Revoked {
+		s.logf(capnslog.ERROR, "OCSP responder reports certificate %q revoked at %v", s.cert.Leaf.Subject.CommonName, resp.RevokedAt)
+	}
+	return nil
+}
+
+func (s *OCSPStapler) fetch(ctx context.Context) (*ocsp.Response, []byte, error) {
+	issuer, err := s.issuer()
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := ocsp.CreateRequest(s.cert.Leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest("POST", s.cert.Leaf.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP responder returned %s", res.Status)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(res.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := ocsp.ParseResponseForCert(raw, s.cert.Leaf, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.Status == ocsp.Unknown {
+		return nil, nil, errors.New("OCSP responder does not know the certificate")
+	}
+	now := s.clock().Now()
+	if resp.NextUpdate.IsZero() || !now.Before(resp.NextUpdate) || now.Before(resp.ThisUpdate.Add(-notBeforeSkew)) {
+		return nil, nil, fmt.Errorf("OCSP response is not current: valid %v to %v", resp.ThisUpdate, resp.NextUpdate)
+	}
+	return resp, raw, nil
+}
+
+// Run fetches and refreshes the OCSP response until ctx is done.
+func (s *OCSPStapler) Run(ctx context.Context) {
+	retry := s.RetryInterval
+	if retry <= 0 {
+		retry = DefaultOCSPRetryInterval
+	}
+	clock := s.clock()
+	for {
+		wait := retry
+		if err := s.Refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if s.Stats().Stale {
+				s.logf(capnslog.WARNING, "OCSP staple for %q is stale, failed to refresh: %v", s.cert.Leaf.Subject.CommonName, err)
+			} else {
+				s.logf(capnslog.WARNING, "failed to refresh OCSP staple for %q: %v", s.cert.Leaf.Subject.CommonName, err)
+			}
+		} else {
+			s.mu.RLock()
+			resp := s.resp
+			s.mu.RUnlock()
+			refresh := resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+			if d := refresh.Sub(clock.Now()); d > wait {
+				wait = d
+			}
+		}
+
+		t := clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+	}
+}
+
+func (s *OCSPStapler) logf(l capnslog.LogLevel, format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Logf(l, format, args...)
+	}
+}
+
+// current returns the stapled certificate if its response is still valid.
+func (s *OCSPStapler) current() *tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.staple == nil || !s.clock().Now().Before(s.resp.NextUpdate) {
+		return nil
+	}
+	return s.staple
+}
+
+// GetCertificate implements tls.Config.GetCertificate, serving the
+// certificate with the current OCSP staple, or without one if there is no
+// valid response.
+func (s *OCSPStapler) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if c := s.current(); c != nil {
+		return c, nil
+	}
+	return &s.cert, nil
+}
+
+// Stats returns a snapshot of the stapler's state.
+func (s *OCSPStapler) Stats() OCSPStats {
+	stale := s.current() == nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := s.stats
+	stats.Stale = stale
+	return stats
+}
+
+// String returns the stats as JSON, implementing expvar.Var.
+func (s *OCSPStapler) String() string {
+	b, err := json.Marshal(s.Stats())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
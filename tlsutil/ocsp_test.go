@@ -0,0 +1,198 @@
+package tlsutil
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testResponder struct {
+	ca    *KeyPair
+	clock timeutil.Clock
+
+	mu      sync.Mutex
+	status  int
+	fail    bool
+	fetches int
+}
+
+func (r *testResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fetches++
+	if r.fail {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := r.clock.Now().Truncate(time.Second)
+	resp, err := ocsp.CreateResponse(r.ca.Cert, r.ca.Cert, ocsp.Response{
+		Status:       r.status,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(time.Hour),
+		RevokedAt:    now,
+	}, r.ca.Key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+func (r *testResponder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fetches
+}
+
+func newOCSPTest(t *testing.T) (*OCSPStapler, *testResponder, *timeutil.FakeClock, func()) {
+	clock := timeutil.NewFakeClock(time.Now())
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	responder := &testResponder{ca: ca, clock: clock, status: ocsp.Good}
+	srv := httptest.NewServer(responder)
+
+	key, _ := GenerateKey(ECDSAP256)
+	tmpl, _ := newTemplate(CertOptions{CommonName: "localhost"}, DefaultCertValidity)
+	tmpl.OCSPServer = []string{srv.URL}
+	leaf, err := createKeyPair(tmpl, ca.Cert, key, ca.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := leaf.TLSCertificate()
+	cert.Certificate = append(cert.Certificate, ca.Cert.Raw)
+
+	s, err := NewOCSPStapler(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Clock = clock
+	return s, responder, clock, srv.Close
+}
+
+func TestOCSPStapler(t *testing.T) {
+	s, responder, clock, done := newOCSPTest(t)
+	defer done()
+
+	c, _ := s.GetCertificate(nil)
+	if c.OCSPStaple != nil || !s.Stats().Stale {
+		t.Fatalf("stapled before the first fetch")
+	}
+
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = s.GetCertificate(nil)
+	resp, err := ocsp.ParseResponse(c.OCSPStaple, nil)
+	if err != nil {
+		t.Fatalf("invalid staple: %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Errorf("unexpected status %d", resp.Status)
+	}
+
+	// A failed refresh keeps the staple while it is valid.
+	responder.fail = true
+	if err := s.Refresh(context.Background()); err == nil {
+		t.Errorf("expected error from failing responder")
+	}
+	stats := s.Stats()
+	if stats.Fetches != 2 || stats.Failures != 1 || stats.LastError == "" || stats.Stale {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if c, _ := s.GetCertificate(nil); c.OCSPStaple == nil {
+		t.Errorf("staple dropped while still valid")
+	}
+
+	// Once expired, it is no longer served.
+	clock.Advance(time.Hour)
+	if c, _ := s.GetCertificate(nil); c.OCSPStaple != nil {
+		t.Errorf("served an expired staple")
+	}
+	if !s.Stats().Stale {
+		t.Errorf("stats do not report the stale staple")
+	}
+
+	var published OCSPStats
+	if err := json.Unmarshal([]byte(s.String()), &published); err != nil {
+		t.Fatalf("invalid expvar JSON %q: %v", s.String(), err)
+	}
+	if published.Failures != 1 || !published.Stale {
+		t.Errorf("unexpected published stats %+v", published)
+	}
+}
+
+func TestOCSPStaplerRevokedAndUnknown(t *testing.T) {
+	s, responder, _, done := newOCSPTest(t)
+	defer done()
+
+	responder.status = ocsp.Unknown
+	if err := s.Refresh(context.Background()); err == nil {
+		t.Errorf("expected error for unknown status")
+	}
+	responder.status = ocsp.Revoked
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := s.GetCertificate(nil)
+	if resp, err := ocsp.ParseResponse(c.OCSPStaple, nil); err != nil || resp.Status != ocsp.Revoked {
+		t.Errorf("want revoked staple, got %v %v", resp, err)
+	}
+}
+
+func TestOCSPStaplerRun(t *testing.T) {
+	s, responder, clock, done := newOCSPTest(t)
+	defer done()
+	s.RetryInterval = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+
+	// The first fetch happens immediately, the next halfway through the
+	// response's one hour validity.
+	clock.BlockUntil(1)
+	if n := responder.count(); n != 1 {
+		t.Fatalf("want 1 fetch, got %d", n)
+	}
+	clock.Advance(29 * time.Minute)
+	if n := responder.count(); n != 1 {
+		t.Errorf("refreshed early: %d fetches", n)
+	}
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	for i := 0; i < 100 && responder.count() < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := responder.count(); n != 2 {
+		t.Errorf("want 2 fetches, got %d", n)
+	}
+
+	cancel()
+	<-stopped
+}
+
+func TestNewOCSPStapler(t *testing.T) {
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	leaf, _ := ca.NewServerCert(CertOptions{CommonName: "localhost"})
+	if _, err := NewOCSPStapler(leaf.TLSCertificate()); err == nil {
+		t.Errorf("expected error for certificate without OCSP server")
+	}
+}
@@ -0,0 +1,99 @@
+package tlsutil
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CertFingerprint returns the SHA-256 fingerprint of cert's DER encoding as
+// colon separated uppercase hex, as printed by
+// `openssl x509 -fingerprint -sha256`.
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	var b strings.Builder
+	for i, c := range sum {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, "%02X", c)
+	}
+	return b.String()
+}
+
+// SPKIFingerprint returns the base64 encoded SHA-256 hash of cert's
+// subject public key info. Unlike a certificate fingerprint it stays the
+// same when a certificate is reissued for the same key, which makes it the
+// right thing to pin.
+func SPKIFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// parsePin decodes a pin in SPKIFingerprint format, optionally prefixed by
+// "sha256/" as used by HPKP and curl.
+func parsePin(pin string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+	if err != nil || len(b) != sha256.Size {
+		return sum, fmt.Errorf("invalid SPKI pin %q", pin)
+	}
+	copy(sum[:], b)
+	return sum, nil
+}
+
+// VerifyPins returns a tls.Config.VerifyPeerCertificate function which
+// accepts a connection only if the public key of some certificate in the
+// peer's chain matches one of pins or backupPins. Pins are in
+// SPKIFingerprint format.
+//
+// At least one backup pin is required: it should be the fingerprint of a
+// key held in reserve, so that the pinned key can be rotated without
+// locking clients out.
+//
+// Pinning is in addition to normal chain verification. If the config sets
+// InsecureSkipVerify, for example to pin a self-signed endpoint, only the
+// pins protect the connection and only the peer's leaf certificate is
+// checked.
+func VerifyPins(pins, backupPins []string) (func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error, error) {
+	if len(pins) == 0 {
+		return nil, errors.New("no SPKI pins given")
+	}
+	if len(backupPins) == 0 {
+		return nil, errors.New("no backup SPKI pins given; rotating the pinned key would lock out clients")
+	}
+	set := make(map[[sha256.Size]byte]bool)
+	for _, p := range append(append([]string(nil), pins...), backupPins...) {
+		sum, err := parsePin(p)
+		if err != nil {
+			return nil, err
+		}
+		set[sum] = true
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			for _, cert := range chain {
+				if set[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+					return nil
+				}
+			}
+		}
+		// Without verified chains, nothing links the rest of rawCerts to
+		// the leaf, whose key the handshake proved the peer holds, so only
+		// the leaf may match.
+		if len(verifiedChains) == 0 && len(rawCerts) > 0 {
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			if set[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+				return nil
+			}
+		}
+		return errors.New("no certificate in the peer's chain matches a pinned public key")
+	}, nil
+}
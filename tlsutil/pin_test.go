@@ -0,0 +1,92 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func TestFingerprints(t *testing.T) {
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+
+	fp := CertFingerprint(ca.Cert)
+	if len(fp) != 95 || strings.ToUpper(fp) != fp || strings.Count(fp, ":") != 31 {
+		t.Errorf("malformed fingerprint %q", fp)
+	}
+
+	// Reissuing a certificate for the same key keeps the SPKI pin.
+	tmpl, _ := newTemplate(CertOptions{CommonName: "reissued"}, DefaultCAValidity)
+	tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	reissued, err := createKeyPair(tmpl, tmpl, ca.Key, ca.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CertFingerprint(reissued.Cert) == fp {
+		t.Errorf("certificate fingerprints should differ")
+	}
+	if SPKIFingerprint(reissued.Cert) != SPKIFingerprint(ca.Cert) {
+		t.Errorf("SPKI fingerprints should match")
+	}
+}
+
+func TestVerifyPins(t *testing.T) {
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	server, _ := ca.NewServerCert(CertOptions{CommonName: "localhost"})
+	backupCA, _ := NewCA(CertOptions{CommonName: "backup"})
+	other, _ := NewCA(CertOptions{CommonName: "other"})
+
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{server.TLSCertificate()}}
+
+	tests := []struct {
+		pins     []string
+		insecure bool
+		ok       bool
+	}{
+		{[]string{SPKIFingerprint(server.Cert)}, false, true},
+		{[]string{"sha256/" + SPKIFingerprint(ca.Cert)}, false, true},
+		{[]string{SPKIFingerprint(server.Cert)}, true, true},
+		{[]string{SPKIFingerprint(other.Cert)}, false, false},
+		// Without verified chains only the peer's own certificates count.
+		{[]string{SPKIFingerprint(ca.Cert)}, true, false},
+	}
+	for i, tt := range tests {
+		verify, err := VerifyPins(tt.pins, []string{SPKIFingerprint(backupCA.Cert)})
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		cerr, _ := handshake(serverConfig, &tls.Config{
+			RootCAs:               ca.CertPool(),
+			ServerName:            "localhost",
+			InsecureSkipVerify:    tt.insecure,
+			VerifyPeerCertificate: verify,
+		})
+		if (cerr == nil) != tt.ok {
+			t.Errorf("case %d: want ok=%t, got %v", i, tt.ok, cerr)
+		}
+	}
+
+	// A peer can send any public certificate after its own leaf.
+	attacker, _ := other.NewServerCert(CertOptions{CommonName: "localhost"})
+	verify, err := VerifyPins([]string{SPKIFingerprint(server.Cert)}, []string{SPKIFingerprint(backupCA.Cert)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verify([][]byte{attacker.Cert.Raw, server.Cert.Raw}, nil); err == nil {
+		t.Errorf("pinned certificate after an unpinned leaf accepted")
+	}
+	if err := verify([][]byte{server.Cert.Raw, attacker.Cert.Raw}, nil); err != nil {
+		t.Errorf("pinned leaf rejected: %v", err)
+	}
+
+	pin := SPKIFingerprint(ca.Cert)
+	for i, args := range [][2][]string{
+		{nil, {pin}},
+		{{pin}, nil},
+		{{"not base64!"}, {pin}},
+		{{pin}, {"c2hvcnQ="}},
+	} {
+		if _, err := VerifyPins(args[0], args[1]); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
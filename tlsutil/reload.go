@@ -0,0 +1,194 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/coreos/pkg/fileutil"
+	"github.com/coreos/pkg/timeutil"
+)
+
+// DefaultReloadInterval is how often a CertReloader polls its files when
+// no Interval is set.
+const DefaultReloadInterval = 10 * time.Second
+
+// CertReloader serves a certificate and key loaded from files, and reloads
+// them when the files change, so certificates can be rotated without a
+// restart. Use its GetCertificate or GetClientCertificate methods in a
+// tls.Config.
+//
+// The directories holding the files are watched with a fileutil.Watcher,
+// so changes are picked up as soon as they are made where inotify is
+// available, and the files are polled, which works on any filesystem and
+// with the symlink swaps used by Kubernetes secret volumes. If a reload
+// fails, for example because only one of the files has been replaced so
+// far, the previous certificate keeps being served and the reload is retried
+// on the next change or poll.
+type CertReloader struct {
+	CertFile string
+	KeyFile  string
+
+	// Interval defaults to DefaultReloadInterval.
+	Interval time.Duration
+	// Clock defaults to timeutil.RealClock.
+	Clock timeutil.Clock
+	// Logger, if set, logs successful reloads at INFO and failures at
+	// ERROR.
+	Logger *capnslog.PackageLogger
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	stamp [2]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader loads certFile and keyFile, returning an error if they
+// cannot be loaded. Call Run to start watching them.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{CertFile: certFile, KeyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files now, replacing the served certificate if they
+// are valid.
+func (r *CertReloader) Reload() error {
+	stamp, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.stamp = stamp
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *CertReloader) stat() ([2]fileStamp, error) {
+	var stamp [2]fileStamp
+	for i, name := range []string{r.CertFile, r.KeyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return stamp, err
+		}
+		stamp[i] = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return stamp, nil
+}
+
+// changed reports whether either file differs from when it was last
+// loaded successfully.
+func (r *CertReloader) changed() bool {
+	stamp, err := r.stat()
+	if err != nil {
+		// Report the error through Reload.
+		return true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return stamp != r.stamp
+}
+
+// Run watches the files until ctx is done, reloading them when they change.
+func (r *CertReloader) Run(ctx context.Context) {
+	clock := r.Clock
+	if clock == nil {
+		clock = timeutil.RealClock
+	}
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var watches [2]<-chan []fileutil.Event
+	dirs := []string{filepath.Dir(r.CertFile)}
+	if dir := filepath.Dir(r.KeyFile); dir != dirs[0] {
+		dirs = append(dirs, dir)
+	}
+	for i, dir := range dirs {
+		w := &fileutil.Watcher{Dir: dir, Interval: interval, Clock: clock, Logger: r.Logger}
+		watches[i] = w.Watch(ctx)
+	}
+
+	// Changes to both directories, as when the certificate and key are
+	// rotated together, are reloaded once.
+	changes := make(chan struct{}, 1)
+	db := timeutil.DebounceWithClock(clock, fileutil.DefaultWatchDebounce, func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	defer db.Stop()
+
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ok, watched := true, false
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-changes:
+		case _, ok = <-watches[0]:
+			watched = true
+		case _, ok = <-watches[1]:
+			watched = true
+		}
+		if !ok {
+			return
+		}
+		if watched {
+			db.Call()
+			continue
+		}
+		if !r.changed() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			r.logf(capnslog.ERROR, "failed to reload certificate %s: %v", r.CertFile, err)
+			continue
+		}
+		r.logf(capnslog.INFO, "reloaded certificate %s", r.CertFile)
+	}
+}
+
+func (r *CertReloader) logf(l capnslog.LogLevel, format string, args ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Logf(l, format, args...)
+	}
+}
+
+// Certificate returns the currently loaded certificate.
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
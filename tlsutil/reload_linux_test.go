@@ -0,0 +1,56 @@
+package tlsutil
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/fileutil"
+	"github.com/coreos/pkg/timeutil"
+)
+
+func TestCertReloaderInotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	first, _ := ca.NewServerCert(CertOptions{CommonName: "first"})
+	second, _ := ca.NewServerCert(CertOptions{CommonName: "second"})
+	writeKeyPair(t, first, certFile, keyFile)
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Polling never comes due, so the rotation is seen through inotify.
+	clock := timeutil.NewFakeClock(time.Now())
+	r.Clock = clock
+	r.Interval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	// The ticker of Run and of its Watcher.
+	clock.BlockUntil(2)
+
+	writeKeyPair(t, second, certFile, keyFile)
+	for i := 0; i < 100 && r.Certificate().Leaf.Subject.CommonName != "second"; i++ {
+		clock.Advance(fileutil.DefaultWatchDebounce)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := r.Certificate().Leaf.Subject.CommonName; got != "second" {
+		t.Errorf("want second after rotation, got %q", got)
+	}
+
+	cancel()
+	<-done
+}
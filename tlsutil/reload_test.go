@@ -0,0 +1,96 @@
+package tlsutil
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/timeutil"
+)
+
+func writeKeyPair(t *testing.T, kp *KeyPair, certFile, keyFile string) {
+	keyPEM, err := kp.KeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, kp.CertPEM(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	ca, _ := NewCA(CertOptions{CommonName: "test-ca"})
+	first, _ := ca.NewServerCert(CertOptions{CommonName: "first"})
+	second, _ := ca.NewServerCert(CertOptions{CommonName: "second"})
+
+	if _, err := NewCertReloader(certFile, keyFile); err == nil {
+		t.Fatalf("expected error for missing files")
+	}
+
+	writeKeyPair(t, first, certFile, keyFile)
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := timeutil.NewFakeClock(time.Now())
+	r.Clock = clock
+	r.Interval = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	clock.BlockUntil(1)
+
+	commonName := func() string {
+		c, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Leaf.Subject.CommonName
+	}
+	if got := commonName(); got != "first" {
+		t.Fatalf("want first, got %q", got)
+	}
+
+	// A half written pair keeps the old certificate.
+	if err := ioutil.WriteFile(certFile, second.CertPEM(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Errorf("expected error for mismatched pair")
+	}
+	if got := commonName(); got != "first" {
+		t.Errorf("want first after failed reload, got %q", got)
+	}
+
+	writeKeyPair(t, second, certFile, keyFile)
+	for i := 0; i < 100 && commonName() != "second"; i++ {
+		clock.Advance(time.Second)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := commonName(); got != "second" {
+		t.Errorf("want second after rotation, got %q", got)
+	}
+	if c, _ := r.GetClientCertificate(nil); c.Leaf.Subject.CommonName != "second" {
+		t.Errorf("client certificate not rotated")
+	}
+
+	cancel()
+	<-done
+}
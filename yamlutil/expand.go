@@ -0,0 +1,160 @@
+package yamlutil
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// expandEnv expands the references to environment variables in the string
+// values of the document data, as described by Loader.ExpandEnv. It
+// reports whether anything was expanded, and if so returns the expanded
+// document.
+func (l Loader) expandEnv(path string, format Format, data []byte) ([]byte, bool, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, false, yamlError(path, format, err)
+	}
+	e := &expander{Loader: l}
+	if e.LookupEnv == nil {
+		e.LookupEnv = os.LookupEnv
+	}
+	doc = e.expand("", doc)
+	if len(e.missing) > 0 {
+		sort.Strings(e.missing)
+		errs := make(ErrorSlice, len(e.missing))
+		for i, msg := range e.missing {
+			errs[i] = &DecodeError{Path: path, Format: format, Msg: msg}
+		}
+		return nil, false, errs
+	}
+	if !e.changed {
+		return nil, false, nil
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+type expander struct {
+	Loader
+	changed bool
+	missing []string
+}
+
+// expand expands the strings in the decoded YAML value v found at key.
+func (e *expander) expand(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for k, val := range v {
+			sub := fmt.Sprint(k)
+			if key != "" {
+				sub = key + "." + sub
+			}
+			v[k] = e.expand(sub, val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = e.expand(fmt.Sprintf("%s[%d]", key, i), val)
+		}
+	case string:
+		s, ok := e.expandString(key, v)
+		if ok {
+			e.changed = true
+			return plainScalar(s)
+		}
+	}
+	return v
+}
+
+// expandString expands the references in s, reporting whether there were
+// any.
+func (e *expander) expandString(key, s string) (string, bool) {
+	if !strings.Contains(s, "${") {
+		return s, false
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			// $${ is a literal ${.
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			break
+		}
+		b.WriteString(s[:i])
+		b.WriteString(e.lookup(key, s[i+2:i+end]))
+		s = s[i+end+1:]
+	}
+	b.WriteString(s)
+	return b.String(), true
+}
+
+// lookup returns the value of the reference ${ref}.
+func (e *expander) lookup(key, ref string) string {
+	name, def := ref, ""
+	hasDefault := false
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		name, def, hasDefault = ref[:i], ref[i+2:], true
+	}
+	val, ok := e.LookupEnv(name)
+	switch {
+	case ok && val != "":
+		return val
+	case hasDefault:
+		return def
+	case !ok && e.RequireEnv:
+		if key == "" {
+			key = "document"
+		}
+		e.missing = append(e.missing, fmt.Sprintf("%s: environment variable %s is not set", key, name))
+	}
+	return val
+}
+
+// plainScalar returns the number or boolean which s would be read as if
+// written unquoted, if it would be written back the same way, and s
+// otherwise. Strings such as "0755", which YAML reads as a number but
+// would write differently, are left as strings.
+func plainScalar(s string) interface{} {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	switch v.(type) {
+	case int, int64, uint64, float64, bool:
+	default:
+		return s
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil || strings.TrimSpace(string(out)) != s {
+		return s
+	}
+	return v
+}
+
+// unposition removes the lines from the errors in err, which refer to an
+// expanded document rather than the one given.
+func unposition(err error) {
+	switch e := err.(type) {
+	case *DecodeError:
+		e.Line, e.Column = 0, 0
+	case ErrorSlice:
+		for _, err := range e {
+			unposition(err)
+		}
+	}
+}
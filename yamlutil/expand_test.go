@@ -0,0 +1,92 @@
+package yamlutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+type envConfig struct {
+	Name     string   `yaml:"name"`
+	Port     int      `yaml:"port"`
+	Debug    bool     `yaml:"debug"`
+	URL      string   `yaml:"url"`
+	Mode     string   `yaml:"mode"`
+	Password string   `yaml:"password"`
+	Peers    []string `yaml:"peers"`
+}
+
+func testEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoadExpandEnv(t *testing.T) {
+	env := testEnv(map[string]string{
+		"NAME":  "svc",
+		"HOST":  "example.com",
+		"PORT":  "9090",
+		"MODE":  "0755",
+		"PASS":  "p#ss: \"word\"",
+		"EMPTY": "",
+		"PEER":  "b",
+	})
+	data := `
+name: ${NAME}
+port: ${PORT:-8080}
+debug: ${DEBUG:-true}
+url: https://${HOST}:${PORT}/$${literal}
+mode: ${MODE}
+password: "${PASS}"
+peers: [a, "${PEER}", "${EMPTY:-c}", "${UNSET}"]
+`
+	var got envConfig
+	if err := (Loader{ExpandEnv: true, LookupEnv: env}).Load([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := envConfig{
+		Name:     "svc",
+		Port:     9090,
+		Debug:    true,
+		URL:      "https://example.com:9090/${literal}",
+		Mode:     "0755",
+		Password: "p#ss: \"word\"",
+		Peers:    []string{"a", "b", "c", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v got=%+v", want, got)
+	}
+
+	// Without ExpandEnv, references are kept.
+	got = envConfig{}
+	if err := Load([]byte("name: ${NAME}"), &got); err != nil || got.Name != "${NAME}" {
+		t.Errorf("got name %q, err %v", got.Name, err)
+	}
+}
+
+func TestLoadRequireEnv(t *testing.T) {
+	l := Loader{ExpandEnv: true, RequireEnv: true, LookupEnv: testEnv(map[string]string{"SET": "x", "EMPTY": ""})}
+	data := "name: ${SET}\nurl: ${MISSING}\npeers: [\"${ALSO_MISSING}\", \"${MISSING_WITH_DEFAULT:-d}\", \"${EMPTY}\"]\n"
+	var c envConfig
+	err := l.Load([]byte(data), &c)
+	want := ErrorSlice{
+		&DecodeError{Format: FormatYAML, Msg: "peers[0]: environment variable ALSO_MISSING is not set"},
+		&DecodeError{Format: FormatYAML, Msg: "url: environment variable MISSING is not set"},
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("want=%v got=%v", want, err)
+	}
+}
+
+func TestLoadExpandEnvTypeError(t *testing.T) {
+	l := Loader{ExpandEnv: true, LookupEnv: testEnv(map[string]string{"PORT": "eighty"})}
+	var c envConfig
+	err := l.Load([]byte("name: a\nport: ${PORT}\n"), &c)
+	want := ErrorSlice{
+		&DecodeError{Format: FormatYAML, Msg: "cannot unmarshal !!str `eighty` into int"},
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("want=%v got=%v", want, err)
+	}
+}
@@ -0,0 +1,107 @@
+package yamlutil
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// SetFlagsFromYAML sets the flags in fs which have not already been set
+// from the YAML document data. Nested mappings are flattened by joining
+// their keys with dots, and underscores and dashes are interchangeable in
+// both keys and flag names, so both
+//
+//	server:
+//	  listen_addr: :8080
+//
+// and
+//
+//	server.listen-addr: :8080
+//
+// set the flag server.listen-addr. A sequence is given to its flag as a
+// comma-separated list, as accepted by flagutil.StringSliceFlag.
+//
+// Every key without a flag, and every value its flag rejects, is reported
+// in the returned ErrorSlice, after the other flags have been set.
+func SetFlagsFromYAML(fs *flag.FlagSet, data []byte) error {
+	var conf map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return err
+	}
+	vals := make(map[string]string)
+	flattenYAML("", conf, vals)
+
+	flags := make(map[string]*flag.Flag)
+	fs.VisitAll(func(f *flag.Flag) {
+		flags[normalizeFlagName(f.Name)] = f
+	})
+	alreadySet := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		alreadySet[f.Name] = true
+	})
+
+	keys := make([]string, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs ErrorSlice
+	for _, key := range keys {
+		f, ok := flags[normalizeFlagName(key)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown key %s", key))
+			continue
+		}
+		if alreadySet[f.Name] {
+			continue
+		}
+		if err := fs.Set(f.Name, vals[key]); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", vals[key], key, err))
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// normalizeFlagName returns name with underscores replaced by dashes.
+func normalizeFlagName(name string) string {
+	return strings.Replace(name, "_", "-", -1)
+}
+
+// flattenYAML adds the scalars and sequences in m to vals, keyed by their
+// dotted paths below prefix.
+func flattenYAML(prefix string, m map[interface{}]interface{}, vals map[string]string) {
+	for k, v := range m {
+		key := fmt.Sprint(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := v.(type) {
+		case map[interface{}]interface{}:
+			flattenYAML(key, v, vals)
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = yamlScalar(item)
+			}
+			vals[key] = strings.Join(items, ",")
+		default:
+			vals[key] = yamlScalar(v)
+		}
+	}
+}
+
+// yamlScalar formats a decoded YAML scalar as a flag value. A null value,
+// as written by a key with nothing after it, is the empty string.
+func yamlScalar(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
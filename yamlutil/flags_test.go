@@ -0,0 +1,96 @@
+package yamlutil
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/flagutil"
+)
+
+func TestSetFlagsFromYAML(t *testing.T) {
+	config := `
+server:
+  listen_addr: ":8080"
+  timeout: 10s
+  tls:
+    enabled: true
+log-level: debug
+peers: [a, b, c]
+workers: 4
+name:
+max-conns: 100
+`
+	fs := flag.NewFlagSet("testing", flag.ContinueOnError)
+	listen := fs.String("server.listen-addr", "", "")
+	timeout := fs.Duration("server.timeout", 0, "")
+	tls := fs.Bool("server.tls.enabled", false, "")
+	level := fs.String("log-level", "info", "")
+	workers := fs.Int("workers", 1, "")
+	name := fs.String("name", "default", "")
+	maxConns := fs.Int("max_conns", 0, "")
+	var peers flagutil.StringSliceFlag
+	fs.Var(&peers, "peers", "")
+	if err := fs.Parse([]string{"-log-level=warn"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetFlagsFromYAML(fs, []byte(config)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *listen != ":8080" || *timeout != 10*time.Second || !*tls || *workers != 4 || *name != "" {
+		t.Errorf("flags not set: listen=%q timeout=%v tls=%v workers=%d name=%q", *listen, *timeout, *tls, *workers, *name)
+	}
+	// Flag names are normalised as keys are.
+	if *maxConns != 100 {
+		t.Errorf("max_conns=%d, want 100", *maxConns)
+	}
+	// Command-line flags take precedence over the file.
+	if *level != "warn" {
+		t.Errorf("log-level=%q, want warn", *level)
+	}
+	if got := strings.Join(peers, " "); got != "a b c" {
+		t.Errorf("peers=%q, want a b c", got)
+	}
+}
+
+func TestSetFlagsFromYAMLErrors(t *testing.T) {
+	config := `
+server:
+  port: lots
+  bogus: 1
+typo: x
+ok: 2
+`
+	fs := flag.NewFlagSet("testing", flag.ContinueOnError)
+	fs.Int("server.port", 0, "")
+	ok := fs.Int("ok", 0, "")
+
+	err := SetFlagsFromYAML(fs, []byte(config))
+	es, isSlice := err.(ErrorSlice)
+	if !isSlice {
+		t.Fatalf("want ErrorSlice, got %v", err)
+	}
+	want := []string{
+		"unknown key server.bogus",
+		`invalid value "lots" for server.port: parse error`,
+		"unknown key typo",
+	}
+	if len(es) != len(want) {
+		t.Fatalf("want %d errors, got %v", len(want), es)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(es[i].Error(), w) {
+			t.Errorf("error %d: want prefix %q, got %q", i, w, es[i])
+		}
+	}
+	// Valid keys are still applied.
+	if *ok != 2 {
+		t.Errorf("ok=%d, want 2", *ok)
+	}
+
+	if err := SetFlagsFromYAML(fs, []byte("a: [")); err == nil {
+		t.Errorf("expected error for invalid YAML")
+	}
+}
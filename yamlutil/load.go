@@ -0,0 +1,244 @@
+package yamlutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// Format is the format of a config document.
+type Format int
+
+const (
+	FormatYAML Format = iota
+	FormatJSON
+)
+
+func (f Format) String() string {
+	if f == FormatJSON {
+		return "JSON"
+	}
+	return "YAML"
+}
+
+// DecodeError is a problem with a config document, located by line and
+// column where they are known. Lines and columns count from 1, and are 0
+// when unknown; YAML errors only report their line.
+type DecodeError struct {
+	// Path is the file the document was read from, if any.
+	Path   string
+	Format Format
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *DecodeError) Error() string {
+	var pos []string
+	if e.Path != "" {
+		pos = append(pos, e.Path)
+	}
+	if e.Line > 0 {
+		pos = append(pos, strconv.Itoa(e.Line))
+		if e.Column > 0 {
+			pos = append(pos, strconv.Itoa(e.Column))
+		}
+	}
+	if len(pos) == 0 {
+		return fmt.Sprintf("invalid %s: %s", e.Format, e.Msg)
+	}
+	return fmt.Sprintf("%s: invalid %s: %s", strings.Join(pos, ":"), e.Format, e.Msg)
+}
+
+// DetectFormat reports whether data looks like a JSON document, that is
+// whether it starts with an object or array, or is a YAML document.
+func DetectFormat(data []byte) Format {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+	if len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Load decodes the JSON or YAML document data into v, detecting which it is
+// with DetectFormat. JSON is a subset of YAML, so either is decoded as YAML,
+// using the yaml field tags of v; a JSON document is first checked by the
+// JSON parser, so that mistakes such as trailing commas, which YAML would
+// accept with a different meaning, are reported as JSON errors. A YAML
+// document in flow style is therefore taken to be JSON.
+//
+// Syntax errors are returned as a *DecodeError, and values which do not
+// fit v as an ErrorSlice of them, one for each value.
+func Load(data []byte, v interface{}) error {
+	return Loader{}.Load(data, v)
+}
+
+// LoadFile is Load for the contents of the file at path. Errors in the
+// document name the file.
+func LoadFile(path string, v interface{}) error {
+	return Loader{}.LoadFile(path, v)
+}
+
+// Loader loads config documents as Load does, with optional processing.
+// The zero Loader is what Load uses.
+type Loader struct {
+	// ExpandEnv expands references to environment variables in string
+	// values, written ${VAR}, or ${VAR:-default} for a default used when
+	// VAR is unset or empty. $${ is a literal ${. Keys are never
+	// expanded. A value which is a number or boolean once expanded, such
+	// as "${PORT:-8080}", can be decoded into a field of that type.
+	//
+	// The document is decoded again after expansion, so, when anything
+	// was expanded, values which do not fit v are reported without
+	// their lines.
+	ExpandEnv bool
+
+	// RequireEnv, with ExpandEnv, makes a reference without a default to
+	// an unset variable an error. By default it expands to the empty
+	// string. All such references are reported, by their keys.
+	RequireEnv bool
+
+	// LookupEnv looks up environment variables, and defaults to
+	// os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+
+	// Validate, if set, checks v with Validate once it is decoded.
+	Validate bool
+}
+
+// Load is Load using l.
+func (l Loader) Load(data []byte, v interface{}) error {
+	return l.load("", data, v)
+}
+
+// LoadFile is LoadFile using l.
+func (l Loader) LoadFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return l.load(path, data, v)
+}
+
+func (l Loader) load(path string, data []byte, v interface{}) error {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	format := DetectFormat(data)
+	if format == FormatJSON {
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return jsonError(path, data, err)
+		}
+		// YAML does not allow tabs for indentation. Valid JSON has no
+		// raw tabs in its strings, so they are all whitespace.
+		data = bytes.Replace(data, []byte("\t"), []byte(" "), -1)
+		data = requoteJSONStrings(data)
+	}
+	positioned := true
+	if l.ExpandEnv {
+		expanded, changed, err := l.expandEnv(path, format, data)
+		if err != nil {
+			return err
+		}
+		if changed {
+			data, positioned = expanded, false
+		}
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		err = yamlError(path, format, err)
+		if !positioned {
+			unposition(err)
+		}
+		return err
+	}
+	if l.Validate {
+		return Validate(v)
+	}
+	return nil
+}
+
+// requoteJSONStrings rewrites the strings with escapes in the valid JSON
+// document data as YAML double-quoted strings, as JSON escapes such as \/
+// and surrogate pairs are not YAML's. Lines are left where they were.
+func requoteJSONStrings(data []byte) []byte {
+	var out []byte
+	last := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] != '"' {
+			continue
+		}
+		escaped := false
+		j := i + 1
+		for ; data[j] != '"'; j++ {
+			if data[j] == '\\' {
+				escaped = true
+				j++
+			}
+		}
+		if escaped {
+			var str string
+			json.Unmarshal(data[i:j+1], &str)
+			out = append(out, data[last:i]...)
+			out = append(out, strconv.Quote(str)...)
+			last = j + 1
+		}
+		i = j
+	}
+	if out == nil {
+		return data
+	}
+	return append(out, data[last:]...)
+}
+
+// jsonError locates the JSON syntax error err in data.
+func jsonError(path string, data []byte, err error) error {
+	e := &DecodeError{Path: path, Format: FormatJSON, Msg: err.Error()}
+	if serr, ok := err.(*json.SyntaxError); ok {
+		// Offset counts the bytes read, including the offending one.
+		off := int(serr.Offset) - 1
+		if off < 0 {
+			off = 0
+		}
+		if off > len(data) {
+			off = len(data)
+		}
+		e.Line = bytes.Count(data[:off], []byte("\n")) + 1
+		e.Column = off - bytes.LastIndexByte(data[:off], '\n')
+	}
+	return e
+}
+
+var yamlLineRE = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// yamlError converts an error from the YAML decoder, which reports
+// positions in its messages.
+func yamlError(path string, format Format, err error) error {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	locate := func(msg string) *DecodeError {
+		e := &DecodeError{Path: path, Format: format, Msg: msg}
+		if m := yamlLineRE.FindStringSubmatch(msg); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Msg = m[2]
+		}
+		return e
+	}
+
+	const unmarshalErrors = "unmarshal errors:\n"
+	if !strings.HasPrefix(msg, unmarshalErrors) {
+		return locate(msg)
+	}
+	var errs ErrorSlice
+	for _, line := range strings.Split(strings.TrimPrefix(msg, unmarshalErrors), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			errs = append(errs, locate(line))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,132 @@
+package yamlutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type loadConfig struct {
+	Name  string   `yaml:"name"`
+	Port  int      `yaml:"port"`
+	Peers []string `yaml:"peers"`
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		data string
+		want Format
+	}{
+		{`{"name": "a"}`, FormatJSON},
+		{"\xef\xbb\xbf\n  [1, 2]", FormatJSON},
+		{"name: a", FormatYAML},
+		{"# {comment}\nname: a", FormatYAML},
+		{"", FormatYAML},
+	}
+	for i, tt := range tests {
+		if got := DetectFormat([]byte(tt.data)); got != tt.want {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, got)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	want := loadConfig{Name: "a", Port: 80, Peers: []string{"x", "y"}}
+	for i, data := range []string{
+		"name: a\nport: 80\npeers: [x, y]\n",
+		`{"name": "a", "port": 80, "peers": ["x", "y"]}`,
+		"\xef\xbb\xbf{\n  \"name\": \"a\",\n  \"port\": 80,\n  \"peers\": [\"x\", \"y\"]\n}\n",
+		"{\n\t\"name\": \"a\",\n\t\"port\": 80,\n\t\"peers\": [\n\t\t\"x\",\n\t\t\"y\"\n\t]\n}",
+	} {
+		var got loadConfig
+		if err := Load([]byte(data), &got); err != nil {
+			t.Errorf("case %d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("case %d: want=%+v got=%+v", i, want, got)
+		}
+	}
+}
+
+func TestLoadJSONEscapes(t *testing.T) {
+	data := `{"name": "http:\/\/x\u00e9\ud83d\ude00 \"q\" \\ \t\n", "peers": ["a\/b", "\u2028"]}`
+	var got loadConfig
+	if err := Load([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := loadConfig{Name: "http://x\u00e9\U0001f600 \"q\" \\ \t\n", Peers: []string{"a/b", "\u2028"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v got=%+v", want, got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		want error
+	}{
+		{
+			"{\n  \"name\": \"a\",\n  \"port\": 80,\n}",
+			&DecodeError{Format: FormatJSON, Line: 4, Column: 1, Msg: "invalid character '}' looking for beginning of object key string"},
+		},
+		{
+			"{\"name\": \"a\"",
+			&DecodeError{Format: FormatJSON, Line: 1, Column: 12, Msg: "unexpected end of JSON input"},
+		},
+		{
+			// YAML in flow style is taken to be JSON.
+			"{name: a}",
+			&DecodeError{Format: FormatJSON, Line: 1, Column: 2, Msg: "invalid character 'n' looking for beginning of object key string"},
+		},
+		{
+			"name: a\n  port: 80\n",
+			&DecodeError{Format: FormatYAML, Line: 2, Msg: "mapping values are not allowed in this context"},
+		},
+		{
+			"name: a\nport: eighty\npeers: 3\n",
+			ErrorSlice{
+				&DecodeError{Format: FormatYAML, Line: 2, Msg: "cannot unmarshal !!str `eighty` into int"},
+				&DecodeError{Format: FormatYAML, Line: 3, Msg: "cannot unmarshal !!int `3` into []string"},
+			},
+		},
+		{
+			// Type errors in JSON documents are found by the YAML
+			// decoder.
+			"{\"port\": \"eighty\"}",
+			ErrorSlice{
+				&DecodeError{Format: FormatJSON, Line: 1, Msg: "cannot unmarshal !!str `eighty` into int"},
+			},
+		},
+	}
+	for i, tt := range tests {
+		var got loadConfig
+		err := Load([]byte(tt.data), &got)
+		if !reflect.DeepEqual(err, tt.want) {
+			t.Errorf("case %d: want=%v got=%v", i, tt.want, err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte("{\"name\": }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var c loadConfig
+	err = LoadFile(path, &c)
+	want := path + ":1:10: invalid JSON: invalid character '}' looking for beginning of value"
+	if err == nil || err.Error() != want {
+		t.Errorf("want=%q got=%v", want, err)
+	}
+	if err := LoadFile(filepath.Join(dir, "missing"), &c); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
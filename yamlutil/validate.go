@@ -0,0 +1,268 @@
+package yamlutil
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/pkg/multierror"
+)
+
+// FieldError is a config value which breaks a validation rule. Path is
+// the YAML path of the value, such as server.listen or peers[2].name.
+type FieldError struct {
+	Path string
+	Msg  string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+// Validate checks the struct v, or the struct v points to, against the
+// rules given in the validate tags of its fields, comma separated:
+//
+//	required        the value must not be the zero value
+//	min=N, max=N    numbers must be at least or at most N, and strings,
+//	                slices and maps must have at least or at most N
+//	                elements; for time.Duration fields N is a duration
+//	                such as 10s
+//	oneof=a b c     the value must be one of the space separated values
+//	duration        the string must be a duration, such as 1m30s
+//	url             the string must be an absolute URL
+//	hostport        the string must be a host:port address, or :port
+//
+// Rules other than required are only checked for values which are set, so
+// optional fields may be left empty. Nested structs, and the structs in
+// slices, maps and pointers, are checked too. Every violation is returned,
+// as a *FieldError in a multierror.Error, and fields are named as in YAML,
+// by their yaml tags or lowercased names.
+func Validate(v interface{}) error {
+	var errs multierror.Error
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errs.ErrorOrNil()
+}
+
+func validateValue(v reflect.Value, path string, errs *multierror.Error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			name, inline := yamlFieldName(f)
+			if name == "-" {
+				continue
+			}
+			fpath := path
+			if !inline {
+				fpath = joinPath(path, name)
+			}
+			if tag := f.Tag.Get("validate"); tag != "" {
+				checkRules(v.Field(i), fpath, tag, errs)
+			}
+			validateValue(v.Field(i), fpath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			validateValue(v.MapIndex(k), joinPath(path, fmt.Sprint(k)), errs)
+		}
+	}
+}
+
+// yamlFieldName returns the key of f in YAML, and whether its fields are
+// inlined into its parent's.
+func yamlFieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("yaml")
+	parts := strings.Split(tag, ",")
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			return "", true
+		}
+	}
+	if parts[0] != "" {
+		return parts[0], false
+	}
+	return strings.ToLower(f.Name), false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// checkRules checks v against the comma separated rules in tag.
+func checkRules(v reflect.Value, path, tag string, errs *multierror.Error) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &FieldError{Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+	rules := strings.Split(tag, ",")
+	if isZero(v) {
+		for _, rule := range rules {
+			if rule == "required" {
+				fail("is required")
+			}
+		}
+		return
+	}
+
+	for _, rule := range rules {
+		name, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		var msg string
+		var err error
+		switch name {
+		case "required":
+		case "min", "max":
+			msg, err = checkBound(v, name == "min", arg)
+		case "oneof":
+			msg = checkOneOf(v, strings.Fields(arg))
+		case "duration", "url", "hostport":
+			msg, err = checkFormat(v, name)
+		default:
+			err = fmt.Errorf("unknown validation rule %q", rule)
+		}
+		if err != nil {
+			fail("%v", err)
+		} else if msg != "" {
+			fail("%s", msg)
+		}
+	}
+}
+
+func isZero(v reflect.Value) bool {
+	// An empty slice or map is as good as none.
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// checkBound checks v against the bound min=arg or max=arg, returning a
+// message if v is out of bounds.
+func checkBound(v reflect.Value, min bool, arg string) (string, error) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	bound, cmp := "at most", 1
+	if min {
+		bound, cmp = "at least", -1
+	}
+	compare := func(a, b float64) bool {
+		return (cmp < 0 && a < b) || (cmp > 0 && a > b)
+	}
+	rule := "max=" + arg
+	if min {
+		rule = "min=" + arg
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid validation rule %s", rule)
+		}
+		if compare(float64(v.Int()), float64(d)) {
+			return fmt.Sprintf("must be %s %v", bound, d), nil
+		}
+		return "", nil
+	}
+
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid validation rule %s", rule)
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if compare(float64(v.Int()), n) {
+			return fmt.Sprintf("must be %s %s", bound, arg), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if compare(float64(v.Uint()), n) {
+			return fmt.Sprintf("must be %s %s", bound, arg), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if compare(v.Float(), n) {
+			return fmt.Sprintf("must be %s %s", bound, arg), nil
+		}
+	case reflect.String:
+		if compare(float64(len(v.String())), n) {
+			return fmt.Sprintf("must be %s %s characters long", bound, arg), nil
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if compare(float64(v.Len()), n) {
+			return fmt.Sprintf("must have %s %s elements", bound, arg), nil
+		}
+	default:
+		return "", fmt.Errorf("rule %s does not apply to %v", rule, v.Type())
+	}
+	return "", nil
+}
+
+func checkOneOf(v reflect.Value, allowed []string) string {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	s := fmt.Sprint(v.Interface())
+	for _, a := range allowed {
+		if s == a {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%q must be one of %s", s, strings.Join(allowed, ", "))
+}
+
+// checkFormat checks that the string v is in the format named by rule.
+func checkFormat(v reflect.Value, rule string) (string, error) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("rule %s does not apply to %v", rule, v.Type())
+	}
+	s := v.String()
+	switch rule {
+	case "duration":
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Sprintf("invalid duration %q", s), nil
+		}
+	case "url":
+		if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Sprintf("invalid URL %q", s), nil
+		}
+	case "hostport":
+		_, port, err := net.SplitHostPort(s)
+		if err != nil {
+			return fmt.Sprintf("invalid address %q", s), nil
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			return fmt.Sprintf("invalid port %q", port), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,117 @@
+package yamlutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/pkg/multierror"
+)
+
+type validTLS struct {
+	CertFile string `yaml:"cert_file" validate:"required"`
+}
+
+type validServer struct {
+	Listen  string        `yaml:"listen" validate:"required,hostport"`
+	Timeout time.Duration `yaml:"timeout" validate:"min=1s,max=1m"`
+	TLS     *validTLS     `yaml:"tls"`
+}
+
+type validBackend struct {
+	URL    string `yaml:"url" validate:"required,url"`
+	Weight int    `validate:"min=1,max=100"`
+}
+
+type validCommon struct {
+	LogLevel string `yaml:"log_level" validate:"oneof=debug info warn error"`
+}
+
+type validConfig struct {
+	validCommon `yaml:",inline"`
+	Server      validServer             `yaml:"server"`
+	Backends    []validBackend          `yaml:"backends" validate:"required,max=3"`
+	Retry       string                  `yaml:"retry" validate:"duration"`
+	Name        string                  `yaml:"name" validate:"min=2,max=10"`
+	Zones       map[string]validBackend `yaml:"zones"`
+	Ignored     string                  `yaml:"-" validate:"required"`
+	internal    string
+}
+
+func TestValidate(t *testing.T) {
+	good := validConfig{
+		validCommon: validCommon{LogLevel: "info"},
+		Server:      validServer{Listen: ":8080", Timeout: 10 * time.Second, TLS: &validTLS{CertFile: "tls.crt"}},
+		Backends:    []validBackend{{URL: "https://a.example.com", Weight: 10}},
+		Retry:       "5s",
+		Zones:       map[string]validBackend{"eu": {URL: "http://eu.example.com", Weight: 1}},
+	}
+	if err := Validate(&good); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// Optional fields may be left unset.
+	if err := Validate(validConfig{Server: validServer{Listen: "host:1"}, Backends: good.Backends}); err != nil {
+		t.Errorf("unexpected error for minimal config: %v", err)
+	}
+
+	bad := validConfig{
+		validCommon: validCommon{LogLevel: "loud"},
+		Server:      validServer{Listen: "localhost:http2", Timeout: time.Hour, TLS: &validTLS{}},
+		Backends: []validBackend{
+			{URL: "https://a.example.com", Weight: 10},
+			{URL: "/relative", Weight: 1000},
+		},
+		Retry: "soon",
+		Name:  "x",
+		Zones: map[string]validBackend{"us": {Weight: 5}},
+	}
+	want := multierror.Error{
+		&FieldError{"log_level", `"loud" must be one of debug, info, warn, error`},
+		&FieldError{"server.listen", `invalid port "http2"`},
+		&FieldError{"server.timeout", "must be at most 1m0s"},
+		&FieldError{"server.tls.cert_file", "is required"},
+		&FieldError{"backends[1].url", `invalid URL "/relative"`},
+		&FieldError{"backends[1].weight", "must be at most 100"},
+		&FieldError{"retry", `invalid duration "soon"`},
+		&FieldError{"name", "must be at least 2 characters long"},
+		&FieldError{"zones.us.url", "is required"},
+	}
+	if err := Validate(bad); !reflect.DeepEqual(err, want) {
+		t.Errorf("want=\n%v\ngot=\n%v", want, err)
+	}
+
+	if err := Validate(validConfig{}); !reflect.DeepEqual(err, multierror.Error{
+		&FieldError{"server.listen", "is required"},
+		&FieldError{"backends", "is required"},
+	}) {
+		t.Errorf("unexpected errors for empty config: %v", err)
+	}
+}
+
+func TestValidateBadRules(t *testing.T) {
+	type config struct {
+		A int    `validate:"min=x"`
+		B int    `validate:"url"`
+		C string `validate:"positive"`
+	}
+	want := multierror.Error{
+		&FieldError{"a", "invalid validation rule min=x"},
+		&FieldError{"b", "rule url does not apply to int"},
+		&FieldError{"c", `unknown validation rule "positive"`},
+	}
+	if err := Validate(config{A: 1, B: 1, C: "c"}); !reflect.DeepEqual(err, want) {
+		t.Errorf("want=%v got=%v", want, err)
+	}
+}
+
+func TestLoadValidate(t *testing.T) {
+	data := "server:\n  listen: \"localhost\"\nbackends:\n  - url: https://a.example.com\n    weight: 0\n"
+	var c validConfig
+	err := Loader{Validate: true}.Load([]byte(data), &c)
+	want := multierror.Error{
+		&FieldError{"server.listen", `invalid address "localhost"`},
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("want=%v got=%v", want, err)
+	}
+}